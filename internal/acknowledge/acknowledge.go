@@ -0,0 +1,100 @@
+// Package acknowledge provides a service to acknowledge vulnerabilities of a project through a merge request,
+// which adds the acknowledgement to the project configuration file for its maintainers to review.
+package acknowledge
+
+import (
+	"errors"
+	"fmt"
+	"sheriff/internal/config"
+	"sheriff/internal/repository"
+	"sheriff/internal/repository/provider"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// branchPrefix is the prefix of the branches of the acknowledgement merge requests
+const branchPrefix = "sheriff/acknowledge-"
+
+type IService interface {
+	// Acknowledge opens a merge request adding the acknowledgement to the configuration file of the project
+	// at the given location, and returns its web URL.
+	Acknowledge(location config.ProjectLocation, ack config.AcknowledgedVuln) (url string, err error)
+}
+
+type service struct {
+	repoService provider.IProvider
+}
+
+// New creates a new service acknowledging vulnerabilities in the projects of the given repository services
+func New(repoService provider.IProvider) IService {
+	return &service{repoService: repoService}
+}
+
+// Acknowledge commits the project configuration file with the acknowledgement appended to it on a new branch,
+// and opens a merge request of that branch into the default branch of the project.
+// The configuration file is created if the project does not have one yet.
+func (s *service) Acknowledge(location config.ProjectLocation, ack config.AcknowledgedVuln) (string, error) {
+	repoService := s.repoService.Provide(location.Type)
+
+	project, err := getProject(repoService, location.Path)
+	if err != nil {
+		return "", err
+	}
+
+	content, found, err := repoService.GetFile(project, config.ProjectConfigFileName)
+	if err != nil {
+		return "", errors.Join(fmt.Errorf("failed to get %v", config.ProjectConfigFileName), err)
+	}
+	if !found {
+		log.Info().Str("project", project.Path).Msgf("No %v found, it is created", config.ProjectConfigFileName)
+	}
+
+	updated, err := config.AddAcknowledgement(content, ack)
+	if err != nil {
+		return "", err
+	}
+
+	branch := branchPrefix + strings.ToLower(ack.Code)
+	if err := repoService.CreateBranch(project, branch); err != nil {
+		return "", err
+	}
+
+	message := fmt.Sprintf("Acknowledge %v in %v", ack.Code, config.ProjectConfigFileName)
+	if err := repoService.CommitFile(project, branch, config.ProjectConfigFileName, updated, message); err != nil {
+		return "", err
+	}
+
+	url, err := repoService.OpenMergeRequest(project, branch, message, formatDescription(ack))
+	if err != nil {
+		return "", err
+	}
+	log.Info().Str("project", project.Path).Str("url", url).Msg("Opened merge request acknowledging the vulnerability")
+
+	return url, nil
+}
+
+// getProject returns the project at the given path, which must be a project rather than a group
+func getProject(repoService repository.IRepositoryService, path string) (repository.Project, error) {
+	projects, warn := repoService.GetProjectList([]string{path})
+	for _, p := range projects {
+		// GitHub paths are case-insensitive
+		if strings.EqualFold(p.Path, path) {
+			return p, nil
+		}
+	}
+
+	return repository.Project{}, errors.Join(fmt.Errorf("project %v not found", path), warn)
+}
+
+// formatDescription returns the description of the merge request, for the maintainers of the project to review
+func formatDescription(ack config.AcknowledgedVuln) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "This adds the acknowledgement of %v to `%v`, so that sheriff no longer reports it as a vulnerability of this project.\n\n", ack.Code, config.ProjectConfigFileName)
+	fmt.Fprintf(&b, "**Reason:** %v\n", ack.Reason)
+	if ack.Expires != "" {
+		fmt.Fprintf(&b, "\nThe acknowledgement expires after %v, when the vulnerability is reported again.\n", ack.Expires)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,168 @@
+package acknowledge
+
+import (
+	"context"
+	"errors"
+	"sheriff/internal/config"
+	"sheriff/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAcknowledge(t *testing.T) {
+	project := repository.Project{Path: "group/project", DefaultBranch: "main", Repository: repository.Gitlab}
+	want := "ignored = []\n\n[[acknowledged]]\ncode = \"CVE-2024-1234\"\nreason = \"not reachable\"\n"
+
+	mockClient := mockClient{}
+	mockClient.On("GetProjectList", []string{"group/project"}).Return([]repository.Project{project}, nil)
+	mockClient.On("GetFile", project, "sheriff.toml").Return([]byte("ignored = []\n"), true, nil)
+	mockClient.On("CreateBranch", project, "sheriff/acknowledge-cve-2024-1234").Return(nil)
+	mockClient.On("CommitFile", project, "sheriff/acknowledge-cve-2024-1234", "sheriff.toml", []byte(want), "Acknowledge CVE-2024-1234 in sheriff.toml").Return(nil)
+	mockClient.On("OpenMergeRequest", project, "sheriff/acknowledge-cve-2024-1234", "Acknowledge CVE-2024-1234 in sheriff.toml", mock.MatchedBy(func(description string) bool {
+		return assert.Contains(t, description, "**Reason:** not reachable")
+	})).Return("https://gitlab.com/group/project/-/merge_requests/1", nil)
+	mockRepoService := mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(&mockClient)
+
+	svc := New(&mockRepoService)
+
+	url, err := svc.Acknowledge(config.ProjectLocation{Type: repository.Gitlab, Path: "group/project"}, config.AcknowledgedVuln{Code: "CVE-2024-1234", Reason: "not reachable"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://gitlab.com/group/project/-/merge_requests/1", url)
+	mockClient.AssertExpectations(t)
+}
+
+func TestAcknowledgeCreatesMissingConfiguration(t *testing.T) {
+	project := repository.Project{Path: "owner/repo", DefaultBranch: "main", Repository: repository.Github}
+
+	mockClient := mockClient{}
+	mockClient.On("GetProjectList", []string{"Owner/Repo"}).Return([]repository.Project{project}, nil)
+	mockClient.On("GetFile", project, "sheriff.toml").Return([]byte(nil), false, nil)
+	mockClient.On("CreateBranch", project, mock.Anything).Return(nil)
+	mockClient.On("CommitFile", project, mock.Anything, "sheriff.toml", []byte("[[acknowledged]]\ncode = \"GHSA-xxxx\"\nreason = \"dev dependency\"\nexpires = \"2024-06-30\"\n"), mock.Anything).Return(nil)
+	mockClient.On("OpenMergeRequest", project, mock.Anything, mock.Anything, mock.Anything).Return("https://github.com/owner/repo/pull/1", nil)
+	mockRepoService := mockRepoService{}
+	mockRepoService.On("Provide", repository.Github).Return(&mockClient)
+
+	svc := New(&mockRepoService)
+
+	url, err := svc.Acknowledge(config.ProjectLocation{Type: repository.Github, Path: "Owner/Repo"}, config.AcknowledgedVuln{Code: "GHSA-xxxx", Reason: "dev dependency", Expires: "2024-06-30"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://github.com/owner/repo/pull/1", url)
+	mockClient.AssertExpectations(t)
+}
+
+func TestAcknowledgeProjectNotFound(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("GetProjectList", []string{"group"}).Return([]repository.Project{{Path: "group/project"}}, nil)
+	mockRepoService := mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(&mockClient)
+
+	svc := New(&mockRepoService)
+
+	_, err := svc.Acknowledge(config.ProjectLocation{Type: repository.Gitlab, Path: "group"}, config.AcknowledgedVuln{Code: "CVE-2024-1234", Reason: "not reachable"})
+
+	assert.ErrorContains(t, err, "project group not found")
+}
+
+func TestAcknowledgeAlreadyAcknowledged(t *testing.T) {
+	project := repository.Project{Path: "group/project"}
+
+	mockClient := mockClient{}
+	mockClient.On("GetProjectList", mock.Anything).Return([]repository.Project{project}, nil)
+	mockClient.On("GetFile", project, "sheriff.toml").Return([]byte("[[acknowledged]]\ncode = \"CVE-2024-1234\"\n"), true, nil)
+	mockRepoService := mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(&mockClient)
+
+	svc := New(&mockRepoService)
+
+	_, err := svc.Acknowledge(config.ProjectLocation{Type: repository.Gitlab, Path: "group/project"}, config.AcknowledgedVuln{Code: "CVE-2024-1234", Reason: "not reachable"})
+
+	assert.ErrorContains(t, err, "CVE-2024-1234 is already acknowledged")
+	mockClient.AssertNotCalled(t, "CreateBranch", mock.Anything, mock.Anything)
+}
+
+func TestAcknowledgeFailsToCreateBranch(t *testing.T) {
+	project := repository.Project{Path: "group/project"}
+
+	mockClient := mockClient{}
+	mockClient.On("GetProjectList", mock.Anything).Return([]repository.Project{project}, nil)
+	mockClient.On("GetFile", project, "sheriff.toml").Return([]byte(nil), false, nil)
+	mockClient.On("CreateBranch", project, mock.Anything).Return(errors.New("branch already exists"))
+	mockRepoService := mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(&mockClient)
+
+	svc := New(&mockRepoService)
+
+	_, err := svc.Acknowledge(config.ProjectLocation{Type: repository.Gitlab, Path: "group/project"}, config.AcknowledgedVuln{Code: "CVE-2024-1234", Reason: "not reachable"})
+
+	assert.ErrorContains(t, err, "branch already exists")
+	mockClient.AssertNotCalled(t, "OpenMergeRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+type mockRepoService struct {
+	mock.Mock
+}
+
+func (c *mockRepoService) Provide(platform repository.RepositoryType) repository.IRepositoryService {
+	args := c.Called(platform)
+	return args.Get(0).(repository.IRepositoryService)
+}
+
+type mockClient struct {
+	mock.Mock
+}
+
+func (c *mockClient) GetProjectList(paths []string) ([]repository.Project, error) {
+	args := c.Called(paths)
+	return args.Get(0).([]repository.Project), args.Error(1)
+}
+
+func (c *mockClient) CloseVulnerabilityIssue(project repository.Project, title string, comment string) error {
+	args := c.Called(project, title, comment)
+	return args.Error(0)
+}
+
+func (c *mockClient) OpenVulnerabilityIssue(project repository.Project, title string, report string, assignees []string) (*repository.Issue, error) {
+	args := c.Called(project, title, report, assignees)
+	return args.Get(0).(*repository.Issue), args.Error(1)
+}
+
+func (c *mockClient) Download(ctx context.Context, project repository.Project, dir string) (string, error) {
+	args := c.Called(project, dir)
+	return args.String(0), args.Error(1)
+}
+
+func (c *mockClient) GetLatestCommitSha(project repository.Project) (string, error) {
+	args := c.Called(project)
+	return args.String(0), args.Error(1)
+}
+
+func (c *mockClient) GetFile(project repository.Project, path string) ([]byte, bool, error) {
+	args := c.Called(project, path)
+	return args.Get(0).([]byte), args.Bool(1), args.Error(2)
+}
+
+func (c *mockClient) CreateBranch(project repository.Project, branch string) error {
+	args := c.Called(project, branch)
+	return args.Error(0)
+}
+
+func (c *mockClient) CommitFile(project repository.Project, branch string, path string, content []byte, message string) error {
+	args := c.Called(project, branch, path, content, message)
+	return args.Error(0)
+}
+
+func (c *mockClient) OpenMergeRequest(project repository.Project, branch string, title string, description string) (string, error) {
+	args := c.Called(project, branch, title, description)
+	return args.String(0), args.Error(1)
+}
+
+func (c *mockClient) UploadIssueAttachment(project repository.Project, filename string, content []byte) (string, error) {
+	args := c.Called(project, filename, content)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,120 @@
+// Package cache keeps the downloaded archives of projects between runs, so that unchanged projects are not downloaded again.
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sheriff/internal/repository"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// archiveExtension is the extension of the cached archives, which are all tar.gz
+const archiveExtension = ".tar.gz"
+
+// ArchiveCache stores a single archive per project, for the commit it was downloaded at.
+// A nil *ArchiveCache is a valid, disabled cache.
+type ArchiveCache struct {
+	dir string
+}
+
+// New creates an archive cache in the given directory, creating the directory if needed.
+func New(dir string) (*ArchiveCache, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to create cache directory %v", dir), err)
+	}
+
+	return &ArchiveCache{dir: dir}, nil
+}
+
+// Open returns the cached archive of the project identified by key at the given commit sha, if any.
+// The caller must close the returned file.
+func (c *ArchiveCache) Open(key string, sha string) (*os.File, bool) {
+	if c == nil || sha == "" {
+		return nil, false
+	}
+
+	f, err := os.Open(c.path(key, sha))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Str("key", key).Msg("Failed to open cached archive, ignoring it")
+		}
+		return nil, false
+	}
+
+	return f, true
+}
+
+// Store saves the archive of the project identified by key at the given commit sha,
+// replacing the archives cached for previous commits of the same project.
+// The archive is written to a temporary file first, so that a failed write never leaves a partial archive behind.
+func (c *ArchiveCache) Store(key string, sha string, archive io.Reader) error {
+	if c == nil || sha == "" {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.dir, sanitize(key)+"-*.tmp")
+	if err != nil {
+		return errors.Join(errors.New("failed to create temporary cache file"), err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, archive); err != nil {
+		tmp.Close()
+		return errors.Join(errors.New("failed to write archive to cache"), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Join(errors.New("failed to write archive to cache"), err)
+	}
+
+	c.removeStale(key)
+
+	if err := os.Rename(tmp.Name(), c.path(key, sha)); err != nil {
+		return errors.Join(errors.New("failed to move archive into cache"), err)
+	}
+
+	return nil
+}
+
+// removeStale removes the archives cached for the project identified by key
+func (c *ArchiveCache) removeStale(key string) {
+	stale, err := filepath.Glob(filepath.Join(c.dir, sanitize(key)+"@*"+archiveExtension))
+	if err != nil {
+		return
+	}
+
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to remove stale cached archive")
+		}
+	}
+}
+
+// ProjectKey returns the key identifying the project in the cache.
+// Projects scanned at another branch than their default one are cached separately, so that they do not evict each other.
+func ProjectKey(project repository.Project) string {
+	if project.Ref != "" {
+		return fmt.Sprintf("%v-%v-%v", project.Repository, project.ID, project.Ref)
+	}
+
+	return fmt.Sprintf("%v-%v", project.Repository, project.ID)
+}
+
+// path returns the path of the cached archive of the project identified by key at the given commit sha
+func (c *ArchiveCache) path(key string, sha string) string {
+	return filepath.Join(c.dir, sanitize(key)+"@"+sanitize(sha)+archiveExtension)
+}
+
+// sanitize replaces the characters which cannot be part of a file name
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == '@' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sheriff/internal/repository"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCreatesDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "cache")
+
+	c, err := New(dir)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, c)
+	assert.DirExists(t, dir)
+}
+
+func TestStoreAndOpen(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, c.Store("gitlab-1", "abc", strings.NewReader("archive")))
+
+	f, ok := c.Open("gitlab-1", "abc")
+	require.True(t, ok)
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "archive", string(content))
+
+	_, ok = c.Open("gitlab-1", "def")
+	assert.False(t, ok, "a different commit is a cache miss")
+
+	_, ok = c.Open("gitlab-2", "abc")
+	assert.False(t, ok, "a different project is a cache miss")
+}
+
+func TestStoreReplacesPreviousCommit(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Store("gitlab-1", "abc", strings.NewReader("old")))
+	require.NoError(t, c.Store("gitlab-1", "def", strings.NewReader("new")))
+	require.NoError(t, c.Store("gitlab-12", "abc", strings.NewReader("other project")))
+
+	_, ok := c.Open("gitlab-1", "abc")
+	assert.False(t, ok)
+	_, ok = c.Open("gitlab-12", "abc")
+	assert.True(t, ok)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestNilCacheIsDisabled(t *testing.T) {
+	var c *ArchiveCache
+
+	assert.NoError(t, c.Store("gitlab-1", "abc", strings.NewReader("archive")))
+	_, ok := c.Open("gitlab-1", "abc")
+	assert.False(t, ok)
+}
+
+func TestProjectKeySeparatesRefs(t *testing.T) {
+	project := repository.Project{ID: 1, Repository: repository.Gitlab, DefaultBranch: "main"}
+	release := project
+	release.Ref = "release/1.2"
+
+	assert.Equal(t, "gitlab-1", ProjectKey(project))
+	assert.Equal(t, "gitlab-1-release/1.2", ProjectKey(release))
+}
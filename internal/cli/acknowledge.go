@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sheriff/internal/acknowledge"
+	"sheriff/internal/compress"
+	"sheriff/internal/config"
+	"sheriff/internal/repository"
+	"sheriff/internal/repository/gitlab"
+	"sheriff/internal/repository/provider"
+
+	"github.com/urfave/cli/v2"
+)
+
+const projectFlag = "project"
+const vulnFlag = "vuln"
+const reasonFlag = "reason"
+const expiresFlag = "expires"
+
+var AcknowledgeFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:     projectFlag,
+		Usage:    "Project in which to acknowledge the vulnerability, formatted as a target (e.g. gitlab://group/project or github://owner/repo)",
+		Required: true,
+	},
+	&cli.StringFlag{
+		Name:     vulnFlag,
+		Usage:    "ID of the vulnerability to acknowledge, as reported by sheriff (e.g. GHSA-c2qf-rxjj-qqgw or CVE-2022-25883)",
+		Required: true,
+	},
+	&cli.StringFlag{
+		Name:     reasonFlag,
+		Usage:    "Reason why the vulnerability does not affect the project, for its maintainers to review",
+		Required: true,
+	},
+	&cli.StringFlag{
+		Name:  expiresFlag,
+		Usage: "Date (YYYY-MM-DD) after which the acknowledgement no longer applies and the vulnerability is reported again",
+	},
+	&cli.BoolFlag{
+		Name:     verboseFlag,
+		Aliases:  []string{"v"},
+		Usage:    "Enable verbose logging",
+		Category: string(Miscellaneous),
+		Value:    false,
+	},
+	&cli.StringFlag{
+		Name:     logFormatFlag,
+		Usage:    "Format of the logs written to stderr: console for human readable lines, or json for a JSON object per line.",
+		Category: string(Miscellaneous),
+		Value:    "console",
+	},
+	&cli.StringFlag{
+		Name:     logLevelFlag,
+		Usage:    "Lowest level of the logs written to stderr: trace, debug, info, warn or error. Overrides --verbose. Defaults to debug with --verbose, warn otherwise.",
+		Category: string(Miscellaneous),
+	},
+	&cli.Float64Flag{
+		Name:     apiRateLimitFlag,
+		Usage:    "Maximum number of requests per second sent to the GitLab and GitHub APIs, each. Set to 0 to disable the throttling.",
+		Category: string(Miscellaneous),
+		Value:    10,
+	},
+	&cli.StringFlag{
+		Name:     gitlabUrlFlag,
+		Usage:    "Base URL of a self-managed GitLab instance (e.g. https://gitlab.example.com). Defaults to gitlab.com.",
+		EnvVars:  []string{"GITLAB_URL"},
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     githubUrlFlag,
+		Usage:    "Base URL of a GitHub Enterprise Server instance (e.g. https://github.example.com). Defaults to github.com.",
+		EnvVars:  []string{"GITHUB_URL"},
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     proxyFlag,
+		Usage:    "URL of a proxy through which all requests are sent (e.g. http://proxy.example.com:3128). Defaults to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     caCertFlag,
+		Usage:    "Path to a PEM bundle of CA certificates trusted on top of those of the system, e.g. of a proxy intercepting TLS connections.",
+		Category: string(Miscellaneous),
+	},
+	// Secret tokens
+	&cli.StringFlag{
+		Name:     gitlabTokenFlag,
+		Usage:    "Token to access the Gitlab API, with permission to push branches and open merge requests in the project.",
+		EnvVars:  []string{"GITLAB_TOKEN"},
+		Category: string(Tokens),
+	},
+	&cli.StringFlag{
+		Name:     githubTokenFlag,
+		Usage:    "Token to access the Github API, with permission to push branches and open pull requests in the repository. Not needed when authenticating as a GitHub App.",
+		EnvVars:  []string{"GITHUB_TOKEN"},
+		Category: string(Tokens),
+	},
+	&cli.Int64Flag{
+		Name:     githubAppIdFlag,
+		Usage:    "ID of the GitHub App to access the Github API as, instead of with --github-token. Requires --github-app-installation-id and --github-app-private-key.",
+		EnvVars:  []string{"GITHUB_APP_ID"},
+		Category: string(Tokens),
+	},
+	&cli.Int64Flag{
+		Name:     githubAppInstallationIdFlag,
+		Usage:    "ID of the installation of the GitHub App in the organization or account of the repository.",
+		EnvVars:  []string{"GITHUB_APP_INSTALLATION_ID"},
+		Category: string(Tokens),
+	},
+	&cli.StringFlag{
+		Name:     githubAppPrivateKeyFlag,
+		Usage:    "Path to the PEM encoded private key of the GitHub App.",
+		EnvVars:  []string{"GITHUB_APP_PRIVATE_KEY"},
+		Category: string(Tokens),
+	},
+}
+
+func AcknowledgeAction(cCtx *cli.Context) error {
+	location, err := config.ParseProjectLocation(cCtx.String(projectFlag))
+	if err != nil {
+		return errors.Join(fmt.Errorf("invalid --%v", projectFlag), err)
+	}
+
+	githubApp, err := getGithubAppCredentials(cCtx)
+	if err != nil {
+		return errors.Join(errors.New("failed to get GitHub App credentials"), err)
+	}
+
+	if err := configureTransport(cCtx); err != nil {
+		return err
+	}
+
+	// Nothing is downloaded, so the archive options are irrelevant
+	repositoryService, err := provider.NewProvider(cCtx.String(gitlabTokenFlag), gitlab.AuthModePat, cCtx.String(gitlabUrlFlag), cCtx.String(githubTokenFlag), githubApp, cCtx.String(githubUrlFlag), cCtx.Float64(apiRateLimitFlag), 0, 0, compress.Limits{}, nil, repository.ProjectFilter{})
+	if err != nil {
+		return errors.Join(errors.New("failed to create repository service"), err)
+	}
+
+	url, err := acknowledge.New(repositoryService).Acknowledge(location, config.AcknowledgedVuln{
+		Code:    cCtx.String(vulnFlag),
+		Reason:  cCtx.String(reasonFlag),
+		Expires: cCtx.String(expiresFlag),
+	})
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to acknowledge %v in %v", cCtx.String(vulnFlag), location.Path), err)
+	}
+
+	fmt.Fprintln(cCtx.App.Writer, url)
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestAcknowledgeActionInvalidProject(t *testing.T) {
+	set := flag.NewFlagSet("flagset", flag.ContinueOnError)
+	set.String(projectFlag, "group/project", "")
+	context := cli.NewContext(cli.NewApp(), set, nil)
+
+	err := AcknowledgeAction(context)
+
+	assert.ErrorContains(t, err, "invalid --project")
+	assert.ErrorContains(t, err, "target missing platform scheme")
+}
@@ -5,11 +5,22 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+// Build information of sheriff. The commit and date of the build are injected at build time with
+// -ldflags "-X sheriff/internal/cli.buildCommit=... -X sheriff/internal/cli.buildDate=...", see getBuildInfo.
+var (
+	buildVersion = "0.27.1"
+	buildCommit  = ""
+	buildDate    = ""
+)
+
 func App(args []string) {
+	// The --version flag prints the same as the version command
+	cli.VersionPrinter = printVersion
+
 	app := &cli.App{
 		Name:    "sheriff",
 		Usage:   "Fighting dangerous dangerous dependencies since 2024.",
-		Version: "0.27.1",
+		Version: buildVersion,
 		Commands: []*cli.Command{
 			{
 				Name:  "patrol",
@@ -24,6 +35,35 @@ This file is formatted in TOML and can contain any of the flags that can be set
 				Action: PatrolAction,
 				Before: ConfigureLogs,
 			},
+			{
+				Name:  "acknowledge",
+				Usage: "Open a merge request acknowledging a vulnerability in the sheriff.toml of a project",
+				Description: `Sheriff will open a merge request (pull request on GitHub) adding the acknowledgement of the vulnerability to the sheriff.toml of the project, creating the file if needed.
+Once it is merged, the vulnerability is no longer reported as such in the project.
+
+The merge request is opened from a new branch named sheriff/acknowledge-<vulnerability>, which must not exist yet.
+`,
+				Flags:  AcknowledgeFlags,
+				Action: AcknowledgeAction,
+				Before: ConfigureLogs,
+			},
+			{
+				Name:  "list",
+				Usage: "List the projects which sheriff would patrol, without scanning them",
+				Description: `Sheriff will list the projects of the targets it would patrol, without the ignored and excluded ones, and exit without downloading nor scanning them.
+It is useful to check which projects the targets and exclusions select, e.g. with glob patterns, before running a patrol.
+
+The projects are selected with the same flags and sheriff.toml configuration file as the patrol command.
+`,
+				Flags:  ListFlags,
+				Action: ListAction,
+				Before: ConfigureLogs,
+			},
+			{
+				Name:   "version",
+				Usage:  "Print the version and build information of sheriff, and the version of the osv-scanner it runs",
+				Action: VersionAction,
+			},
 		},
 	}
 
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sheriff/internal/compress"
+	"sheriff/internal/config"
+	"sheriff/internal/patrol"
+	"sheriff/internal/publish"
+	"sheriff/internal/repository"
+	"sheriff/internal/repository/provider"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/elliotchance/pie/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v2"
+)
+
+// listPatrolFlags are the flags of the patrol command which select the projects to scan, or are needed to list them
+var listPatrolFlags = []string{
+	configFlag,
+	verboseFlag,
+	logFormatFlag,
+	logLevelFlag,
+	targetFlag,
+	ignoreFlag,
+	excludePathFlag,
+	includeArchivedFlag,
+	includeForksFlag,
+	apiRateLimitFlag,
+	pageSizeFlag,
+	gitlabUrlFlag,
+	githubUrlFlag,
+	proxyFlag,
+	caCertFlag,
+	gitlabTokenFlag,
+	gitlabAuthModeFlag,
+	githubTokenFlag,
+	githubAppIdFlag,
+	githubAppInstallationIdFlag,
+	githubAppPrivateKeyFlag,
+}
+
+// ListFlags are the flags of the list command, shared with the patrol command so that both select the same projects
+var ListFlags = append(
+	pie.Filter(PatrolFlags, func(f cli.Flag) bool { return slices.Contains(listPatrolFlags, f.Names()[0]) }),
+	&cli.StringFlag{
+		Name:     outputFormatFlag,
+		Usage:    "Format of the list printed to the console: human for a table, or json to pipe into other tools",
+		Category: string(Miscellaneous),
+		Value:    "human",
+	},
+)
+
+// listedProject is a project as printed by the list command in JSON
+type listedProject struct {
+	Path       string                    `json:"path"`
+	Platform   repository.RepositoryType `json:"platform"`
+	Visibility repository.Visibility     `json:"visibility,omitempty"`
+	WebURL     string                    `json:"web_url"`
+}
+
+func ListAction(cCtx *cli.Context) error {
+	config, err := config.GetPatrolConfiguration(config.PatrolCLIOpts{
+		PatrolCommonOpts: config.PatrolCommonOpts{
+			Targets:         getStringSliceIfSet(cCtx, targetFlag),
+			Ignored:         getStringSliceIfSet(cCtx, ignoreFlag),
+			ExcludePaths:    getStringSliceIfSet(cCtx, excludePathFlag),
+			IncludeArchived: getBoolIfSet(cCtx, includeArchivedFlag),
+			IncludeForks:    getBoolIfSet(cCtx, includeForksFlag),
+		},
+		Config:       cCtx.String(configFlag),
+		Verbose:      cCtx.Bool(verboseFlag),
+		OutputFormat: cCtx.String(outputFormatFlag),
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to get list configuration"), err)
+	}
+
+	githubApp, err := getGithubAppCredentials(cCtx)
+	if err != nil {
+		return errors.Join(errors.New("failed to get GitHub App credentials"), err)
+	}
+
+	if err := configureTransport(cCtx); err != nil {
+		return err
+	}
+
+	// Nothing is downloaded, so the archive options are irrelevant
+	repositoryService, err := provider.NewProvider(cCtx.String(gitlabTokenFlag), getGitlabAuthMode(cCtx), cCtx.String(gitlabUrlFlag), cCtx.String(githubTokenFlag), githubApp, cCtx.String(githubUrlFlag), cCtx.Float64(apiRateLimitFlag), cCtx.Int(pageSizeFlag), 0, compress.Limits{}, nil, repository.ProjectFilter{
+		IncludeArchived: config.IncludeArchived,
+		IncludeForks:    config.IncludeForks,
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to create repository service"), err)
+	}
+
+	projects, warn := patrol.New(repositoryService, nil).List(config)
+
+	if err := printProjects(cCtx.App.Writer, projects, publish.ConsoleFormat(config.OutputFormat)); err != nil {
+		return errors.Join(errors.New("failed to print projects"), err)
+	}
+
+	if warn != nil {
+		log.Err(warn).Msg("Some projects could not be listed.")
+		return cli.Exit("Exiting list with partial success", exitCodePartialSuccess)
+	}
+
+	return nil
+}
+
+// printProjects prints the projects as a table, or as a JSON array if the format is json
+func printProjects(w io.Writer, projects []repository.Project, format publish.ConsoleFormat) error {
+	if format == publish.ConsoleFormatJSON {
+		listed := pie.Map(projects, func(p repository.Project) listedProject {
+			return listedProject{Path: p.Path, Platform: p.Repository, Visibility: p.Visibility, WebURL: p.WebURL}
+		})
+		if listed == nil {
+			listed = []listedProject{}
+		}
+
+		return json.NewEncoder(w).Encode(listed)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tPLATFORM\tVISIBILITY")
+	for _, p := range projects {
+		visibility := string(p.Visibility)
+		if visibility == "" {
+			visibility = "-"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", p.Path, p.Repository, visibility)
+	}
+	fmt.Fprintf(tw, "\n%v projects would be scanned\n", len(projects))
+
+	return tw.Flush()
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"sheriff/internal/publish"
+	"sheriff/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+var listedProjects = []repository.Project{
+	{Path: "group/platform/api", Repository: repository.Gitlab, Visibility: repository.Internal, WebURL: "https://gitlab.com/group/platform/api"},
+	{Path: "owner/repo", Repository: repository.Github, WebURL: "https://github.com/owner/repo"},
+}
+
+func TestListActionEmptyRun(t *testing.T) {
+	var out bytes.Buffer
+	app := cli.NewApp()
+	app.Writer = &out
+	context := cli.NewContext(app, flag.NewFlagSet("flagset", flag.ContinueOnError), nil)
+
+	err := ListAction(context)
+
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "0 projects would be scanned")
+}
+
+func TestPrintProjectsAsTable(t *testing.T) {
+	var out bytes.Buffer
+
+	require.NoError(t, printProjects(&out, listedProjects, publish.ConsoleFormatHuman))
+
+	assert.Equal(t, "PROJECT             PLATFORM  VISIBILITY\n"+
+		"group/platform/api  gitlab    internal\n"+
+		"owner/repo          github    -\n"+
+		"\n2 projects would be scanned\n", out.String())
+}
+
+func TestPrintProjectsAsJson(t *testing.T) {
+	var out bytes.Buffer
+
+	require.NoError(t, printProjects(&out, listedProjects, publish.ConsoleFormatJSON))
+	assert.JSONEq(t, `[
+		{"path": "group/platform/api", "platform": "gitlab", "visibility": "internal", "web_url": "https://gitlab.com/group/platform/api"},
+		{"path": "owner/repo", "platform": "github", "web_url": "https://github.com/owner/repo"}
+	]`, out.String())
+
+	out.Reset()
+	require.NoError(t, printProjects(&out, nil, publish.ConsoleFormatJSON))
+	assert.JSONEq(t, `[]`, out.String())
+}
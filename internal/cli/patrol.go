@@ -1,16 +1,28 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"os/signal"
+	"sheriff/internal/cache"
+	"sheriff/internal/compress"
 	"sheriff/internal/config"
 	"sheriff/internal/patrol"
+	"sheriff/internal/repository"
+	"sheriff/internal/repository/github"
+	"sheriff/internal/repository/gitlab"
 	"sheriff/internal/repository/provider"
 	"sheriff/internal/scanner"
 	"sheriff/internal/slack"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/elliotchance/pie/v2"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 )
@@ -24,20 +36,100 @@ const (
 	Scanning      CommandCategory = "Scanning (configurable by file):"
 )
 
+// Exit codes of the patrol command, on top of 0 for success
+const (
+	exitCodePartialSuccess  = 1 // Some projects could not be scanned or reported
+	exitCodeVulnerabilities = 2 // Vulnerabilities were found, see --fail-on-vulnerabilities
+	exitCodeCancelled       = 3 // The patrol was interrupted or timed out, see --timeout
+)
+
 const configFlag = "config"
 const verboseFlag = "verbose"
+const logFormatFlag = "log-format"
+const logLevelFlag = "log-level"
 const targetFlag = "target"
 const ignoreFlag = "ignore"
+const excludePathFlag = "exclude-path"
+const includeArchivedFlag = "include-archived"
+const includeForksFlag = "include-forks"
+const maxConcurrencyFlag = "max-concurrency"
+const cloneRetriesFlag = "clone-retries"
+const osvOfflineDbFlag = "osv-offline-db"
+const osvScannerPathFlag = "osv-scanner-path"
+const osvScannerExtraArgsFlag = "osv-scanner-extra-args"
+const maxArchiveSizeFlag = "max-archive-size"
+const cacheDirFlag = "cache-dir"
+const tmpDirFlag = "tmp-dir"
+const keepScansOnFailureFlag = "keep-scans-on-failure"
+const strictConfigFlag = "strict-config"
+const apiRateLimitFlag = "api-rate-limit"
+const pageSizeFlag = "page-size"
+const proxyFlag = "proxy"
+const caCertFlag = "ca-cert"
+const scannerFlag = "scanner"
+const scanPathsFlag = "scan-paths"
+const ignorePathsFlag = "ignore-paths"
+const ignoreVulnFlag = "ignore-vuln"
+const ignoreFileFlag = "ignore-file"
+const enableEpssFlag = "enable-epss"
+const callAnalysisFlag = "call-analysis"
+const allowedLicensesFlag = "allowed-licenses"
 const reportToEmailFlag = "report-to-email"
 const reportToIssueFlag = "report-to-issue"
 const reportToSlackChannel = "report-to-slack-channel"
 const reportEnableProjectReportToFlag = "report-enable-project-report-to"
+const reportEnableProjectIssueFlag = "report-enable-project-issue"
+const reportSlackDeltaFlag = "report-slack-delta"
+const reportSlackThreadFlag = "report-slack-thread"
+const reportSlackActionsFlag = "report-slack-actions"
+const reportSlackNewOnlyFlag = "report-slack-new-only"
+const severityThresholdFlag = "severity-threshold"
 const silentReportFlag = "silent"
+const verboseIssueFlag = "verbose-issue"
+const issueTitleFlag = "issue-title"
+const issueAssigneesFlag = "issue-assignees"
+const issueTemplateFlag = "issue-template"
+const centralIssueFlag = "central-issue"
+const closeIssueCommentFlag = "close-issue-comment"
+const attachRawReportFlag = "attach-raw-report"
+const closeAfterCleanCountFlag = "close-after-clean-count"
+const stateFileFlag = "state-file"
+const incrementalFlag = "incremental"
+const progressFlag = "progress"
+const metricsFileFlag = "metrics-file"
+const baseRefFlag = "base-ref"
+const targetRefFlag = "target-ref"
+const diffFileFlag = "diff-file"
+const failOnVulnerabilitiesFlag = "fail-on-vulnerabilities"
+const failOnSeverityFlag = "fail-on-severity"
+const failOnLicenseFlag = "fail-on-license"
+const timeoutFlag = "timeout"
+const perProjectTimeoutFlag = "per-project-timeout"
+const downloadTimeoutFlag = "download-timeout"
+const outputFormatFlag = "output-format"
+const consoleDetailFlag = "console-detail"
+const reportToFlag = "report-to"
+const dryRunFlag = "dry-run"
 const gitlabTokenFlag = "gitlab-token"
+const gitlabUrlFlag = "gitlab-url"
+const gitlabAuthModeFlag = "gitlab-auth-mode"
 const githubTokenFlag = "github-token"
+const githubUrlFlag = "github-url"
+const githubAppIdFlag = "github-app-id"
+const githubAppInstallationIdFlag = "github-app-installation-id"
+const githubAppPrivateKeyFlag = "github-app-private-key"
 const slackTokenFlag = "slack-token"
+const slackWebhookFlag = "slack-webhook"
+const pagerDutyRoutingKeyFlag = "pagerduty-routing-key"
+
+// scannerCommands are the commands which must be in $PATH to run each scanner
+var scannerCommands = map[string]string{
+	scanner.OsvScannerName:   scanner.OsvCommandName,
+	scanner.TrivyScannerName: scanner.TrivyCommandName,
+}
 
-var necessaryScanners = []string{scanner.OsvCommandName}
+// checkOsvScanner checks that osv-scanner can be run and is recent enough
+var checkOsvScanner = scanner.CheckOsvScanner
 
 var PatrolFlags = []cli.Flag{
 	&cli.StringFlag{
@@ -52,6 +144,110 @@ var PatrolFlags = []cli.Flag{
 		Category: string(Miscellaneous),
 		Value:    false,
 	},
+	&cli.StringFlag{
+		Name:     logFormatFlag,
+		Usage:    "Format of the logs written to stderr: console for human readable lines, or json for a JSON object per line.",
+		Category: string(Miscellaneous),
+		Value:    "console",
+	},
+	&cli.StringFlag{
+		Name:     logLevelFlag,
+		Usage:    "Lowest level of the logs written to stderr: trace, debug, info, warn or error. Overrides --verbose. Defaults to debug with --verbose, warn otherwise.",
+		Category: string(Miscellaneous),
+	},
+	&cli.BoolFlag{
+		Name:     progressFlag,
+		Usage:    "Show the progress of the scan, as a progress bar when stderr is a terminal or as periodic log lines otherwise. Without it, the progress is only logged in verbose mode.",
+		Category: string(Miscellaneous),
+		Value:    false,
+	},
+	&cli.StringFlag{
+		Name:     stateFileFlag,
+		Usage:    "Path of the file where sheriff keeps the results of the last run, to compare against in the next one",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     metricsFileFlag,
+		Usage:    "Path of the file where metrics of the scan are written in the Prometheus text format, e.g. for the textfile collector of the node exporter",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     targetRefFlag,
+		Usage:    "Branch of the projects to diff against the base ref. If set, sheriff scans the projects at both refs and only reports the vulnerabilities added and removed by this branch, without creating issues or posting to slack.",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     baseRefFlag,
+		Usage:    "Branch of the projects which the target ref is diffed against. Defaults to the default branch of each project. Requires --target-ref.",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     diffFileFlag,
+		Usage:    "Path of the file where the diff between the base and target refs is written, as JSON. Requires --target-ref.",
+		Category: string(Miscellaneous),
+	},
+	&cli.BoolFlag{
+		Name:     incrementalFlag,
+		Usage:    "Skip the projects whose default branch did not change since the last run and had no vulnerabilities then. Requires --state-file.",
+		Category: string(Miscellaneous),
+		Value:    false,
+	},
+	&cli.BoolFlag{
+		Name:     failOnVulnerabilitiesFlag,
+		Usage:    "Exit with code 2 if any scanned project is vulnerable, e.g. to fail a CI pipeline. Takes precedence over exit code 1, which means that some projects could not be scanned or reported.",
+		Category: string(Miscellaneous),
+		Value:    false,
+	},
+	&cli.StringFlag{
+		Name:     failOnSeverityFlag,
+		Usage:    "Only exit with code 2 if a vulnerable project has vulnerabilities of this severity or higher (LOW, MODERATE, HIGH, CRITICAL). Requires --fail-on-vulnerabilities.",
+		Category: string(Miscellaneous),
+	},
+	&cli.BoolFlag{
+		Name:     failOnLicenseFlag,
+		Usage:    "Consider the projects with license violations as vulnerable, whatever the severity of their vulnerabilities. Requires --allowed-licenses.",
+		Category: string(Miscellaneous),
+		Value:    false,
+	},
+	&cli.BoolFlag{
+		Name:     dryRunFlag,
+		Usage:    "Scan the projects and print the report, but only log the issues and slack messages which would be published. The state file is not updated either.",
+		Category: string(Miscellaneous),
+		Value:    false,
+	},
+	&cli.StringFlag{
+		Name:     outputFormatFlag,
+		Usage:    "Format of the report printed to the console: human, or json for a compact summary to pipe into other tools",
+		Category: string(Miscellaneous),
+		Value:    "human",
+	},
+	&cli.StringFlag{
+		Name:     consoleDetailFlag,
+		Usage:    "Detail of the human report printed to the console: full to list all the vulnerabilities, projects for a line per project, or summary for the counts only",
+		Category: string(Miscellaneous),
+		Value:    "full",
+	},
+	&cli.StringSliceFlag{
+		Name:     reportToFlag,
+		Usage:    "Write the report to a file as `kind:path` (list argument which can be repeated). Supported kinds: junit (e.g. junit:report.xml), html (e.g. html:report.html), pagerduty (pagerduty:, triggers an event for each project with critical vulnerabilities, requires --pagerduty-routing-key), vex (e.g. vex:vex.json, CycloneDX VEX document of the acknowledged and active vulnerabilities)",
+		Category: string(Miscellaneous),
+	},
+	&cli.DurationFlag{
+		Name:     timeoutFlag,
+		Usage:    "Maximum duration of the patrol (e.g. 30m), after which it is aborted with exit code 3. No limit by default.",
+		Category: string(Miscellaneous),
+	},
+	&cli.DurationFlag{
+		Name:     perProjectTimeoutFlag,
+		Usage:    "Maximum duration of the download and scan of each project (e.g. 10m), after which the project is reported as failed and the other projects go on. No limit by default.",
+		Category: string(Miscellaneous),
+	},
+	&cli.DurationFlag{
+		Name:     downloadTimeoutFlag,
+		Usage:    "Maximum duration of the download of the archive of each project (e.g. 10m), after which the download is aborted and retried as per --clone-retries. Set to 0 to disable it.",
+		Category: string(Miscellaneous),
+		Value:    5 * time.Minute,
+	},
 	&cli.StringSliceFlag{
 		Name:     targetFlag,
 		Usage:    "Groups and projects to scan for vulnerabilities (list argument which can be repeated)",
@@ -62,6 +258,148 @@ var PatrolFlags = []cli.Flag{
 		Usage:    "List of repositories or groups to ignore (list argument which can be repeated)",
 		Category: string(Scanning),
 	},
+	&cli.StringSliceFlag{
+		Name:     excludePathFlag,
+		Usage:    "Glob patterns of the paths of the projects to leave out of the scan, e.g. 'group/platform/legacy-*' (list argument which can be repeated). A project is also left out if the path of one of its parent groups matches.",
+		Category: string(Scanning),
+	},
+	&cli.BoolFlag{
+		Name:     includeArchivedFlag,
+		Usage:    "Also scan the archived projects of the targeted groups and owners. Archived projects targeted by their own path are always scanned.",
+		Category: string(Scanning),
+	},
+	&cli.BoolFlag{
+		Name:     includeForksFlag,
+		Usage:    "Also scan the forked repositories of the targeted GitHub owners. Forks targeted by their own path are always scanned.",
+		Category: string(Scanning),
+	},
+	&cli.IntFlag{
+		Name:     maxConcurrencyFlag,
+		Usage:    "Maximum number of projects to download and scan at the same time",
+		Category: string(Scanning),
+		Value:    4,
+	},
+	&cli.IntFlag{
+		Name:     cloneRetriesFlag,
+		Usage:    "Number of times to retry downloading a project after a failure, with exponential backoff",
+		Category: string(Scanning),
+		Value:    2,
+	},
+	&cli.IntFlag{
+		Name:     maxArchiveSizeFlag,
+		Usage:    "Maximum size in megabytes of a downloaded project once extracted. Projects exceeding it are not scanned.",
+		Category: string(Scanning),
+		Value:    2048,
+	},
+	&cli.StringSliceFlag{
+		Name:     scannerFlag,
+		Usage:    fmt.Sprintf("Vulnerability scanners to run on each project, one of %v (list argument which can be repeated). The findings of all scanners are merged into a single report per project. Defaults to osv.", strings.Join(scanner.ScannerNames, ", ")),
+		Category: string(Scanning),
+	},
+	&cli.StringSliceFlag{
+		Name:     scanPathsFlag,
+		Usage:    "Glob patterns, relative to the root of each project, of the lockfiles and directories to scan, e.g. 'go.mod' or 'services/*' (list argument which can be repeated). The whole project is scanned by default.",
+		Category: string(Scanning),
+	},
+	&cli.StringSliceFlag{
+		Name:     ignorePathsFlag,
+		Usage:    "Glob patterns, relative to the root of each project, of the paths whose vulnerabilities are dropped from the report, e.g. 'testdata' or 'examples/*' (list argument which can be repeated). A pattern without a '/' matches a file or directory of that name at any depth.",
+		Category: string(Scanning),
+	},
+	&cli.StringSliceFlag{
+		Name:     ignoreVulnFlag,
+		Usage:    "OSV ids of the vulnerabilities to acknowledge in all projects, e.g. org-wide false positives (list argument which can be repeated)",
+		Category: string(Scanning),
+	},
+	&cli.StringFlag{
+		Name:     ignoreFileFlag,
+		Usage:    "Path to a file listing OSV ids to acknowledge in all projects, one per line. Empty lines and lines starting with '#' are skipped.",
+		Category: string(Scanning),
+	},
+	&cli.BoolFlag{
+		Name:     enableEpssFlag,
+		Usage:    "Enrich the vulnerabilities with their EPSS score, the probability of being exploited in the next 30 days, from the FIRST API. Scores which cannot be fetched are reported as unknown.",
+		Category: string(Scanning),
+	},
+	&cli.StringSliceFlag{
+		Name:     allowedLicensesFlag,
+		Usage:    "SPDX identifiers of the allowed licenses, e.g. 'MIT' or 'Apache-2.0' (list argument which can be repeated). If set, osv-scanner reports the packages with other licenses as license violations, which do not make a project vulnerable unless --fail-on-license is set.",
+		Category: string(Scanning),
+	},
+	&cli.StringFlag{
+		Name:     osvOfflineDbFlag,
+		Usage:    "Path to a local OSV database directory. If set, osv-scanner runs in offline mode and makes no network calls. The database must have been updated in the last 7 days.",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     osvScannerPathFlag,
+		Usage:    "Path to the osv-scanner binary to run, e.g. a pinned version. Defaults to the osv-scanner in $PATH.",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringSliceFlag{
+		Name:     osvScannerExtraArgsFlag,
+		Usage:    "Argument passed as is to osv-scanner, for its options not supported by sheriff (list argument which can be repeated, e.g. --osv-scanner-extra-args=--no-resolve). Arguments changing the output format break the scan.",
+		Category: string(Miscellaneous),
+	},
+	&cli.BoolFlag{
+		Name:     callAnalysisFlag,
+		Usage:    "Analyse whether the vulnerable code is actually called by each project, for the ecosystems supported by osv-scanner (e.g. Go and Rust). Reachable vulnerabilities are highlighted in the reports.",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     cacheDirFlag,
+		Usage:    "Directory in which the downloaded archives of the projects are kept between runs. Projects whose default branch did not change since are not downloaded again. Disabled by default.",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     tmpDirFlag,
+		Usage:    "Directory in which the projects are downloaded and scanned. It must exist. Defaults to the temporary directory of the OS.",
+		Category: string(Miscellaneous),
+	},
+	&cli.BoolFlag{
+		Name:     keepScansOnFailureFlag,
+		Usage:    "Keep the downloaded files of the projects which could not be scanned, for debugging. The files of the other projects are removed as usual.",
+		Category: string(Miscellaneous),
+	},
+	&cli.Float64Flag{
+		Name:     apiRateLimitFlag,
+		Usage:    "Maximum number of requests per second sent to the GitLab and GitHub APIs, each. Rate limited requests are retried after the wait requested by the API. Set to 0 to disable the throttling.",
+		Category: string(Miscellaneous),
+		Value:    10,
+	},
+	&cli.IntFlag{
+		Name:     pageSizeFlag,
+		Usage:    "Number of items per page of the listings of the GitLab and GitHub APIs, between 1 and 100. Smaller pages reduce the memory spikes, larger pages reduce the number of requests.",
+		Category: string(Miscellaneous),
+		Value:    repository.MaxPageSize,
+	},
+	&cli.StringFlag{
+		Name:     gitlabUrlFlag,
+		Usage:    "Base URL of a self-managed GitLab instance (e.g. https://gitlab.example.com). Defaults to gitlab.com.",
+		EnvVars:  []string{"GITLAB_URL"},
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     githubUrlFlag,
+		Usage:    "Base URL of a GitHub Enterprise Server instance (e.g. https://github.example.com). Defaults to github.com.",
+		EnvVars:  []string{"GITHUB_URL"},
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     proxyFlag,
+		Usage:    "URL of a proxy through which all requests are sent (e.g. http://proxy.example.com:3128). Defaults to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.",
+		Category: string(Miscellaneous),
+	},
+	&cli.StringFlag{
+		Name:     caCertFlag,
+		Usage:    "Path to a PEM bundle of CA certificates trusted on top of those of the system, e.g. of a proxy intercepting TLS connections.",
+		Category: string(Miscellaneous),
+	},
+	&cli.BoolFlag{
+		Name:     strictConfigFlag,
+		Usage:    "Treat a malformed sheriff.toml in a project, e.g. with invalid TOML or unknown keys, as a failed scan of that project. By default the project is scanned and the problem is reported as a warning.",
+		Category: string(Miscellaneous),
+	},
 	&cli.StringSliceFlag{
 		Name:     reportToEmailFlag,
 		Usage:    "Enable reporting to the provided list of emails",
@@ -74,7 +412,7 @@ var PatrolFlags = []cli.Flag{
 	},
 	&cli.StringSliceFlag{
 		Name:     reportToSlackChannel,
-		Usage:    "Enable reporting to the provided slack channels",
+		Usage:    "Enable reporting to the provided slack channels, given by their name or by their ID prefixed with 'id:' (e.g. 'id:C0123ABCD') to skip looking them up",
 		Category: string(Reporting),
 	},
 	&cli.BoolFlag{
@@ -83,6 +421,85 @@ var PatrolFlags = []cli.Flag{
 		Category: string(Reporting),
 		Value:    true,
 	},
+	&cli.BoolFlag{
+		Name:     reportEnableProjectIssueFlag,
+		Usage:    "Let each project enable or disable its issue with 'report.to.issue' in its sheriff.toml. Without it, the issue is enabled in private and internal projects, and disabled in public ones. Takes precedence over '--report-to-issue'.",
+		Category: string(Reporting),
+	},
+	&cli.BoolFlag{
+		Name:     reportSlackDeltaFlag,
+		Usage:    "Only post to the slack channels the vulnerabilities which were introduced or resolved since the last run. Requires --state-file.",
+		Category: string(Reporting),
+		Value:    false,
+	},
+	&cli.BoolFlag{
+		Name:     reportSlackThreadFlag,
+		Usage:    "Post the list of vulnerabilities of each project in the thread of its summary in the project slack channel, rather than as separate messages.",
+		Category: string(Reporting),
+		Value:    true,
+	},
+	&cli.BoolFlag{
+		Name:     reportSlackActionsFlag,
+		Usage:    "Add buttons to open the issue and acknowledge the vulnerabilities to the summary in the project slack channel. Requires interactivity to be enabled for the slack app.",
+		Category: string(Reporting),
+		Value:    false,
+	},
+	&cli.BoolFlag{
+		Name:     reportSlackNewOnlyFlag,
+		Usage:    "Only list in the project slack channel the vulnerabilities which were not in the issue of the project yet. The issue still lists all of them. Requires issues.",
+		Category: string(Reporting),
+		Value:    false,
+	},
+	&cli.StringFlag{
+		Name:     severityThresholdFlag,
+		Usage:    "Only consider a project vulnerable if it has vulnerabilities of this severity or higher (LOW, MODERATE, HIGH, CRITICAL). Vulnerabilities below the threshold are still reported.",
+		Category: string(Reporting),
+	},
+	&cli.StringFlag{
+		Name:     issueTitleFlag,
+		Usage:    "Title of the issue opened in the affected projects. Use different titles to have several sheriff configurations report to the same project without overwriting each other's issue.",
+		Category: string(Reporting),
+		Value:    repository.VulnerabilityIssueTitle,
+	},
+	&cli.StringSliceFlag{
+		Name:     issueAssigneesFlag,
+		Usage:    "Usernames to assign to the issues created in the affected projects (list argument which can be repeated). Users who cannot be found or assigned are skipped.",
+		Category: string(Reporting),
+	},
+	&cli.StringFlag{
+		Name:     issueTemplateFlag,
+		Usage:    "Path to a Go text/template file rendering the body of the issue from the report of the project, instead of the built-in layout.",
+		Category: string(Reporting),
+	},
+	&cli.StringFlag{
+		Name:     centralIssueFlag,
+		Usage:    "Project in which a single issue lists all the vulnerable projects, instead of an issue in each of them, formatted as a target (e.g. gitlab://group/security). Requires --report-to-issue.",
+		Category: string(Reporting),
+	},
+	&cli.BoolFlag{
+		Name:     closeIssueCommentFlag,
+		Usage:    "Comment on the issue that all vulnerabilities are resolved before closing it. Disable with --close-issue-comment=false to close it silently.",
+		Category: string(Reporting),
+		Value:    true,
+	},
+	&cli.BoolFlag{
+		Name:     attachRawReportFlag,
+		Usage:    "Upload the raw JSON report of osv-scanner of each vulnerable project and link it from its issue, for auditability. Only supported on GitLab. The report can be large, and is uploaded again on every run.",
+		Category: string(Reporting),
+		Value:    false,
+	},
+	&cli.IntFlag{
+		Name:     closeAfterCleanCountFlag,
+		Usage:    "Only close the issue of a project once it has not been vulnerable for this many consecutive runs, so that a flaky scan does not close it. Above 1, requires --state-file.",
+		Category: string(Reporting),
+		Value:    1,
+	},
+	&cli.BoolFlag{
+		Name:     verboseIssueFlag,
+		Usage:    "Add a collapsible section with the summary and details of each vulnerability to the issue, below the tables. Disable with --verbose-issue=false.",
+		Category: string(Reporting),
+		Value:    true,
+	},
 	&cli.BoolFlag{
 		Name:     silentReportFlag,
 		Usage:    "Disable report output to stdout.",
@@ -92,85 +509,310 @@ var PatrolFlags = []cli.Flag{
 	// Secret tokens
 	&cli.StringFlag{
 		Name:     gitlabTokenFlag,
-		Usage:    "Token to access the Gitlab API.",
+		Usage:    "Token to access the Gitlab API. Falls back to the CI job token in GitLab CI.",
 		Required: true,
-		EnvVars:  []string{"GITLAB_TOKEN"},
+		EnvVars:  []string{"GITLAB_TOKEN", "CI_JOB_TOKEN"},
+		Category: string(Tokens),
+	},
+	&cli.StringFlag{
+		Name:     gitlabAuthModeFlag,
+		Usage:    "Kind of the gitlab token: pat for a personal, group or project access token, or job-token for a CI job token, which can neither list groups nor manage issues. Defaults to job-token if the token is the CI job token, pat otherwise.",
 		Category: string(Tokens),
 	},
 	&cli.StringFlag{
 		Name:     githubTokenFlag,
-		Usage:    "Token to access the Github API.",
-		Required: true,
+		Usage:    "Token to access the Github API, either a classic or a fine-grained personal access token. Not needed when authenticating as a GitHub App.",
 		EnvVars:  []string{"GITHUB_TOKEN"},
 		Category: string(Tokens),
 	},
+	&cli.Int64Flag{
+		Name:     githubAppIdFlag,
+		Usage:    "ID of the GitHub App to access the Github API as, instead of with --github-token. Requires --github-app-installation-id and --github-app-private-key.",
+		EnvVars:  []string{"GITHUB_APP_ID"},
+		Category: string(Tokens),
+	},
+	&cli.Int64Flag{
+		Name:     githubAppInstallationIdFlag,
+		Usage:    "ID of the installation of the GitHub App in the organization or account to scan.",
+		EnvVars:  []string{"GITHUB_APP_INSTALLATION_ID"},
+		Category: string(Tokens),
+	},
+	&cli.StringFlag{
+		Name:     githubAppPrivateKeyFlag,
+		Usage:    "Path to the PEM encoded private key of the GitHub App.",
+		EnvVars:  []string{"GITHUB_APP_PRIVATE_KEY"},
+		Category: string(Tokens),
+	},
 	&cli.StringFlag{
 		Name:     slackTokenFlag,
 		Usage:    "Token to access the Slack API.",
 		EnvVars:  []string{"SLACK_TOKEN"},
 		Category: string(Tokens),
 	},
+	&cli.StringFlag{
+		Name:     slackWebhookFlag,
+		Usage:    "URL of a Slack incoming webhook to post to instead of the Slack API, for teams without a bot token. Every message is posted to the channel of the webhook.",
+		EnvVars:  []string{"SLACK_WEBHOOK_URL"},
+		Category: string(Tokens),
+	},
+	&cli.StringFlag{
+		Name:     pagerDutyRoutingKeyFlag,
+		Usage:    "Routing key of the PagerDuty service to trigger events in with '--report-to pagerduty:'.",
+		EnvVars:  []string{"PAGERDUTY_ROUTING_KEY"},
+		Category: string(Tokens),
+	},
 }
 
 func PatrolAction(cCtx *cli.Context) error {
 	config, err := config.GetPatrolConfiguration(config.PatrolCLIOpts{
 		PatrolCommonOpts: config.PatrolCommonOpts{
-			Targets: getStringSliceIfSet(cCtx, targetFlag),
-			Ignored: getStringSliceIfSet(cCtx, ignoreFlag),
+			Targets:         getStringSliceIfSet(cCtx, targetFlag),
+			Ignored:         getStringSliceIfSet(cCtx, ignoreFlag),
+			ExcludePaths:    getStringSliceIfSet(cCtx, excludePathFlag),
+			IncludeArchived: getBoolIfSet(cCtx, includeArchivedFlag),
+			IncludeForks:    getBoolIfSet(cCtx, includeForksFlag),
+			MaxConcurrency:  getIntIfSet(cCtx, maxConcurrencyFlag),
+			CloneRetries:    getIntIfSet(cCtx, cloneRetriesFlag),
+			MaxArchiveSize:  getIntIfSet(cCtx, maxArchiveSizeFlag),
+			Scanners:        getStringSliceIfSet(cCtx, scannerFlag),
+			AllowedLicenses: getStringSliceIfSet(cCtx, allowedLicensesFlag),
+			ScanPaths:       getStringSliceIfSet(cCtx, scanPathsFlag),
+			IgnorePaths:     getStringSliceIfSet(cCtx, ignorePathsFlag),
+			IgnoreVulns:     getStringSliceIfSet(cCtx, ignoreVulnFlag),
+			IgnoreFile:      getStringIfSet(cCtx, ignoreFileFlag),
+			EnableEpss:      getBoolIfSet(cCtx, enableEpssFlag),
 			Report: config.PatrolReportOpts{
 				To: config.PatrolReportToOpts{
 					Issue:                 getBoolIfSet(cCtx, reportToIssueFlag),
 					Emails:                getStringSliceIfSet(cCtx, reportToEmailFlag),
 					SlackChannels:         getStringSliceIfSet(cCtx, reportToSlackChannel),
 					EnableProjectReportTo: getBoolIfSet(cCtx, reportEnableProjectReportToFlag),
+					EnableProjectIssue:    getBoolIfSet(cCtx, reportEnableProjectIssueFlag),
 				},
-				SilentReport: getBoolIfSet(cCtx, silentReportFlag),
+				SlackDelta:           getBoolIfSet(cCtx, reportSlackDeltaFlag),
+				SlackThread:          getBoolIfSet(cCtx, reportSlackThreadFlag),
+				SlackActions:         getBoolIfSet(cCtx, reportSlackActionsFlag),
+				SlackNewOnly:         getBoolIfSet(cCtx, reportSlackNewOnlyFlag),
+				SeverityThreshold:    getStringIfSet(cCtx, severityThresholdFlag),
+				SilentReport:         getBoolIfSet(cCtx, silentReportFlag),
+				VerboseIssue:         getBoolIfSet(cCtx, verboseIssueFlag),
+				IssueTitle:           getStringIfSet(cCtx, issueTitleFlag),
+				IssueAssignees:       getStringSliceIfSet(cCtx, issueAssigneesFlag),
+				IssueTemplate:        getStringIfSet(cCtx, issueTemplateFlag),
+				CentralIssue:         getStringIfSet(cCtx, centralIssueFlag),
+				CloseIssueComment:    getBoolIfSet(cCtx, closeIssueCommentFlag),
+				AttachRawReport:      getBoolIfSet(cCtx, attachRawReportFlag),
+				CloseAfterCleanCount: getIntIfSet(cCtx, closeAfterCleanCountFlag),
 			},
 		},
-		Config:  cCtx.String(configFlag),
-		Verbose: cCtx.Bool(verboseFlag),
+		Config:                cCtx.String(configFlag),
+		Verbose:               cCtx.Bool(verboseFlag),
+		StateFile:             cCtx.String(stateFileFlag),
+		MetricsFile:           cCtx.String(metricsFileFlag),
+		ProjectTimeout:        cCtx.Duration(perProjectTimeoutFlag),
+		Incremental:           cCtx.Bool(incrementalFlag),
+		Progress:              cCtx.Bool(progressFlag),
+		TmpDir:                cCtx.String(tmpDirFlag),
+		KeepScansOnFailure:    cCtx.Bool(keepScansOnFailureFlag),
+		StrictConfig:          cCtx.Bool(strictConfigFlag),
+		FailOnVulnerabilities: cCtx.Bool(failOnVulnerabilitiesFlag),
+		FailOnLicense:         cCtx.Bool(failOnLicenseFlag),
+		FailOnSeverity:        cCtx.String(failOnSeverityFlag),
+		OutputFormat:          cCtx.String(outputFormatFlag),
+		ConsoleDetail:         cCtx.String(consoleDetailFlag),
+		ReportTo:              cCtx.StringSlice(reportToFlag),
+		PagerDutyRoutingKey:   cCtx.String(pagerDutyRoutingKeyFlag),
+		DryRun:                cCtx.Bool(dryRunFlag),
+		BaseRef:               cCtx.String(baseRefFlag),
+		TargetRef:             cCtx.String(targetRefFlag),
+		DiffFile:              cCtx.String(diffFileFlag),
 	})
 	if err != nil {
 		return errors.Join(errors.New("failed to get patrol configuration"), err)
 	}
 
+	// Applied before any scan, so that all reports are generated with the same thresholds
+	scanner.SetSeverityScoreThresholds(map[scanner.SeverityScoreKind]float64{
+		scanner.Critical: config.SeverityScores.Critical,
+		scanner.High:     config.SeverityScores.High,
+		scanner.Moderate: config.SeverityScores.Moderate,
+		scanner.Low:      config.SeverityScores.Low,
+	})
+
 	// Get tokens
 	gitlabToken := cCtx.String(gitlabTokenFlag)
 	githubToken := cCtx.String(githubTokenFlag)
 	slackToken := cCtx.String(slackTokenFlag)
+	githubApp, err := getGithubAppCredentials(cCtx)
+	if err != nil {
+		return errors.Join(errors.New("failed to get GitHub App credentials"), err)
+	}
 
 	// Create services
-	repositoryService, err := provider.NewProvider(gitlabToken, githubToken)
+	if err := configureTransport(cCtx); err != nil {
+		return err
+	}
+
+	var archiveCache *cache.ArchiveCache
+	if cacheDir := cCtx.String(cacheDirFlag); cacheDir != "" {
+		if archiveCache, err = cache.New(cacheDir); err != nil {
+			return errors.Join(errors.New("failed to create archive cache"), err)
+		}
+	}
+
+	repositoryService, err := provider.NewProvider(gitlabToken, getGitlabAuthMode(cCtx), cCtx.String(gitlabUrlFlag), githubToken, githubApp, cCtx.String(githubUrlFlag), cCtx.Float64(apiRateLimitFlag), cCtx.Int(pageSizeFlag), cCtx.Duration(downloadTimeoutFlag), compress.NewLimits(int64(config.MaxArchiveSize)<<20), archiveCache, repository.ProjectFilter{
+		IncludeArchived: config.IncludeArchived,
+		IncludeForks:    config.IncludeForks,
+	})
 	if err != nil {
 		return errors.Join(errors.New("failed to create repository service"), err)
 	}
 
-	slackService, err := slack.New(slackToken, config.Verbose)
+	slackService, err := newSlackService(slackToken, cCtx.String(slackWebhookFlag), config.Verbose)
 	if err != nil {
 		return errors.Join(errors.New("failed to create Slack service"), err)
 	}
 
-	osvService := scanner.NewOsvScanner()
+	scanners := make([]scanner.ProjectScanner, 0, len(config.Scanners))
+	necessaryScanners := make([]string, 0, len(config.Scanners))
+	for _, name := range config.Scanners {
+		switch name {
+		case scanner.OsvScannerName:
+			osvService, err := scanner.NewOsvScanner(scanner.OsvOpts{
+				OfflineDbPath:   cCtx.String(osvOfflineDbFlag),
+				ScanPaths:       config.ScanPaths,
+				CallAnalysis:    cCtx.Bool(callAnalysisFlag),
+				AllowedLicenses: config.AllowedLicenses,
+				BinaryPath:      cCtx.String(osvScannerPathFlag),
+				ExtraArgs:       cCtx.StringSlice(osvScannerExtraArgsFlag),
+				KeepRawReport:   config.AttachRawReport,
+			})
+			if err != nil {
+				return errors.Join(errors.New("failed to create OSV scanner service"), err)
+			}
+			scanners = append(scanners, scanner.NewProjectScanner(name, osvService))
+		case scanner.TrivyScannerName:
+			scanners = append(scanners, scanner.NewProjectScanner(name, scanner.NewTrivyScanner()))
+		}
+		// A custom osv-scanner binary is checked when creating its scanner instead
+		if name != scanner.OsvScannerName || cCtx.String(osvScannerPathFlag) == "" {
+			necessaryScanners = append(necessaryScanners, scannerCommands[name])
+		}
+	}
 
-	patrolService := patrol.New(repositoryService, slackService, osvService)
+	patrolService := patrol.New(repositoryService, slackService, scanners...)
 
 	// Check whether the necessary scanners are available
 	missingScanners := getMissingScanners(necessaryScanners)
 	if len(missingScanners) > 0 {
 		return fmt.Errorf("cannot find all necessary scanners in $PATH, missing: %v", strings.Join(missingScanners, ", "))
 	}
+	if slices.Contains(config.Scanners, scanner.OsvScannerName) {
+		if err := checkOsvScanner(cCtx.Context, cCtx.String(osvScannerPathFlag)); err != nil {
+			return errors.Join(errors.New("failed to check osv-scanner"), err)
+		}
+	}
+
+	// Abort the patrol on SIGINT/SIGTERM, or once the timeout is reached
+	ctx, stop := signal.NotifyContext(cCtx.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout := cCtx.Duration(timeoutFlag); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	// Do the patrol
-	if warn, err := patrolService.Patrol(config); err != nil {
+	reports, warn, err := patrolService.Patrol(ctx, config)
+	if err != nil && ctx.Err() != nil {
+		log.Err(err).Msg("Patrol was cancelled")
+		return cli.Exit("Exiting patrol as it was cancelled", exitCodeCancelled)
+	} else if err != nil {
 		return errors.Join(errors.New("failed to scan"), err)
-	} else if warn != nil {
+	}
+
+	if warn != nil {
 		log.Err(warn).Msg("Patrol was partially successful, some errors occurred.")
-		return cli.Exit("Exiting patrol with partial success", 1)
+	}
+
+	if config.FailOnVulnerabilities && hasVulnerabilities(reports, scanner.SeverityScoreKind(config.FailOnSeverity), config.FailOnLicense) {
+		return cli.Exit("Exiting patrol as vulnerabilities were found", exitCodeVulnerabilities)
+	} else if warn != nil {
+		return cli.Exit("Exiting patrol with partial success", exitCodePartialSuccess)
 	}
 
 	return nil
 }
 
+// hasVulnerabilities returns true if any of the reports is vulnerable,
+// with at least one vulnerability at or above the given severity if it is set.
+// If licenses is set, license violations are vulnerable whatever the severity, as they have none.
+func hasVulnerabilities(reports []scanner.Report, severity scanner.SeverityScoreKind, licenses bool) bool {
+	return pie.Any(reports, func(r scanner.Report) bool {
+		if !r.IsVulnerable {
+			return false
+		}
+
+		if severity == "" || (licenses && len(r.LicenseViolations) > 0) {
+			return true
+		}
+
+		return pie.Any(r.Vulnerabilities, func(v scanner.Vulnerability) bool {
+			return scanner.MeetsSeverityThreshold(v.SeverityScoreKind, severity)
+		})
+	})
+}
+
+// getGitlabAuthMode returns the kind of the gitlab token, as given by --gitlab-auth-mode.
+// Otherwise, the token is taken as a CI job token if it is the one of the running GitLab CI job.
+func getGitlabAuthMode(cCtx *cli.Context) gitlab.AuthMode {
+	if cCtx.IsSet(gitlabAuthModeFlag) {
+		return gitlab.AuthMode(cCtx.String(gitlabAuthModeFlag))
+	}
+
+	if token := cCtx.String(gitlabTokenFlag); token != "" && token == os.Getenv("CI_JOB_TOKEN") {
+		return gitlab.AuthModeJobToken
+	}
+
+	return gitlab.AuthModePat
+}
+
+// getGithubAppCredentials returns the credentials of the GitHub App installation to access GitHub as,
+// which are empty if no app id is given
+func getGithubAppCredentials(cCtx *cli.Context) (github.AppCredentials, error) {
+	appID := cCtx.Int64(githubAppIdFlag)
+	if appID == 0 {
+		return github.AppCredentials{}, nil
+	}
+
+	keyPath := cCtx.String(githubAppPrivateKeyFlag)
+	if keyPath == "" {
+		return github.AppCredentials{}, fmt.Errorf("--%v is required with --%v", githubAppPrivateKeyFlag, githubAppIdFlag)
+	}
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return github.AppCredentials{}, errors.Join(fmt.Errorf("failed to read GitHub App private key %v", keyPath), err)
+	}
+
+	return github.AppCredentials{
+		AppID:          appID,
+		InstallationID: cCtx.Int64(githubAppInstallationIdFlag),
+		PrivateKey:     key,
+	}, nil
+}
+
+// newSlackService creates the slack service posting with the bot token, or to the incoming webhook if one is given
+func newSlackService(token string, webhookURL string, debug bool) (slack.IService, error) {
+	if webhookURL == "" {
+		return slack.New(token, debug)
+	}
+	if token != "" {
+		return nil, fmt.Errorf("--%v cannot be combined with --%v", slackWebhookFlag, slackTokenFlag)
+	}
+
+	return slack.NewWebhook(webhookURL)
+}
+
 func getMissingScanners(necessary []string) []string {
 	missingScanners := make([]string, 0, len(necessary))
 	for _, scanner := range necessary {
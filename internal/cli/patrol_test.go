@@ -1,7 +1,10 @@
 package cli
 
 import (
+	"context"
 	"flag"
+	"sheriff/internal/repository/gitlab"
+	"sheriff/internal/scanner"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,12 +12,15 @@ import (
 )
 
 func TestPatrolActionEmptyRun(t *testing.T) {
-	// Monkey patch necessaryScanners to avoid missing scanners
+	// Monkey patch scannerCommands to avoid missing scanners
 	// during testing
-	origNecessaryScanners := necessaryScanners
-	necessaryScanners = []string{}
+	origScannerCommands := scannerCommands
+	scannerCommands = map[string]string{scanner.OsvScannerName: "ls"}
+	origCheckOsvScanner := checkOsvScanner
+	checkOsvScanner = func(context.Context, string) error { return nil }
 	defer func() {
-		necessaryScanners = origNecessaryScanners
+		scannerCommands = origScannerCommands
+		checkOsvScanner = origCheckOsvScanner
 	}()
 
 	context := cli.NewContext(cli.NewApp(), flag.NewFlagSet("flagset", flag.ContinueOnError), nil)
@@ -40,3 +46,96 @@ func TestGetMissingScanners(t *testing.T) {
 		assert.Equal(t, tc.want, missingScanners)
 	}
 }
+
+func TestGetGitlabAuthMode(t *testing.T) {
+	t.Setenv("CI_JOB_TOKEN", "job-token-value")
+	testCases := []struct {
+		name  string
+		token string
+		mode  string
+		want  gitlab.AuthMode
+	}{
+		{"access token", "access-token-value", "", gitlab.AuthModePat},
+		{"ci job token", "job-token-value", "", gitlab.AuthModeJobToken},
+		{"explicit mode", "access-token-value", "job-token", gitlab.AuthModeJobToken},
+		{"explicit mode overrides detection", "job-token-value", "pat", gitlab.AuthModePat},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("", flag.ContinueOnError)
+			flags.String(gitlabTokenFlag, "", "")
+			flags.String(gitlabAuthModeFlag, "", "")
+			_ = flags.Set(gitlabTokenFlag, tc.token)
+			if tc.mode != "" {
+				_ = flags.Set(gitlabAuthModeFlag, tc.mode)
+			}
+			cCtx := cli.NewContext(nil, flags, nil)
+
+			assert.Equal(t, tc.want, getGitlabAuthMode(cCtx))
+		})
+	}
+}
+
+func TestNewSlackService(t *testing.T) {
+	s, err := newSlackService("xoxb-token", "", false)
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+
+	s, err = newSlackService("", "https://hooks.slack.com/services/T000/B000/XXXX", false)
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+
+	_, err = newSlackService("xoxb-token", "https://hooks.slack.com/services/T000/B000/XXXX", false)
+	assert.ErrorContains(t, err, "--slack-webhook cannot be combined with --slack-token")
+}
+
+func TestHasVulnerabilities(t *testing.T) {
+	testCases := []struct {
+		name     string
+		reports  []scanner.Report
+		severity scanner.SeverityScoreKind
+		licenses bool
+		want     bool
+	}{
+		{"no reports", []scanner.Report{}, "", false, false},
+		{"not vulnerable", []scanner.Report{{IsVulnerable: false}}, "", false, false},
+		{"vulnerable", []scanner.Report{{IsVulnerable: false}, {IsVulnerable: true}}, "", false, true},
+		{
+			"vulnerable below severity",
+			[]scanner.Report{{IsVulnerable: true, Vulnerabilities: []scanner.Vulnerability{{SeverityScoreKind: scanner.Moderate}}}},
+			scanner.High,
+			false,
+			false,
+		},
+		{
+			"vulnerable at severity",
+			[]scanner.Report{{IsVulnerable: true, Vulnerabilities: []scanner.Vulnerability{{SeverityScoreKind: scanner.Moderate}, {SeverityScoreKind: scanner.High}}}},
+			scanner.High,
+			false,
+			true,
+		},
+		{
+			"license violation below severity",
+			[]scanner.Report{{IsVulnerable: true, LicenseViolations: []scanner.LicenseViolation{{PackageName: "lib"}}}},
+			scanner.High,
+			true,
+			true,
+		},
+		{
+			"license violation without failing on licenses",
+			[]scanner.Report{{IsVulnerable: true, Vulnerabilities: []scanner.Vulnerability{{SeverityScoreKind: scanner.Low}}, LicenseViolations: []scanner.LicenseViolation{{PackageName: "lib"}}}},
+			scanner.High,
+			false,
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := hasVulnerabilities(tc.reports, tc.severity, tc.licenses)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -1,18 +1,61 @@
 package cli
 
 import (
+	"errors"
+	"os"
 	"sheriff/internal/log"
+	"sheriff/internal/transport"
 
 	zerolog "github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 )
 
 func ConfigureLogs(cCtx *cli.Context) error {
-	log.ConfigureLogs(cCtx.Bool(verboseFlag))
+	err := log.ConfigureLogs(log.Options{
+		Verbose: cCtx.Bool(verboseFlag),
+		Level:   cCtx.String(logLevelFlag),
+		Format:  cCtx.String(logFormatFlag),
+		// Flags which are not defined by the command are empty, and ignored
+		Secrets: []string{
+			cCtx.String(gitlabTokenFlag),
+			cCtx.String(githubTokenFlag),
+			cCtx.String(slackTokenFlag),
+			cCtx.String(slackWebhookFlag),
+			cCtx.String(pagerDutyRoutingKeyFlag),
+			readSecretFile(cCtx.String(githubAppPrivateKeyFlag)),
+		},
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to configure logs"), err)
+	}
 	zerolog.Info().Msg("Logging configured")
 	return nil
 }
 
+// readSecretFile returns the content of the file holding a secret, to redact it from the logs.
+// It is empty if no file is given or it cannot be read, which the command reports when it reads the file itself.
+func readSecretFile(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+// configureTransport configures the proxy and CA certificates of all the outbound requests.
+// It must be called before creating the services sending them, see transport.SetDefault.
+func configureTransport(cCtx *cli.Context) error {
+	if err := transport.SetDefault(transport.Options{Proxy: cCtx.String(proxyFlag), CACert: cCtx.String(caCertFlag)}); err != nil {
+		return errors.Join(errors.New("failed to configure HTTP transport"), err)
+	}
+
+	return nil
+}
+
 func getStringSliceIfSet(cCtx *cli.Context, flagName string) *[]string {
 	if cCtx.IsSet(flagName) {
 		v := cCtx.StringSlice(flagName)
@@ -25,7 +68,15 @@ func getStringSliceIfSet(cCtx *cli.Context, flagName string) *[]string {
 func getStringIfSet(cCtx *cli.Context, flagName string) *string {
 	if cCtx.IsSet(flagName) {
 		v := cCtx.String(flagName)
-		print(v)
+		return &v
+	}
+
+	return nil
+}
+
+func getIntIfSet(cCtx *cli.Context, flagName string) *int {
+	if cCtx.IsSet(flagName) {
+		v := cCtx.Int(flagName)
 		return &v
 	}
 
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sheriff/internal/scanner"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v2"
+)
+
+// getOsvScannerVersion returns the version of the osv-scanner in $PATH
+var getOsvScannerVersion = scanner.GetOsvScannerVersion
+
+// VersionAction prints the version and build information of sheriff, and the version of osv-scanner
+func VersionAction(cCtx *cli.Context) error {
+	_, err := fmt.Fprint(cCtx.App.Writer, formatVersion(cCtx.Context))
+	return err
+}
+
+// printVersion prints the version for the --version flag, see cli.VersionPrinter
+func printVersion(cCtx *cli.Context) {
+	if err := VersionAction(cCtx); err != nil {
+		log.Err(err).Msg("Failed to print version")
+	}
+}
+
+// formatVersion formats the version and build information of sheriff, followed by the version of osv-scanner,
+// which is unknown if osv-scanner cannot be run
+func formatVersion(ctx context.Context) string {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	commit, date := getBuildInfo()
+	osvVersion, err := getOsvScannerVersion(ctx)
+	if err != nil {
+		osvVersion = "unknown, is osv-scanner in $PATH?"
+	}
+
+	return fmt.Sprintf("sheriff version %v\ncommit: %v\nbuilt at: %v\nosv-scanner version: %v\n", buildVersion, commit, date, osvVersion)
+}
+
+// getBuildInfo returns the commit and date of the build as injected with ldflags,
+// falling back to the version control information embedded by go build, if any
+func getBuildInfo() (commit string, date string) {
+	commit, date = buildCommit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				commit = cmp.Or(commit, s.Value)
+			case "vcs.time":
+				date = cmp.Or(date, s.Value)
+			}
+		}
+	}
+
+	return cmp.Or(commit, "unknown"), cmp.Or(date, "unknown")
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestVersionAction(t *testing.T) {
+	originalGetOsvScannerVersion, originalCommit, originalDate := getOsvScannerVersion, buildCommit, buildDate
+	getOsvScannerVersion = func(context.Context) (string, error) { return "2.2.2", nil }
+	buildCommit, buildDate = "abc123", "2026-01-01T00:00:00Z"
+	defer func() {
+		getOsvScannerVersion, buildCommit, buildDate = originalGetOsvScannerVersion, originalCommit, originalDate
+	}()
+
+	var out bytes.Buffer
+	app := cli.NewApp()
+	app.Writer = &out
+
+	err := VersionAction(cli.NewContext(app, flag.NewFlagSet("flagset", flag.ContinueOnError), nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "sheriff version "+buildVersion+"\ncommit: abc123\nbuilt at: 2026-01-01T00:00:00Z\nosv-scanner version: 2.2.2\n", out.String())
+}
+
+func TestFormatVersionWithoutOsvScanner(t *testing.T) {
+	originalGetOsvScannerVersion := getOsvScannerVersion
+	getOsvScannerVersion = func(context.Context) (string, error) { return "", errors.New("executable file not found in $PATH") }
+	defer func() {
+		getOsvScannerVersion = originalGetOsvScannerVersion
+	}()
+
+	got := formatVersion(context.Background())
+
+	assert.Contains(t, got, "sheriff version "+buildVersion+"\n")
+	assert.Contains(t, got, "osv-scanner version: unknown, is osv-scanner in $PATH?\n")
+}
@@ -2,35 +2,93 @@ package compress
 
 import (
 	"archive/tar"
+	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
 )
 
+// defaultMaxFileSize is the default maximum size of a single extracted file
+const defaultMaxFileSize int64 = 512 << 20
+
+// maxBufferedFileSize is the size up to which a file is read into memory to be written by a worker,
+// which bounds the memory used by the extraction. Larger files are written as they are read.
+const maxBufferedFileSize int64 = 1 << 20
+
+// extractWorkers is the maximum number of files of an archive written at once, one per available core
+var extractWorkers = runtime.GOMAXPROCS(0)
+
+// writeWorkerFile writes the files handed to the workers, replaced in tests to observe them
+var writeWorkerFile = writeFile
+
+// ErrLimitExceeded tells that the content extracted from an archive exceeds its Limits
+var ErrLimitExceeded = errors.New("extracted content exceeds the maximum size")
+
+// ErrInvalidArchive tells that an archive is malformed, e.g. corrupt or with entries escaping the destination directory
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// Limits bounds the size of the content extracted from an archive, to protect against decompression bombs.
+// A zero value for any of its fields means no limit.
+type Limits struct {
+	MaxTotalSize int64 // Maximum size in bytes of all extracted files together
+	MaxFileSize  int64 // Maximum size in bytes of a single extracted file
+}
+
+// NewLimits returns the limits for the given maximum total extracted size in bytes.
+// A single file may not be larger than 512MB, nor than the total size.
+func NewLimits(maxTotalSize int64) Limits {
+	return Limits{
+		MaxTotalSize: maxTotalSize,
+		MaxFileSize:  min(defaultMaxFileSize, maxTotalSize),
+	}
+}
+
 // ExtractTarGz extracts a tar.gz archive to the specified destination directory.
-func ExtractTarGz(reader io.Reader, destDir string) error {
+// It aborts with an error as soon as the extracted content exceeds the given limits.
+// The archive is read in order, creating the directories as they come, while its regular files
+// are written concurrently by up to extractWorkers workers, see fileWriter.
+func ExtractTarGz(reader io.Reader, destDir string, limits Limits) error {
 	if _, err := os.Stat(destDir); os.IsNotExist(err) {
 		return fmt.Errorf("destination directory does not exist: %s", destDir)
 	}
 
 	gzReader, err := gzip.NewReader(reader)
 	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+		return markInvalidArchive(fmt.Errorf("failed to create gzip reader: %w", err))
 	}
 	defer gzReader.Close()
 
-	tarReader := tar.NewReader(gzReader)
+	files := newFileWriter(extractWorkers)
+	err = extractEntries(tar.NewReader(gzReader), destDir, limits, files)
+	// Wait for the files being written even if the extraction failed, so that nothing is written once it returns
+	if werr := files.wait(); err == nil {
+		err = werr
+	}
+
+	return err
+}
 
+// extractEntries extracts the entries of the archive one after the other, handing its small regular files to the writer
+func extractEntries(tarReader *tar.Reader, destDir string, limits Limits, files *fileWriter) error {
+	var totalSize int64
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("failed to read tar header: %w", err)
+			return markInvalidArchive(fmt.Errorf("failed to read tar header: %w", err))
 		}
 
 		// Skip the root directory (GitLab archives have a root folder)
@@ -46,8 +104,8 @@ func ExtractTarGz(reader io.Reader, destDir string) error {
 		}
 
 		targetPath := filepath.Join(destDir, relativePath)
-		if !strings.HasPrefix(targetPath, filepath.Clean(destDir)+string(os.PathSeparator)) {
-			return fmt.Errorf("content of tar file is trying to write outside of destination directory: %s", relativePath)
+		if !isWithinDir(destDir, targetPath) {
+			return fmt.Errorf("%w: content of tar file is trying to write outside of destination directory: %s", ErrInvalidArchive, relativePath)
 		}
 
 		switch header.Typeflag {
@@ -59,18 +117,162 @@ func ExtractTarGz(reader io.Reader, destDir string) error {
 			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 				return fmt.Errorf("failed to create parent directory for %s: %w", targetPath, err)
 			}
-
-			file, err := os.OpenFile(targetPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(header.Mode))
-			if err != nil {
-				return fmt.Errorf("failed to create file %s: %w", targetPath, err)
+			// The last of several entries of the same file wins, as if they were written one after the other
+			if err := files.waitFor(targetPath); err != nil {
+				return err
 			}
-			defer file.Close()
 
-			if _, err := io.Copy(file, tarReader); err != nil {
-				return fmt.Errorf("failed to write file %s: %w", targetPath, err)
+			maxSize := limits.remaining(totalSize)
+			var written int64
+			if header.Size <= maxBufferedFileSize {
+				var content bytes.Buffer
+				content.Grow(int(min(header.Size, maxSize) + 1))
+				if _, err := content.ReadFrom(io.LimitReader(tarReader, maxSize+1)); err != nil {
+					return markInvalidArchive(fmt.Errorf("failed to read file %s: %w", targetPath, err))
+				}
+				written = int64(content.Len())
+				if written <= maxSize {
+					if err := files.write(targetPath, content.Bytes(), os.FileMode(header.Mode)); err != nil {
+						return err
+					}
+				}
+			} else {
+				if written, err = writeFile(targetPath, io.LimitReader(tarReader, maxSize+1), os.FileMode(header.Mode)); err != nil {
+					return markInvalidArchive(err)
+				}
 			}
+			if written > maxSize {
+				return fmt.Errorf("%w (%d bytes total, %d bytes per file) at file %s", ErrLimitExceeded, limits.MaxTotalSize, limits.MaxFileSize, relativePath)
+			}
+			totalSize += written
+		case tar.TypeLink:
+			// Hard links point to another entry of the archive, so their target has the same root folder
+			linkParts := strings.Split(header.Linkname, "/")
+			linkPath := filepath.Join(destDir, strings.Join(linkParts[1:], "/"))
+			if filepath.IsAbs(header.Linkname) || len(linkParts) <= 1 || !isWithinDir(destDir, linkPath) {
+				return fmt.Errorf("%w: hard link in tar file is pointing outside of destination directory: %s -> %s", ErrInvalidArchive, relativePath, header.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+				return fmt.Errorf("failed to create parent directory for %s: %w", targetPath, err)
+			}
+			// The file linked to must be fully written first
+			if err := files.wait(); err != nil {
+				return err
+			}
+			if err := os.Link(linkPath, targetPath); err != nil {
+				return fmt.Errorf("failed to create hard link %s: %w", targetPath, err)
+			}
+		case tar.TypeSymlink:
+			// Symlinks are never recreated: once a link exists on disk, later entries could be written through it,
+			// and there is no reliable way to check in advance where a chain of links resolves to.
+			log.Warn().Str("path", relativePath).Str("target", header.Linkname).Msg("Skipping symlink in tar file")
 		}
 	}
 
 	return nil
 }
+
+// fileWriter writes files concurrently, with a bounded number of workers.
+// Their parent directories must exist before they are handed to it.
+type fileWriter struct {
+	workers int
+	group   *errgroup.Group
+	ctx     context.Context // Cancelled as soon as a write fails
+	pending map[string]bool // Paths of the files handed to the workers since the last wait
+}
+
+func newFileWriter(workers int) *fileWriter {
+	w := &fileWriter{workers: workers}
+	w.reset()
+
+	return w
+}
+
+func (w *fileWriter) reset() {
+	w.group, w.ctx = errgroup.WithContext(context.Background())
+	w.group.SetLimit(w.workers)
+	w.pending = make(map[string]bool)
+}
+
+// write writes the content to the file at the given path in the background, blocking while all the workers are busy.
+// If a previous write failed, nothing is written and its error is returned instead.
+func (w *fileWriter) write(path string, content []byte, mode os.FileMode) error {
+	if w.ctx.Err() != nil {
+		return w.wait()
+	}
+
+	w.pending[path] = true
+	w.group.Go(func() error {
+		_, err := writeWorkerFile(path, bytes.NewReader(content), mode)
+		return err
+	})
+
+	return nil
+}
+
+// waitFor waits for all the files being written if the file at the given path is one of them
+func (w *fileWriter) waitFor(path string) error {
+	if !w.pending[path] {
+		return nil
+	}
+
+	return w.wait()
+}
+
+// wait waits for all the files being written, and returns the first error writing them
+func (w *fileWriter) wait() error {
+	err := w.group.Wait()
+	w.reset()
+
+	return err
+}
+
+// writeFile creates the file at the given path, or truncates it, with the content of the reader.
+// It returns the number of bytes written.
+func writeFile(path string, r io.Reader, mode os.FileMode) (written int64, err error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, mode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close file %s: %w", path, cerr)
+		}
+	}()
+
+	if written, err = io.Copy(file, r); err != nil {
+		return written, fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+
+	return written, nil
+}
+
+// markInvalidArchive wraps ErrInvalidArchive in the error reading the archive if it is caused by its malformed content.
+// Other errors, e.g. of the network from which the archive is streamed, may not happen again.
+func markInvalidArchive(err error) error {
+	var corrupt flate.CorruptInputError
+	if errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum) || errors.Is(err, tar.ErrHeader) || errors.As(err, &corrupt) {
+		return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+
+	return err
+}
+
+// remaining returns the maximum number of bytes the next file may have,
+// given the number of bytes already extracted.
+func (l Limits) remaining(extracted int64) int64 {
+	remaining := int64(math.MaxInt64 - 1)
+	if l.MaxFileSize > 0 {
+		remaining = min(remaining, l.MaxFileSize)
+	}
+	if l.MaxTotalSize > 0 {
+		remaining = min(remaining, max(l.MaxTotalSize-extracted, 0))
+	}
+
+	return remaining
+}
+
+// isWithinDir returns whether path is strictly inside dir
+func isWithinDir(dir string, path string) bool {
+	return strings.HasPrefix(filepath.Clean(path), filepath.Clean(dir)+string(os.PathSeparator))
+}
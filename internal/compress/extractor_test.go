@@ -0,0 +1,333 @@
+package compress
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tarEntry struct {
+	header  tar.Header
+	content string
+}
+
+func createTarGz(t testing.TB, entries []tarEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzWriter)
+
+	for _, e := range entries {
+		e.header.Size = int64(len(e.content))
+		if e.header.Mode == 0 {
+			e.header.Mode = 0644
+		}
+		if err := tarWriter.WriteHeader(&e.header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+func TestExtractTarGz(t *testing.T) {
+	archive := createTarGz(t, []tarEntry{
+		{header: tar.Header{Name: "root/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{header: tar.Header{Name: "root/dir/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{header: tar.Header{Name: "root/dir/file.txt", Typeflag: tar.TypeReg}, content: "content"},
+	})
+	destDir := t.TempDir()
+
+	err := ExtractTarGz(archive, destDir, Limits{})
+
+	assert.Nil(t, err)
+	content, err := os.ReadFile(filepath.Join(destDir, "dir", "file.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(content))
+}
+
+func TestExtractTarGzSkipsSymlinks(t *testing.T) {
+	outsideDir := t.TempDir()
+	archive := createTarGz(t, []tarEntry{
+		{header: tar.Header{Name: "root/link", Typeflag: tar.TypeSymlink, Linkname: outsideDir}},
+		{header: tar.Header{Name: "root/relative-link", Typeflag: tar.TypeSymlink, Linkname: "../../.."}},
+		{header: tar.Header{Name: "root/link/evil.txt", Typeflag: tar.TypeReg}, content: "evil"},
+	})
+	destDir := t.TempDir()
+
+	err := ExtractTarGz(archive, destDir, Limits{})
+
+	assert.Nil(t, err)
+	// Nothing was written through the symlink
+	assert.NoFileExists(t, filepath.Join(outsideDir, "evil.txt"))
+	_, err = os.Lstat(filepath.Join(destDir, "relative-link"))
+	assert.True(t, os.IsNotExist(err))
+	info, err := os.Lstat(filepath.Join(destDir, "link"))
+	assert.Nil(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestExtractTarGzCreatesHardLinks(t *testing.T) {
+	archive := createTarGz(t, []tarEntry{
+		{header: tar.Header{Name: "root/file.txt", Typeflag: tar.TypeReg}, content: "content"},
+		{header: tar.Header{Name: "root/link.txt", Typeflag: tar.TypeLink, Linkname: "root/file.txt"}},
+	})
+	destDir := t.TempDir()
+
+	err := ExtractTarGz(archive, destDir, Limits{})
+
+	assert.Nil(t, err)
+	content, err := os.ReadFile(filepath.Join(destDir, "link.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(content))
+}
+
+func TestExtractTarGzRejectsHardLinksOutsideDestination(t *testing.T) {
+	testCases := map[string]string{
+		"relative escape": "root/../../outside.txt",
+		"absolute":        "/etc/passwd",
+		"no root folder":  "file.txt",
+	}
+
+	for name, linkname := range testCases {
+		t.Run(name, func(t *testing.T) {
+			archive := createTarGz(t, []tarEntry{
+				{header: tar.Header{Name: "root/link.txt", Typeflag: tar.TypeLink, Linkname: linkname}},
+			})
+
+			err := ExtractTarGz(archive, t.TempDir(), Limits{})
+
+			assert.ErrorIs(t, err, ErrInvalidArchive)
+			assert.ErrorContains(t, err, "outside of destination directory")
+		})
+	}
+}
+
+func TestExtractTarGzRejectsPathTraversal(t *testing.T) {
+	archive := createTarGz(t, []tarEntry{
+		{header: tar.Header{Name: "root/../../evil.txt", Typeflag: tar.TypeReg}, content: "evil"},
+	})
+
+	err := ExtractTarGz(archive, t.TempDir(), Limits{})
+
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+	assert.ErrorContains(t, err, "outside of destination directory")
+}
+
+func TestExtractTarGzRejectsMalformedArchives(t *testing.T) {
+	valid := createTarGz(t, []tarEntry{{header: tar.Header{Name: "root/a.txt", Typeflag: tar.TypeReg}, content: "content"}}).Bytes()
+	var corruptTar bytes.Buffer
+	gzWriter := gzip.NewWriter(&corruptTar)
+	_, _ = gzWriter.Write(bytes.Repeat([]byte("not a tar header"), 64))
+	_ = gzWriter.Close()
+	corruptData := bytes.Clone(valid)
+	corruptData[12] ^= 0xff
+
+	testCases := map[string][]byte{
+		"not gzip":     []byte("not a tar.gz archive"),
+		"corrupt tar":  corruptTar.Bytes(),
+		"corrupt data": corruptData,
+	}
+
+	for name, archive := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := ExtractTarGz(bytes.NewReader(archive), t.TempDir(), Limits{})
+
+			assert.ErrorIs(t, err, ErrInvalidArchive)
+		})
+	}
+}
+
+func TestExtractTarGzDoesNotMarkReadErrorsAsInvalidArchive(t *testing.T) {
+	valid := createTarGz(t, []tarEntry{{header: tar.Header{Name: "root/a.txt", Typeflag: tar.TypeReg}, content: "content"}}).Bytes()
+	readErr := errors.New("connection reset by peer")
+
+	err := ExtractTarGz(io.MultiReader(bytes.NewReader(valid[:len(valid)/2]), iotest.ErrReader(readErr)), t.TempDir(), Limits{})
+
+	assert.ErrorIs(t, err, readErr)
+	assert.NotErrorIs(t, err, ErrInvalidArchive)
+}
+
+func TestExtractTarGzRejectsArchivesExceedingLimits(t *testing.T) {
+	testCases := map[string]Limits{
+		"total size": {MaxTotalSize: 10, MaxFileSize: 8},
+		"file size":  {MaxTotalSize: 100, MaxFileSize: 5},
+	}
+
+	for name, limits := range testCases {
+		t.Run(name, func(t *testing.T) {
+			archive := createTarGz(t, []tarEntry{
+				{header: tar.Header{Name: "root/a.txt", Typeflag: tar.TypeReg}, content: "123456"},
+				{header: tar.Header{Name: "root/b.txt", Typeflag: tar.TypeReg}, content: "123456"},
+			})
+
+			err := ExtractTarGz(archive, t.TempDir(), limits)
+
+			assert.ErrorContains(t, err, "exceeds the maximum size")
+		})
+	}
+}
+
+func TestExtractTarGzWithinLimits(t *testing.T) {
+	archive := createTarGz(t, []tarEntry{
+		{header: tar.Header{Name: "root/a.txt", Typeflag: tar.TypeReg}, content: "123456"},
+		{header: tar.Header{Name: "root/b.txt", Typeflag: tar.TypeReg}, content: "123456"},
+	})
+
+	err := ExtractTarGz(archive, t.TempDir(), Limits{MaxTotalSize: 12, MaxFileSize: 6})
+
+	assert.Nil(t, err)
+}
+
+func TestNewLimits(t *testing.T) {
+	assert.Equal(t, Limits{MaxTotalSize: 2 << 30, MaxFileSize: 512 << 20}, NewLimits(2<<30))
+	assert.Equal(t, Limits{MaxTotalSize: 1 << 20, MaxFileSize: 1 << 20}, NewLimits(1<<20))
+}
+
+// createLargeTarGz creates an archive of a monorepo-like project with the given number of small files,
+// spread over nested directories
+func createLargeTarGz(t testing.TB, files int) *bytes.Buffer {
+	entries := []tarEntry{{header: tar.Header{Name: "root/", Typeflag: tar.TypeDir, Mode: 0755}}}
+	for i := range files {
+		dir := fmt.Sprintf("root/packages/pkg-%d/src/", i/100)
+		if i%100 == 0 {
+			entries = append(entries,
+				tarEntry{header: tar.Header{Name: fmt.Sprintf("root/packages/pkg-%d/", i/100), Typeflag: tar.TypeDir, Mode: 0755}},
+				tarEntry{header: tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0755}},
+			)
+		}
+		entries = append(entries, tarEntry{header: tar.Header{Name: fmt.Sprintf("%vfile-%d.js", dir, i), Typeflag: tar.TypeReg}, content: strings.Repeat(fmt.Sprintf("// file %d\n", i), 50)})
+	}
+
+	return createTarGz(t, entries)
+}
+
+func TestExtractTarGzLargeArchive(t *testing.T) {
+	const files = 5000
+	archive := createLargeTarGz(t, files)
+	destDir := t.TempDir()
+
+	err := ExtractTarGz(archive, destDir, Limits{})
+
+	require.NoError(t, err)
+	for i := range files {
+		content, err := os.ReadFile(filepath.Join(destDir, "packages", fmt.Sprintf("pkg-%d", i/100), "src", fmt.Sprintf("file-%d.js", i)))
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat(fmt.Sprintf("// file %d\n", i), 50), string(content))
+	}
+}
+
+func TestExtractTarGzBoundsConcurrentWrites(t *testing.T) {
+	originalWorkers, originalWrite := extractWorkers, writeWorkerFile
+	defer func() { extractWorkers, writeWorkerFile = originalWorkers, originalWrite }()
+	extractWorkers = 4
+
+	var running, maxRunning atomic.Int32
+	writeWorkerFile = func(path string, r io.Reader, mode os.FileMode) (int64, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			current := maxRunning.Load()
+			if n <= current || maxRunning.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		// Keep the write running long enough for the next files to be handed to the other workers
+		time.Sleep(2 * time.Millisecond)
+
+		return writeFile(path, r, mode)
+	}
+
+	err := ExtractTarGz(createLargeTarGz(t, 200), t.TempDir(), Limits{})
+
+	require.NoError(t, err)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(extractWorkers))
+	assert.Greater(t, maxRunning.Load(), int32(1), "the files should be written concurrently")
+}
+
+func TestExtractTarGzLastDuplicatedEntryWins(t *testing.T) {
+	archive := createTarGz(t, []tarEntry{
+		{header: tar.Header{Name: "root/file.txt", Typeflag: tar.TypeReg}, content: "first"},
+		{header: tar.Header{Name: "root/other.txt", Typeflag: tar.TypeReg}, content: "other"},
+		{header: tar.Header{Name: "root/file.txt", Typeflag: tar.TypeReg}, content: "second"},
+	})
+	destDir := t.TempDir()
+
+	err := ExtractTarGz(archive, destDir, Limits{})
+
+	assert.Nil(t, err)
+	content, err := os.ReadFile(filepath.Join(destDir, "file.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "second", string(content))
+}
+
+func TestExtractTarGzWritesLargeFiles(t *testing.T) {
+	large := strings.Repeat("x", int(maxBufferedFileSize)+1)
+	archive := createTarGz(t, []tarEntry{
+		{header: tar.Header{Name: "root/small.txt", Typeflag: tar.TypeReg}, content: "small"},
+		{header: tar.Header{Name: "root/large.txt", Typeflag: tar.TypeReg}, content: large},
+	})
+	destDir := t.TempDir()
+
+	err := ExtractTarGz(archive, destDir, Limits{})
+
+	assert.Nil(t, err)
+	content, err := os.ReadFile(filepath.Join(destDir, "large.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, large, string(content))
+
+	err = ExtractTarGz(createTarGz(t, []tarEntry{{header: tar.Header{Name: "root/large.txt", Typeflag: tar.TypeReg}, content: large}}), t.TempDir(), Limits{MaxFileSize: maxBufferedFileSize})
+	assert.ErrorContains(t, err, "exceeds the maximum size")
+}
+
+func TestExtractTarGzFailsIfAFileCannotBeWritten(t *testing.T) {
+	archive := createTarGz(t, []tarEntry{
+		{header: tar.Header{Name: "root/dir/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{header: tar.Header{Name: "root/dir", Typeflag: tar.TypeReg}, content: "not a directory"},
+		{header: tar.Header{Name: "root/file.txt", Typeflag: tar.TypeReg}, content: "content"},
+	})
+
+	err := ExtractTarGz(archive, t.TempDir(), Limits{})
+
+	assert.ErrorContains(t, err, "failed to create file")
+}
+
+func BenchmarkExtractTarGz(b *testing.B) {
+	archive := createLargeTarGz(b, 10000).Bytes()
+
+	for _, workers := range []int{1, max(extractWorkers, 4)} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			originalWorkers := extractWorkers
+			extractWorkers = workers
+			defer func() { extractWorkers = originalWorkers }()
+
+			for range b.N {
+				if err := ExtractTarGz(bytes.NewReader(archive), b.TempDir(), Limits{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
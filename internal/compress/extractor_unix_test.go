@@ -0,0 +1,59 @@
+//go:build unix
+
+package compress
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractTarGzReleasesFileDescriptors(t *testing.T) {
+	testCases := map[string]struct {
+		files   int
+		content string
+	}{
+		"files written by the workers":   {files: 1000, content: "content"},
+		"files written as they are read": {files: 80, content: strings.Repeat("a", int(maxBufferedFileSize)+1)},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			entries := make([]tarEntry, 0, tc.files)
+			for i := range tc.files {
+				entries = append(entries, tarEntry{header: tar.Header{Name: fmt.Sprintf("root/file-%d.txt", i), Typeflag: tar.TypeReg}, content: tc.content})
+			}
+			archive := createTarGz(t, entries)
+			destDir := t.TempDir()
+
+			// Fewer descriptors than files, so the extraction fails if the files are only closed at the end
+			var limit syscall.Rlimit
+			require.NoError(t, syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit))
+			lowered := limit
+			lowered.Cur = 64
+			require.NoError(t, syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lowered))
+			defer func() { _ = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit) }()
+
+			originalWorkers := extractWorkers
+			extractWorkers = 4
+			defer func() { extractWorkers = originalWorkers }()
+
+			err := ExtractTarGz(archive, destDir, Limits{})
+
+			assert.NoError(t, err)
+			extracted, err := filepath.Glob(filepath.Join(destDir, "*.txt"))
+			assert.NoError(t, err)
+			assert.Len(t, extracted, tc.files)
+			content, err := os.ReadFile(filepath.Join(destDir, fmt.Sprintf("file-%d.txt", tc.files-1)))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.content, string(content))
+		})
+	}
+}
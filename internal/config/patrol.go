@@ -4,11 +4,80 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"sheriff/internal/repository"
+	"slices"
+	"strings"
+	"time"
 
 	zerolog "github.com/rs/zerolog/log"
 )
 
+// defaultMaxConcurrency is the default number of projects scanned at the same time
+const defaultMaxConcurrency = 4
+
+// defaultMaxArchiveSize is the default maximum size in megabytes of a downloaded project once extracted
+const defaultMaxArchiveSize = 2048
+
+// defaultCloneRetries is the default number of times a failed project download is retried
+const defaultCloneRetries = 2
+
+// severityThresholds are the severity kinds that can be used as a threshold.
+// They mirror the scanner.SeverityScoreKind values, which cannot be imported here.
+var severityThresholds = []string{"CRITICAL", "HIGH", "MODERATE", "LOW"}
+
+// scannerNames are the vulnerability scanners that can be run on the projects, the first one being the default.
+// They mirror the scanner.ScannerNames values, which cannot be imported here.
+var scannerNames = []string{osvScannerName, "trivy"}
+
+// osvScannerName is the name of the osv-scanner, the only one checking the licenses
+const osvScannerName = "osv"
+
+// defaultSeverityScoreThresholds are the default lower bounds (inclusive) of the CVSS score of each severity kind.
+// They mirror the scanner.SeverityScoreThresholds values, which cannot be imported here.
+var defaultSeverityScoreThresholds = SeverityScoreThresholds{Critical: 9.0, High: 8.0, Moderate: 3.0, Low: 0.0}
+
+// slackChannelIdRegex matches the slack channels given by their ID, e.g. `id:C0123ABCD`, instead of their name.
+// The prefix mirrors the slack.ChannelIdPrefix value.
+var slackChannelIdRegex = regexp.MustCompile(`^id:[CG][A-Z0-9]+$`)
+
+// outputFormats are the formats in which the report can be printed to the console
+var outputFormats = []string{"human", "json"}
+
+// consoleDetails are the levels of detail of the report printed to the console in the human format, the default first
+var consoleDetails = []string{"full", "projects", "summary"}
+
+// Kinds of destinations the report can be written to, see ReportDestination
+const (
+	ReportToJUnit     = "junit"
+	ReportToHtml      = "html"
+	ReportToPagerDuty = "pagerduty"
+	ReportToVex       = "vex"
+)
+
+// reportDestinationKinds are the supported kinds of report destinations
+var reportDestinationKinds = []string{ReportToJUnit, ReportToHtml, ReportToPagerDuty, ReportToVex}
+
+// targetlessReportDestinationKinds are the kinds of report destinations which do not write to a target file
+var targetlessReportDestinationKinds = []string{ReportToPagerDuty}
+
+// ReportDestination is a destination the report is written to, given as `kind:target` (e.g. `junit:report.xml`)
+type ReportDestination struct {
+	Kind   string
+	Target string // e.g. the path of the file to write the report to, empty for targetless kinds
+}
+
+// SeverityScoreThresholds are the lower bounds (inclusive) of the CVSS score of each severity kind
+type SeverityScoreThresholds struct {
+	Critical float64
+	High     float64
+	Moderate float64
+	Low      float64
+}
+
 type ProjectLocation struct {
 	Type repository.RepositoryType
 	Path string
@@ -17,12 +86,58 @@ type ProjectLocation struct {
 type PatrolConfig struct {
 	Locations             []ProjectLocation
 	Ignored               []ProjectLocation
+	ExcludePaths          []string
+	IncludeArchived       bool
+	IncludeForks          bool
+	MaxConcurrency        int
+	CloneRetries          int
+	MaxArchiveSize        int
+	Scanners              []string
+	AllowedLicenses       []string
+	ScanPaths             []string
+	IgnorePaths           []string
+	IgnoredVulns          []string
+	EnableEpss            bool
 	ReportToEmails        []string
 	ReportToSlackChannels []string
 	ReportToIssue         bool
+	IssueTitle            string
+	IssueAssignees        []string
+	IssueTemplate         string           // Path to a Go text/template file rendering the body of the issue, see publish.ParseIssueTemplate
+	CentralIssue          *ProjectLocation // Project of the single issue listing all the vulnerable projects, instead of an issue in each of them, if set
+	CloseIssueComment     bool
+	AttachRawReport       bool // Upload the raw osv-scanner report of each vulnerable project and link it from its issue
+	CloseAfterCleanCount  int  // Number of consecutive clean runs after which the issue is closed, see publish.IssueOpts
 	EnableProjectReportTo bool
+	EnableProjectIssue    bool // Let projects enable or disable their issue in their configuration, see publish.IssueOpts
+	ReportSlackDelta      bool
+	ReportSlackThread     bool
+	ReportSlackActions    bool
+	ReportSlackNewOnly    bool // Only list the vulnerabilities new since the previous issue in the project slack channels
+	VerboseIssue          bool
+	SeverityThreshold     string
+	SeverityScores        SeverityScoreThresholds
 	SilentReport          bool
+	StateFile             string
+	Incremental           bool
+	Progress              bool
+	MetricsFile           string
+	ProjectTimeout        time.Duration
+	TmpDir                string
+	KeepScansOnFailure    bool
+	StrictConfig          bool
+	FailOnVulnerabilities bool
+	FailOnLicense         bool
+	FailOnSeverity        string
+	OutputFormat          string
+	ConsoleDetail         string
+	ReportDestinations    []ReportDestination
+	PagerDutyRoutingKey   string `json:"-"` // Secret, never logged
+	DryRun                bool
 	Verbose               bool
+	BaseRef               string // Branch compared against in diff mode, the default branch of the projects if empty
+	TargetRef             string // Branch compared in diff mode, which is enabled if it is set
+	DiffFile              string // File where the diff is written in diff mode, if set
 }
 
 // Options common in both the CLI options & file options
@@ -31,28 +146,83 @@ type PatrolReportToOpts struct {
 	SlackChannels         *[]string `toml:"slack-channels"`
 	Issue                 *bool     `toml:"issue"`
 	EnableProjectReportTo *bool     `toml:"enable-project-report-to"`
+	EnableProjectIssue    *bool     `toml:"enable-project-issue"`
 }
 
 type PatrolReportOpts struct {
-	SilentReport *bool              `toml:"silent"`
-	To           PatrolReportToOpts `toml:"to"`
+	SilentReport         *bool              `toml:"silent"`
+	SlackDelta           *bool              `toml:"slack-delta"`
+	SlackThread          *bool              `toml:"slack-thread"`
+	SlackActions         *bool              `toml:"slack-actions"`
+	SlackNewOnly         *bool              `toml:"slack-new-only"`
+	VerboseIssue         *bool              `toml:"verbose-issue"`
+	IssueTitle           *string            `toml:"issue-title"`
+	IssueAssignees       *[]string          `toml:"issue-assignees"`
+	IssueTemplate        *string            `toml:"issue-template"`
+	CentralIssue         *string            `toml:"central-issue"`
+	CloseIssueComment    *bool              `toml:"close-issue-comment"`
+	AttachRawReport      *bool              `toml:"attach-raw-report"`
+	CloseAfterCleanCount *int               `toml:"close-after-clean-count"`
+	SeverityThreshold    *string            `toml:"severity-threshold"`
+	To                   PatrolReportToOpts `toml:"to"`
 }
 
 type PatrolCommonOpts struct {
-	Targets *[]string        `toml:"targets"`
-	Ignored *[]string        `toml:"ignored"`
-	Report  PatrolReportOpts `toml:"report"`
+	Targets         *[]string        `toml:"targets"`
+	Ignored         *[]string        `toml:"ignored"`
+	ExcludePaths    *[]string        `toml:"exclude-paths"`
+	IncludeArchived *bool            `toml:"include-archived"`
+	IncludeForks    *bool            `toml:"include-forks"`
+	MaxConcurrency  *int             `toml:"max-concurrency"`
+	CloneRetries    *int             `toml:"clone-retries"`
+	MaxArchiveSize  *int             `toml:"max-archive-size"`
+	Scanners        *[]string        `toml:"scanners"`
+	AllowedLicenses *[]string        `toml:"allowed-licenses"`
+	ScanPaths       *[]string        `toml:"scan-paths"`
+	IgnorePaths     *[]string        `toml:"ignore-paths"`
+	IgnoreVulns     *[]string        `toml:"ignore-vulns"`
+	IgnoreFile      *string          `toml:"ignore-file"`
+	EnableEpss      *bool            `toml:"enable-epss"`
+	Report          PatrolReportOpts `toml:"report"`
 }
 
 // PatrolCLIOpts are the options only available from CLI configuration
 type PatrolCLIOpts struct {
-	Config  string
-	Verbose bool
+	Config                string
+	Verbose               bool
+	StateFile             string
+	Incremental           bool
+	Progress              bool
+	MetricsFile           string
+	ProjectTimeout        time.Duration
+	TmpDir                string
+	KeepScansOnFailure    bool
+	StrictConfig          bool
+	FailOnVulnerabilities bool
+	FailOnLicense         bool
+	FailOnSeverity        string
+	OutputFormat          string
+	ConsoleDetail         string
+	ReportTo              []string
+	PagerDutyRoutingKey   string `json:"-"` // Secret, never logged
+	DryRun                bool
+	BaseRef               string
+	TargetRef             string
+	DiffFile              string
 	PatrolCommonOpts
 }
 
+// PatrolSeverityOpts override the lower bounds of the CVSS score of each severity kind
+type PatrolSeverityOpts struct {
+	Critical *float64 `toml:"critical"`
+	High     *float64 `toml:"high"`
+	Moderate *float64 `toml:"moderate"`
+	Low      *float64 `toml:"low"`
+}
+
 // PatrolFileOpts are the options only available from File configuration
 type PatrolFileOpts struct {
+	Severity PatrolSeverityOpts `toml:"severity"`
 	PatrolCommonOpts
 }
 
@@ -60,13 +230,16 @@ func GetPatrolConfiguration(cliOpts PatrolCLIOpts) (config PatrolConfig, err err
 	zerolog.Debug().Interface("cli options", cliOpts).Msg("Running with cli options")
 	var tomlOpts PatrolFileOpts
 	if cliOpts.Config != "" {
-		found, err := getTOMLFile(cliOpts.Config, &tomlOpts)
+		found, undecoded, err := getTOMLFile(cliOpts.Config, &tomlOpts)
 		if !found {
 			zerolog.Info().Msg("No configuration file found, running with CLI options only")
 		}
 		if err != nil {
 			return config, errors.Join(errors.New("failed to parse patrol configuration file"), err)
 		}
+		if len(undecoded) > 0 {
+			zerolog.Warn().Strs("keys", undecoded).Msg("Found undecoded keys in TOML file")
+		}
 
 		zerolog.Debug().Interface("file config", tomlOpts).Msg("Running with file configuration")
 	}
@@ -94,15 +267,211 @@ func mergeConfigs(cliOpts PatrolCLIOpts, fileOpts PatrolFileOpts) (config Patrol
 		return config, errors.Join(errors.New("could not parse targets from CLI options"), err)
 	}
 
+	var centralIssue *ProjectLocation
+	if target := getCliOrFileOption(cliOpts.Report.CentralIssue, fileOpts.Report.CentralIssue, ""); target != "" {
+		location, err := ParseProjectLocation(target)
+		if err != nil {
+			return config, errors.Join(errors.New("invalid central issue project"), err)
+		}
+		centralIssue = &location
+	}
+
+	excludePaths := getCliOrFileOption(cliOpts.ExcludePaths, fileOpts.ExcludePaths, []string{})
+	for _, p := range excludePaths {
+		if _, err := path.Match(p, ""); err != nil {
+			return config, fmt.Errorf("invalid exclude path, %v is not a valid glob pattern", p)
+		}
+	}
+
+	severityThreshold, err := parseSeverityThreshold(getCliOrFileOption(cliOpts.Report.SeverityThreshold, fileOpts.Report.SeverityThreshold, ""))
+	if err != nil {
+		return config, err
+	}
+
+	severityScores, err := parseSeverityScoreThresholds(fileOpts.Severity)
+	if err != nil {
+		return config, errors.Join(errors.New("invalid severity thresholds"), err)
+	}
+
+	maxConcurrency := getCliOrFileOption(cliOpts.MaxConcurrency, fileOpts.MaxConcurrency, defaultMaxConcurrency)
+	if maxConcurrency < 1 {
+		return config, fmt.Errorf("max concurrency must be at least 1, got %v", maxConcurrency)
+	}
+
+	cloneRetries := getCliOrFileOption(cliOpts.CloneRetries, fileOpts.CloneRetries, defaultCloneRetries)
+	if cloneRetries < 0 {
+		return config, fmt.Errorf("clone retries cannot be negative, got %v", cloneRetries)
+	}
+
+	maxArchiveSize := getCliOrFileOption(cliOpts.MaxArchiveSize, fileOpts.MaxArchiveSize, defaultMaxArchiveSize)
+	if maxArchiveSize < 1 {
+		return config, fmt.Errorf("max archive size must be at least 1MB, got %v", maxArchiveSize)
+	}
+
+	scanners, err := parseScanners(getCliOrFileOption(cliOpts.Scanners, fileOpts.Scanners, []string{}))
+	if err != nil {
+		return config, errors.Join(errors.New("invalid scanners"), err)
+	}
+
+	scanPaths := getCliOrFileOption(cliOpts.ScanPaths, fileOpts.ScanPaths, []string{})
+	if err := validatePathPatterns(scanPaths); err != nil {
+		return config, errors.Join(errors.New("invalid scan paths"), err)
+	}
+
+	ignorePaths := getCliOrFileOption(cliOpts.IgnorePaths, fileOpts.IgnorePaths, []string{})
+	if err := validatePathPatterns(ignorePaths); err != nil {
+		return config, errors.Join(errors.New("invalid ignore paths"), err)
+	}
+
+	ignoredVulns, err := getIgnoredVulns(
+		getCliOrFileOption(cliOpts.IgnoreVulns, fileOpts.IgnoreVulns, []string{}),
+		getCliOrFileOption(cliOpts.IgnoreFile, fileOpts.IgnoreFile, ""),
+	)
+	if err != nil {
+		return config, errors.Join(errors.New("invalid ignored vulnerabilities"), err)
+	}
+
+	issueTitle := strings.TrimSpace(getCliOrFileOption(cliOpts.Report.IssueTitle, fileOpts.Report.IssueTitle, repository.VulnerabilityIssueTitle))
+	if issueTitle == "" {
+		return config, errors.New("issue title cannot be empty")
+	}
+
+	failOnSeverity, err := parseSeverityThreshold(cliOpts.FailOnSeverity)
+	if err != nil {
+		return config, errors.Join(errors.New("invalid severity to fail on"), err)
+	}
+
+	outputFormat := cliOpts.OutputFormat
+	if outputFormat == "" {
+		outputFormat = outputFormats[0]
+	} else if !slices.Contains(outputFormats, outputFormat) {
+		return config, fmt.Errorf("unknown output format %v, must be one of %v", outputFormat, strings.Join(outputFormats, ", "))
+	}
+
+	consoleDetail := cliOpts.ConsoleDetail
+	if consoleDetail == "" {
+		consoleDetail = consoleDetails[0]
+	} else if !slices.Contains(consoleDetails, consoleDetail) {
+		return config, fmt.Errorf("unknown console detail %v, must be one of %v", consoleDetail, strings.Join(consoleDetails, ", "))
+	}
+
+	reportDestinations, err := parseReportDestinations(cliOpts.ReportTo)
+	if err != nil {
+		return config, errors.Join(errors.New("invalid report destination"), err)
+	}
+
+	slackChannels := getCliOrFileOption(cliOpts.Report.To.SlackChannels, fileOpts.Report.To.SlackChannels, []string{})
+	if err := validateSlackChannels(slackChannels); err != nil {
+		return config, err
+	}
+
 	config = PatrolConfig{
 		Locations:             parsedLocations,
+		ExcludePaths:          excludePaths,
+		IncludeArchived:       getCliOrFileOption(cliOpts.IncludeArchived, fileOpts.IncludeArchived, false),
+		IncludeForks:          getCliOrFileOption(cliOpts.IncludeForks, fileOpts.IncludeForks, false),
+		MaxConcurrency:        maxConcurrency,
+		CloneRetries:          cloneRetries,
+		MaxArchiveSize:        maxArchiveSize,
+		Scanners:              scanners,
+		AllowedLicenses:       parseLicenses(getCliOrFileOption(cliOpts.AllowedLicenses, fileOpts.AllowedLicenses, []string{})),
+		ScanPaths:             scanPaths,
+		IgnorePaths:           ignorePaths,
+		IgnoredVulns:          ignoredVulns,
+		EnableEpss:            getCliOrFileOption(cliOpts.EnableEpss, fileOpts.EnableEpss, false),
 		ReportToIssue:         getCliOrFileOption(cliOpts.Report.To.Issue, fileOpts.Report.To.Issue, false),
+		IssueTitle:            issueTitle,
+		IssueTemplate:         getCliOrFileOption(cliOpts.Report.IssueTemplate, fileOpts.Report.IssueTemplate, ""),
+		CentralIssue:          centralIssue,
+		IssueAssignees:        parseAssignees(getCliOrFileOption(cliOpts.Report.IssueAssignees, fileOpts.Report.IssueAssignees, []string{})),
 		ReportToEmails:        getCliOrFileOption(cliOpts.Report.To.Emails, fileOpts.Report.To.Emails, []string{}),
-		ReportToSlackChannels: getCliOrFileOption(cliOpts.Report.To.SlackChannels, fileOpts.Report.To.SlackChannels, []string{}),
+		ReportToSlackChannels: slackChannels,
+		CloseIssueComment:     getCliOrFileOption(cliOpts.Report.CloseIssueComment, fileOpts.Report.CloseIssueComment, true),
+		AttachRawReport:       getCliOrFileOption(cliOpts.Report.AttachRawReport, fileOpts.Report.AttachRawReport, false),
+		CloseAfterCleanCount:  getCliOrFileOption(cliOpts.Report.CloseAfterCleanCount, fileOpts.Report.CloseAfterCleanCount, 1),
 		EnableProjectReportTo: getCliOrFileOption(cliOpts.Report.To.EnableProjectReportTo, fileOpts.Report.To.EnableProjectReportTo, false),
+		EnableProjectIssue:    getCliOrFileOption(cliOpts.Report.To.EnableProjectIssue, fileOpts.Report.To.EnableProjectIssue, false),
+		ReportSlackDelta:      getCliOrFileOption(cliOpts.Report.SlackDelta, fileOpts.Report.SlackDelta, false),
+		ReportSlackThread:     getCliOrFileOption(cliOpts.Report.SlackThread, fileOpts.Report.SlackThread, true),
+		ReportSlackActions:    getCliOrFileOption(cliOpts.Report.SlackActions, fileOpts.Report.SlackActions, false),
+		ReportSlackNewOnly:    getCliOrFileOption(cliOpts.Report.SlackNewOnly, fileOpts.Report.SlackNewOnly, false),
+		VerboseIssue:          getCliOrFileOption(cliOpts.Report.VerboseIssue, fileOpts.Report.VerboseIssue, true),
+		SeverityThreshold:     severityThreshold,
+		SeverityScores:        severityScores,
 		SilentReport:          getCliOrFileOption(cliOpts.Report.SilentReport, fileOpts.Report.SilentReport, false),
+		StateFile:             cliOpts.StateFile,
+		Incremental:           cliOpts.Incremental,
+		Progress:              cliOpts.Progress,
+		MetricsFile:           cliOpts.MetricsFile,
+		ProjectTimeout:        cliOpts.ProjectTimeout,
+		TmpDir:                cliOpts.TmpDir,
+		KeepScansOnFailure:    cliOpts.KeepScansOnFailure,
+		StrictConfig:          cliOpts.StrictConfig,
+		FailOnVulnerabilities: cliOpts.FailOnVulnerabilities,
+		FailOnLicense:         cliOpts.FailOnLicense,
+		FailOnSeverity:        failOnSeverity,
+		OutputFormat:          outputFormat,
+		ConsoleDetail:         consoleDetail,
+		ReportDestinations:    reportDestinations,
+		PagerDutyRoutingKey:   cliOpts.PagerDutyRoutingKey,
+		DryRun:                cliOpts.DryRun,
 		Verbose:               cliOpts.Verbose,
 		Ignored:               parsedIgnored,
+		BaseRef:               cliOpts.BaseRef,
+		TargetRef:             cliOpts.TargetRef,
+		DiffFile:              cliOpts.DiffFile,
+	}
+
+	if config.ReportSlackDelta && config.StateFile == "" {
+		return config, errors.New("reporting only the changes to slack requires a state file")
+	}
+
+	if config.CloseAfterCleanCount < 1 {
+		return config, fmt.Errorf("close after clean count must be at least 1, got %v", config.CloseAfterCleanCount)
+	}
+
+	if config.CloseAfterCleanCount > 1 && config.StateFile == "" {
+		return config, errors.New("closing issues after several clean runs requires a state file, in which the clean runs are counted")
+	}
+
+	if config.ReportSlackNewOnly && !config.ReportToIssue && !config.EnableProjectIssue {
+		return config, errors.New("reporting only the new vulnerabilities to slack requires issues, which they are compared against")
+	}
+
+	if config.CentralIssue != nil && !config.ReportToIssue {
+		return config, errors.New("a central issue requires reporting to issues")
+	}
+
+	if config.CentralIssue != nil && (config.EnableProjectIssue || config.ReportSlackNewOnly) {
+		return config, errors.New("a central issue cannot be combined with project issues, nor with reporting only the new vulnerabilities to slack")
+	}
+
+	if config.AttachRawReport && ((!config.ReportToIssue && !config.EnableProjectIssue) || config.CentralIssue != nil) {
+		return config, errors.New("attaching the raw osv-scanner report requires issues in the projects, it cannot be attached to a central issue")
+	}
+
+	if config.Incremental && config.StateFile == "" {
+		return config, errors.New("scanning only the changed projects requires a state file")
+	}
+
+	if config.TargetRef == "" && (config.BaseRef != "" || config.DiffFile != "") {
+		return config, errors.New("diffing the vulnerabilities of two refs requires a target ref")
+	}
+
+	if config.TargetRef != "" && config.Incremental {
+		return config, errors.New("diffing the vulnerabilities of two refs cannot be incremental")
+	}
+
+	if len(config.AllowedLicenses) > 0 && !slices.Contains(config.Scanners, osvScannerName) {
+		return config, errors.New("checking the licenses requires the osv scanner")
+	}
+
+	if config.FailOnLicense && len(config.AllowedLicenses) == 0 {
+		return config, errors.New("failing on license violations requires allowed licenses")
+	}
+
+	if config.PagerDutyRoutingKey == "" && slices.ContainsFunc(config.ReportDestinations, func(d ReportDestination) bool { return d.Kind == ReportToPagerDuty }) {
+		return config, errors.New("reporting to pagerduty requires a routing key")
 	}
 
 	return
@@ -121,6 +490,178 @@ func getCliOrFileOption[T interface{}](valueA *T, valueB *T, def T) (r T) {
 	return def
 }
 
+// parseSeverityThreshold validates the given severity threshold and normalizes it to upper case.
+// An empty threshold is valid, and means that vulnerabilities of any severity are relevant.
+func parseSeverityThreshold(threshold string) (string, error) {
+	if threshold == "" {
+		return "", nil
+	}
+
+	normalized := strings.ToUpper(threshold)
+	if !slices.Contains(severityThresholds, normalized) {
+		return "", fmt.Errorf("unknown severity threshold %v, must be one of %v", threshold, strings.Join(severityThresholds, ", "))
+	}
+
+	return normalized, nil
+}
+
+// parseSeverityScoreThresholds returns the severity score thresholds, with the defaults for those not overridden.
+// The thresholds must be within 0 and 10, and strictly decreasing from critical to low.
+func parseSeverityScoreThresholds(opts PatrolSeverityOpts) (SeverityScoreThresholds, error) {
+	thresholds := SeverityScoreThresholds{
+		Critical: getCliOrFileOption(nil, opts.Critical, defaultSeverityScoreThresholds.Critical),
+		High:     getCliOrFileOption(nil, opts.High, defaultSeverityScoreThresholds.High),
+		Moderate: getCliOrFileOption(nil, opts.Moderate, defaultSeverityScoreThresholds.Moderate),
+		Low:      getCliOrFileOption(nil, opts.Low, defaultSeverityScoreThresholds.Low),
+	}
+
+	ordered := []struct {
+		kind  string
+		value float64
+	}{
+		{"critical", thresholds.Critical},
+		{"high", thresholds.High},
+		{"moderate", thresholds.Moderate},
+		{"low", thresholds.Low},
+	}
+	for i, t := range ordered {
+		if t.value < 0 || t.value > 10 {
+			return thresholds, fmt.Errorf("%v threshold must be within 0 and 10, got %v", t.kind, t.value)
+		}
+
+		if i > 0 && t.value >= ordered[i-1].value {
+			return thresholds, fmt.Errorf("%v threshold (%v) must be lower than %v threshold (%v)", t.kind, t.value, ordered[i-1].kind, ordered[i-1].value)
+		}
+	}
+
+	return thresholds, nil
+}
+
+// parseScanners validates the given scanner names, normalizing them to lower case and removing duplicates.
+// If none is given, only the default scanner is run.
+func parseScanners(names []string) ([]string, error) {
+	scanners := make([]string, 0, len(names))
+	for _, n := range names {
+		normalized := strings.ToLower(strings.TrimSpace(n))
+		if !slices.Contains(scannerNames, normalized) {
+			return nil, fmt.Errorf("unknown scanner %v, must be one of %v", n, strings.Join(scannerNames, ", "))
+		}
+
+		if !slices.Contains(scanners, normalized) {
+			scanners = append(scanners, normalized)
+		}
+	}
+
+	if len(scanners) == 0 {
+		return []string{scannerNames[0]}, nil
+	}
+
+	return scanners, nil
+}
+
+// parseLicenses normalizes the given license identifiers, removing the empty ones
+func parseLicenses(licenses []string) []string {
+	parsed := make([]string, 0, len(licenses))
+	for _, l := range licenses {
+		if l = strings.TrimSpace(l); l != "" {
+			parsed = append(parsed, l)
+		}
+	}
+
+	return parsed
+}
+
+// parseAssignees normalizes the given usernames, removing the leading @ of mentions and empty names
+func parseAssignees(usernames []string) []string {
+	assignees := make([]string, 0, len(usernames))
+	for _, u := range usernames {
+		if u = strings.TrimPrefix(strings.TrimSpace(u), "@"); u != "" {
+			assignees = append(assignees, u)
+		}
+	}
+
+	return assignees
+}
+
+// validatePathPatterns checks that the given paths are valid glob patterns, relative to and within the project directory
+func validatePathPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %v", p)
+		}
+
+		if filepath.IsAbs(p) || !filepath.IsLocal(p) {
+			return fmt.Errorf("path %v must be relative to the project directory", p)
+		}
+	}
+
+	return nil
+}
+
+// getIgnoredVulns returns the given vulnerability ids together with those listed in the ignore file, if any, without duplicates.
+// The ignore file lists an id per line, where empty lines and lines starting with # are skipped.
+func getIgnoredVulns(ids []string, ignoreFile string) ([]string, error) {
+	all := slices.Clone(ids)
+	if ignoreFile != "" {
+		content, err := os.ReadFile(ignoreFile)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to read ignore file %v", ignoreFile), err)
+		}
+
+		all = append(all, strings.Split(string(content), "\n")...)
+	}
+
+	ignored := make([]string, 0, len(all))
+	for _, id := range all {
+		id = strings.TrimSpace(id)
+		if id == "" || strings.HasPrefix(id, "#") || slices.Contains(ignored, id) {
+			continue
+		}
+		ignored = append(ignored, id)
+	}
+
+	return ignored, nil
+}
+
+// validateSlackChannels checks that the slack channels given by their ID, rather than by their name, have a valid ID
+func validateSlackChannels(channels []string) error {
+	for _, c := range channels {
+		if strings.HasPrefix(c, "id:") && !slackChannelIdRegex.MatchString(c) {
+			return fmt.Errorf("invalid slack channel id %v, must be like id:C0123ABCD", c)
+		}
+	}
+
+	return nil
+}
+
+// parseReportDestinations parses the given `kind:target` report destinations
+func parseReportDestinations(destinations []string) (parsed []ReportDestination, err error) {
+	for _, d := range destinations {
+		kind, target, _ := strings.Cut(d, ":")
+		if !slices.Contains(reportDestinationKinds, kind) {
+			return nil, fmt.Errorf("unknown report destination %v, must be one of %v", kind, strings.Join(reportDestinationKinds, ", "))
+		}
+
+		if target == "" && !slices.Contains(targetlessReportDestinationKinds, kind) {
+			return nil, fmt.Errorf("report destination %v is missing a file path, e.g. %v:report.xml", d, kind)
+		}
+
+		parsed = append(parsed, ReportDestination{Kind: kind, Target: target})
+	}
+
+	return
+}
+
+// ParseProjectLocation parses a single target, formatted as in the --target option (e.g. gitlab://group/project)
+func ParseProjectLocation(target string) (ProjectLocation, error) {
+	locations, err := parseTargets([]string{target})
+	if err != nil {
+		return ProjectLocation{}, err
+	}
+
+	return locations[0], nil
+}
+
 func parseTargets(targets []string) ([]ProjectLocation, error) {
 	locations := make([]ProjectLocation, len(targets))
 	for i, t := range targets {
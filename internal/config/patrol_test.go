@@ -11,11 +11,30 @@ func TestGetPatrolConfiguration(t *testing.T) {
 	want := PatrolConfig{
 		Locations:             []ProjectLocation{{Type: repository.Gitlab, Path: "group1"}, {Type: repository.Gitlab, Path: "group2/project1"}},
 		Ignored:               []ProjectLocation{},
+		ExcludePaths:          []string{},
+		MaxConcurrency:        8,
+		CloneRetries:          defaultCloneRetries,
+		MaxArchiveSize:        defaultMaxArchiveSize,
+		Scanners:              []string{"osv"},
+		AllowedLicenses:       []string{},
+		ScanPaths:             []string{},
+		IgnorePaths:           []string{},
+		IgnoredVulns:          []string{},
 		ReportToEmails:        []string{"some-email@gmail.com"},
 		ReportToSlackChannels: []string{"report-slack-channel"},
 		ReportToIssue:         true,
+		IssueTitle:            repository.VulnerabilityIssueTitle,
+		IssueAssignees:        []string{},
+		CloseIssueComment:     true,
+		CloseAfterCleanCount:  1,
 		EnableProjectReportTo: true,
+		EnableProjectIssue:    true,
+		ReportSlackThread:     true,
+		VerboseIssue:          true,
+		SeverityScores:        SeverityScoreThresholds{Critical: 9.0, High: 7.0, Moderate: 3.0, Low: 0.0},
 		SilentReport:          true,
+		OutputFormat:          "human",
+		ConsoleDetail:         "full",
 		Verbose:               true,
 	}
 
@@ -32,27 +51,67 @@ func TestGetPatrolConfigurationCLIOverridesFile(t *testing.T) {
 	want := PatrolConfig{
 		Locations:             []ProjectLocation{{Type: repository.Gitlab, Path: "group1"}, {Type: repository.Gitlab, Path: "group2/project1"}},
 		Ignored:               []ProjectLocation{},
+		ExcludePaths:          []string{"group1/legacy-*"},
+		MaxConcurrency:        2,
+		CloneRetries:          0,
+		MaxArchiveSize:        512,
+		Scanners:              []string{"osv", "trivy"},
+		AllowedLicenses:       []string{"MIT"},
+		ScanPaths:             []string{"go.mod", "services/*"},
+		IgnorePaths:           []string{"testdata"},
+		IgnoredVulns:          []string{"CVE-2021-1234"},
+		EnableEpss:            true,
 		ReportToEmails:        []string{"email@gmail.com", "other@gmail.com"},
 		ReportToSlackChannels: []string{"other-slack-channel"},
 		ReportToIssue:         false,
+		IssueTitle:            "Sheriff - 🐳 Container report",
+		IssueAssignees:        []string{"alice", "bob"},
+		CloseIssueComment:     false,
+		CloseAfterCleanCount:  1,
 		EnableProjectReportTo: false, // Here we test overriding with a zero-value, which works!
+		EnableProjectIssue:    false,
+		ReportSlackThread:     false,
+		ReportSlackActions:    true,
+		VerboseIssue:          false,
+		SeverityScores:        SeverityScoreThresholds{Critical: 9.0, High: 7.0, Moderate: 3.0, Low: 0.0},
 		SilentReport:          false,
+		OutputFormat:          "json",
+		ConsoleDetail:         "summary",
 		Verbose:               true,
 	}
 
 	got, err := GetPatrolConfiguration(PatrolCLIOpts{
-		Config:  "testdata/patrol/valid.toml",
-		Verbose: true,
+		Config:        "testdata/patrol/valid.toml",
+		Verbose:       true,
+		OutputFormat:  want.OutputFormat,
+		ConsoleDetail: want.ConsoleDetail,
 		PatrolCommonOpts: PatrolCommonOpts{
-			Targets: &[]string{"gitlab://group1", "gitlab://group2/project1"},
+			Targets:         &[]string{"gitlab://group1", "gitlab://group2/project1"},
+			ExcludePaths:    &want.ExcludePaths,
+			MaxConcurrency:  &want.MaxConcurrency,
+			CloneRetries:    &want.CloneRetries,
+			MaxArchiveSize:  &want.MaxArchiveSize,
+			Scanners:        &want.Scanners,
+			AllowedLicenses: &want.AllowedLicenses,
+			ScanPaths:       &want.ScanPaths,
+			IgnorePaths:     &want.IgnorePaths,
+			IgnoreVulns:     &want.IgnoredVulns,
+			EnableEpss:      &want.EnableEpss,
 			Report: PatrolReportOpts{
 				To: PatrolReportToOpts{
 					Emails:                &want.ReportToEmails,
 					SlackChannels:         &want.ReportToSlackChannels,
 					Issue:                 &want.ReportToIssue,
 					EnableProjectReportTo: &want.EnableProjectReportTo,
+					EnableProjectIssue:    &want.EnableProjectIssue,
 				},
-				SilentReport: &want.SilentReport,
+				SilentReport:      &want.SilentReport,
+				SlackThread:       &want.ReportSlackThread,
+				SlackActions:      &want.ReportSlackActions,
+				VerboseIssue:      &want.VerboseIssue,
+				IssueTitle:        &want.IssueTitle,
+				IssueAssignees:    &[]string{"@alice", " bob", ""},
+				CloseIssueComment: &want.CloseIssueComment,
 			},
 		},
 	})
@@ -61,6 +120,394 @@ func TestGetPatrolConfigurationCLIOverridesFile(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestGetPatrolConfigurationRecursiveTarget(t *testing.T) {
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{Targets: &[]string{"gitlab://group/platform/**"}},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ProjectLocation{{Type: repository.Gitlab, Path: "group/platform/**"}}, got.Locations)
+}
+
+func TestGetPatrolConfigurationInvalidExcludePaths(t *testing.T) {
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{ExcludePaths: &[]string{"group/[legacy"}},
+	})
+
+	assert.ErrorContains(t, err, "group/[legacy is not a valid glob pattern")
+}
+
+func TestGetPatrolConfigurationDefaultMaxConcurrency(t *testing.T) {
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, defaultMaxConcurrency, got.MaxConcurrency)
+}
+
+func TestGetPatrolConfigurationInvalidMaxConcurrency(t *testing.T) {
+	maxConcurrency := 0
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{MaxConcurrency: &maxConcurrency},
+	})
+
+	assert.NotNil(t, err)
+}
+
+func TestGetPatrolConfigurationInvalidCloneRetries(t *testing.T) {
+	cloneRetries := -1
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{CloneRetries: &cloneRetries},
+	})
+
+	assert.NotNil(t, err)
+}
+
+func TestGetPatrolConfigurationInvalidMaxArchiveSize(t *testing.T) {
+	maxArchiveSize := 0
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{MaxArchiveSize: &maxArchiveSize},
+	})
+
+	assert.NotNil(t, err)
+}
+
+func TestGetPatrolConfigurationInvalidOutputFormat(t *testing.T) {
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{OutputFormat: "xml"})
+
+	assert.NotNil(t, err)
+}
+
+func TestGetPatrolConfigurationInvalidConsoleDetail(t *testing.T) {
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{ConsoleDetail: "everything"})
+
+	assert.ErrorContains(t, err, "unknown console detail everything")
+}
+
+func TestGetPatrolConfigurationInvalidScanPaths(t *testing.T) {
+	testCases := []string{"[invalid", "/etc/passwd", "../other-project"}
+
+	for _, tc := range testCases {
+		t.Run(tc, func(t *testing.T) {
+			_, err := GetPatrolConfiguration(PatrolCLIOpts{
+				PatrolCommonOpts: PatrolCommonOpts{ScanPaths: &[]string{tc}},
+			})
+
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestGetPatrolConfigurationScanners(t *testing.T) {
+	testCases := []struct {
+		name     string
+		scanners []string
+		want     []string
+	}{
+		{"trivy only", []string{"trivy"}, []string{"trivy"}},
+		{"both", []string{"Trivy", "osv"}, []string{"trivy", "osv"}},
+		{"duplicated", []string{"osv", "osv"}, []string{"osv"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := GetPatrolConfiguration(PatrolCLIOpts{
+				PatrolCommonOpts: PatrolCommonOpts{Scanners: &tc.scanners},
+			})
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got.Scanners)
+		})
+	}
+}
+
+func TestGetPatrolConfigurationInvalidScanner(t *testing.T) {
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{Scanners: &[]string{"snyk"}},
+	})
+
+	assert.NotNil(t, err)
+}
+
+func TestGetPatrolConfigurationAllowedLicenses(t *testing.T) {
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{
+		FailOnLicense:    true,
+		PatrolCommonOpts: PatrolCommonOpts{AllowedLicenses: &[]string{"MIT", " Apache-2.0 ", ""}},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"MIT", "Apache-2.0"}, got.AllowedLicenses)
+	assert.True(t, got.FailOnLicense)
+}
+
+func TestGetPatrolConfigurationInvalidLicenseOptions(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts PatrolCLIOpts
+	}{
+		{"fail on license without allowed licenses", PatrolCLIOpts{FailOnLicense: true}},
+		{"allowed licenses without osv", PatrolCLIOpts{PatrolCommonOpts: PatrolCommonOpts{AllowedLicenses: &[]string{"MIT"}, Scanners: &[]string{"trivy"}}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := GetPatrolConfiguration(tc.opts)
+
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestGetPatrolConfigurationIgnoredVulns(t *testing.T) {
+	ignoreFile := "testdata/patrol/ignore.txt"
+	testCases := []struct {
+		name string
+		opts PatrolCommonOpts
+		want []string
+	}{
+		{"flag only", PatrolCommonOpts{IgnoreVulns: &[]string{"CVE-2021-1234", " GHSA-aaaa-bbbb-cccc "}}, []string{"CVE-2021-1234", "GHSA-aaaa-bbbb-cccc"}},
+		{"file only", PatrolCommonOpts{IgnoreFile: &ignoreFile}, []string{"CVE-2022-0001", "GHSA-xxxx-yyyy-zzzz"}},
+		{"flag and file", PatrolCommonOpts{IgnoreVulns: &[]string{"CVE-2021-1234", "CVE-2022-0001"}, IgnoreFile: &ignoreFile}, []string{"CVE-2021-1234", "CVE-2022-0001", "GHSA-xxxx-yyyy-zzzz"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := GetPatrolConfiguration(PatrolCLIOpts{PatrolCommonOpts: tc.opts})
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got.IgnoredVulns)
+		})
+	}
+}
+
+func TestGetPatrolConfigurationInexistentIgnoreFile(t *testing.T) {
+	ignoreFile := "testdata/patrol/inexistent.txt"
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{IgnoreFile: &ignoreFile},
+	})
+
+	assert.ErrorContains(t, err, "failed to read ignore file")
+}
+
+func TestGetPatrolConfigurationReportDestinations(t *testing.T) {
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{ReportTo: []string{"junit:report.xml"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ReportDestination{{Kind: ReportToJUnit, Target: "report.xml"}}, got.ReportDestinations)
+}
+
+func TestGetPatrolConfigurationPagerDutyReportDestination(t *testing.T) {
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{ReportTo: []string{"pagerduty:"}, PagerDutyRoutingKey: "routing-key"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ReportDestination{{Kind: ReportToPagerDuty}}, got.ReportDestinations)
+	assert.Equal(t, "routing-key", got.PagerDutyRoutingKey)
+}
+
+func TestGetPatrolConfigurationPagerDutyWithoutRoutingKey(t *testing.T) {
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{ReportTo: []string{"pagerduty:"}})
+
+	assert.ErrorContains(t, err, "requires a routing key")
+}
+
+func TestGetPatrolConfigurationInvalidReportDestination(t *testing.T) {
+	testCases := []string{"junit", "junit:", "unknown:report.xml"}
+
+	for _, tc := range testCases {
+		t.Run(tc, func(t *testing.T) {
+			_, err := GetPatrolConfiguration(PatrolCLIOpts{ReportTo: []string{tc}})
+
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestGetPatrolConfigurationSlackChannelIds(t *testing.T) {
+	channels := []string{"security", "id:C0123ABCD"}
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{PatrolCommonOpts: PatrolCommonOpts{Report: PatrolReportOpts{To: PatrolReportToOpts{SlackChannels: &channels}}}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, channels, got.ReportToSlackChannels)
+}
+
+func TestGetPatrolConfigurationInvalidSlackChannelId(t *testing.T) {
+	testCases := []string{"id:", "id:security", "id:c0123abcd"}
+
+	for _, tc := range testCases {
+		t.Run(tc, func(t *testing.T) {
+			channels := []string{tc}
+			_, err := GetPatrolConfiguration(PatrolCLIOpts{PatrolCommonOpts: PatrolCommonOpts{Report: PatrolReportOpts{To: PatrolReportToOpts{SlackChannels: &channels}}}})
+
+			assert.ErrorContains(t, err, "invalid slack channel id")
+		})
+	}
+}
+
+func TestGetPatrolConfigurationDefaultSeverityScores(t *testing.T) {
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, defaultSeverityScoreThresholds, got.SeverityScores)
+}
+
+func TestParseSeverityScoreThresholdsInvalid(t *testing.T) {
+	ptr := func(f float64) *float64 { return &f }
+	testCases := []struct {
+		name    string
+		opts    PatrolSeverityOpts
+		wantErr string
+	}{
+		{"above 10", PatrolSeverityOpts{Critical: ptr(10.5)}, "critical threshold must be within 0 and 10"},
+		{"negative", PatrolSeverityOpts{Low: ptr(-1)}, "low threshold must be within 0 and 10"},
+		{"not decreasing", PatrolSeverityOpts{High: ptr(9.5)}, "high threshold (9.5) must be lower than critical threshold (9)"},
+		{"equal", PatrolSeverityOpts{Moderate: ptr(8)}, "moderate threshold (8) must be lower than high threshold (8)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseSeverityScoreThresholds(tc.opts)
+
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestGetPatrolConfigurationEmptyIssueTitle(t *testing.T) {
+	issueTitle := "  "
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{
+			Report: PatrolReportOpts{IssueTitle: &issueTitle},
+		},
+	})
+
+	assert.NotNil(t, err)
+}
+
+func TestGetPatrolConfigurationSlackDeltaRequiresStateFile(t *testing.T) {
+	slackDelta := true
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{
+			Report: PatrolReportOpts{SlackDelta: &slackDelta},
+		},
+	})
+
+	assert.NotNil(t, err)
+}
+
+func TestGetPatrolConfigurationCloseAfterCleanCount(t *testing.T) {
+	count := 3
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{Report: PatrolReportOpts{CloseAfterCleanCount: &count}},
+	})
+	assert.ErrorContains(t, err, "requires a state file")
+
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{
+		StateFile:        "state.json",
+		PatrolCommonOpts: PatrolCommonOpts{Report: PatrolReportOpts{CloseAfterCleanCount: &count}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, got.CloseAfterCleanCount)
+
+	count = 0
+	_, err = GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{Report: PatrolReportOpts{CloseAfterCleanCount: &count}},
+	})
+	assert.ErrorContains(t, err, "must be at least 1")
+}
+
+func TestGetPatrolConfigurationSlackNewOnlyRequiresIssues(t *testing.T) {
+	slackNewOnly, issue := true, true
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{
+			Report: PatrolReportOpts{SlackNewOnly: &slackNewOnly},
+		},
+	})
+	assert.ErrorContains(t, err, "requires issues")
+
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{
+			Report: PatrolReportOpts{SlackNewOnly: &slackNewOnly, To: PatrolReportToOpts{Issue: &issue}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, got.ReportSlackNewOnly)
+}
+
+func TestGetPatrolConfigurationCentralIssue(t *testing.T) {
+	target, issue := "gitlab://group/security", true
+	got, err := GetPatrolConfiguration(PatrolCLIOpts{
+		PatrolCommonOpts: PatrolCommonOpts{
+			Report: PatrolReportOpts{CentralIssue: &target, To: PatrolReportToOpts{Issue: &issue}},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &ProjectLocation{Type: repository.Gitlab, Path: "group/security"}, got.CentralIssue)
+}
+
+func TestGetPatrolConfigurationInvalidCentralIssue(t *testing.T) {
+	target, invalid, enabled := "gitlab://group/security", "group/security", true
+	testCases := map[string]PatrolReportOpts{
+		"invalid target":      {CentralIssue: &invalid, To: PatrolReportToOpts{Issue: &enabled}},
+		"without issues":      {CentralIssue: &target},
+		"with project issue":  {CentralIssue: &target, To: PatrolReportToOpts{Issue: &enabled, EnableProjectIssue: &enabled}},
+		"with slack new only": {CentralIssue: &target, SlackNewOnly: &enabled, To: PatrolReportToOpts{Issue: &enabled}},
+	}
+
+	for name, opts := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := GetPatrolConfiguration(PatrolCLIOpts{PatrolCommonOpts: PatrolCommonOpts{Report: opts}})
+
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestGetPatrolConfigurationAttachRawReport(t *testing.T) {
+	target, enabled := "gitlab://group/security", true
+	testCases := map[string]struct {
+		opts    PatrolReportOpts
+		wantErr bool
+	}{
+		"with issues":         {PatrolReportOpts{AttachRawReport: &enabled, To: PatrolReportToOpts{Issue: &enabled}}, false},
+		"with project issues": {PatrolReportOpts{AttachRawReport: &enabled, To: PatrolReportToOpts{EnableProjectIssue: &enabled}}, false},
+		"without issues":      {PatrolReportOpts{AttachRawReport: &enabled}, true},
+		"with central issue":  {PatrolReportOpts{AttachRawReport: &enabled, CentralIssue: &target, To: PatrolReportToOpts{Issue: &enabled}}, true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := GetPatrolConfiguration(PatrolCLIOpts{PatrolCommonOpts: PatrolCommonOpts{Report: tc.opts}})
+
+			if tc.wantErr {
+				assert.ErrorContains(t, err, "attaching the raw osv-scanner report requires issues in the projects")
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, got.AttachRawReport)
+			}
+		})
+	}
+}
+
+func TestGetPatrolConfigurationIncrementalRequiresStateFile(t *testing.T) {
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{Incremental: true})
+
+	assert.ErrorContains(t, err, "requires a state file")
+}
+
+func TestGetPatrolConfigurationDiffRequiresTargetRef(t *testing.T) {
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{BaseRef: "main"})
+	assert.ErrorContains(t, err, "requires a target ref")
+
+	_, err = GetPatrolConfiguration(PatrolCLIOpts{DiffFile: "diff.json"})
+	assert.ErrorContains(t, err, "requires a target ref")
+}
+
+func TestGetPatrolConfigurationDiffCannotBeIncremental(t *testing.T) {
+	_, err := GetPatrolConfiguration(PatrolCLIOpts{TargetRef: "release", Incremental: true, StateFile: "state.json"})
+
+	assert.ErrorContains(t, err, "cannot be incremental")
+}
+
 func TestGetPatrolConfigurationInvalidFile(t *testing.T) {
 	_, err := GetPatrolConfiguration(PatrolCLIOpts{
 		Config:  "testdata/patrol/invalid.toml",
@@ -80,6 +527,33 @@ func TestGetPatrolConfigurationInexistentFile(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestParseSeverityThreshold(t *testing.T) {
+	testCases := []struct {
+		threshold string
+		want      string
+		wantError bool
+	}{
+		{"", "", false},
+		{"HIGH", "HIGH", false},
+		{"moderate", "MODERATE", false},
+		{"ACKNOWLEDGED", "", true},
+		{"not a severity", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.threshold, func(t *testing.T) {
+			got, err := parseSeverityThreshold(tc.threshold)
+
+			if tc.wantError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
 func TestParseUrls(t *testing.T) {
 	testCases := []struct {
 		paths               []string
@@ -106,3 +580,13 @@ func TestParseUrls(t *testing.T) {
 		}
 	}
 }
+
+func TestParseProjectLocation(t *testing.T) {
+	got, err := ParseProjectLocation("github://organization/project")
+
+	assert.Nil(t, err)
+	assert.Equal(t, ProjectLocation{Type: "github", Path: "organization/project"}, got)
+
+	_, err = ParseProjectLocation("organization/project")
+	assert.ErrorContains(t, err, "target missing platform scheme")
+}
@@ -1,20 +1,107 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
 	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/BurntSushi/toml"
+	"github.com/elliotchance/pie/v2"
 	"github.com/rs/zerolog/log"
 )
 
-const projectConfigFileName = "sheriff.toml"
+// ProjectConfigFileName is the configuration file of sheriff in the repository of a project
+const ProjectConfigFileName = "sheriff.toml"
 
+// IgnoreFileName is the file in the repository of a project listing glob patterns, one per line,
+// of the files whose vulnerabilities are suppressed
+const IgnoreFileName = ".sheriffignore"
+
+// OsvScannerConfigFileName is the configuration file of osv-scanner,
+// whose ignored vulnerabilities are acknowledged in the project
+const OsvScannerConfigFileName = "osv-scanner.toml"
+
+// osvIgnoredVulnReason is the acknowledgement reason of the vulnerabilities ignored in osv-scanner.toml without a reason
+const osvIgnoredVulnReason = "Ignored in " + OsvScannerConfigFileName
+
+// ackExpiryLayout is the expected format of the expiry date of acknowledgements
+const ackExpiryLayout = "2006-01-02"
+
+// slackMentionRegex matches the slack mentions of a project: the ID of a member (e.g. U0123ABCD) or of a user group
+// (e.g. S0123ABCD), or one of the @here, @channel and @everyone handles. Other handles cannot be mentioned, as slack only
+// notifies members and user groups referenced by their ID.
+var slackMentionRegex = regexp.MustCompile(`^([UWS][A-Z0-9]+|@here|@channel|@everyone)$`)
+
+// AcknowledgedVuln acknowledges the vulnerability with the given code, or all the vulnerabilities of the given package.
+// The version and ecosystem optionally narrow down the package acknowledged, and a code and a package can be combined
+// to only acknowledge a vulnerability in that package.
 type AcknowledgedVuln struct {
-	Code   string `toml:"code"`
-	Reason string `toml:"reason"`
+	Code      string `toml:"code,omitempty"`
+	Package   string `toml:"package,omitempty"`
+	Version   string `toml:"version,omitempty"`   // Optional, any version of the package if unset
+	Ecosystem string `toml:"ecosystem,omitempty"` // Optional, e.g. npm or Go, any ecosystem if unset
+	Reason    string `toml:"reason"`
+	Expires   string `toml:"expires,omitempty"` // Optional date (YYYY-MM-DD) after which the acknowledgement no longer applies
+}
+
+// Subject returns what the acknowledgement applies to, to refer to it in messages:
+// its code, or its package like lodash@4.17.20 (npm).
+func (a AcknowledgedVuln) Subject() string {
+	if a.Package == "" {
+		return a.Code
+	}
+
+	subject := a.Package
+	if a.Version != "" {
+		subject += "@" + a.Version
+	}
+	if a.Ecosystem != "" {
+		subject += fmt.Sprintf(" (%v)", a.Ecosystem)
+	}
+	if a.Code != "" {
+		subject = fmt.Sprintf("%v in %v", a.Code, subject)
+	}
+
+	return subject
+}
+
+// IsExpired returns whether the acknowledgement has expired at the given time.
+// An acknowledgement still applies during the whole day of its expiry date.
+func (a AcknowledgedVuln) IsExpired(now time.Time) bool {
+	if a.Expires == "" {
+		return false
+	}
+
+	expires, err := time.ParseInLocation(ackExpiryLayout, a.Expires, now.Location())
+	if err != nil {
+		// Validated when reading the configuration, so this should not happen
+		return false
+	}
+
+	return !now.Before(expires.AddDate(0, 0, 1))
+}
+
+// osvScannerConfig is the subset of the osv-scanner configuration file read by sheriff
+type osvScannerConfig struct {
+	IgnoredVulns []osvIgnoredVuln `toml:"IgnoredVulns"`
+}
+
+type osvIgnoredVuln struct {
+	Id          string    `toml:"id"`
+	IgnoreUntil time.Time `toml:"ignoreUntil"` // Optional, the vulnerability is reported again after this date
+	Reason      string    `toml:"reason"`
 }
 
 type ProjectReportTo struct {
-	SlackChannel string `toml:"slack-channel"`
+	SlackChannel string   `toml:"slack-channel"`
+	SlackMention []string `toml:"slack-mention"` // Members, user groups or handles mentioned in the report of the slack channel
+	Issue        *bool    `toml:"issue"`         // Whether sheriff manages an issue in the project, only honored if enabled in sheriff, nil if unset
 }
 
 type ProjectReport struct {
@@ -22,25 +109,238 @@ type ProjectReport struct {
 }
 
 type ProjectConfig struct {
-	Report       ProjectReport      `toml:"report"`
-	SlackChannel string             `toml:"slack-channel"` // TODO #27: Break in v1.0. Kept for backwards-compatibility
-	Acknowledged []AcknowledgedVuln `toml:"acknowledged"`
-	Ignored      []string           `toml:"ignored"` // List of repositories or groups to ignore
+	Report            ProjectReport      `toml:"report"`
+	SlackChannel      string             `toml:"slack-channel"` // TODO #27: Break in v1.0. Kept for backwards-compatibility
+	Acknowledged      []AcknowledgedVuln `toml:"acknowledged"`
+	Ignored           []string           `toml:"ignored"`            // List of repositories or groups to ignore
+	IgnorePaths       []string           `toml:"ignore-paths"`       // Glob patterns of the paths whose vulnerabilities are ignored, e.g. test fixtures
+	SeverityThreshold string             `toml:"severity-threshold"` // Overrides the patrol-level severity threshold for this project
+	SuppressedPaths   []string           `toml:"-"`                  // Glob patterns read from the ignore file, whose vulnerabilities are suppressed
 }
 
-func GetProjectConfiguration(projectName string, dir string) (config ProjectConfig) {
-	found, err := getTOMLFile(path.Join(dir, projectConfigFileName), &config)
-	if err != nil {
-		log.Error().Err(err).Str("project", projectName).Msg("Failed to read project configuration. Running with empty configuration.")
+// GetProjectConfiguration reads the project configuration file in the given directory.
+// A missing file results in an empty configuration, while a readable file with invalid values results in an error.
+// References to environment variables in its string values are expanded, see expandEnv.
+// A malformed or unreadable file, unknown keys and malformed acknowledgements do not prevent the project from being
+// scanned, but are returned as a warning: the configuration is then empty, or lacks the unknown keys and malformed
+// acknowledgements.
+// The glob patterns of the ignore file, if any, are read into SuppressedPaths, where malformed patterns are skipped with a warning.
+func GetProjectConfiguration(projectName string, dir string) (config ProjectConfig, warn error, err error) {
+	found, undecoded, ferr := getTOMLFile(path.Join(dir, ProjectConfigFileName), &config)
+	if ferr != nil {
+		log.Error().Err(ferr).Str("project", projectName).Msg("Failed to read project configuration. Running with empty configuration.")
+		config = ProjectConfig{}
+		warn = fmt.Errorf("failed to read %v, it is ignored: %w", ProjectConfigFileName, ferr)
 	} else if found {
 		log.Info().Str("project", projectName).Msg("Found project configuration")
 	} else {
 		log.Info().Str("project", projectName).Msg("No project configuration found. Using default")
 	}
 
+	if len(undecoded) > 0 {
+		log.Warn().Str("project", projectName).Strs("keys", undecoded).Msg("Found unknown keys in project configuration")
+		warn = errors.Join(warn, fmt.Errorf("unknown keys in %v: %v", ProjectConfigFileName, strings.Join(undecoded, ", ")))
+	}
+
+	expandEnv(&config)
+
+	var ackWarn error
+	config.Acknowledged, ackWarn = validateAcknowledgements(config.Acknowledged)
+	if ackWarn != nil {
+		log.Warn().Err(ackWarn).Str("project", projectName).Msg("Found malformed acknowledgements in project configuration")
+		warn = errors.Join(warn, ackWarn)
+	}
+
 	if config.SlackChannel != "" {
 		config.Report.To.SlackChannel = config.SlackChannel
 	}
 
+	if config.SeverityThreshold, err = parseSeverityThreshold(config.SeverityThreshold); err != nil {
+		return config, warn, errors.Join(errors.New("invalid project configuration"), err)
+	}
+
+	if err := validatePathPatterns(config.IgnorePaths); err != nil {
+		return config, warn, errors.Join(errors.New("invalid project configuration"), errors.New("invalid ignore paths"), err)
+	}
+
+	if err := validateSlackMentions(config.Report.To.SlackMention); err != nil {
+		return config, warn, errors.Join(errors.New("invalid project configuration"), err)
+	}
+
+	for _, ack := range config.Acknowledged {
+		if ack.Expires == "" {
+			continue
+		}
+		if _, err := time.Parse(ackExpiryLayout, ack.Expires); err != nil {
+			err = fmt.Errorf("invalid expiry date %v of acknowledgement %v, must be formatted as YYYY-MM-DD", ack.Expires, ack.Subject())
+			return config, warn, errors.Join(errors.New("invalid project configuration"), err)
+		}
+	}
+
+	config.Acknowledged = appendOsvIgnoredVulns(projectName, dir, config.Acknowledged)
+
+	var ignoreWarn error
+	config.SuppressedPaths, ignoreWarn = readIgnoreFile(projectName, dir)
+	if ignoreWarn != nil {
+		log.Warn().Err(ignoreWarn).Str("project", projectName).Msgf("Found malformed patterns in %v", IgnoreFileName)
+		warn = errors.Join(warn, ignoreWarn)
+	}
+
 	return
 }
+
+// readIgnoreFile returns the glob patterns listed in the ignore file of the given directory, if any.
+// Like in a .gitignore, empty lines and lines starting with # are skipped.
+// Patterns which are not valid globs relative to the project root are skipped and returned as a warning.
+func readIgnoreFile(projectName string, dir string) (patterns []string, warn error) {
+	content, err := os.ReadFile(path.Join(dir, IgnoreFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %v, it is ignored: %w", IgnoreFileName, err)
+	}
+
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := validatePathPatterns([]string{line}); err != nil {
+			warn = errors.Join(warn, fmt.Errorf("line %v of %v is ignored: %w", i+1, IgnoreFileName, err))
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	log.Info().Str("project", projectName).Int("patterns", len(patterns)).Msgf("Found %v", IgnoreFileName)
+
+	return
+}
+
+// AddAcknowledgement returns the content of a project configuration file with the acknowledgement appended to it.
+// The existing content is kept as is, so that its comments and formatting are preserved.
+// The acknowledgement must have a code, a reason and a well-formed expiry date if any,
+// and its vulnerability must not be acknowledged already.
+func AddAcknowledgement(content []byte, ack AcknowledgedVuln) ([]byte, error) {
+	if strings.TrimSpace(ack.Code) == "" {
+		return nil, errors.New("acknowledgement has no code")
+	} else if strings.TrimSpace(ack.Reason) == "" {
+		return nil, fmt.Errorf("acknowledgement of %v has no reason", ack.Code)
+	} else if ack.Expires != "" {
+		if _, err := time.Parse(ackExpiryLayout, ack.Expires); err != nil {
+			return nil, fmt.Errorf("invalid expiry date %v of acknowledgement %v, must be formatted as YYYY-MM-DD", ack.Expires, ack.Code)
+		}
+	}
+
+	var config ProjectConfig
+	if _, err := toml.Decode(string(content), &config); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to decode %v", ProjectConfigFileName), err)
+	}
+	if slices.ContainsFunc(config.Acknowledged, func(a AcknowledgedVuln) bool { return a.Code == ack.Code }) {
+		return nil, fmt.Errorf("%v is already acknowledged in %v", ack.Code, ProjectConfigFileName)
+	}
+
+	var block bytes.Buffer
+	enc := toml.NewEncoder(&block)
+	enc.Indent = ""
+	if err := enc.Encode(struct {
+		Acknowledged []AcknowledgedVuln `toml:"acknowledged"`
+	}{[]AcknowledgedVuln{ack}}); err != nil {
+		return nil, errors.Join(errors.New("failed to encode acknowledgement"), err)
+	}
+
+	updated := bytes.Clone(bytes.TrimRight(content, "\n"))
+	if len(updated) > 0 {
+		updated = append(updated, "\n\n"...)
+	}
+	updated = append(updated, block.Bytes()...)
+
+	// Appending the table is only invalid if the acknowledgements are already written as an inline array
+	if _, err := toml.Decode(string(updated), &ProjectConfig{}); err != nil {
+		return nil, errors.Join(fmt.Errorf("cannot append the acknowledgement to %v, it must be added manually", ProjectConfigFileName), err)
+	}
+
+	return updated, nil
+}
+
+// expandEnv replaces the ${VAR} and $VAR references to environment variables in the string values of the configuration,
+// so that they can differ between environments. References to unset variables are replaced by an empty string.
+func expandEnv(config *ProjectConfig) {
+	config.Report.To.SlackChannel = os.ExpandEnv(config.Report.To.SlackChannel)
+	config.SlackChannel = os.ExpandEnv(config.SlackChannel)
+	config.Report.To.SlackMention = pie.Map(config.Report.To.SlackMention, os.ExpandEnv)
+	config.SeverityThreshold = os.ExpandEnv(config.SeverityThreshold)
+	for i := range config.Acknowledged {
+		config.Acknowledged[i].Code = os.ExpandEnv(config.Acknowledged[i].Code)
+		config.Acknowledged[i].Package = os.ExpandEnv(config.Acknowledged[i].Package)
+		config.Acknowledged[i].Version = os.ExpandEnv(config.Acknowledged[i].Version)
+		config.Acknowledged[i].Ecosystem = os.ExpandEnv(config.Acknowledged[i].Ecosystem)
+		config.Acknowledged[i].Reason = os.ExpandEnv(config.Acknowledged[i].Reason)
+		config.Acknowledged[i].Expires = os.ExpandEnv(config.Acknowledged[i].Expires)
+	}
+	config.Ignored = pie.Map(config.Ignored, os.ExpandEnv)
+	config.IgnorePaths = pie.Map(config.IgnorePaths, os.ExpandEnv)
+}
+
+// validateSlackMentions checks that the slack mentions are given in one of the formats of slackMentionRegex
+func validateSlackMentions(mentions []string) error {
+	for _, m := range mentions {
+		if !slackMentionRegex.MatchString(m) {
+			return fmt.Errorf("invalid slack mention %v, must be the ID of a member (e.g. U0123ABCD) or of a user group (e.g. S0123ABCD), or one of @here, @channel and @everyone", m)
+		}
+	}
+
+	return nil
+}
+
+// validateAcknowledgements returns the acknowledgements which are well-formed,
+// and a warning listing those which are not, e.g. without the code of the vulnerability nor its package.
+func validateAcknowledgements(acks []AcknowledgedVuln) (valid []AcknowledgedVuln, warn error) {
+	for i, ack := range acks {
+		if strings.TrimSpace(ack.Code) == "" && strings.TrimSpace(ack.Package) == "" {
+			warn = errors.Join(warn, fmt.Errorf("acknowledgement #%v has neither a code nor a package, it is ignored", i+1))
+			continue
+		}
+		if strings.TrimSpace(ack.Package) == "" && (ack.Version != "" || ack.Ecosystem != "") {
+			warn = errors.Join(warn, fmt.Errorf("acknowledgement #%v has a version or ecosystem but no package, it is ignored", i+1))
+			continue
+		}
+		valid = append(valid, ack)
+	}
+
+	return
+}
+
+// appendOsvIgnoredVulns appends the vulnerabilities ignored in the osv-scanner configuration file of the given directory,
+// if any, to the given acknowledgements. Those already acknowledged in the project configuration are kept as they are.
+// An unreadable file is logged and skipped, as it should not prevent the project from being scanned.
+func appendOsvIgnoredVulns(projectName string, dir string, acks []AcknowledgedVuln) []AcknowledgedVuln {
+	filename := path.Join(dir, OsvScannerConfigFileName)
+	if _, err := os.Stat(filename); err != nil {
+		return acks
+	}
+
+	var osvConfig osvScannerConfig
+	if _, err := toml.DecodeFile(filename, &osvConfig); err != nil {
+		log.Error().Err(err).Str("project", projectName).Msg("Failed to read osv-scanner configuration. Its ignored vulnerabilities are not acknowledged.")
+		return acks
+	}
+
+	log.Info().Str("project", projectName).Int("ignored", len(osvConfig.IgnoredVulns)).Msg("Found osv-scanner configuration")
+	for _, v := range osvConfig.IgnoredVulns {
+		if v.Id == "" || slices.ContainsFunc(acks, func(a AcknowledgedVuln) bool { return a.Code == v.Id }) {
+			continue
+		}
+
+		ack := AcknowledgedVuln{Code: v.Id, Reason: v.Reason}
+		if ack.Reason == "" {
+			ack.Reason = osvIgnoredVulnReason
+		}
+		if !v.IgnoreUntil.IsZero() {
+			ack.Expires = v.IgnoreUntil.Format(ackExpiryLayout)
+		}
+		acks = append(acks, ack)
+	}
+
+	return acks
+}
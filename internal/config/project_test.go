@@ -2,28 +2,223 @@ package config
 
 import (
 	"fmt"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetConfiguration(t *testing.T) {
+	disabled := false
 	testCases := []struct {
 		foldername string
 		wantConfig ProjectConfig
 	}{
 		{"valid", ProjectConfig{Report: ProjectReport{To: ProjectReportTo{SlackChannel: "the-devils-slack-channel"}}}},
-		{"invalid", ProjectConfig{}},
 		{"nonexistent", ProjectConfig{}},
 		{"valid_with_ack", ProjectConfig{Acknowledged: []AcknowledgedVuln{{Code: "CSV111", Reason: "not relevant"}, {Code: "CSV222", Reason: ""}}}},
 		{"valid_with_ack_alt", ProjectConfig{Acknowledged: []AcknowledgedVuln{{Code: "CSV111", Reason: "not relevant"}, {Code: "CSV222", Reason: ""}}}},
+		{"valid_with_threshold", ProjectConfig{SeverityThreshold: "HIGH"}},
+		{"valid_with_ignore_paths", ProjectConfig{IgnorePaths: []string{"testdata", "tools/*/node_modules"}}},
+		{"valid_with_ack_expiry", ProjectConfig{Acknowledged: []AcknowledgedVuln{{Code: "CSV111", Reason: "fix planned next sprint", Expires: "2024-06-30"}, {Code: "CSV222", Reason: ""}}}},
+		{"valid_with_osv_config", ProjectConfig{Acknowledged: []AcknowledgedVuln{
+			{Code: "CSV111", Reason: "not relevant"},
+			{Code: "GO-2022-0968", Reason: "No ssh servers are connected to or hosted in Go lang", Expires: "2024-06-30"},
+			{Code: "GHSA-xxxx-yyyy-zzzz", Reason: "Ignored in osv-scanner.toml"},
+		}}},
+		{"invalid_osv_config", ProjectConfig{}},
+		{"valid_with_issue", ProjectConfig{Report: ProjectReport{To: ProjectReportTo{Issue: &disabled}}}},
+		{"valid_with_sheriffignore", ProjectConfig{SuppressedPaths: []string{"testdata", "tools/*/go.mod"}}},
+		{"valid_with_package_ack", ProjectConfig{Acknowledged: []AcknowledgedVuln{
+			{Package: "lodash", Reason: "not used in production"},
+			{Package: "semver", Version: "7.3.7", Ecosystem: "npm", Reason: "no untrusted input"},
+			{Code: "GHSA-xxxx-yyyy-zzzz", Package: "minimist", Reason: "only in a dev tool"},
+		}}},
+		{"valid_with_mention", ProjectConfig{Report: ProjectReport{To: ProjectReportTo{SlackChannel: "the-devils-slack-channel", SlackMention: []string{"U0123ABCD", "S0456EFGH", "@here"}}}}},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.foldername, func(t *testing.T) {
-			got := GetProjectConfiguration("", fmt.Sprintf("testdata/project/%v", tc.foldername))
+			got, warn, err := GetProjectConfiguration("", fmt.Sprintf("testdata/project/%v", tc.foldername))
 
+			assert.Nil(t, err)
+			assert.Nil(t, warn)
 			assert.Equal(t, tc.wantConfig, got)
 		})
 	}
 }
+
+func TestGetConfigurationMalformedToml(t *testing.T) {
+	got, warn, err := GetProjectConfiguration("", "testdata/project/invalid")
+
+	assert.Nil(t, err)
+	assert.Equal(t, ProjectConfig{}, got)
+	assert.ErrorContains(t, warn, "failed to read sheriff.toml, it is ignored")
+	assert.ErrorContains(t, warn, "failed to decode TOML file")
+}
+
+func TestGetConfigurationUnknownKeys(t *testing.T) {
+	got, warn, err := GetProjectConfiguration("", "testdata/project/unknown_keys")
+
+	assert.Nil(t, err)
+	assert.Equal(t, ProjectConfig{
+		Report:       ProjectReport{To: ProjectReportTo{SlackChannel: "the-devils-slack-channel"}},
+		Acknowledged: []AcknowledgedVuln{{Code: "CSV111"}},
+	}, got)
+	assert.ErrorContains(t, warn, "unknown keys in sheriff.toml: report.to.slack-chanel, acknowledged.reson")
+}
+
+func TestGetConfigurationMalformedAcknowledgements(t *testing.T) {
+	got, warn, err := GetProjectConfiguration("", "testdata/project/invalid_ack_shape")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []AcknowledgedVuln{{Code: "CSV111", Reason: "not relevant"}}, got.Acknowledged)
+	assert.ErrorContains(t, warn, "acknowledgement #2 has neither a code nor a package, it is ignored")
+	assert.ErrorContains(t, warn, "acknowledgement #3 has a version or ecosystem but no package, it is ignored")
+}
+
+func TestGetConfigurationExpandsEnv(t *testing.T) {
+	t.Setenv("SHERIFF_TEST_TEAM_CHANNEL", "team-channel")
+	t.Setenv("SHERIFF_TEST_FIXTURES_DIR", "fixtures")
+
+	got, _, err := GetProjectConfiguration("", "testdata/project/valid_with_env")
+
+	assert.Nil(t, err)
+	assert.Equal(t, ProjectConfig{
+		Report:       ProjectReport{To: ProjectReportTo{SlackChannel: "team-channel"}},
+		Acknowledged: []AcknowledgedVuln{{Code: "CSV111", Reason: "not relevant"}},
+		IgnorePaths:  []string{"fixtures/*"},
+	}, got)
+}
+
+func TestGetConfigurationExpandsUnsetEnvToEmpty(t *testing.T) {
+	t.Setenv("SHERIFF_TEST_TEAM_CHANNEL", "") // Restores the variable after the test
+	require.NoError(t, os.Unsetenv("SHERIFF_TEST_TEAM_CHANNEL"))
+	t.Setenv("SHERIFF_TEST_FIXTURES_DIR", "fixtures")
+
+	got, _, err := GetProjectConfiguration("", "testdata/project/valid_with_env")
+
+	assert.Nil(t, err)
+	assert.Empty(t, got.Report.To.SlackChannel)
+}
+
+func TestGetConfigurationInvalidThreshold(t *testing.T) {
+	_, _, err := GetProjectConfiguration("", "testdata/project/invalid_threshold")
+
+	assert.NotNil(t, err)
+	assert.ErrorContains(t, err, "unknown severity threshold SUPER-CRITICAL")
+}
+
+func TestGetConfigurationInvalidIgnorePaths(t *testing.T) {
+	_, _, err := GetProjectConfiguration("", "testdata/project/invalid_ignore_paths")
+
+	assert.NotNil(t, err)
+	assert.ErrorContains(t, err, "path ../other-project must be relative to the project directory")
+}
+
+func TestGetConfigurationInvalidSlackMention(t *testing.T) {
+	_, _, err := GetProjectConfiguration("", "testdata/project/invalid_mention")
+
+	assert.NotNil(t, err)
+	assert.ErrorContains(t, err, "invalid slack mention @jane.doe")
+}
+
+func TestGetConfigurationMalformedSheriffignore(t *testing.T) {
+	got, warn, err := GetProjectConfiguration("", "testdata/project/invalid_sheriffignore")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"go.mod"}, got.SuppressedPaths)
+	assert.ErrorContains(t, warn, "line 2 of .sheriffignore is ignored: path ../other-project must be relative to the project directory")
+	assert.ErrorContains(t, warn, "line 3 of .sheriffignore is ignored: invalid glob pattern [")
+}
+
+func TestGetConfigurationInvalidAckExpiry(t *testing.T) {
+	_, _, err := GetProjectConfiguration("", "testdata/project/invalid_ack_expiry")
+
+	assert.NotNil(t, err)
+	assert.ErrorContains(t, err, "invalid expiry date 30/06/2024 of acknowledgement CSV111")
+}
+
+func TestAcknowledgedVulnIsExpired(t *testing.T) {
+	ack := AcknowledgedVuln{Code: "CSV111", Expires: "2024-06-30"}
+
+	testCases := map[string]struct {
+		now  time.Time
+		want bool
+	}{
+		"before expiry":     {time.Date(2024, 6, 29, 12, 0, 0, 0, time.UTC), false},
+		"on the expiry day": {time.Date(2024, 6, 30, 23, 59, 0, 0, time.UTC), false},
+		"the day after":     {time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), true},
+		"long after":        {time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ack.IsExpired(tc.now))
+		})
+	}
+
+	t.Run("without expiry", func(t *testing.T) {
+		assert.False(t, AcknowledgedVuln{Code: "CSV111"}.IsExpired(time.Now()))
+	})
+}
+
+func TestAcknowledgedVulnSubject(t *testing.T) {
+	testCases := map[string]struct {
+		ack  AcknowledgedVuln
+		want string
+	}{
+		"code":                  {AcknowledgedVuln{Code: "CSV111"}, "CSV111"},
+		"package":               {AcknowledgedVuln{Package: "lodash"}, "lodash"},
+		"package with version":  {AcknowledgedVuln{Package: "semver", Version: "7.3.7", Ecosystem: "npm"}, "semver@7.3.7 (npm)"},
+		"code within a package": {AcknowledgedVuln{Code: "CSV111", Package: "minimist"}, "CSV111 in minimist"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.ack.Subject())
+		})
+	}
+}
+
+func TestAddAcknowledgement(t *testing.T) {
+	content := []byte("# Owned by the backend team\n[report.to]\nslack-channel = \"backend\"\n\n[[acknowledged]]\ncode = \"CSV111\"\nreason = \"not relevant\"\n")
+
+	got, err := AddAcknowledgement(content, AcknowledgedVuln{Code: "CSV222", Reason: "fix planned next sprint", Expires: "2024-06-30"})
+
+	require.NoError(t, err)
+	want := string(content) + "\n[[acknowledged]]\ncode = \"CSV222\"\nreason = \"fix planned next sprint\"\nexpires = \"2024-06-30\"\n"
+	assert.Equal(t, want, string(got))
+}
+
+func TestAddAcknowledgementToEmptyFile(t *testing.T) {
+	got, err := AddAcknowledgement(nil, AcknowledgedVuln{Code: "CSV222", Reason: "not relevant"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "[[acknowledged]]\ncode = \"CSV222\"\nreason = \"not relevant\"\n", string(got))
+}
+
+func TestAddAcknowledgementErrors(t *testing.T) {
+	testCases := map[string]struct {
+		content string
+		ack     AcknowledgedVuln
+		want    string
+	}{
+		"without code":           {"", AcknowledgedVuln{Reason: "not relevant"}, "acknowledgement has no code"},
+		"without reason":         {"", AcknowledgedVuln{Code: "CSV222"}, "acknowledgement of CSV222 has no reason"},
+		"invalid expiry":         {"", AcknowledgedVuln{Code: "CSV222", Reason: "not relevant", Expires: "30/06/2024"}, "invalid expiry date 30/06/2024"},
+		"already acknowledged":   {"[[acknowledged]]\ncode = \"CSV222\"\n", AcknowledgedVuln{Code: "CSV222", Reason: "not relevant"}, "CSV222 is already acknowledged"},
+		"malformed file":         {"[report", AcknowledgedVuln{Code: "CSV222", Reason: "not relevant"}, "failed to decode sheriff.toml"},
+		"inline acknowledgments": {"acknowledged = [{ code = \"CSV111\" }]\n", AcknowledgedVuln{Code: "CSV222", Reason: "not relevant"}, "it must be added manually"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := AddAcknowledgement([]byte(tc.content), tc.ack)
+
+			assert.ErrorContains(t, err, tc.want)
+		})
+	}
+}
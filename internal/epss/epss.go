@@ -0,0 +1,138 @@
+// Package epss fetches the Exploit Prediction Scoring System (EPSS) scores of vulnerabilities from the FIRST API.
+package epss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sheriff/internal/scanner"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultBaseURL is the URL of the FIRST EPSS API
+const defaultBaseURL = "https://api.first.org/data/v1/epss"
+
+// maxCvesPerRequest is the number of CVEs queried at once, which keeps the request URL within the API limits
+const maxCvesPerRequest = 100
+
+// requestTimeout is the timeout of a single request to the EPSS API
+const requestTimeout = 30 * time.Second
+
+type IService interface {
+	// Enrich sets the EPSS score of the vulnerabilities of the given reports in place.
+	// Vulnerabilities without a CVE, or whose score could not be fetched, are set to scanner.EpssUnknown.
+	Enrich(ctx context.Context, reports []scanner.Report)
+}
+
+type service struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// epssResponse is the response of the EPSS API
+type epssResponse struct {
+	Data []struct {
+		Cve  string `json:"cve"`
+		Epss string `json:"epss"` // Probability of exploitation, returned as a string
+	} `json:"data"`
+}
+
+// New creates a new EPSS service
+func New() IService {
+	return &service{
+		baseURL:    defaultBaseURL,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Enrich sets the EPSS score of the vulnerabilities of the given reports in place.
+// Failing to reach the API is not an error: the affected vulnerabilities are simply set to scanner.EpssUnknown.
+func (s *service) Enrich(ctx context.Context, reports []scanner.Report) {
+	var cves []string
+	for _, r := range reports {
+		for _, v := range r.Vulnerabilities {
+			if cve := getCve(v); cve != "" && !slices.Contains(cves, cve) {
+				cves = append(cves, cve)
+			}
+		}
+	}
+
+	scores := make(map[string]float64, len(cves))
+	for batch := range slices.Chunk(cves, maxCvesPerRequest) {
+		batchScores, err := s.getScores(ctx, batch)
+		if err != nil {
+			log.Warn().Err(err).Int("cves", len(batch)).Msg("Failed to fetch EPSS scores, leaving them unknown")
+			continue
+		}
+		for cve, score := range batchScores {
+			scores[cve] = score
+		}
+	}
+
+	for i := range reports {
+		for j, v := range reports[i].Vulnerabilities {
+			score, ok := scores[getCve(v)]
+			if !ok {
+				score = scanner.EpssUnknown
+			}
+			reports[i].Vulnerabilities[j].Epss = score
+		}
+	}
+}
+
+// getScores fetches the EPSS scores of the given CVEs, keyed by CVE.
+// CVEs unknown to EPSS are not part of the result.
+func (s *service) getScores(ctx context.Context, cves []string) (map[string]float64, error) {
+	u := fmt.Sprintf("%s?cve=%s", s.baseURL, url.QueryEscape(strings.Join(cves, ",")))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to create EPSS request"), err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to query EPSS API"), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query EPSS API, status: %s", resp.Status)
+	}
+
+	var body epssResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, errors.Join(errors.New("failed to parse EPSS response"), err)
+	}
+
+	scores := make(map[string]float64, len(body.Data))
+	for _, d := range body.Data {
+		score, err := strconv.ParseFloat(d.Epss, 64)
+		if err != nil {
+			log.Warn().Str("cve", d.Cve).Str("epss", d.Epss).Msg("Failed to parse EPSS score, leaving it unknown")
+			continue
+		}
+		scores[d.Cve] = score
+	}
+
+	return scores, nil
+}
+
+// getCve returns the CVE identifier of the vulnerability, which is either its id or one of its aliases.
+// It is empty if the vulnerability has no CVE.
+func getCve(v scanner.Vulnerability) string {
+	for _, id := range append([]string{v.Id}, v.Aliases...) {
+		if strings.HasPrefix(id, "CVE-") {
+			return id
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,56 @@
+package epss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sheriff/internal/scanner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrich(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("cve")
+		_, _ = w.Write([]byte(`{"status":"OK","data":[{"cve":"CVE-2021-1234","epss":"0.975000000"},{"cve":"CVE-2022-0001","epss":"0.001230000"}]}`))
+	}))
+	defer server.Close()
+
+	reports := []scanner.Report{
+		{Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234"}, {Id: "GHSA-xxxx-yyyy-zzzz", Aliases: []string{"CVE-2022-0001"}}}},
+		{Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234"}, {Id: "GHSA-aaaa-bbbb-cccc"}, {Id: "CVE-2023-9999"}}},
+	}
+
+	svc := service{baseURL: server.URL, httpClient: server.Client()}
+	svc.Enrich(context.Background(), reports)
+
+	assert.Equal(t, "CVE-2021-1234,CVE-2022-0001,CVE-2023-9999", gotQuery)
+	assert.Equal(t, 0.975, reports[0].Vulnerabilities[0].Epss)
+	assert.Equal(t, 0.00123, reports[0].Vulnerabilities[1].Epss)
+	assert.Equal(t, 0.975, reports[1].Vulnerabilities[0].Epss)
+	// Without a CVE, or unknown to EPSS
+	assert.Equal(t, scanner.EpssUnknown, reports[1].Vulnerabilities[1].Epss)
+	assert.Equal(t, scanner.EpssUnknown, reports[1].Vulnerabilities[2].Epss)
+}
+
+func TestEnrichWithFailingApi(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	reports := []scanner.Report{{Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234"}}}}
+
+	svc := service{baseURL: server.URL, httpClient: server.Client()}
+	svc.Enrich(context.Background(), reports)
+
+	assert.Equal(t, scanner.EpssUnknown, reports[0].Vulnerabilities[0].Epss)
+}
+
+func TestGetCve(t *testing.T) {
+	assert.Equal(t, "CVE-2021-1234", getCve(scanner.Vulnerability{Id: "CVE-2021-1234"}))
+	assert.Equal(t, "CVE-2021-1234", getCve(scanner.Vulnerability{Id: "GHSA-xxxx-yyyy-zzzz", Aliases: []string{"PYSEC-2021-1", "CVE-2021-1234"}}))
+	assert.Empty(t, getCve(scanner.Vulnerability{Id: "GHSA-xxxx-yyyy-zzzz"}))
+}
@@ -1,20 +1,148 @@
 package log
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
-func ConfigureLogs(verbose bool) {
+// Formats of the logs, see Options.Format
+const (
+	FormatConsole = "console" // Human readable lines
+	FormatJson    = "json"    // A JSON object per line, for log pipelines to ingest
+)
+
+// Formats are the supported formats of the logs
+var Formats = []string{FormatConsole, FormatJson}
+
+// redactedSecret replaces the secrets in the logs, see Options.Secrets
+const redactedSecret = "[REDACTED]"
+
+// secrets are the secrets redacted from the logs, those of the last configuration and the ones added since
+var secrets struct {
+	sync.RWMutex
+	values   []string
+	replacer *strings.Replacer // Replaces the values with redactedSecret, nil if there are none
+}
+
+type Options struct {
+	Verbose bool     // Log from the debug level rather than the warn level, unless Level is set
+	Level   string   // Lowest level logged (e.g. debug, info, warn), overriding Verbose if set
+	Format  string   // One of Formats, FormatConsole if empty
+	Secrets []string // Values redacted from all the logs whatever their level, e.g. the tokens of the APIs
+}
+
+// ConfigureLogs configures the global logger of zerolog, to which all the logs of sheriff are written
+func ConfigureLogs(opts Options) error {
+	return configureLogs(opts, os.Stderr)
+}
+
+func configureLogs(opts Options, out io.Writer) error {
+	level := zerolog.WarnLevel
+	if opts.Level != "" {
+		parsed, err := zerolog.ParseLevel(strings.ToLower(opts.Level))
+		if err != nil || parsed == zerolog.NoLevel {
+			return fmt.Errorf("unknown log level %v, must be one of trace, debug, info, warn, error", opts.Level)
+		}
+		level = parsed
+	} else if opts.Verbose {
+		level = zerolog.DebugLevel
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = FormatConsole
+	} else if !slices.Contains(Formats, format) {
+		return fmt.Errorf("unknown log format %v, must be one of %v", format, strings.Join(Formats, ", "))
+	}
+
 	// UNIX Time is faster and smaller than most timestamps
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	zerolog.SetGlobalLevel(level)
+
+	secrets.Lock()
+	secrets.values = slices.Clone(opts.Secrets)
+	secrets.replacer = newSecretsReplacer(secrets.values)
+	secrets.Unlock()
+	// Always redacting, as secrets may be added after the configuration
+	out = redactingWriter{out: out}
+	if format == FormatConsole {
+		out = zerolog.ConsoleWriter{Out: out}
+	}
+	log.Logger = log.Output(out)
+
+	return nil
+}
+
+// redactingWriter replaces the secrets with redactedSecret in everything written to the underlying writer,
+// so that secrets which end up in a log, e.g. within the error of a request, are never output
+type redactingWriter struct {
+	out io.Writer
+}
+
+func (w redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, Redact(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Redact replaces the secrets given to ConfigureLogs or AddSecret with a placeholder in the message.
+// It must be applied to the messages, mostly errors, which are output elsewhere than in the logs, e.g. in the reports.
+func Redact(message string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+	if secrets.replacer == nil {
+		return message
+	}
 
-	zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	if verbose {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	return secrets.replacer.Replace(message)
+}
+
+// AddSecret redacts a secret obtained after the configuration of the logs, e.g. a token minted at runtime,
+// from the logs and the messages given to Redact until the next configuration
+func AddSecret(secret string) {
+	if secret == "" {
+		return
+	}
+
+	secrets.Lock()
+	defer secrets.Unlock()
+	if slices.Contains(secrets.values, secret) {
+		return
+	}
+	secrets.values = append(secrets.values, secret)
+	secrets.replacer = newSecretsReplacer(secrets.values)
+}
+
+// newSecretsReplacer returns a replacer of the non-empty secrets with redactedSecret, nil if there are none.
+// Each line of a secret spanning several lines, e.g. a private key, is also a secret,
+// since the line breaks are escaped in the JSON logs.
+func newSecretsReplacer(secrets []string) *strings.Replacer {
+	var oldnew []string
+	for _, s := range secrets {
+		// An empty secret would match everywhere
+		if s != "" {
+			oldnew = append(oldnew, s, redactedSecret)
+		}
+		if lines := strings.Split(strings.TrimSpace(s), "\n"); len(lines) > 1 {
+			for _, line := range lines {
+				if line = strings.TrimSpace(line); line != "" {
+					oldnew = append(oldnew, line, redactedSecret)
+				}
+			}
+		}
+	}
+	if len(oldnew) == 0 {
+		return nil
 	}
 
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	return strings.NewReplacer(oldnew...)
 }
@@ -3,78 +3,166 @@ package patrol
 
 import (
 	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"sheriff/internal/compress"
 	"sheriff/internal/config"
+	"sheriff/internal/epss"
+	sheriffLog "sheriff/internal/log"
 	"sheriff/internal/publish"
 	"sheriff/internal/repository"
 	"sheriff/internal/repository/provider"
+	"sheriff/internal/retry"
 	"sheriff/internal/scanner"
 	"sheriff/internal/slack"
+	"sheriff/internal/state"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
 
 	"github.com/elliotchance/pie/v2"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/exp/slices"
+	"golang.org/x/sync/errgroup"
 )
 
-const tempScanDir = "tmp_scans"
+// tempScanDirPattern is the pattern of the name of the temporary directory in which the projects are scanned
+const tempScanDirPattern = "sheriff-scans-"
+
+// globalIgnoreReason is the acknowledgement reason of the vulnerabilities ignored in all projects
+const globalIgnoreReason = "Ignored in all projects"
+
+// errScanTimedOut is the cause of the cancellation of a project scan which exceeded the per-project timeout
+var errScanTimedOut = errors.New("scan timed out")
+
+// now is a function that returns the current time
+var now = time.Now
+
+// defaultDownloadBackoff is the initial backoff between attempts to download a project
+const defaultDownloadBackoff = 2 * time.Second
 
 // securityPatroller is the interface of the main security scanner service of this tool.
 type securityPatroller interface {
 	// Scans the given Gitlab groups and projects, creates and publishes the necessary reports
-	Patrol(args config.PatrolConfig) (warn error, err error)
+	Patrol(ctx context.Context, args config.PatrolConfig) (reports []scanner.Report, warn error, err error)
+	// Lists the projects which a patrol would scan, without downloading nor scanning them
+	List(args config.PatrolConfig) (projects []repository.Project, warn error)
 }
 
 // sheriffService is the implementation of the SecurityPatroller interface.
 type sheriffService struct {
-	repoService  provider.IProvider
-	slackService slack.IService
-	osvService   scanner.VulnScanner[scanner.OsvReport]
+	repoService     provider.IProvider
+	slackService    slack.IService
+	scanners        []scanner.ProjectScanner
+	epssService     epss.IService
+	downloadBackoff time.Duration
 }
 
 // New creates a new securityPatroller service.
 // It contains the main "loop" logic of this tool.
 // A "patrol" is defined as scanning GitLab groups for vulnerabilities and publishing reports where needed.
-func New(repoService provider.IProvider, slackService slack.IService, osvService scanner.VulnScanner[scanner.OsvReport]) securityPatroller {
+// Each project is scanned with all the given scanners, and their findings are merged into a single report.
+func New(repoService provider.IProvider, slackService slack.IService, scanners ...scanner.ProjectScanner) securityPatroller {
 	return &sheriffService{
-		repoService:  repoService,
-		slackService: slackService,
-		osvService:   osvService,
+		repoService:     repoService,
+		slackService:    slackService,
+		scanners:        scanners,
+		epssService:     epss.New(),
+		downloadBackoff: defaultDownloadBackoff,
 	}
 }
 
 // Patrol scans the given Gitlab groups and projects, creates and publishes the necessary reports.
-func (s *sheriffService) Patrol(args config.PatrolConfig) (warn error, err error) {
-	scanReports, swarn, err := s.scanAndGetReports(args.Locations, args.Ignored)
+// It returns the published reports.
+// If the context is cancelled, no new projects are scanned, the in-flight scans are aborted
+// and nothing is published.
+// If a target ref is configured, the projects are diffed between two refs instead, see diff.
+func (s *sheriffService) Patrol(ctx context.Context, args config.PatrolConfig) (reports []scanner.Report, warn error, err error) {
+	if args.TargetRef != "" {
+		return s.diff(ctx, args)
+	}
+
+	// Fail before scanning rather than when publishing the issues
+	var issueTemplate *template.Template
+	if args.ReportToIssue && args.IssueTemplate != "" {
+		if issueTemplate, err = publish.ParseIssueTemplate(args.IssueTemplate); err != nil {
+			return nil, nil, errors.Join(errors.New("invalid issue template"), err)
+		}
+	}
+
+	var previousState state.State
+	if args.StateFile != "" {
+		if previousState, err = state.Load(args.StateFile); err != nil {
+			return nil, nil, errors.Join(errors.New("failed to load state of previous run"), err)
+		}
+	}
+
+	start := now()
+	scanReports, unchanged, swarn, err := s.scanAndGetReports(ctx, args, previousState)
 	if err != nil {
-		return nil, errors.Join(errors.New("failed to scan projects"), err)
+		return nil, nil, errors.Join(errors.New("failed to scan projects"), err)
 	}
 	if swarn != nil {
 		swarn = errors.Join(errors.New("errors occured when scanning projects"), swarn)
 		warn = errors.Join(swarn, warn)
 	}
 
-	if len(scanReports) == 0 {
+	if args.MetricsFile != "" {
+		log.Info().Str("path", args.MetricsFile).Msg("Writing metrics of the scan")
+		if merr := publish.PublishAsMetrics(scanReports, now().Sub(start), args.MetricsFile); merr != nil {
+			log.Error().Err(merr).Str("path", args.MetricsFile).Msg("Failed to write metrics")
+			warn = errors.Join(merr, warn)
+		}
+	}
+
+	if len(scanReports) == 0 && len(unchanged) > 0 {
+		log.Info().Int("unchanged", len(unchanged)).Msg("No project changed since the last run, nothing to report")
+		return scanReports, warn, nil
+	} else if len(scanReports) == 0 {
 		log.Warn().Msg("No reports found. Check if projects and group paths are correct, and check the logs for any earlier errors.")
-		return swarn, nil
+		return scanReports, warn, nil
 	}
 
-	if args.ReportToIssue {
+	if args.EnableEpss {
+		log.Info().Msg("Fetching EPSS scores of the vulnerabilities")
+		s.epssService.Enrich(ctx, scanReports)
+	}
+
+	issueOpts := publish.IssueOpts{Title: args.IssueTitle, Assignees: args.IssueAssignees, Verbose: args.VerboseIssue, Comment: args.CloseIssueComment, Epss: args.EnableEpss, DryRun: args.DryRun, EnableProjectIssue: args.EnableProjectIssue, CloseAfterCleanCount: args.CloseAfterCleanCount, Previous: previousState, Template: issueTemplate, AttachRawReport: args.AttachRawReport}
+	if args.CentralIssue != nil {
+		log.Info().Str("project", args.CentralIssue.Path).Msg("Creating central issue of the affected projects")
+		if gwarn := publish.PublishAsCentralIssue(scanReports, *args.CentralIssue, issueOpts, s.repoService); gwarn != nil {
+			gwarn = errors.Join(errors.New("errors occured when creating the central issue"), gwarn)
+			warn = errors.Join(gwarn, warn)
+		}
+	} else if args.ReportToIssue || args.EnableProjectIssue {
 		log.Info().Msg("Creating issue in affected projects")
-		if gwarn := publish.PublishAsIssues(scanReports, s.repoService); gwarn != nil {
+		if gwarn := publish.PublishAsIssues(scanReports, issueOpts, s.repoService); gwarn != nil {
 			gwarn = errors.Join(errors.New("errors occured when creating issues"), gwarn)
 			warn = errors.Join(gwarn, warn)
 		}
 
 	}
 
-	if s.slackService != nil {
+	if s.slackService != nil && args.DryRun {
+		logSlackDryRun(scanReports, args)
+	} else if s.slackService != nil {
 		if len(args.ReportToSlackChannels) > 0 {
 			log.Info().Strs("slackChannels", args.ReportToSlackChannels).Msg("Posting report to slack channels")
 			paths := pie.Map(args.Locations, func(v config.ProjectLocation) string { return v.Path })
-			if err := publish.PublishAsGeneralSlackMessage(args.ReportToSlackChannels, scanReports, paths, s.slackService); err != nil {
+			var err error
+			if args.ReportSlackDelta {
+				err = publish.PublishAsDeltaSlackMessage(args.ReportToSlackChannels, scanReports, previousState, paths, s.slackService)
+			} else {
+				err = publish.PublishAsGeneralSlackMessage(args.ReportToSlackChannels, scanReports, paths, s.slackService)
+			}
+			if err != nil {
 				log.Error().Err(err).Msg("Failed to post slack report to some channels")
 				err = errors.Join(errors.New("failed to post slack report"), err)
 				warn = errors.Join(err, warn)
@@ -83,7 +171,7 @@ func (s *sheriffService) Patrol(args config.PatrolConfig) (warn error, err error
 
 		if args.EnableProjectReportTo {
 			log.Info().Msg("Posting report to project slack channel")
-			if swarn := publish.PublishAsSpecificChannelSlackMessage(scanReports, s.slackService); swarn != nil {
+			if swarn := publish.PublishAsSpecificChannelSlackMessage(scanReports, publish.SlackOpts{Thread: args.ReportSlackThread, Actions: args.ReportSlackActions, NewOnly: args.ReportSlackNewOnly}, s.slackService); swarn != nil {
 				swarn = errors.Join(errors.New("errors occured when posting to project slack channel"), swarn)
 				warn = errors.Join(swarn, warn)
 			}
@@ -91,60 +179,240 @@ func (s *sheriffService) Patrol(args config.PatrolConfig) (warn error, err error
 		}
 	}
 
-	publish.PublishToConsole(scanReports, args.SilentReport)
+	publish.PublishToConsole(scanReports, args.SilentReport, publish.ConsoleFormat(args.OutputFormat), publish.ConsoleDetail(args.ConsoleDetail))
+
+	if dwarn := publishToDestinations(scanReports, args); dwarn != nil {
+		dwarn = errors.Join(errors.New("errors occured when writing the report to its destinations"), dwarn)
+		warn = errors.Join(dwarn, warn)
+	}
+
+	if args.StateFile != "" && args.DryRun {
+		log.Info().Str("path", args.StateFile).Msg("Dry run: would save state of this run")
+	} else if args.StateFile != "" {
+		newState := state.FromReports(scanReports, previousState)
+		newState.KeepProjects(previousState, unchanged)
+		if err := state.Save(args.StateFile, newState); err != nil {
+			log.Error().Err(err).Str("path", args.StateFile).Msg("Failed to save state of this run")
+			warn = errors.Join(errors.New("failed to save state"), err, warn)
+		}
+	}
+
+	return scanReports, warn, nil
+}
+
+// diff scans the projects at the base and target refs, and publishes the vulnerabilities added and removed by the target ref.
+// Nothing else is published, so that scanning other branches does not affect the issues and messages of the default branch.
+// It returns the reports of the target ref.
+func (s *sheriffService) diff(ctx context.Context, args config.PatrolConfig) (reports []scanner.Report, warn error, err error) {
+	log.Info().Str("baseRef", args.BaseRef).Str("targetRef", args.TargetRef).Msg("Scanning projects at the base ref")
+	baseReports, _, bwarn, err := s.scanAndGetReportsAt(ctx, args, state.State{}, args.BaseRef)
+	if err != nil {
+		return nil, nil, errors.Join(errors.New("failed to scan projects at the base ref"), err)
+	}
+	if bwarn != nil {
+		warn = errors.Join(errors.New("errors occured when scanning projects at the base ref"), bwarn)
+	}
+
+	log.Info().Str("baseRef", args.BaseRef).Str("targetRef", args.TargetRef).Msg("Scanning projects at the target ref")
+	targetReports, _, twarn, err := s.scanAndGetReportsAt(ctx, args, state.State{}, args.TargetRef)
+	if err != nil {
+		return nil, nil, errors.Join(errors.New("failed to scan projects at the target ref"), err)
+	}
+	if twarn != nil {
+		warn = errors.Join(errors.New("errors occured when scanning projects at the target ref"), twarn, warn)
+	}
+
+	if derr := publish.PublishDiff(baseReports, targetReports, args.BaseRef, args.TargetRef, args.SilentReport, publish.ConsoleFormat(args.OutputFormat), args.DiffFile); derr != nil {
+		log.Error().Err(derr).Str("path", args.DiffFile).Msg("Failed to write diff")
+		warn = errors.Join(derr, warn)
+	}
+
+	return targetReports, warn, nil
+}
+
+// publishToDestinations writes the reports to each of the configured destinations
+func publishToDestinations(reports []scanner.Report, args config.PatrolConfig) (warn error) {
+	for _, d := range args.ReportDestinations {
+		if d.Kind == config.ReportToPagerDuty && args.DryRun {
+			log.Info().Msg("Dry run: would trigger PagerDuty events for projects with critical vulnerabilities")
+			continue
+		}
+
+		log.Info().Str("kind", d.Kind).Str("target", d.Target).Msg("Writing report to destination")
+
+		var err error
+		switch d.Kind {
+		case config.ReportToJUnit:
+			err = publish.PublishAsJUnit(reports, d.Target)
+		case config.ReportToHtml:
+			err = publish.PublishAsHtml(reports, d.Target)
+		case config.ReportToPagerDuty:
+			err = publish.PublishAsPagerDutyEvents(reports, args.PagerDutyRoutingKey)
+		case config.ReportToVex:
+			err = publish.PublishAsVex(reports, d.Target)
+		default:
+			err = fmt.Errorf("unknown report destination %v", d.Kind)
+		}
+
+		if err != nil {
+			log.Error().Err(err).Str("kind", d.Kind).Str("target", d.Target).Msg("Failed to write report to destination")
+			warn = errors.Join(err, warn)
+		}
+	}
+
+	return
+}
+
+// logSlackDryRun logs the slack messages which would be posted, without posting them
+func logSlackDryRun(reports []scanner.Report, args config.PatrolConfig) {
+	if len(args.ReportToSlackChannels) > 0 {
+		log.Info().Strs("slackChannels", args.ReportToSlackChannels).Bool("delta", args.ReportSlackDelta).Msg("Dry run: would post report to slack channels")
+	}
+
+	if args.EnableProjectReportTo {
+		for _, r := range reports {
+			if r.ProjectConfig.Report.To.SlackChannel != "" {
+				log.Info().Str("project", r.Project.Path).Str("slackChannel", r.ProjectConfig.Report.To.SlackChannel).Msg("Dry run: would post report to project slack channel")
+			}
+		}
+	}
+}
 
-	return warn, nil
+// scanAndGetReports scans the default branch of all projects of the configured locations and returns their reports.
+// In incremental mode, the projects unchanged since the previous run are not scanned,
+// and their paths are returned instead of a report.
+func (s *sheriffService) scanAndGetReports(ctx context.Context, args config.PatrolConfig, previous state.State) (reports []scanner.Report, unchanged []string, warn error, err error) {
+	return s.scanAndGetReportsAt(ctx, args, previous, "")
 }
 
-func (s *sheriffService) scanAndGetReports(locations []config.ProjectLocation, ignored []config.ProjectLocation) (reports []scanner.Report, warn error, err error) {
-	// Create a temporary directory to store the scans
-	err = os.MkdirAll(tempScanDir, os.ModePerm)
+// scanAndGetReportsAt is scanAndGetReports scanning the given branch of the projects, or their default branch if it is empty
+func (s *sheriffService) scanAndGetReportsAt(ctx context.Context, args config.PatrolConfig, previous state.State, ref string) (reports []scanner.Report, unchanged []string, warn error, err error) {
+	// Create a temporary directory to store the scans, within the OS temporary directory if none is configured
+	scanDir, err := os.MkdirTemp(args.TmpDir, tempScanDirPattern)
 	if err != nil {
-		return nil, nil, errors.New("could not create temporary directory")
+		return nil, nil, nil, errors.Join(errors.New("could not create temporary directory"), err)
 	}
-	defer os.RemoveAll(tempScanDir)
-	log.Info().Str("path", tempScanDir).Msg("Created temporary directory")
+	var keptFailedScans atomic.Bool
+	defer func() {
+		// The directory only holds the files of the failed scans at this point
+		if keptFailedScans.Load() {
+			log.Warn().Str("path", scanDir).Msg("Kept the files of the failed scans for debugging")
+			return
+		}
+		os.RemoveAll(scanDir)
+	}()
+	log.Info().Str("path", scanDir).Msg("Created temporary directory")
 
-	projects, pwarn := s.getProjectList(locations, ignored)
+	projects, pwarn := s.getProjectList(args.Locations, args.Ignored, args.ExcludePaths)
 	if pwarn != nil {
 		pwarn = errors.Join(errors.New("errors occured when getting project list"), pwarn)
 		warn = errors.Join(pwarn, warn)
 	}
+	for i := range projects {
+		projects[i].Ref = ref
+	}
+
+	progress := newProgress(len(projects), args.Progress)
+	progress.start()
 
-	// Scan all projects in parallel
-	var wg sync.WaitGroup
+	// Scan projects in parallel, with at most args.MaxConcurrency projects in-flight at once
+	g := new(errgroup.Group)
+	if args.MaxConcurrency > 0 {
+		g.SetLimit(args.MaxConcurrency)
+	}
+	var warnMutex sync.Mutex
 	reportsChan := make(chan scanner.Report, len(projects))
+	unchangedChan := make(chan string, len(projects))
 	for _, project := range projects {
-		wg.Add(1)
-		go func(reportsChan chan<- scanner.Report) {
-			defer wg.Done()
+		// Stop scheduling new scans once cancelled
+		if ctx.Err() != nil {
+			break
+		}
+
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if args.Incremental && s.isUnchangedSinceLastRun(project, previous) {
+				log.Info().Str("project", project.Path).Msg("Project unchanged since last run, skipping.")
+				unchangedChan <- project.Path
+				progress.skip()
+				return nil
+			}
+
 			log.Info().Str("project", project.Path).Msg("Scanning project")
-			if report, err := s.scanProject(project); err != nil {
-				log.Error().Err(err).Str("project", project.Path).Msg("Failed to scan project, skipping.")
+			projectCtx, cancel := ctx, context.CancelFunc(func() {})
+			if args.ProjectTimeout > 0 {
+				projectCtx, cancel = context.WithTimeoutCause(ctx, args.ProjectTimeout, errScanTimedOut)
+			}
+			// Measured once the project got its slot among the concurrent scans, so that it only reflects its own work
+			scanStart := now()
+			report, kept, err := s.scanProject(projectCtx, project, scanDir, args)
+			duration := now().Sub(scanStart)
+			kind := getErrorKind(err)
+			if err != nil && errors.Is(context.Cause(projectCtx), errScanTimedOut) {
+				err = errors.Join(fmt.Errorf("%w after %v", errScanTimedOut, args.ProjectTimeout), err)
+				kind = scanner.Timeout
+			}
+			cancel()
+			if kept {
+				keptFailedScans.Store(true)
+			}
+			if err != nil {
+				log.Error().Err(err).Str("project", project.Path).Str("kind", string(kind)).Msg("Failed to scan project, skipping.")
+				failed := scanner.NewFailedReport(project, kind, sheriffLog.Redact(strings.ReplaceAll(err.Error(), "\n", ": ")))
+				report = &failed
 				err = errors.Join(fmt.Errorf("failed to scan project %v", project.Path), err)
+				warnMutex.Lock()
 				warn = errors.Join(err, warn)
-				reportsChan <- scanner.Report{Project: project, Error: true}
-			} else {
-				reportsChan <- *report
+				warnMutex.Unlock()
 			}
-		}(reportsChan)
+			report.Duration = duration
+			reportsChan <- *report
+			progress.add(*report)
+
+			return nil
+		})
 	}
-	wg.Wait()
+	_ = g.Wait()
+	progress.finish()
 	close(reportsChan)
+	close(unchangedChan)
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, errors.Join(errors.New("patrol was cancelled"), err)
+	}
 
 	// Collect the reports
 	for r := range reportsChan {
 		reports = append(reports, r)
 	}
+	for path := range unchangedChan {
+		unchanged = append(unchanged, path)
+	}
 
+	// The scans finish in any order, so projects with as many vulnerabilities are sorted by path to keep the order stable
 	slices.SortFunc(reports, func(a, b scanner.Report) int {
-		return cmp.Compare(len(b.Vulnerabilities), len(a.Vulnerabilities))
+		return cmp.Or(
+			cmp.Compare(len(b.Vulnerabilities), len(a.Vulnerabilities)),
+			cmp.Compare(a.Project.Path, b.Project.Path),
+		)
 	})
 
 	return
 }
 
-func (s *sheriffService) getProjectList(locs []config.ProjectLocation, ignored []config.ProjectLocation) (projects []repository.Project, warn error) {
+// List returns the projects of the configured locations which Patrol would scan, without the ignored and excluded ones.
+// The projects are neither downloaded nor scanned.
+func (s *sheriffService) List(args config.PatrolConfig) (projects []repository.Project, warn error) {
+	return s.getProjectList(args.Locations, args.Ignored, args.ExcludePaths)
+}
+
+// getProjectList returns the projects of the given locations, without the ignored ones
+// and those whose path, or the path of one of their parent groups, matches any of the excluded glob patterns.
+// It warns if none of the locations has any project.
+func (s *sheriffService) getProjectList(locs []config.ProjectLocation, ignored []config.ProjectLocation, excluded []string) (projects []repository.Project, warn error) {
 	gitlabLocs := pie.Map(
 		pie.Filter(locs, func(loc config.ProjectLocation) bool { return loc.Type == repository.Gitlab }),
 		func(loc config.ProjectLocation) string { return loc.Path },
@@ -174,6 +442,11 @@ func (s *sheriffService) getProjectList(locs []config.ProjectLocation, ignored [
 		projects = append(projects, githubProjects...)
 	}
 
+	// Paths which are not found or empty are only logged, the patrol fails only if none of them has projects
+	if len(locs) > 0 && len(projects) == 0 {
+		warn = errors.Join(errors.New("no projects found in any of the paths to scan, check the logs for paths which were not found or are empty"), warn)
+	}
+
 	// Filter out locations that are in the ignored list
 	projects = pie.Filter(projects, func(project repository.Project) bool {
 		return !slices.ContainsFunc(ignored, func(ignoredPath config.ProjectLocation) bool {
@@ -185,76 +458,349 @@ func (s *sheriffService) getProjectList(locs []config.ProjectLocation, ignored [
 		})
 	})
 
+	projects = pie.Filter(projects, func(project repository.Project) bool {
+		if isExcludedPath(project.Path, excluded) {
+			log.Info().Str("path", project.Path).Msg("Excluding project as its path matches an excluded pattern")
+			return false
+		}
+		return true
+	})
+
 	return
 }
 
-// scanProject scans a project for vulnerabilities using the osv scanner.
-func (s *sheriffService) scanProject(project repository.Project) (report *scanner.Report, err error) {
-	dir, err := os.MkdirTemp(tempScanDir, fmt.Sprintf("%v-", project.Slug))
+// isExcludedPath returns whether the given project path, or the path of any of its parent groups, matches any of the patterns
+func isExcludedPath(projectPath string, patterns []string) bool {
+	parts := strings.Split(projectPath, "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		if slices.ContainsFunc(patterns, func(p string) bool {
+			ok, _ := path.Match(strings.TrimSuffix(p, "/"), prefix)
+			return ok
+		}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isUnchangedSinceLastRun returns whether the project can be skipped in incremental mode,
+// which is the case if its default branch is still at the commit scanned in the previous run.
+// Projects which were vulnerable in the previous run are always scanned again,
+// so that their issue is updated or closed once their vulnerabilities are resolved.
+func (s *sheriffService) isUnchangedSinceLastRun(project repository.Project, previous state.State) bool {
+	p, ok := previous.Projects[project.Path]
+	if !ok || p.CommitSha == "" || len(p.Vulnerabilities) > 0 {
+		return false
+	}
+
+	sha, err := s.repoService.Provide(project.Repository).GetLatestCommitSha(project)
+	if err != nil {
+		log.Warn().Err(err).Str("project", project.Path).Msg("Failed to get latest commit of project, scanning it anyway")
+		return false
+	}
+
+	return sha == p.CommitSha
+}
+
+// scanFailure is an error of the scan of a project, with the reason of the failure
+type scanFailure struct {
+	kind scanner.ErrorKind
+	err  error
+}
+
+func (f *scanFailure) Error() string { return f.err.Error() }
+
+func (f *scanFailure) Unwrap() error { return f.err }
+
+// getErrorKind returns the reason of the failure of a scan, which is a failure of the scan itself unless told otherwise
+func getErrorKind(err error) scanner.ErrorKind {
+	if err == nil {
+		return ""
+	}
+
+	var failure *scanFailure
+	if errors.As(err, &failure) {
+		return failure.kind
+	}
+
+	return scanner.ScanFailed
+}
+
+// splitWarnings returns the message of each of the joined warnings, on a single line
+func splitWarnings(warn error) (messages []string) {
+	if warn == nil {
+		return nil
+	}
+
+	if joined, ok := warn.(interface{ Unwrap() []error }); ok {
+		for _, w := range joined.Unwrap() {
+			messages = append(messages, splitWarnings(w)...)
+		}
+		return
+	}
+
+	return []string{sheriffLog.Redact(strings.ReplaceAll(warn.Error(), "\n", ": "))}
+}
+
+// scanProject downloads a project into a new directory of scanDir, and scans it for vulnerabilities with all the scanners.
+// The directory is removed once done, unless the scan fails and args.KeepScansOnFailure is set, in which case kept is true.
+func (s *sheriffService) scanProject(ctx context.Context, project repository.Project, scanDir string, args config.PatrolConfig) (report *scanner.Report, kept bool, err error) {
+	dir, err := os.MkdirTemp(scanDir, fmt.Sprintf("%v-", project.Slug))
 	if err != nil {
-		return nil, errors.Join(errors.New("failed to create project temporary directory"), err)
+		return nil, false, errors.Join(errors.New("failed to create project temporary directory"), err)
 	}
-	defer os.RemoveAll(dir)
+	defer func() {
+		if err != nil && args.KeepScansOnFailure {
+			log.Warn().Str("project", project.Path).Str("dir", dir).Msg("Keeping the files of the failed scan")
+			kept = true
+			return
+		}
+		os.RemoveAll(dir)
+	}()
 
 	// Download the project
 	log.Info().Str("project", project.Path).Str("dir", dir).Str("url", project.RepoUrl).Msg("Cloning project")
-	if err := s.repoService.Provide(project.Repository).Download(project, dir); err != nil {
-		return nil, errors.Join(fmt.Errorf("failed to clone project %v", project.Path), err)
+	sha, err := s.downloadProject(ctx, project, dir, args.CloneRetries)
+	if err != nil {
+		return nil, false, &scanFailure{scanner.CloneFailed, errors.Join(fmt.Errorf("failed to clone project %v", project.Path), err)}
+	}
+	if hasSubmodules(dir) {
+		log.Warn().Str("project", project.Path).Msg("Project has git submodules, which are not included in its downloaded archive: the manifests within them are not scanned")
 	}
 
-	config := config.GetProjectConfiguration(project.Path, dir)
-
-	// Scan the project
-	log.Info().Str("project", project.Path).Msg("Running osv-scanner")
-	osvReport, err := s.osvService.Scan(dir)
+	config, configWarn, err := config.GetProjectConfiguration(project.Path, dir)
+	if err == nil && configWarn != nil && args.StrictConfig {
+		err = errors.Join(errors.New("malformed configuration in strict mode"), configWarn)
+	}
 	if err != nil {
-		log.Error().Err(err).Str("project", project.Path).Msg("Failed to run osv-scanner")
-		return nil, errors.Join(errors.New("failed to run osv-scanner"), err)
+		return nil, false, &scanFailure{scanner.ConfigInvalid, errors.Join(fmt.Errorf("failed to get project configuration of %v", project.Path), err)}
+	}
+
+	// Scan the project with every scanner, a project is only reported if all of them succeed
+	scannerReports := make([]scanner.Report, 0, len(s.scanners))
+	for _, sc := range s.scanners {
+		log.Info().Str("project", project.Path).Str("scanner", sc.Name()).Msg("Running scanner")
+		sr, err := sc.ScanProject(ctx, project, dir)
+		if err != nil {
+			log.Error().Err(err).Str("project", project.Path).Str("scanner", sc.Name()).Msg("Failed to run scanner")
+			return nil, false, errors.Join(fmt.Errorf("failed to run scanner %v", sc.Name()), err)
+		}
+		log.Info().Str("project", project.Path).Str("scanner", sc.Name()).Msg("Finished scanning")
+		scannerReports = append(scannerReports, sr)
 	}
 
-	r := s.osvService.GenerateReport(project, osvReport)
-	log.Info().Str("project", project.Path).Msg("Finished scanning with osv-scanner")
+	r := scanner.MergeReports(scannerReports)
 
 	r.ProjectConfig = config
+	r.CommitSha = sha
+	r.ConfigWarnings = splitWarnings(configWarn)
 
+	removeVulnsInIgnoredPaths(&r, append(slices.Clone(args.IgnorePaths), config.IgnorePaths...))
+	r.Suppressed = removeVulnsInIgnoredPaths(&r, config.SuppressedPaths)
 	markVulnsAsAcknowledgedInReport(&r, config)
+	markIgnoredVulnsInReport(&r, args.IgnoredVulns)
 	markOutdatedAcknowledgements(&r, config)
-	return &r, nil
+	markReportVulnerability(&r, getSeverityThreshold(args, config))
+	if args.FailOnLicense && len(r.LicenseViolations) > 0 {
+		r.IsVulnerable = true
+	}
+	return &r, false, nil
 }
 
-// markVulnsAsAcknowledgedInReport marks vulnerabilities as acknowledged in the report
-// if the user has acknowledged them in the project configuration.
+// hasSubmodules returns whether the project downloaded in the given directory declares git submodules.
+// Projects are downloaded as archives of their repository, which never include the content of the submodules.
+func hasSubmodules(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".gitmodules"))
+
+	return err == nil
+}
+
+// downloadProject downloads the project into the given directory,
+// retrying up to the given number of times on transient failures, see isTransientDownloadError.
+// The directory is emptied before each retry so that no partial download is left behind.
+// It returns the sha of the downloaded commit, which is empty if it is not known.
+func (s *sheriffService) downloadProject(ctx context.Context, project repository.Project, dir string, retries int) (sha string, err error) {
+	attempt := 0
+	err = retry.DoIf(ctx, func() (err error) {
+		attempt++
+		if attempt > 1 {
+			log.Info().Str("project", project.Path).Int("attempt", attempt).Msg("Retrying to clone project")
+			if err := os.RemoveAll(dir); err != nil {
+				return errors.Join(errors.New("failed to clean up project temporary directory"), err)
+			}
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return errors.Join(errors.New("failed to recreate project temporary directory"), err)
+			}
+		}
+
+		sha, err = s.repoService.Provide(project.Repository).Download(ctx, project, dir)
+		return err
+	}, isTransientDownloadError, retries+1, s.downloadBackoff)
+
+	return sha, err
+}
+
+// isTransientDownloadError returns whether the download may succeed if retried, which is the case of network errors
+// and of 5xx responses. Requests rejected by the platform, e.g. for a missing project or an invalid token, and archives
+// which are malformed or exceed the size limits would fail again, and a decompression bomb should not be downloaded over and over.
+func isTransientDownloadError(err error) bool {
+	var rejected *repository.RejectedError
+	return !errors.As(err, &rejected) && !errors.Is(err, compress.ErrLimitExceeded) && !errors.Is(err, compress.ErrInvalidArchive)
+}
+
+// getSeverityThreshold returns the severity threshold to apply to a project,
+// where the project-level configuration takes precedence over the patrol-level one.
+func getSeverityThreshold(args config.PatrolConfig, projectConfig config.ProjectConfig) scanner.SeverityScoreKind {
+	if projectConfig.SeverityThreshold != "" {
+		return scanner.SeverityScoreKind(projectConfig.SeverityThreshold)
+	}
+
+	return scanner.SeverityScoreKind(args.SeverityThreshold)
+}
+
+// markReportVulnerability sets whether the report is vulnerable, only counting the vulnerabilities
+// at or above the given severity threshold. With an empty threshold, any vulnerability counts.
+// Vulnerabilities below the threshold are kept in the report.
+func markReportVulnerability(report *scanner.Report, threshold scanner.SeverityScoreKind) {
+	if threshold == "" {
+		report.IsVulnerable = len(report.Vulnerabilities) > 0
+		return
+	}
+
+	report.IsVulnerable = pie.Any(report.Vulnerabilities, func(v scanner.Vulnerability) bool {
+		return scanner.MeetsSeverityThreshold(v.SeverityScoreKind, threshold)
+	})
+}
+
+// removeVulnsInIgnoredPaths removes from the report the lockfiles matching any of the given glob patterns,
+// relative to the project root, from the sources of each vulnerability.
+// Vulnerabilities only found in ignored lockfiles are removed from the report altogether, and returned.
+// A pattern matches the lockfile itself or any of its parent directories, and a pattern
+// without a '/' matches a file or directory of that name at any depth.
 // It modifies the given report in place.
-func markVulnsAsAcknowledgedInReport(report *scanner.Report, config config.ProjectConfig) {
-	ackCodes := make(map[string]bool, len(config.Acknowledged))
-	AckReasons := make(map[string]string, len(config.Acknowledged))
-	for _, ack := range config.Acknowledged {
-		ackCodes[ack.Code] = true
-		AckReasons[ack.Code] = ack.Reason
+func removeVulnsInIgnoredPaths(report *scanner.Report, patterns []string) (removed []scanner.Vulnerability) {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	vulns := make([]scanner.Vulnerability, 0, len(report.Vulnerabilities))
+	for _, v := range report.Vulnerabilities {
+		sources := pie.Filter(v.Sources, func(src string) bool { return !isInIgnoredPath(src, patterns) })
+		if len(sources) == 0 {
+			log.Debug().Str("project", report.Project.Path).Str("vuln", v.Id).Strs("sources", v.Sources).Msg("Ignoring vulnerability found in ignored paths")
+			removed = append(removed, v)
+			continue
+		}
+
+		v.Sources = sources
+		v.Source = filepath.Base(sources[0])
+		vulns = append(vulns, v)
+	}
+	report.Vulnerabilities = vulns
+
+	violations := make([]scanner.LicenseViolation, 0, len(report.LicenseViolations))
+	for _, v := range report.LicenseViolations {
+		if v.Sources = pie.Filter(v.Sources, func(src string) bool { return !isInIgnoredPath(src, patterns) }); len(v.Sources) > 0 {
+			violations = append(violations, v)
+		}
+	}
+	report.LicenseViolations = violations
+
+	return removed
+}
+
+// isInIgnoredPath returns whether the given path, relative to the project root, or any of its parent directories matches any of the patterns
+func isInIgnoredPath(path string, patterns []string) bool {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		for _, p := range patterns {
+			p = strings.TrimSuffix(filepath.ToSlash(p), "/")
+			if !strings.Contains(p, "/") {
+				if ok, _ := filepath.Match(p, parts[i]); ok {
+					return true
+				}
+			} else if ok, _ := filepath.Match(p, prefix); ok {
+				return true
+			}
+		}
 	}
 
+	return false
+}
+
+// markVulnsAsAcknowledgedInReport marks vulnerabilities as acknowledged in the report
+// if the user has acknowledged them in the project configuration, by their code or by their package.
+// Acknowledgements past their expiry date are not applied, and are listed in the report's ExpiredAcks instead.
+// It modifies the given report in place.
+func markVulnsAsAcknowledgedInReport(report *scanner.Report, config config.ProjectConfig) {
 	for i, v := range report.Vulnerabilities {
-		if _, ok := ackCodes[v.Id]; ok {
+		var acknowledged, expired bool
+		var reason string
+		for _, ack := range config.Acknowledged {
+			if !acknowledges(ack, v) {
+				continue
+			}
+			if ack.IsExpired(now()) {
+				expired = true
+				continue
+			}
+			// The reason of an acknowledgement of the vulnerability prevails over the one of its whole package
+			if !acknowledged || ack.Code != "" {
+				reason = ack.Reason
+			}
+			acknowledged = true
+		}
+
+		// Expired acknowledgements no longer apply, the vulnerability keeps its real severity
+		if expired && !acknowledged {
+			log.Info().Str("project", report.Project.Path).Str("ack", v.Id).Msg("Acknowledgement of vulnerability has expired")
+			report.ExpiredAcks = append(report.ExpiredAcks, v.Id)
+			continue
+		}
+
+		if acknowledged {
 			// We override the severity kind
 			report.Vulnerabilities[i].SeverityScoreKind = scanner.Acknowledged
-			if _, ok := AckReasons[v.Id]; ok {
-				report.Vulnerabilities[i].AckReason = AckReasons[v.Id]
-			}
+			report.Vulnerabilities[i].AckReason = reason
 		}
 	}
 }
 
+// acknowledges returns whether the acknowledgement applies to the vulnerability:
+// each of its code, package, version and ecosystem must match the vulnerability if set.
+// The code may be any of the identifiers of the vulnerability, as those of the merged duplicates are its aliases.
+func acknowledges(ack config.AcknowledgedVuln, v scanner.Vulnerability) bool {
+	return (ack.Code == "" || slices.Contains(scanner.Identifiers(v), ack.Code)) &&
+		(ack.Package == "" || ack.Package == v.PackageName) &&
+		(ack.Version == "" || ack.Version == v.PackageVersion) &&
+		(ack.Ecosystem == "" || strings.EqualFold(ack.Ecosystem, v.PackageEcosystem))
+}
+
+// markIgnoredVulnsInReport marks the vulnerabilities ignored globally with any of the given ids as acknowledged in the report,
+// keeping the reason of those already acknowledged in the project configuration.
+// It modifies the given report in place.
+func markIgnoredVulnsInReport(report *scanner.Report, ignoredIds []string) {
+	for i, v := range report.Vulnerabilities {
+		isIgnored := slices.ContainsFunc(scanner.Identifiers(v), func(id string) bool { return slices.Contains(ignoredIds, id) })
+		if v.SeverityScoreKind == scanner.Acknowledged || !isIgnored {
+			continue
+		}
+
+		report.Vulnerabilities[i].SeverityScoreKind = scanner.Acknowledged
+		report.Vulnerabilities[i].AckReason = globalIgnoreReason
+	}
+}
+
 // markOutdatedAcknowledgements marks configured acknowledged vulnerabilities as outdated in the report
-// A vulnerability is "outdated" if it is no longer present in the report.
+// An acknowledgement is "outdated" if none of the vulnerabilities of the report matches it anymore.
 func markOutdatedAcknowledgements(report *scanner.Report, config config.ProjectConfig) {
-	var vulnCodes = make(map[string]bool, len(report.Vulnerabilities))
-	for _, vuln := range report.Vulnerabilities {
-		vulnCodes[vuln.Id] = true
-	}
 	for _, ack := range config.Acknowledged {
-		if !vulnCodes[ack.Code] {
-			log.Info().Str("ack", ack.Code).Msg("Acknowledged vulnerability is outdated")
-			report.OutdatedAcks = append(report.OutdatedAcks, ack.Code)
+		if !slices.ContainsFunc(report.Vulnerabilities, func(v scanner.Vulnerability) bool { return acknowledges(ack, v) }) {
+			log.Info().Str("ack", ack.Subject()).Msg("Acknowledged vulnerability is outdated")
+			report.OutdatedAcks = append(report.OutdatedAcks, ack.Subject())
 		}
 	}
 }
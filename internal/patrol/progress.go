@@ -0,0 +1,144 @@
+package patrol
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sheriff/internal/scanner"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// progressInterval is the interval between two progress log lines
+var progressInterval = 10 * time.Second
+
+// progressBarWidth is the number of characters of the progress bar, excluding the counters
+const progressBarWidth = 30
+
+// progress tracks how many projects of a patrol have been scanned so far, and reports it periodically.
+// It is safe to update from several goroutines.
+type progress struct {
+	total      int
+	scanned    atomic.Int64
+	vulnerable atomic.Int64
+	failed     atomic.Int64
+
+	bar      io.Writer     // If set, a progress bar is rendered to it on each update instead of logging the progress
+	logLevel zerolog.Level // Level of the progress log lines
+	barMutex sync.Mutex
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+// newProgress creates the progress of a patrol of the given number of projects.
+// If enabled, a progress bar is rendered to stderr when it is a terminal,
+// and the progress is logged whatever the log level otherwise. Else it is only logged in verbose mode.
+func newProgress(total int, enabled bool) *progress {
+	p := &progress{total: total, logLevel: zerolog.InfoLevel}
+	if enabled {
+		if isTerminal(os.Stderr) {
+			p.bar = os.Stderr
+		} else {
+			p.logLevel = zerolog.NoLevel
+		}
+	}
+
+	return p
+}
+
+// start logs the progress every progressInterval, until finish is called
+func (p *progress) start() {
+	p.stop = make(chan struct{})
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if p.bar == nil {
+					p.log()
+				}
+			}
+		}
+	}()
+}
+
+// add records a scanned project
+func (p *progress) add(r scanner.Report) {
+	if r.Error {
+		p.failed.Add(1)
+	} else if r.IsVulnerable {
+		p.vulnerable.Add(1)
+	}
+	p.skip()
+}
+
+// skip records a project which was not scanned, e.g. because it did not change since the last run
+func (p *progress) skip() {
+	p.scanned.Add(1)
+	if p.bar != nil {
+		p.render()
+	}
+}
+
+// finish stops the periodic logging and reports the final progress
+func (p *progress) finish() {
+	if p.stop != nil {
+		close(p.stop)
+		p.stopped.Wait()
+	}
+
+	if p.bar != nil {
+		p.barMutex.Lock()
+		defer p.barMutex.Unlock()
+		// Leave the bar as it is, so that the following output starts on a new line
+		fmt.Fprintln(p.bar)
+		return
+	}
+	p.log()
+}
+
+func (p *progress) log() {
+	log.WithLevel(p.logLevel).
+		Int64("scanned", p.scanned.Load()).
+		Int("total", p.total).
+		Int64("vulnerable", p.vulnerable.Load()).
+		Int64("failed", p.failed.Load()).
+		Msg(p.summary())
+}
+
+// render draws the progress bar over the previous one
+func (p *progress) render() {
+	p.barMutex.Lock()
+	defer p.barMutex.Unlock()
+
+	filled := progressBarWidth
+	if p.total > 0 {
+		filled = min(int(p.scanned.Load())*progressBarWidth/p.total, progressBarWidth)
+	}
+	fmt.Fprintf(p.bar, "\r[%v%v] %v", strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled), p.summary())
+}
+
+func (p *progress) summary() string {
+	summary := fmt.Sprintf("Scanned %v/%v projects, %v vulnerable so far", p.scanned.Load(), p.total, p.vulnerable.Load())
+	if failed := p.failed.Load(); failed > 0 {
+		summary += fmt.Sprintf(", %v failed", failed)
+	}
+
+	return summary
+}
+
+// isTerminal returns whether the given file is a terminal, rather than e.g. a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,60 @@
+package patrol
+
+import (
+	"bytes"
+	"sheriff/internal/scanner"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressCountsConcurrentUpdates(t *testing.T) {
+	p := newProgress(30, false)
+	p.start()
+
+	var wg sync.WaitGroup
+	for i := range 30 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch i % 3 {
+			case 0:
+				p.add(scanner.Report{IsVulnerable: true})
+			case 1:
+				p.add(scanner.Report{Error: true})
+			default:
+				p.skip()
+			}
+		}()
+	}
+	wg.Wait()
+	p.finish()
+
+	assert.Equal(t, int64(30), p.scanned.Load())
+	assert.Equal(t, int64(10), p.vulnerable.Load())
+	assert.Equal(t, int64(10), p.failed.Load())
+	assert.Equal(t, "Scanned 30/30 projects, 10 vulnerable so far, 10 failed", p.summary())
+}
+
+func TestProgressRendersBar(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{total: 4, bar: &out}
+
+	p.add(scanner.Report{IsVulnerable: true})
+	p.add(scanner.Report{})
+	p.finish()
+
+	lines := strings.Split(out.String(), "\r")
+	assert.Equal(t, "[###############---------------] Scanned 2/4 projects, 1 vulnerable so far\n", lines[len(lines)-1])
+}
+
+func TestProgressRendersBarWithoutProjects(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{total: 0, bar: &out}
+
+	p.render()
+
+	assert.Equal(t, "\r[##############################] Scanned 0/0 projects, 0 vulnerable so far", out.String())
+}
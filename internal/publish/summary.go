@@ -0,0 +1,43 @@
+package publish
+
+import (
+	"sheriff/internal/scanner"
+)
+
+// patrolSummary aggregates the vulnerabilities of all the reports of a patrol
+type patrolSummary struct {
+	UniqueVulnerabilities int // Number of distinct vulnerabilities, counting once those found in several projects
+	UniquePackages        int // Number of distinct packages affected by at least one vulnerability, whatever their version
+}
+
+// summarizePatrol counts the distinct vulnerabilities and affected packages across all the reports.
+// A vulnerability is identified by its OSV id or any of its aliases, so that the same vulnerability
+// reported under different ids by different scanners is only counted once.
+func summarizePatrol(reports []scanner.Report) (summary patrolSummary) {
+	seenIds := make(map[string]bool)
+	seenPackages := make(map[string]bool)
+
+	for _, r := range reports {
+		for _, v := range r.Vulnerabilities {
+			ids := append([]string{v.Id}, v.Aliases...)
+			known := false
+			for _, id := range ids {
+				known = known || seenIds[id]
+			}
+			for _, id := range ids {
+				seenIds[id] = true
+			}
+			if !known {
+				summary.UniqueVulnerabilities++
+			}
+
+			pkg := v.PackageEcosystem + "/" + v.PackageName
+			if !seenPackages[pkg] {
+				seenPackages[pkg] = true
+				summary.UniquePackages++
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,44 @@
+package publish
+
+import (
+	"sheriff/internal/scanner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizePatrolCountsSharedVulnerabilityOnce(t *testing.T) {
+	reports := []scanner.Report{
+		{
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "CVE-2022-25883", PackageName: "semver", PackageVersion: "7.3.7", PackageEcosystem: "npm"},
+			},
+		},
+		{
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "CVE-2022-25883", PackageName: "semver", PackageVersion: "7.3.8", PackageEcosystem: "npm"},
+			},
+		},
+	}
+
+	assert.Equal(t, patrolSummary{UniqueVulnerabilities: 1, UniquePackages: 1}, summarizePatrol(reports))
+}
+
+func TestSummarizePatrolMatchesAliases(t *testing.T) {
+	reports := []scanner.Report{
+		{
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "GHSA-c2qf-rxjj-qqgw", Aliases: []string{"CVE-2022-25883"}, PackageName: "semver", PackageEcosystem: "npm"},
+				{Id: "GO-2023-0001", PackageName: "golang.org/x/net", PackageEcosystem: "Go"},
+			},
+		},
+		{
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "CVE-2022-25883", PackageName: "semver", PackageEcosystem: "npm"},
+			},
+		},
+		{Error: true},
+	}
+
+	assert.Equal(t, patrolSummary{UniqueVulnerabilities: 2, UniquePackages: 2}, summarizePatrol(reports))
+}
@@ -0,0 +1,135 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sheriff/internal/config"
+	"sheriff/internal/repository"
+	"sheriff/internal/repository/provider"
+	"sheriff/internal/scanner"
+	"strconv"
+	"strings"
+
+	"github.com/elliotchance/pie/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// PublishAsCentralIssue creates or updates a single issue in the project at the given location, listing the
+// vulnerabilities of all the vulnerable projects, instead of an issue in each of them.
+// The issue is closed once none of the projects is vulnerable, and all of them have been clean for
+// opts.CloseAfterCleanCount runs. The URL of the issue is added to the reports of the vulnerable projects.
+func PublishAsCentralIssue(reports []scanner.Report, location config.ProjectLocation, opts IssueOpts, s provider.IProvider) error {
+	vulnerable := pie.Filter(reports, func(r scanner.Report) bool { return r.IsVulnerable })
+	if len(vulnerable) == 0 && !pie.All(reports, func(r scanner.Report) bool { return isIssueClosable(r, opts) }) {
+		log.Info().Str("project", location.Path).Int("closeAfter", opts.CloseAfterCleanCount).Msg("Projects not clean for long enough, keeping the central issue as is")
+		return nil
+	}
+
+	if opts.DryRun {
+		if len(vulnerable) > 0 {
+			log.Info().Str("project", location.Path).Str("title", opts.Title).Int("vulnerable", len(vulnerable)).Msg("Dry run: would open or update central issue")
+		} else {
+			log.Info().Str("project", location.Path).Str("title", opts.Title).Msg("Dry run: would close central issue")
+		}
+		return nil
+	}
+
+	repoService := s.Provide(location.Type)
+	project, err := getCentralIssueProject(repoService, location.Path)
+	if err != nil {
+		return err
+	}
+
+	if len(vulnerable) == 0 {
+		var comment string
+		if opts.Comment {
+			comment = formatCloseComment()
+		}
+		if err := repoService.CloseVulnerabilityIssue(project, opts.Title, comment); err != nil {
+			return errors.Join(fmt.Errorf("failed to close central issue in project %v", project.Path), err)
+		}
+
+		return nil
+	}
+
+	issue, err := repoService.OpenVulnerabilityIssue(project, opts.Title, formatCentralIssue(vulnerable, opts), opts.Assignees)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to open or update central issue in project %v", project.Path), err)
+	}
+	log.Info().Str("project", project.Path).Str("url", issue.WebURL).Int("vulnerable", len(vulnerable)).Msg("Opened or updated central issue")
+
+	for i := range reports {
+		if reports[i].IsVulnerable {
+			reports[i].IssueUrl = issue.WebURL
+		}
+	}
+
+	return nil
+}
+
+// getCentralIssueProject returns the project at the given path, which must be a project rather than a group
+func getCentralIssueProject(repoService repository.IRepositoryService, path string) (repository.Project, error) {
+	projects, warn := repoService.GetProjectList([]string{path})
+	for _, p := range projects {
+		// GitHub paths are case-insensitive
+		if strings.EqualFold(p.Path, path) {
+			return p, nil
+		}
+	}
+
+	return repository.Project{}, errors.Join(fmt.Errorf("project %v of the central issue not found", path), warn)
+}
+
+// formatCentralIssue formats the reports of the vulnerable projects as a single issue, with a summary table
+// linking to each project, followed by a collapsible section with the tables of the vulnerabilities of each project
+func formatCentralIssue(reports []scanner.Report, opts IssueOpts) (md string) {
+	reports = pie.SortUsing(reports, func(a, b scanner.Report) bool { return a.Project.Path < b.Project.Path })
+	kinds := pie.Filter(severityScoreOrder(), func(k scanner.SeverityScoreKind) bool { return k != scanner.Acknowledged })
+
+	md = getCentralIssueHeader(len(reports))
+	md += "\n\n## Vulnerable projects\n\n"
+	columns := append([]string{"Project"}, pie.Map(kinds, func(k scanner.SeverityScoreKind) string { return string(k) })...)
+	columns = append(columns, "License violations")
+	md += formatMarkdownRow(columns)
+	md += formatMarkdownRow(pie.Map(columns, func(string) string { return "---" }))
+	for _, r := range reports {
+		row := []string{formatProjectLink(r.Project)}
+		for _, kind := range kinds {
+			count := len(pie.Filter(r.Vulnerabilities, func(v scanner.Vulnerability) bool { return v.SeverityScoreKind == kind }))
+			row = append(row, strconv.Itoa(count))
+		}
+		row = append(row, strconv.Itoa(len(r.LicenseViolations)))
+		md += formatMarkdownRow(row)
+	}
+
+	for _, r := range reports {
+		tables, _ := formatIssueTables(r.Vulnerabilities, opts.Epss)
+		md += fmt.Sprintf("\n<details><summary>%v</summary>\n", html.EscapeString(r.Project.Path))
+		md += tables
+		md += formatLicenseViolations(r.LicenseViolations)
+		md += "\n</details>\n"
+	}
+
+	return
+}
+
+// formatProjectLink formats the path of the project as a markdown link to the project, if its URL is known
+func formatProjectLink(p repository.Project) string {
+	if p.WebURL == "" {
+		return p.Path
+	}
+
+	return fmt.Sprintf("[%v](%v)", p.Path, p.WebURL)
+}
+
+// getCentralIssueHeader returns the header of the central issue of the given number of vulnerable projects
+func getCentralIssueHeader(count int) string {
+	return fmt.Sprintf(`
+ℹ️ This issue lists the %d projects in which [Sheriff](https://github.com/elementsinteractive/sheriff) found vulnerabilities on %s.
+
+Please review the vulnerabilities of each project and take the necessary actions to fix or acknowledge them, see the [sheriff documentation](https://github.com/elementsinteractive/sheriff) for more information.`,
+		count,
+		now().Local().Format("2006-01-02"),
+	)
+}
@@ -0,0 +1,105 @@
+package publish
+
+import (
+	"sheriff/internal/config"
+	"sheriff/internal/repository"
+	"sheriff/internal/scanner"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var centralIssueLocation = config.ProjectLocation{Type: repository.Gitlab, Path: "group/security"}
+
+var centralIssueProject = repository.Project{ID: 10, Path: "group/security", Repository: repository.Gitlab}
+
+func TestPublishAsCentralIssue(t *testing.T) {
+	var body string
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("GetProjectList", []string{"group/security"}).Return([]repository.Project{centralIssueProject}, nil)
+	mockGitlabService.On("OpenVulnerabilityIssue", centralIssueProject, "Custom title", mock.Anything, []string{"alice"}).Run(func(args mock.Arguments) {
+		body = args.String(2)
+	}).Return(&repository.Issue{WebURL: "https://gitlab.com/group/security/-/issues/1"}, nil).Once()
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	reports := []scanner.Report{
+		{
+			Project:         repository.Project{Path: "group/b", WebURL: "https://gitlab.com/group/b"},
+			IsVulnerable:    true,
+			Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-1", PackageName: "lodash", SeverityScoreKind: scanner.Critical}, {Id: "CVE-2", PackageName: "semver", SeverityScoreKind: scanner.Low}},
+		},
+		{
+			Project:         repository.Project{Path: "group/a", WebURL: "https://gitlab.com/group/a"},
+			IsVulnerable:    true,
+			Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-3", PackageName: "express", SeverityScoreKind: scanner.High}},
+		},
+		{
+			Project:      repository.Project{Path: "group/c"},
+			IsVulnerable: true,
+			LicenseViolations: []scanner.LicenseViolation{
+				{PackageName: "left-pad", PackageVersion: "1.3.0", PackageEcosystem: "npm", Licenses: []string{"WTFPL"}},
+			},
+		},
+		{Project: repository.Project{Path: "group/clean"}},
+	}
+
+	err := PublishAsCentralIssue(reports, centralIssueLocation, IssueOpts{Title: "Custom title", Assignees: []string{"alice"}}, mockRepoService)
+
+	require.NoError(t, err)
+	mockGitlabService.AssertExpectations(t)
+	mockGitlabService.AssertNotCalled(t, "CloseVulnerabilityIssue", mock.Anything, mock.Anything, mock.Anything)
+
+	assert.Contains(t, body, "This issue lists the 3 projects")
+	assert.Contains(t, body, "| Project | CRITICAL | HIGH | MODERATE | LOW | UNKNOWN | License violations |\n")
+	assert.Contains(t, body, "| [group/a](https://gitlab.com/group/a) | 0 | 1 | 0 | 0 | 0 | 0 |\n")
+	assert.Contains(t, body, "| [group/b](https://gitlab.com/group/b) | 1 | 0 | 0 | 1 | 0 | 0 |\n")
+	assert.Contains(t, body, "| group/c | 0 | 0 | 0 | 0 | 0 | 1 |\n")
+	assert.NotContains(t, body, "group/clean")
+	assert.Less(t, strings.Index(body, "<summary>group/a</summary>"), strings.Index(body, "<summary>group/b</summary>"), "projects are sorted by path")
+	for _, id := range []string{"CVE-1", "CVE-2", "CVE-3"} {
+		assert.Contains(t, body, id)
+	}
+	assert.Contains(t, body, "left-pad")
+
+	assert.Equal(t, "https://gitlab.com/group/security/-/issues/1", reports[0].IssueUrl)
+	assert.Equal(t, "https://gitlab.com/group/security/-/issues/1", reports[2].IssueUrl)
+	assert.Empty(t, reports[3].IssueUrl)
+}
+
+func TestPublishAsCentralIssueClosesWhenClean(t *testing.T) {
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("GetProjectList", []string{"group/security"}).Return([]repository.Project{centralIssueProject}, nil)
+	mockGitlabService.On("CloseVulnerabilityIssue", centralIssueProject, "title", "").Return(nil).Once()
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	err := PublishAsCentralIssue([]scanner.Report{{Project: repository.Project{Path: "group/a"}}}, centralIssueLocation, IssueOpts{Title: "title"}, mockRepoService)
+
+	assert.NoError(t, err)
+	mockGitlabService.AssertExpectations(t)
+}
+
+func TestPublishAsCentralIssueKeepsIssueUntilCleanForLongEnough(t *testing.T) {
+	mockRepoService := &mockRepoService{}
+
+	err := PublishAsCentralIssue([]scanner.Report{{Project: repository.Project{Path: "group/a"}}}, centralIssueLocation, IssueOpts{Title: "title", CloseAfterCleanCount: 2}, mockRepoService)
+
+	assert.NoError(t, err)
+	mockRepoService.AssertNotCalled(t, "Provide", mock.Anything)
+}
+
+func TestPublishAsCentralIssueProjectNotFound(t *testing.T) {
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("GetProjectList", []string{"group/security"}).Return([]repository.Project{}, nil)
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	err := PublishAsCentralIssue([]scanner.Report{{IsVulnerable: true, Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-1"}}}}, centralIssueLocation, IssueOpts{}, mockRepoService)
+
+	assert.ErrorContains(t, err, "project group/security of the central issue not found")
+	mockGitlabService.AssertNotCalled(t, "OpenVulnerabilityIssue", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -1,26 +1,232 @@
 package publish
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
+	"sheriff/internal/repository"
 	"sheriff/internal/scanner"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/elliotchance/pie/v2"
 	"github.com/rs/zerolog/log"
 )
 
-// PublishToConsole prints reports to the terminal console.
+// ConsoleFormat is the format in which the report is printed to the console
+type ConsoleFormat string
+
+const (
+	ConsoleFormatHuman ConsoleFormat = "human"
+	ConsoleFormatJSON  ConsoleFormat = "json"
+)
+
+// ConsoleDetail is the level of detail of the report printed to the console in the human format
+type ConsoleDetail string
+
+const (
+	ConsoleDetailFull     ConsoleDetail = "full"     // Each project with its vulnerabilities
+	ConsoleDetailProjects ConsoleDetail = "projects" // A line per project
+	ConsoleDetailSummary  ConsoleDetail = "summary"  // The counts only
+)
+
+// slowestProjectsCount is the number of slowest projects listed in the console report
+const slowestProjectsCount = 5
+
+// consoleSeverityKinds are the severity kinds counted in the JSON summary, in order
+var consoleSeverityKinds = []scanner.SeverityScoreKind{scanner.Critical, scanner.High, scanner.Moderate, scanner.Low, scanner.Unknown, scanner.Acknowledged}
+
+// consoleSummary is the JSON summary printed to the console, see formatReportsJSONForConsole
+type consoleSummary struct {
+	ProjectsScanned           int                               `json:"projects_scanned"`
+	VulnerableProjects        int                               `json:"vulnerable_projects"`
+	VulnerabilitiesBySeverity map[scanner.SeverityScoreKind]int `json:"vulnerabilities_by_severity"`
+	Projects                  []consoleProjectSummary           `json:"projects"`
+	FailedProjects            []string                          `json:"failed_projects"`
+	Failures                  []consoleFailureSummary           `json:"failures"`
+}
+
+type consoleProjectSummary struct {
+	Path            string                `json:"path"`
+	URL             string                `json:"url"`
+	Visibility      repository.Visibility `json:"visibility"` // Empty if unknown
+	Vulnerable      bool                  `json:"vulnerable"`
+	NoManifests     bool                  `json:"no_manifests"` // No lockfile or manifest was found to scan
+	Vulnerabilities int                   `json:"vulnerabilities"`
+	Suppressed      int                   `json:"suppressed"`   // Vulnerabilities left out as only found in files listed in .sheriffignore
+	PackageUrls     []string              `json:"package_urls"` // Distinct package URLs (purl) of the vulnerable packages, when known
+	ConfigWarnings  []string              `json:"config_warnings"`
+	DurationSeconds float64               `json:"duration_seconds"`
+}
+
+type consoleFailureSummary struct {
+	Path    string            `json:"path"`
+	Kind    scanner.ErrorKind `json:"kind"`
+	Message string            `json:"message"`
+}
+
+// PublishToConsole prints reports to the terminal console, in the given format.
+// The level of detail only applies to the human format, the JSON summary is always complete.
 // If silentReport is true, the report will be logged as debug instead of printed to the console.
-func PublishToConsole(scanReports []scanner.Report, silentReport bool) {
-	r := formatReportsMessageForConsole(scanReports)
+func PublishToConsole(scanReports []scanner.Report, silentReport bool, format ConsoleFormat, detail ConsoleDetail) {
+	var r string
+	switch {
+	case format == ConsoleFormatJSON:
+		r = formatReportsJSONForConsole(scanReports)
+	case detail == ConsoleDetailSummary:
+		r = formatReportsSummaryForConsole(scanReports)
+	case detail == ConsoleDetailProjects:
+		r = formatReportsProjectsForConsole(scanReports)
+	default:
+		r = formatReportsMessageForConsole(scanReports)
+	}
+
 	if silentReport {
-		log.Debug().Str("report", r)
+		log.Debug().Str("report", r).Msg("Vulnerability report")
 	} else {
 		fmt.Println(r)
 	}
 }
 
+// formatReportsJSONForConsole formats the scan reports into a compact JSON summary, on a single line.
+// Its schema is stable, so that it can be relied upon by scripts:
+//
+//	{
+//	  "projects_scanned": 3,       // Number of projects for which a scan was attempted
+//	  "vulnerable_projects": 1,    // Number of successfully scanned projects which are vulnerable
+//	  "vulnerabilities_by_severity": {"CRITICAL": 1, "HIGH": 0, "MODERATE": 2, "LOW": 0, "UNKNOWN": 0, "ACKNOWLEDGED": 0},
+//	  "projects": [                // Successfully scanned projects, in the order of the reports, with the problems
+//	                               // found in their configuration which did not prevent the scan
+//	    {"path": "group/project", "url": "https://...", "visibility": "private", "vulnerable": true, "vulnerabilities": 3, "config_warnings": [],
+//	     "no_manifests": false,    // Whether no lockfile or manifest was found to scan, so the project is not known to be safe
+//	     "suppressed": 1,          // Vulnerabilities only found in files listed in .sheriffignore, not counted above
+//	     "package_urls": ["pkg:npm/semver@7.3.7"], // Distinct purls of the vulnerable packages, when known
+//	     "duration_seconds": 12.5} // Time spent downloading and scanning the project
+//	  ],
+//	  "failed_projects": ["group/other-project"], // Paths of the projects which could not be scanned
+//	  "failures": [                // Reasons of the failures, in the order of failed_projects
+//	    {"path": "group/other-project", "kind": "CLONE_FAILED", "message": "failed to clone project ..."}
+//	  ]
+//	}
+//
+// All severity kinds are always present, and the lists are never null.
+func formatReportsJSONForConsole(scanReports []scanner.Report) string {
+	summary := consoleSummary{
+		ProjectsScanned:           len(scanReports),
+		VulnerabilitiesBySeverity: make(map[scanner.SeverityScoreKind]int, len(consoleSeverityKinds)),
+		Projects:                  []consoleProjectSummary{},
+		FailedProjects:            []string{},
+		Failures:                  []consoleFailureSummary{},
+	}
+	for _, kind := range consoleSeverityKinds {
+		summary.VulnerabilitiesBySeverity[kind] = 0
+	}
+
+	for _, report := range scanReports {
+		if report.Error {
+			summary.FailedProjects = append(summary.FailedProjects, report.Project.Path)
+			summary.Failures = append(summary.Failures, consoleFailureSummary{
+				Path:    report.Project.Path,
+				Kind:    report.ErrorKind,
+				Message: report.ErrorMessage,
+			})
+			continue
+		}
+
+		if report.IsVulnerable {
+			summary.VulnerableProjects++
+		}
+		for _, v := range report.Vulnerabilities {
+			summary.VulnerabilitiesBySeverity[v.SeverityScoreKind]++
+		}
+		summary.Projects = append(summary.Projects, consoleProjectSummary{
+			Path:            report.Project.Path,
+			URL:             report.Project.WebURL,
+			Visibility:      report.Project.Visibility,
+			Vulnerable:      report.IsVulnerable,
+			NoManifests:     report.NoManifests,
+			Vulnerabilities: len(report.Vulnerabilities),
+			Suppressed:      len(report.Suppressed),
+			PackageUrls:     getPackageUrls(report.Vulnerabilities),
+			ConfigWarnings:  append([]string{}, report.ConfigWarnings...),
+			DurationSeconds: report.Duration.Seconds(),
+		})
+	}
+
+	// Marshalling cannot fail, as the summary only contains strings, numbers and booleans
+	out, _ := json.Marshal(summary)
+
+	return string(out)
+}
+
+// getPackageUrls returns the distinct package URLs of the vulnerable packages, in order of appearance,
+// skipping the packages whose package URL is unknown
+func getPackageUrls(vs []scanner.Vulnerability) []string {
+	urls := []string{}
+	for _, v := range vs {
+		if v.PackageUrl != "" && !slices.Contains(urls, v.PackageUrl) {
+			urls = append(urls, v.PackageUrl)
+		}
+	}
+
+	return urls
+}
+
+// formatReportsSummaryForConsole formats the counts of the scan reports, without any project,
+// for a concise log: the number of projects scanned, vulnerable and failed, and of vulnerabilities per severity.
+func formatReportsSummaryForConsole(scanReports []scanner.Report) string {
+	scanned := pie.Filter(scanReports, func(r scanner.Report) bool { return !r.Error })
+	vulnerable := pie.Filter(scanned, func(r scanner.Report) bool { return r.IsVulnerable })
+
+	var r strings.Builder
+	r.WriteString("\nVulnerability Report:\n")
+	r.WriteString(fmt.Sprintf("Total number of projects scanned: %v\n", len(scanReports)))
+	r.WriteString(fmt.Sprintf("Vulnerable projects: %v\n", len(vulnerable)))
+	r.WriteString(fmt.Sprintf("Failed scans: %v\n", len(scanReports)-len(scanned)))
+	r.WriteString(fmt.Sprintf("Vulnerabilities: %v\n", formatSeverityCounts(slices.Concat(pie.Map(scanned, func(r scanner.Report) []scanner.Vulnerability { return r.Vulnerabilities })...), true)))
+
+	return r.String()
+}
+
+// formatReportsProjectsForConsole formats the scan reports with a line per project,
+// telling its number of vulnerabilities per severity, or why it was not scanned.
+func formatReportsProjectsForConsole(scanReports []scanner.Report) string {
+	var r strings.Builder
+	r.WriteString("\nVulnerability Report:\n")
+	r.WriteString(fmt.Sprintf("Total number of projects scanned: %v\n", len(scanReports)))
+	for _, report := range scanReports {
+		switch {
+		case report.Error:
+			r.WriteString(fmt.Sprintf("%v: scan failed: %v\n", report.Project.Path, formatErrorReason(report)))
+		case report.NoManifests:
+			r.WriteString(fmt.Sprintf("%v: no lockfiles or manifests found\n", report.Project.Path))
+		case len(report.Vulnerabilities) == 0:
+			r.WriteString(fmt.Sprintf("%v: no vulnerabilities\n", report.Project.Path))
+		default:
+			r.WriteString(fmt.Sprintf("%v: %v vulnerabilities (%v)\n", report.Project.Path, len(report.Vulnerabilities), formatSeverityCounts(report.Vulnerabilities, false)))
+		}
+	}
+
+	return r.String()
+}
+
+// formatSeverityCounts formats the number of vulnerabilities of each severity kind, e.g. "CRITICAL: 1, HIGH: 2".
+// The severity kinds without vulnerabilities are left out, unless withZeros is set.
+func formatSeverityCounts(vs []scanner.Vulnerability, withZeros bool) string {
+	var counts []string
+	for _, kind := range consoleSeverityKinds {
+		n := len(pie.Filter(vs, func(v scanner.Vulnerability) bool { return v.SeverityScoreKind == kind }))
+		if n > 0 || withZeros {
+			counts = append(counts, fmt.Sprintf("%v: %v", kind, n))
+		}
+	}
+
+	return strings.Join(counts, ", ")
+}
+
 // formatReportsMessageForConsole formats the scan reports into a string message
-// ready to be sent to the console.
+// ready to be sent to the console, listing the vulnerabilities of each project.
 func formatReportsMessageForConsole(scanReports []scanner.Report) string {
 	var r strings.Builder
 
@@ -34,7 +240,54 @@ func formatReportsMessageForConsole(scanReports []scanner.Report) string {
 		r.WriteString(fmt.Sprintln("---------------------------------"))
 		r.WriteString(fmt.Sprintf("%v\n", report.Project.Path))
 		r.WriteString(fmt.Sprintf("\tProject URL: %v\n", report.Project.WebURL))
+		if report.Error {
+			r.WriteString(fmt.Sprintf("\tScan failed: %v\n", formatErrorReason(report)))
+			continue
+		}
+		for _, w := range report.ConfigWarnings {
+			r.WriteString(fmt.Sprintf("\tConfiguration warning: %v\n", w))
+		}
+		if report.NoManifests {
+			r.WriteString("\tNo lockfiles or manifests found: nothing was scanned\n")
+		}
 		r.WriteString(fmt.Sprintf("\tNumber of vulnerabilities: %v\n", len(report.Vulnerabilities)))
+		if report.IsVulnerable && report.Project.Visibility == repository.Public {
+			r.WriteString("\tPublic project: its vulnerabilities can be found by anyone\n")
+		}
+		if analysed := pie.Filter(report.Vulnerabilities, func(v scanner.Vulnerability) bool { return v.Reachable != nil }); len(analysed) > 0 {
+			nReachable := len(pie.Filter(analysed, func(v scanner.Vulnerability) bool { return *v.Reachable }))
+			r.WriteString(fmt.Sprintf("\t\tReachable: %v\n", nReachable))
+			r.WriteString(fmt.Sprintf("\t\tPotentially unreachable: %v\n", len(analysed)-nReachable))
+		}
+		for _, v := range report.Vulnerabilities {
+			r.WriteString(fmt.Sprintf("\t- %v %v@%v (%v)", v.Id, v.PackageName, v.PackageVersion, v.SeverityScoreKind))
+			if v.FixedVersion != "" {
+				r.WriteString(fmt.Sprintf(", fixed in %v", v.FixedVersion))
+			}
+			r.WriteString("\n")
+		}
 	}
+	r.WriteString(formatSlowestProjects(scanReports))
+	return r.String()
+}
+
+// formatSlowestProjects lists the projects which took the longest to download and scan, slowest first,
+// to tell which ones to look at when tuning the patrol. It is empty if no scan duration is known.
+func formatSlowestProjects(scanReports []scanner.Report) string {
+	timed := pie.Filter(scanReports, func(r scanner.Report) bool { return r.Duration > 0 })
+	if len(timed) == 0 {
+		return ""
+	}
+
+	timed = slices.Clone(timed)
+	slices.SortStableFunc(timed, func(a, b scanner.Report) int { return cmp.Compare(b.Duration, a.Duration) })
+
+	var r strings.Builder
+	r.WriteString(fmt.Sprintln("---------------------------------"))
+	r.WriteString("Slowest projects:\n")
+	for _, report := range timed[:min(slowestProjectsCount, len(timed))] {
+		r.WriteString(fmt.Sprintf("\t%v: %v\n", report.Project.Path, report.Duration.Round(time.Millisecond)))
+	}
+
 	return r.String()
 }
@@ -1,9 +1,12 @@
 package publish
 
 import (
+	"fmt"
 	"sheriff/internal/repository"
 	"sheriff/internal/scanner"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -49,3 +52,190 @@ func TestFormatReportMessageForConsole(t *testing.T) {
 	assert.Contains(t, r, "Number of vulnerabilities: 2")
 
 }
+
+func TestFormatReportMessageForConsoleWithReachability(t *testing.T) {
+	yes, no := true, false
+	reports := []scanner.Report{
+		{
+			Project: repository.Project{Path: "group/analysed"},
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "GO-2023-0001", Reachable: &yes},
+				{Id: "GO-2023-0002", Reachable: &no},
+				{Id: "GO-2023-0003", Reachable: &no},
+			},
+		},
+		{
+			Project:         repository.Project{Path: "group/not-analysed"},
+			Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234"}},
+		},
+	}
+
+	r := formatReportsMessageForConsole(reports)
+
+	assert.Contains(t, r, "Reachable: 1\n")
+	assert.Contains(t, r, "Potentially unreachable: 2\n")
+	assert.Equal(t, 1, strings.Count(r, "Reachable:"))
+}
+
+func TestFormatReportMessageForConsoleFlagsPublicProjects(t *testing.T) {
+	vulns := []scanner.Vulnerability{{Id: "CVE-2021-1234"}}
+	reports := []scanner.Report{
+		{Project: repository.Project{Path: "group/public", Visibility: repository.Public}, IsVulnerable: true, Vulnerabilities: vulns},
+		{Project: repository.Project{Path: "group/private", Visibility: repository.Private}, IsVulnerable: true, Vulnerabilities: vulns},
+		{Project: repository.Project{Path: "group/public-not-vulnerable", Visibility: repository.Public}},
+	}
+
+	r := formatReportsMessageForConsole(reports)
+
+	assert.Contains(t, r, "group/public\n\tProject URL: \n\tNumber of vulnerabilities: 1\n\tPublic project: its vulnerabilities can be found by anyone\n")
+	assert.Equal(t, 1, strings.Count(r, "Public project"))
+}
+
+func TestFormatReportMessageForConsoleWithFailedScan(t *testing.T) {
+	reports := []scanner.Report{
+		scanner.NewFailedReport(repository.Project{Path: "group/failed"}, scanner.Timeout, "scan timed out after 1m0s"),
+	}
+
+	r := formatReportsMessageForConsole(reports)
+
+	assert.Contains(t, r, "\tScan failed: the scan timed out (`scan timed out after 1m0s`)\n")
+	assert.NotContains(t, r, "Number of vulnerabilities")
+}
+
+func TestFormatReportsJSONForConsole(t *testing.T) {
+	reports := []scanner.Report{
+		{
+			Project:      repository.Project{Path: "group/project1", WebURL: "http://example.com", Visibility: repository.Public},
+			IsVulnerable: true,
+			Duration:     1500 * time.Millisecond,
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "CVE-2021-1234", SeverityScoreKind: scanner.Critical, PackageUrl: "pkg:npm/semver@7.3.7"},
+				{Id: "CVE-2021-1235", SeverityScoreKind: scanner.High, PackageUrl: "pkg:npm/semver@7.3.7"},
+			},
+			Suppressed: []scanner.Vulnerability{{Id: "CVE-2021-1236", SeverityScoreKind: scanner.Critical}},
+		},
+		{
+			Project:        repository.Project{Path: "group/project2", WebURL: "http://example2.com"},
+			ConfigWarnings: []string{"unknown keys in sheriff.toml: reprot"},
+		},
+		scanner.NewFailedReport(repository.Project{Path: "group/project3"}, scanner.CloneFailed, "failed to clone project group/project3"),
+	}
+
+	r := formatReportsJSONForConsole(reports)
+
+	want := `{"projects_scanned":3,"vulnerable_projects":1,` +
+		`"vulnerabilities_by_severity":{"ACKNOWLEDGED":0,"CRITICAL":1,"HIGH":1,"LOW":0,"MODERATE":0,"UNKNOWN":0},` +
+		`"projects":[{"path":"group/project1","url":"http://example.com","visibility":"public","vulnerable":true,"no_manifests":false,"vulnerabilities":2,"suppressed":1,"package_urls":["pkg:npm/semver@7.3.7"],"config_warnings":[],"duration_seconds":1.5},` +
+		`{"path":"group/project2","url":"http://example2.com","visibility":"","vulnerable":false,"no_manifests":false,"vulnerabilities":0,"suppressed":0,"package_urls":[],"config_warnings":["unknown keys in sheriff.toml: reprot"],"duration_seconds":0}],` +
+		`"failed_projects":["group/project3"],` +
+		`"failures":[{"path":"group/project3","kind":"CLONE_FAILED","message":"failed to clone project group/project3"}]}`
+	assert.JSONEq(t, want, r)
+	assert.NotContains(t, r, "\n")
+}
+
+func TestFormatReportsJSONForConsoleWithoutReports(t *testing.T) {
+	r := formatReportsJSONForConsole([]scanner.Report{})
+
+	assert.Contains(t, r, `"projects":[]`)
+	assert.Contains(t, r, `"failed_projects":[]`)
+	assert.Contains(t, r, `"failures":[]`)
+}
+
+func TestFormatReportMessageForConsoleWithConfigWarnings(t *testing.T) {
+	reports := []scanner.Report{
+		{
+			Project:        repository.Project{Path: "group/project"},
+			ConfigWarnings: []string{"unknown keys in sheriff.toml: reprot", "acknowledgement #2 has no code, it is ignored"},
+		},
+	}
+
+	r := formatReportsMessageForConsole(reports)
+
+	assert.Contains(t, r, "\tConfiguration warning: unknown keys in sheriff.toml: reprot\n")
+	assert.Contains(t, r, "\tConfiguration warning: acknowledgement #2 has no code, it is ignored\n")
+}
+
+func TestFormatReportsForConsoleWithNoManifests(t *testing.T) {
+	reports := []scanner.Report{
+		{Project: repository.Project{Path: "group/docs"}, Vulnerabilities: []scanner.Vulnerability{}, NoManifests: true},
+		{Project: repository.Project{Path: "group/app"}, Vulnerabilities: []scanner.Vulnerability{}},
+	}
+
+	r := formatReportsMessageForConsole(reports)
+
+	assert.Equal(t, 1, strings.Count(r, "\tNo lockfiles or manifests found: nothing was scanned\n"))
+	assert.Contains(t, r, "group/docs\n\tProject URL: \n\tNo lockfiles or manifests found")
+
+	j := formatReportsJSONForConsole(reports)
+
+	assert.Contains(t, j, `{"path":"group/docs","url":"","visibility":"","vulnerable":false,"no_manifests":true,`)
+	assert.Contains(t, j, `{"path":"group/app","url":"","visibility":"","vulnerable":false,"no_manifests":false,`)
+}
+
+func TestFormatReportMessageForConsoleListsSlowestProjects(t *testing.T) {
+	var reports []scanner.Report
+	for i := range 7 {
+		reports = append(reports, scanner.Report{
+			Project:  repository.Project{Path: fmt.Sprintf("group/project%v", i)},
+			Duration: time.Duration(i) * time.Second,
+		})
+	}
+
+	r := formatReportsMessageForConsole(reports)
+
+	assert.Contains(t, r, "Slowest projects:\n\tgroup/project6: 6s\n\tgroup/project5: 5s\n\tgroup/project4: 4s\n\tgroup/project3: 3s\n\tgroup/project2: 2s\n")
+	assert.NotContains(t, r, "group/project1: ")
+}
+
+func TestFormatReportMessageForConsoleWithoutDurations(t *testing.T) {
+	r := formatReportsMessageForConsole([]scanner.Report{{Project: repository.Project{Path: "group/project"}}})
+
+	assert.NotContains(t, r, "Slowest projects")
+}
+
+// consoleDetailReports are reports of projects in each state, to test the levels of detail of the console report
+var consoleDetailReports = []scanner.Report{
+	{
+		Project:      repository.Project{Path: "group/vulnerable", WebURL: "http://example.com/group/vulnerable"},
+		IsVulnerable: true,
+		Vulnerabilities: []scanner.Vulnerability{
+			{Id: "CVE-2021-1234", PackageName: "lodash", PackageVersion: "4.17.20", FixedVersion: "4.17.21", SeverityScoreKind: scanner.Critical},
+			{Id: "CVE-2021-1235", PackageName: "semver", PackageVersion: "7.3.7", SeverityScoreKind: scanner.High},
+			{Id: "CVE-2021-1236", PackageName: "semver", PackageVersion: "7.3.7", SeverityScoreKind: scanner.High},
+		},
+	},
+	{Project: repository.Project{Path: "group/clean", WebURL: "http://example.com/group/clean"}},
+	{Project: repository.Project{Path: "group/empty", WebURL: "http://example.com/group/empty"}, NoManifests: true},
+	scanner.NewFailedReport(repository.Project{Path: "group/failed"}, scanner.CloneFailed, ""),
+}
+
+func TestFormatReportsSummaryForConsole(t *testing.T) {
+	r := formatReportsSummaryForConsole(consoleDetailReports)
+
+	assert.Equal(t, "\nVulnerability Report:\n"+
+		"Total number of projects scanned: 4\n"+
+		"Vulnerable projects: 1\n"+
+		"Failed scans: 1\n"+
+		"Vulnerabilities: CRITICAL: 1, HIGH: 2, MODERATE: 0, LOW: 0, UNKNOWN: 0, ACKNOWLEDGED: 0\n", r)
+}
+
+func TestFormatReportsProjectsForConsole(t *testing.T) {
+	r := formatReportsProjectsForConsole(consoleDetailReports)
+
+	assert.Equal(t, "\nVulnerability Report:\n"+
+		"Total number of projects scanned: 4\n"+
+		"group/vulnerable: 3 vulnerabilities (CRITICAL: 1, HIGH: 2)\n"+
+		"group/clean: no vulnerabilities\n"+
+		"group/empty: no lockfiles or manifests found\n"+
+		"group/failed: scan failed: failed to clone the project\n", r)
+}
+
+func TestFormatReportMessageForConsoleListsVulnerabilities(t *testing.T) {
+	r := formatReportsMessageForConsole(consoleDetailReports)
+
+	assert.Contains(t, r, "\tNumber of vulnerabilities: 3\n"+
+		"\t- CVE-2021-1234 lodash@4.17.20 (CRITICAL), fixed in 4.17.21\n"+
+		"\t- CVE-2021-1235 semver@7.3.7 (HIGH)\n"+
+		"\t- CVE-2021-1236 semver@7.3.7 (HIGH)\n")
+	assert.Contains(t, r, "group/clean\n\tProject URL: http://example.com/group/clean\n\tNumber of vulnerabilities: 0\n")
+}
@@ -0,0 +1,135 @@
+package publish
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sheriff/internal/scanner"
+	"sheriff/internal/state"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultRefName is the name of the base ref in the diff when it is the default branch of the projects
+const defaultRefName = "default branch"
+
+// refDiff is the difference between the vulnerabilities found at two refs of the scanned projects
+type refDiff struct {
+	BaseRef        string        `json:"base_ref"`
+	TargetRef      string        `json:"target_ref"`
+	Projects       []projectDiff `json:"projects"`        // Projects whose vulnerabilities differ between the refs, by path
+	FailedProjects []string      `json:"failed_projects"` // Projects which could not be scanned at either ref, by path
+}
+
+type projectDiff struct {
+	Path    string                `json:"path"`
+	URL     string                `json:"url"`
+	Added   []state.Vulnerability `json:"added"`   // Found at the target ref but not at the base ref
+	Removed []state.Vulnerability `json:"removed"` // Found at the base ref but not at the target ref
+}
+
+// PublishDiff prints the difference between the vulnerabilities found at the base and target refs of the projects
+// to the console, in the given format, and writes it as JSON to the given file if it is not empty.
+// An empty baseRef stands for the default branch of the projects.
+// If silentReport is true, the diff is logged as debug instead of printed to the console.
+func PublishDiff(base []scanner.Report, target []scanner.Report, baseRef string, targetRef string, silentReport bool, format ConsoleFormat, path string) error {
+	diff := computeRefDiff(base, target, cmp.Or(baseRef, defaultRefName), targetRef)
+
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return errors.Join(errors.New("failed to encode diff"), err)
+	}
+
+	r := string(data)
+	if format != ConsoleFormatJSON {
+		r = formatRefDiffForConsole(diff)
+	}
+	if silentReport {
+		log.Debug().Str("diff", r).Msg("Vulnerability diff")
+	} else {
+		fmt.Println(r)
+	}
+
+	if path == "" {
+		return nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errors.Join(fmt.Errorf("failed to write diff to %v", path), err)
+	}
+
+	return nil
+}
+
+// computeRefDiff compares the reports of the target ref against those of the base ref, project by project.
+// Vulnerabilities are matched by their id and package name, see computeReportDeltas.
+// Projects without changes are left out, and projects which failed to be scanned at either ref are only listed as failed,
+// so that a failed scan is not mistaken for all of its vulnerabilities being added or removed.
+func computeRefDiff(base []scanner.Report, target []scanner.Report, baseRef string, targetRef string) refDiff {
+	diff := refDiff{BaseRef: baseRef, TargetRef: targetRef, Projects: []projectDiff{}, FailedProjects: []string{}}
+
+	baseByPath := make(map[string]scanner.Report, len(base))
+	for _, r := range base {
+		baseByPath[r.Project.Path] = r
+	}
+
+	for _, r := range target {
+		b, ok := baseByPath[r.Project.Path]
+		delete(baseByPath, r.Project.Path)
+		if !ok || b.Error || r.Error {
+			diff.FailedProjects = append(diff.FailedProjects, r.Project.Path)
+			continue
+		}
+
+		for _, d := range computeReportDeltas([]scanner.Report{r}, state.FromReports([]scanner.Report{b}, state.State{})) {
+			diff.Projects = append(diff.Projects, projectDiff{
+				Path: d.Report.Project.Path,
+				URL:  d.Report.Project.WebURL,
+				// Empty rather than null in the JSON diff
+				Added:   append([]state.Vulnerability{}, d.Introduced...),
+				Removed: append([]state.Vulnerability{}, d.Resolved...),
+			})
+		}
+	}
+	// Projects only found at the base ref, whose scan at the target ref did not even start
+	for path := range baseByPath {
+		diff.FailedProjects = append(diff.FailedProjects, path)
+	}
+
+	slices.SortFunc(diff.Projects, func(a, b projectDiff) int { return cmp.Compare(a.Path, b.Path) })
+	slices.Sort(diff.FailedProjects)
+
+	return diff
+}
+
+// formatRefDiffForConsole formats the diff for humans, with a line per added or removed vulnerability
+func formatRefDiffForConsole(diff refDiff) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Vulnerabilities of %v compared to %v:\n", diff.TargetRef, diff.BaseRef)
+	if len(diff.Projects) == 0 {
+		b.WriteString("No difference found\n")
+	}
+
+	for _, p := range diff.Projects {
+		fmt.Fprintf(&b, "\n%v: %v added, %v removed\n", p.Path, len(p.Added), len(p.Removed))
+		for _, v := range p.Added {
+			fmt.Fprintf(&b, "  + %v\n", formatDiffVulnerability(v))
+		}
+		for _, v := range p.Removed {
+			fmt.Fprintf(&b, "  - %v\n", formatDiffVulnerability(v))
+		}
+	}
+
+	if len(diff.FailedProjects) > 0 {
+		fmt.Fprintf(&b, "\nNot compared, as they could not be scanned at both refs: %v\n", strings.Join(diff.FailedProjects, ", "))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func formatDiffVulnerability(v state.Vulnerability) string {
+	return fmt.Sprintf("%v in %v %v (%v)", v.Id, v.PackageName, v.PackageVersion, v.SeverityScoreKind)
+}
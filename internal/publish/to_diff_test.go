@@ -0,0 +1,112 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"sheriff/internal/repository"
+	"sheriff/internal/scanner"
+	"sheriff/internal/state"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeRefDiff(t *testing.T) {
+	semver := scanner.Vulnerability{Id: "CVE-2022-25883", PackageName: "semver", PackageVersion: "7.3.7", SeverityScoreKind: scanner.High}
+	express := scanner.Vulnerability{Id: "CVE-2024-1234", PackageName: "express", PackageVersion: "4.17.0", SeverityScoreKind: scanner.Critical}
+	lodash := scanner.Vulnerability{Id: "CVE-2021-23337", PackageName: "lodash", PackageVersion: "4.17.20", SeverityScoreKind: scanner.Moderate}
+	base := []scanner.Report{
+		{Project: repository.Project{Path: "group/b", WebURL: "https://gitlab.com/group/b"}, Vulnerabilities: []scanner.Vulnerability{semver, lodash}},
+		{Project: repository.Project{Path: "group/a"}, Vulnerabilities: []scanner.Vulnerability{lodash}},
+		{Project: repository.Project{Path: "group/unchanged"}, Vulnerabilities: []scanner.Vulnerability{semver}},
+	}
+	target := []scanner.Report{
+		{Project: repository.Project{Path: "group/unchanged"}, Vulnerabilities: []scanner.Vulnerability{semver}},
+		{Project: repository.Project{Path: "group/b", WebURL: "https://gitlab.com/group/b"}, Vulnerabilities: []scanner.Vulnerability{lodash, express}},
+		{Project: repository.Project{Path: "group/a"}},
+	}
+
+	got := computeRefDiff(base, target, "main", "release")
+
+	want := refDiff{
+		BaseRef:   "main",
+		TargetRef: "release",
+		Projects: []projectDiff{
+			{Path: "group/a", Added: []state.Vulnerability{}, Removed: []state.Vulnerability{{Id: "CVE-2021-23337", PackageName: "lodash", PackageVersion: "4.17.20", SeverityScoreKind: scanner.Moderate}}},
+			{
+				Path:    "group/b",
+				URL:     "https://gitlab.com/group/b",
+				Added:   []state.Vulnerability{{Id: "CVE-2024-1234", PackageName: "express", PackageVersion: "4.17.0", SeverityScoreKind: scanner.Critical}},
+				Removed: []state.Vulnerability{{Id: "CVE-2022-25883", PackageName: "semver", PackageVersion: "7.3.7", SeverityScoreKind: scanner.High}},
+			},
+		},
+		FailedProjects: []string{},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestComputeRefDiffSkipsFailedProjects(t *testing.T) {
+	vuln := scanner.Vulnerability{Id: "CVE-2022-25883", PackageName: "semver"}
+	base := []scanner.Report{
+		{Project: repository.Project{Path: "group/failed-on-base"}, Error: true},
+		{Project: repository.Project{Path: "group/failed-on-target"}, Vulnerabilities: []scanner.Vulnerability{vuln}},
+		{Project: repository.Project{Path: "group/only-on-base"}},
+	}
+	target := []scanner.Report{
+		{Project: repository.Project{Path: "group/failed-on-base"}, Vulnerabilities: []scanner.Vulnerability{vuln}},
+		{Project: repository.Project{Path: "group/failed-on-target"}, Error: true},
+	}
+
+	got := computeRefDiff(base, target, "main", "release")
+
+	assert.Empty(t, got.Projects)
+	assert.Equal(t, []string{"group/failed-on-base", "group/failed-on-target", "group/only-on-base"}, got.FailedProjects)
+}
+
+func TestFormatRefDiffForConsole(t *testing.T) {
+	diff := refDiff{
+		BaseRef:   "default branch",
+		TargetRef: "release",
+		Projects: []projectDiff{{
+			Path:    "group/b",
+			Added:   []state.Vulnerability{{Id: "CVE-2024-1234", PackageName: "express", PackageVersion: "4.17.0", SeverityScoreKind: scanner.Critical}},
+			Removed: []state.Vulnerability{{Id: "CVE-2022-25883", PackageName: "semver", PackageVersion: "7.3.7", SeverityScoreKind: scanner.High}},
+		}},
+		FailedProjects: []string{"group/failed"},
+	}
+
+	got := formatRefDiffForConsole(diff)
+
+	want := `Vulnerabilities of release compared to default branch:
+
+group/b: 1 added, 1 removed
+  + CVE-2024-1234 in express 4.17.0 (CRITICAL)
+  - CVE-2022-25883 in semver 7.3.7 (HIGH)
+
+Not compared, as they could not be scanned at both refs: group/failed`
+	assert.Equal(t, want, got)
+}
+
+func TestFormatRefDiffForConsoleWithoutDifference(t *testing.T) {
+	got := formatRefDiffForConsole(refDiff{BaseRef: "main", TargetRef: "release"})
+
+	assert.Equal(t, "Vulnerabilities of release compared to main:\nNo difference found", got)
+}
+
+func TestPublishDiffWritesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diff.json")
+
+	err := PublishDiff(nil, nil, "", "release", true, ConsoleFormatHuman, path)
+
+	require.NoError(t, err)
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"base_ref": "default branch", "target_ref": "release", "projects": [], "failed_projects": []}`, string(got))
+}
+
+func TestPublishDiffFailsWithMissingDir(t *testing.T) {
+	err := PublishDiff(nil, nil, "main", "release", true, ConsoleFormatHuman, filepath.Join(t.TempDir(), "missing", "diff.json"))
+
+	assert.ErrorContains(t, err, "failed to write diff")
+}
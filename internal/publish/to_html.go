@@ -0,0 +1,167 @@
+package publish
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"sheriff/internal/scanner"
+	"slices"
+
+	"github.com/elliotchance/pie/v2"
+)
+
+// htmlSeverityColors are the colors of each severity kind in the HTML report
+var htmlSeverityColors = map[scanner.SeverityScoreKind]string{
+	scanner.Critical:     "#b71c1c",
+	scanner.High:         "#e65100",
+	scanner.Moderate:     "#f9a825",
+	scanner.Low:          "#2e7d32",
+	scanner.Unknown:      "#616161",
+	scanner.Acknowledged: "#1565c0",
+}
+
+// htmlReport is the data rendered by htmlReportTemplate
+type htmlReport struct {
+	Date       string
+	Severities []htmlSeverityCount
+	Projects   []htmlProject
+}
+
+type htmlSeverityCount struct {
+	Kind  scanner.SeverityScoreKind
+	Color string
+	Count int
+}
+
+type htmlProject struct {
+	Path            string
+	URL             string
+	IssueURL        string
+	Error           bool
+	Vulnerabilities []htmlVulnerability
+}
+
+type htmlVulnerability struct {
+	scanner.Vulnerability
+	Color string
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Sheriff vulnerability report {{.Date}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #212121; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #e0e0e0; padding: 0.4em 0.8em; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+details { border: 1px solid #e0e0e0; border-radius: 4px; margin: 0.5em 0; padding: 0.5em 1em; }
+summary { cursor: pointer; font-weight: bold; }
+.severity { color: #fff; border-radius: 3px; padding: 0.1em 0.4em; font-size: 0.9em; }
+.error { color: #b71c1c; }
+.muted { color: #757575; }
+</style>
+</head>
+<body>
+<h1>Sheriff vulnerability report</h1>
+<p class="muted">Generated on {{.Date}}</p>
+{{- if not .Projects}}
+<p>No projects scanned.</p>
+{{- else}}
+<h2>Summary</h2>
+<p>Total number of projects scanned: {{len .Projects}}</p>
+<table>
+<tr><th>Severity</th><th>Vulnerabilities</th></tr>
+{{- range .Severities}}
+<tr><td><span class="severity" style="background: {{.Color}}">{{.Kind}}</span></td><td>{{.Count}}</td></tr>
+{{- end}}
+</table>
+<h2>Projects</h2>
+{{- range .Projects}}
+<details>
+<summary>{{.Path}} ({{if .Error}}<span class="error">scan failed</span>{{else}}{{len .Vulnerabilities}} vulnerabilities{{end}})</summary>
+<p><a href="{{.URL}}">Project</a>{{if .IssueURL}} · <a href="{{.IssueURL}}">Full report</a>{{end}}</p>
+{{- if .Vulnerabilities}}
+<table>
+<tr><th>Severity</th><th>OSV</th><th>CVSS</th><th>Ecosystem</th><th>Package</th><th>Version</th><th>Fix Available</th><th>Fixed In</th><th>Source</th><th>Summary</th></tr>
+{{- range .Vulnerabilities}}
+<tr>
+<td><span class="severity" style="background: {{.Color}}">{{.SeverityScoreKind}}</span></td>
+<td><a href="https://osv.dev/{{.Id}}">{{.Id}}</a></td>
+<td>{{.Severity}}</td>
+<td>{{.PackageEcosystem}}</td>
+<td>{{.PackageName}}</td>
+<td>{{.PackageVersion}}</td>
+<td>{{if .FixAvailable}}yes{{else}}no{{end}}</td>
+<td>{{if .FixedVersion}}{{.FixedVersion}}{{else}}-{{end}}</td>
+<td>{{.Source}}</td>
+<td>{{.Summary}}{{if .AckReason}}<br><span class="muted">Acknowledged: {{.AckReason}}</span>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- end}}
+</details>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// PublishAsHtml writes the reports as a self-contained HTML page to the given path,
+// which can be shared with people who do not have access to the issues.
+func PublishAsHtml(reports []scanner.Report, path string) error {
+	out, err := formatReportsAsHtml(reports)
+	if err != nil {
+		return errors.Join(errors.New("failed to format html report"), err)
+	}
+
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return errors.Join(fmt.Errorf("failed to write html report to %v", path), err)
+	}
+
+	return nil
+}
+
+// formatReportsAsHtml renders the reports as an HTML page, with a summary of the vulnerabilities by severity
+// and a collapsible section per project listing its vulnerabilities from the most to the least severe.
+func formatReportsAsHtml(reports []scanner.Report) ([]byte, error) {
+	counts := make(map[scanner.SeverityScoreKind]int)
+	projects := make([]htmlProject, 0, len(reports))
+	for _, r := range reports {
+		vulns := slices.Clone(r.Vulnerabilities)
+		slices.SortStableFunc(vulns, func(a, b scanner.Vulnerability) int {
+			return cmp.Compare(scanner.SeverityScoreThresholds[b.SeverityScoreKind], scanner.SeverityScoreThresholds[a.SeverityScoreKind])
+		})
+		for _, v := range vulns {
+			counts[v.SeverityScoreKind]++
+		}
+
+		projects = append(projects, htmlProject{
+			Path:     r.Project.Path,
+			URL:      r.Project.WebURL,
+			IssueURL: r.IssueUrl,
+			Error:    r.Error,
+			Vulnerabilities: pie.Map(vulns, func(v scanner.Vulnerability) htmlVulnerability {
+				return htmlVulnerability{Vulnerability: v, Color: htmlSeverityColors[v.SeverityScoreKind]}
+			}),
+		})
+	}
+
+	var buf bytes.Buffer
+	err := htmlReportTemplate.Execute(&buf, htmlReport{
+		Date: now().Local().Format("2006-01-02"),
+		Severities: pie.Map(severityScoreOrder(), func(kind scanner.SeverityScoreKind) htmlSeverityCount {
+			return htmlSeverityCount{Kind: kind, Color: htmlSeverityColors[kind], Count: counts[kind]}
+		}),
+		Projects: projects,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,63 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"sheriff/internal/repository"
+	"sheriff/internal/scanner"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatReportsAsHtml(t *testing.T) {
+	reports := []scanner.Report{
+		{
+			Project:      repository.Project{Path: "group/project1", WebURL: "https://gitlab.com/group/project1"},
+			IsVulnerable: true,
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "CVE-2021-1235", PackageName: "low-pkg", SeverityScoreKind: scanner.Low},
+				{Id: "CVE-2021-1234", PackageName: "<script>alert(1)</script>", SeverityScoreKind: scanner.Critical, Details: "a & b"},
+			},
+		},
+		{
+			Project: repository.Project{Path: "group/project2"},
+			Error:   true,
+		},
+	}
+
+	out, err := formatReportsAsHtml(reports)
+	require.NoError(t, err)
+
+	got := string(out)
+	assert.Contains(t, got, "Total number of projects scanned: 2")
+	assert.Contains(t, got, "<summary>group/project1 (2 vulnerabilities)</summary>")
+	assert.Contains(t, got, `<a href="https://osv.dev/CVE-2021-1234">CVE-2021-1234</a>`)
+	assert.Contains(t, got, "background: #b71c1c")
+	assert.Contains(t, got, "scan failed")
+	// Package names are escaped
+	assert.NotContains(t, got, "<script>")
+	assert.Contains(t, got, "&lt;script&gt;")
+	// The most severe vulnerabilities are listed first
+	assert.Less(t, strings.Index(got, "CVE-2021-1234</a>"), strings.Index(got, "CVE-2021-1235</a>"))
+}
+
+func TestFormatReportsAsHtmlWithoutReports(t *testing.T) {
+	out, err := formatReportsAsHtml([]scanner.Report{})
+	require.NoError(t, err)
+
+	assert.Contains(t, string(out), "No projects scanned.")
+}
+
+func TestPublishAsHtml(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.html")
+
+	err := PublishAsHtml(nil, path)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "<!DOCTYPE html>")
+}
@@ -1,44 +1,126 @@
 package publish
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sheriff/internal/repository"
 	"sheriff/internal/repository/provider"
 	"sheriff/internal/scanner"
+	"sheriff/internal/state"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/elliotchance/pie/v2"
 	"github.com/rs/zerolog/log"
 )
 
-// severityScoreOrder represents the order of SeverityScoreKind by their score in descending order
-// which is how we want to display it in the Issue report
-var severityScoreOrder = getSeverityScoreOrder(scanner.SeverityScoreThresholds)
+// issueVulnsRegex matches the hidden list of the vulnerabilities of an issue, see formatIssueVulns
+var issueVulnsRegex = regexp.MustCompile(`<!-- sheriff-vulnerabilities: (.*) -->`)
+
+// severityScoreOrder returns the order of SeverityScoreKind by their score in descending order
+// which is how we want to display it in the Issue report.
+// It is derived on every call, as the thresholds can be overridden through the configuration.
+func severityScoreOrder() []scanner.SeverityScoreKind {
+	return getSeverityScoreOrder(scanner.SeverityScoreThresholds)
+}
 
 // now is a function that returns the current time
 var now = time.Now
 
+// maxIssueDetailsLength is the maximum number of characters of the details of a vulnerability shown in the issue
+const maxIssueDetailsLength = 1000
+
+// maxIssueCellLength is the maximum number of characters of a cell of the tables of the issue
+const maxIssueCellLength = 200
+
+// staleVulnerabilityAge is the age from which a vulnerability left in a project is flagged as long overdue
+const staleVulnerabilityAge = 365 * 24 * time.Hour
+
+// rawReportFilename is the name of the raw osv-scanner report uploaded for the issue, see IssueOpts.AttachRawReport
+const rawReportFilename = "osv-scanner.json"
+
+// IssueOpts are the options of the issue reports
+type IssueOpts struct {
+	Title     string   // Title of the issue, which identifies the issue managed by sheriff
+	Assignees []string // Usernames assigned to newly created issues
+	Verbose   bool     // Add the summary and details of each vulnerability to the issue
+	Comment   bool     // Comment on the issue when closing it
+	Epss      bool     // Add the EPSS score of each vulnerability to the tables
+	DryRun    bool     // Only log the issues which would be opened, updated or closed
+	// Let each project enable or disable its issue in its configuration, see isIssueEnabled.
+	// Otherwise, the issue is managed in all projects.
+	EnableProjectIssue bool
+	// Only close the issue once the project has not been vulnerable for this many consecutive runs,
+	// counting this one, so that a flaky scan does not close it. 0 and 1 close it on the first clean run.
+	CloseAfterCleanCount int
+	Previous             state.State // State of the previous run, in which the clean runs are counted
+	// Renders the body of the issue from the scanner.Report of the project, see ParseIssueTemplate.
+	// The built-in layout is used if it is nil.
+	Template *template.Template
+	// Upload the raw osv-scanner report of the project (see scanner.Report.RawOsvReport) and link it from the issue.
+	// It is left out on the platforms which cannot upload files, or if the upload fails.
+	AttachRawReport bool
+}
+
 // PublishAsIssues creates or updates Issue reports for the given reports
 // It will add the Issue URL to the Report if it was created or updated successfully
-func PublishAsIssues(reports []scanner.Report, s provider.IProvider) (warn error) {
+func PublishAsIssues(reports []scanner.Report, opts IssueOpts, s provider.IProvider) (warn error) {
 	var wg sync.WaitGroup
 	for i := 0; i < len(reports); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			report := reports[i]
+			if !isIssueEnabled(report, opts) {
+				log.Info().Str("project", report.Project.Path).Msg("Issue disabled in project, skipping")
+				return
+			}
+
+			if !report.IsVulnerable && !isIssueClosable(report, opts) {
+				log.Info().Str("project", report.Project.Path).Int("cleanRuns", state.CountCleanRuns(report, opts.Previous)).Int("closeAfter", opts.CloseAfterCleanCount).Msg("Project not clean for long enough, keeping its issue as is")
+				return
+			}
+
+			if opts.DryRun {
+				if report.IsVulnerable {
+					log.Info().Str("project", report.Project.Path).Str("title", opts.Title).Msg("Dry run: would open or update issue")
+				} else {
+					log.Info().Str("project", report.Project.Path).Str("title", opts.Title).Msg("Dry run: would close issue")
+				}
+				return
+			}
+
 			if report.IsVulnerable {
-				if issue, err := s.Provide(report.Project.Repository).OpenVulnerabilityIssue(report.Project, formatIssue(report)); err != nil {
+				repoService := s.Provide(report.Project.Repository)
+				body := formatIssue(report, opts)
+				if opts.AttachRawReport {
+					body += formatRawReportAttachment(report, repoService)
+				}
+				if issue, err := repoService.OpenVulnerabilityIssue(report.Project, opts.Title, body, opts.Assignees); err != nil {
 					log.Error().Err(err).Str("project", reports[i].Project.Path).Msg("Failed to open or update issue")
 					err = fmt.Errorf("failed to open or update issue for project %v", reports[i].Project.Path)
 					warn = errors.Join(err, warn)
 				} else {
 					reports[i].IssueUrl = issue.WebURL
+					reports[i].NewVulnerabilities = findNewVulnerabilities(report.Vulnerabilities, issue.PreviousBody)
 				}
 			} else {
-				if err := s.Provide(report.Project.Repository).CloseVulnerabilityIssue(report.Project); err != nil {
+				var comment string
+				if opts.Comment {
+					comment = formatCloseComment()
+				}
+
+				if err := s.Provide(report.Project.Repository).CloseVulnerabilityIssue(report.Project, opts.Title, comment); err != nil {
 					log.Error().Err(err).Str("project", report.Project.Path).Msg("Failed to close issue")
 					err = fmt.Errorf("failed to close issue for project %v", report.Project.Path)
 					warn = errors.Join(err, warn)
@@ -51,6 +133,48 @@ func PublishAsIssues(reports []scanner.Report, s provider.IProvider) (warn error
 	return
 }
 
+// formatRawReportAttachment uploads the raw osv-scanner report of the project, and returns the markdown linking to it
+// at the end of the issue. It is empty if there is no raw report, or if it could not be uploaded, which does not
+// prevent opening or updating the issue.
+func formatRawReportAttachment(report scanner.Report, repoService repository.IRepositoryService) string {
+	if len(report.RawOsvReport) == 0 {
+		return ""
+	}
+
+	markdown, err := repoService.UploadIssueAttachment(report.Project, rawReportFilename, report.RawOsvReport)
+	if errors.Is(err, repository.ErrNotSupported) {
+		log.Debug().Err(err).Str("project", report.Project.Path).Msg("Cannot attach the raw osv-scanner report to the issue of the project")
+		return ""
+	} else if err != nil {
+		log.Warn().Err(err).Str("project", report.Project.Path).Msg("Failed to upload the raw osv-scanner report, the issue does not link to it")
+		return ""
+	}
+
+	return fmt.Sprintf("\n\n---\n\n📎 Raw osv-scanner report of this scan: %v\n", markdown)
+}
+
+// isIssueEnabled returns whether sheriff manages the issue of the project of the report.
+// If projects can enable or disable their issue, their configuration decides, and without it the issue is
+// enabled in private and internal projects but not in public ones, so that vulnerabilities are not disclosed publicly
+// unless the project opts in.
+func isIssueEnabled(report scanner.Report, opts IssueOpts) bool {
+	if !opts.EnableProjectIssue {
+		return true
+	}
+
+	if enabled := report.ProjectConfig.Report.To.Issue; enabled != nil {
+		return *enabled
+	}
+
+	return report.Project.Visibility != repository.Public
+}
+
+// isIssueClosable returns whether the project of the clean report has been clean for enough consecutive runs
+// to close its issue, see IssueOpts.CloseAfterCleanCount
+func isIssueClosable(report scanner.Report, opts IssueOpts) bool {
+	return state.CountCleanRuns(report, opts.Previous) >= opts.CloseAfterCleanCount
+}
+
 // severityBiggerThan compares two CVSS scores and returns true if a is bigger than b
 // It will fallback to string comparison if it fails to parse the CVSS scores
 func severityBiggerThan(a string, b string) bool {
@@ -63,11 +187,28 @@ func severityBiggerThan(a string, b string) bool {
 	return aFloat > bFloat
 }
 
-// groupVulnReportsByMaxSeverityKind groups the reports by the maximum severity kind of the vulnerabilities
+// failedScansGroup is the group of the reports of the projects which could not be scanned,
+// which have no meaningful severity. It is not one of the severity kinds of severityScoreOrder.
+const failedScansGroup scanner.SeverityScoreKind = "FAILED"
+
+// groupVulnReportsByMaxSeverityKind groups the reports by the maximum severity kind of the vulnerabilities.
+// Acknowledged vulnerabilities never raise the severity of a report: projects whose only vulnerabilities
+// are acknowledged are grouped under scanner.Acknowledged, whatever their original severity.
+// Reports of failed scans are grouped under failedScansGroup, whatever vulnerabilities they may have.
+// Reports only vulnerable because of license violations are left out, as they have no severity.
 func groupVulnReportsByMaxSeverityKind(reports []scanner.Report) map[scanner.SeverityScoreKind][]scanner.Report {
-	vulnerableReports := pie.Filter(reports, func(r scanner.Report) bool { return r.IsVulnerable })
-	groupedVulnerabilities := pie.GroupBy(vulnerableReports, func(r scanner.Report) scanner.SeverityScoreKind {
-		maxSeverity := pie.SortUsing(r.Vulnerabilities, func(a, b scanner.Vulnerability) bool {
+	groupedReports := pie.Filter(reports, func(r scanner.Report) bool { return r.Error || (r.IsVulnerable && len(r.Vulnerabilities) > 0) })
+	groupedVulnerabilities := pie.GroupBy(groupedReports, func(r scanner.Report) scanner.SeverityScoreKind {
+		if r.Error {
+			return failedScansGroup
+		}
+
+		unacknowledged := pie.Filter(r.Vulnerabilities, func(v scanner.Vulnerability) bool { return v.SeverityScoreKind != scanner.Acknowledged })
+		if len(unacknowledged) == 0 {
+			return scanner.Acknowledged
+		}
+
+		maxSeverity := pie.SortUsing(unacknowledged, func(a, b scanner.Vulnerability) bool {
 			return scanner.SeverityScoreThresholds[a.SeverityScoreKind] > scanner.SeverityScoreThresholds[b.SeverityScoreKind]
 		})[0]
 
@@ -77,26 +218,188 @@ func groupVulnReportsByMaxSeverityKind(reports []scanner.Report) map[scanner.Sev
 	return groupedVulnerabilities
 }
 
-// formatIssue formats the report as an issue
-func formatIssue(r scanner.Report) (mdReport string) {
-	groupedVulnerabilities := pie.GroupBy(r.Vulnerabilities, func(v scanner.Vulnerability) scanner.SeverityScoreKind { return v.SeverityScoreKind })
+// formatIssue formats the report as an issue, with the custom template if there is one.
+// If verbose is set, a collapsible section with the summary and details of each vulnerability is added below the tables.
+func formatIssue(r scanner.Report, opts IssueOpts) (mdReport string) {
+	if opts.Template != nil {
+		var b strings.Builder
+		err := opts.Template.Execute(&b, r)
+		if err == nil {
+			return b.String() + formatIssueVulns(r.Vulnerabilities)
+		}
+		log.Error().Err(err).Str("project", r.Project.Path).Msg("Failed to render issue template, using the built-in layout")
+	}
 
 	mdReport = getVulnReportHeader()
-	for _, groupName := range severityScoreOrder {
+	tables, sortedVulns := formatIssueTables(r.Vulnerabilities, opts.Epss)
+	mdReport += tables
+	mdReport += formatIssueByAge(r.Vulnerabilities)
+	mdReport += formatLicenseViolations(r.LicenseViolations)
+
+	if opts.Verbose {
+		mdReport += formatIssueDetails(sortedVulns)
+	}
+
+	// Add outdated and expired acknowledgements sections
+	mdReport += formatOutdatedAcks(r.OutdatedAcks)
+	mdReport += formatExpiredAcks(r.ExpiredAcks)
+	mdReport += formatSuppressedVulns(r.Suppressed)
+	mdReport += formatIssueVulns(r.Vulnerabilities)
+
+	return
+}
+
+// formatIssueTables formats the vulnerabilities as a markdown table per severity, from the most severe,
+// and returns them in the order of the tables
+func formatIssueTables(vs []scanner.Vulnerability, epss bool) (md string, sortedVulns []scanner.Vulnerability) {
+	groupedVulnerabilities := pie.GroupBy(vs, func(v scanner.Vulnerability) scanner.SeverityScoreKind { return v.SeverityScoreKind })
+
+	for _, groupName := range severityScoreOrder() {
 		if group, ok := groupedVulnerabilities[groupName]; ok {
 			sortedVulnsInGroup := pie.SortUsing(group, func(a, b scanner.Vulnerability) bool {
 				return severityBiggerThan(a.Severity, b.Severity)
 			})
-			mdReport += formatIssueTable(groupName, sortedVulnsInGroup)
+			md += formatIssueTable(groupName, sortedVulnsInGroup, epss)
+			sortedVulns = append(sortedVulns, sortedVulnsInGroup...)
 		}
 	}
 
-	// Add outdated acknowledgements section
-	mdReport += formatOutdatedAcks(r.OutdatedAcks)
+	return
+}
+
+// issueTemplateFuncs are the helper functions available in custom issue templates, see ParseIssueTemplate
+var issueTemplateFuncs = template.FuncMap{
+	"severityOrder":   severityScoreOrder,
+	"markdownBoolean": markdownBoolean,
+	"markdownCell":    formatMarkdownCell,
+}
+
+// ParseIssueTemplate parses the Go text/template file which renders the body of the issue from the scanner.Report
+// of the project. Besides the built-in functions of templates, it can use:
+//   - severityOrder, the severity kinds from the most to the least severe
+//   - markdownBoolean, an emoji for a boolean
+//   - markdownCell, a value escaped and truncated to fit in a cell of a markdown table
+//
+// The template is rendered once for an empty report, so that a template referring to unknown fields fails at startup
+// rather than when the issues are published.
+func ParseIssueTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to read issue template %v", path), err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(issueTemplateFuncs).Parse(string(content))
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to parse issue template %v", path), err)
+	}
+
+	if err := tmpl.Execute(io.Discard, scanner.Report{}); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to render issue template %v", path), err)
+	}
+
+	return tmpl, nil
+}
+
+// formatIssueVulns formats the vulnerabilities as a hidden markdown comment, which the next run reads back
+// from the issue to tell which vulnerabilities are new, see findNewVulnerabilities
+func formatIssueVulns(vs []scanner.Vulnerability) string {
+	keys, _ := json.Marshal(pie.Map(vs, issueVulnKey))
+
+	return fmt.Sprintf("\n<!-- sheriff-vulnerabilities: %s -->\n", keys)
+}
+
+// findNewVulnerabilities returns the vulnerabilities which are not listed in the given body of the issue.
+// Vulnerabilities are matched by their id and package name. All of them are new if the body has no list,
+// e.g. if the issue was just created or was last updated by a version of sheriff which did not list them.
+func findNewVulnerabilities(vs []scanner.Vulnerability, previousBody string) []scanner.Vulnerability {
+	var previous []string
+	if m := issueVulnsRegex.FindStringSubmatch(previousBody); m != nil {
+		if err := json.Unmarshal([]byte(m[1]), &previous); err != nil {
+			log.Warn().Err(err).Msg("Failed to parse the vulnerabilities of the previous issue, considering them all new")
+		}
+	}
+
+	return pie.Filter(vs, func(v scanner.Vulnerability) bool { return !slices.Contains(previous, issueVulnKey(v)) })
+}
+
+func issueVulnKey(v scanner.Vulnerability) string {
+	return v.Id + "|" + v.PackageName
+}
+
+// formatIssueByAge formats the vulnerabilities whose publication date is known as a collapsible list,
+// from the oldest, so that those left unfixed for the longest stand out. Acknowledged vulnerabilities are left out.
+func formatIssueByAge(vs []scanner.Vulnerability) (md string) {
+	dated := pie.Filter(vs, func(v scanner.Vulnerability) bool {
+		return !v.Published.IsZero() && v.SeverityScoreKind != scanner.Acknowledged
+	})
+	if len(dated) == 0 {
+		return
+	}
+
+	slices.SortStableFunc(dated, func(a, b scanner.Vulnerability) int { return a.Published.Compare(b.Published) })
+	md = "\n<details>\n<summary>Vulnerabilities by age, from the oldest</summary>\n\n"
+	for _, v := range dated {
+		md += fmt.Sprintf("- %v `%v` in %v %v (%v)\n", formatAge(v.Published), v.Id, v.PackageName, v.PackageVersion, v.SeverityScoreKind)
+	}
+	md += "\n</details>\n"
+
+	return
+}
+
+// formatLicenseViolations formats the packages whose license is not allowed as a markdown table
+func formatLicenseViolations(violations []scanner.LicenseViolation) (md string) {
+	if len(violations) == 0 {
+		return
+	}
+
+	md = "\n## License violations\n"
+	md += "\n⚖️ The licenses of these packages are not in the allowed licenses.\n\n"
+	columns := []string{"Ecosystem", "Package", "Version", "Licenses", "Source"}
+	md += formatMarkdownRow(columns)
+	md += formatMarkdownRow(pie.Map(columns, func(string) string { return "---" }))
+	for _, v := range violations {
+		md += formatMarkdownRow([]string{v.PackageEcosystem, v.PackageName, v.PackageVersion, strings.Join(v.Licenses, ", "), strings.Join(v.Sources, ", ")})
+	}
 
 	return
 }
 
+// formatIssueDetails formats the summary and details of each vulnerability as collapsible markdown sections
+func formatIssueDetails(vs []scanner.Vulnerability) (md string) {
+	if len(vs) == 0 {
+		return
+	}
+
+	md = "\n\n-------\n\n### Details\n"
+	for _, vuln := range vs {
+		summary := vuln.Summary
+		if summary == "" {
+			summary = "No summary available"
+		}
+
+		md += fmt.Sprintf(
+			"\n<details>\n<summary><b>%v</b> (%v %v): %v</summary>\n\n%v\n\n</details>\n",
+			vuln.Id,
+			html.EscapeString(vuln.PackageName),
+			html.EscapeString(vuln.PackageVersion),
+			html.EscapeString(summary),
+			truncate(vuln.Details, maxIssueDetailsLength),
+		)
+	}
+
+	return
+}
+
+// truncate shortens s to at most maxLen characters, marking it with an ellipsis if it was cut
+func truncate(s string, maxLen int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= maxLen {
+		return string(runes)
+	}
+
+	return strings.TrimSpace(string(runes[:maxLen])) + "…"
+}
+
 // formatOutdatedAcks formats the outdated acknowledgements as a markdown section
 func formatOutdatedAcks(outdatedAcks []string) (md string) {
 	if len(outdatedAcks) == 0 {
@@ -111,48 +414,183 @@ func formatOutdatedAcks(outdatedAcks []string) (md string) {
 	return
 }
 
+// formatExpiredAcks formats the expired acknowledgements as a markdown section
+func formatExpiredAcks(expiredAcks []string) (md string) {
+	if len(expiredAcks) == 0 {
+		return
+	}
+
+	md = "\n\n-------\n\n### Expired Acknowledgements\n"
+	md += "\n⏰ The acknowledgement of these vulnerabilities has expired, so they are reported with their real severity again. Please review them.\n\n"
+	for _, ack := range expiredAcks {
+		md += fmt.Sprintf("- `%v`\n", ack)
+	}
+	return
+}
+
+// formatSuppressedVulns formats the vulnerabilities suppressed by the ignore file of the project as a markdown section
+func formatSuppressedVulns(suppressed []scanner.Vulnerability) (md string) {
+	if len(suppressed) == 0 {
+		return
+	}
+
+	md = "\n\n-------\n\n### Suppressed Vulnerabilities\n"
+	md += "\n🙈 These vulnerabilities were only found in files listed in `.sheriffignore`, so they are not reported above.\n\n"
+	for _, v := range suppressed {
+		md += fmt.Sprintf("- `%v` in %v %v (%v)\n", v.Id, v.PackageName, v.PackageVersion, strings.Join(v.Sources, ", "))
+	}
+	return
+}
+
 // formatIssueTable formats a group of vulnerabilities as a markdown table
-// for the issue report
-func formatIssueTable(groupName scanner.SeverityScoreKind, vs []scanner.Vulnerability) (md string) {
+// for the issue report, with an EPSS column if epss is set, an age column if the publication date of any vulnerability
+// is known, and a reachability column if any vulnerability was analysed
+func formatIssueTable(groupName scanner.SeverityScoreKind, vs []scanner.Vulnerability, epss bool) (md string) {
 	md = fmt.Sprintf("\n## Severity: %v\n", groupName)
+	columns := []string{"OSV URL", "CVSS"}
+	if epss {
+		columns = append(columns, "EPSS")
+	}
+	columns = append(columns, "Ecosystem", "Package", "Version", "Package URL", "Fix Available", "Fixed In")
+	// Only some scanners tell when the vulnerabilities were published
+	age := pie.Any(vs, func(v scanner.Vulnerability) bool { return !v.Published.IsZero() })
+	if age {
+		columns = append(columns, "Age")
+	}
+	// Only projects scanned with call analysis know whether their vulnerabilities are reachable
+	reachability := pie.Any(vs, func(v scanner.Vulnerability) bool { return v.Reachable != nil })
+	if reachability {
+		columns = append(columns, "Reachable")
+	}
 	if groupName == scanner.Acknowledged {
 		md += "\n💡 These vulnerabilities have been acknowledged by the team and are not considered a risk.\n\n"
 		// Acknowledge vulnerabilities have an extra `Reason` column
-		md += "| OSV URL | CVSS | Ecosystem | Package | Version | Fix Available | Reason | Source |\n| --- | --- | --- | --- | --- | --- | --- | --- |\n"
-	} else {
-		md += "| OSV URL | CVSS | Ecosystem | Package | Version | Fix Available | Source |\n| --- | --- | --- | --- | --- | --- | --- |\n"
+		columns = append(columns, "Reason")
 	}
+	columns = append(columns, "Source")
+
+	md += formatMarkdownRow(columns)
+	md += formatMarkdownRow(pie.Map(columns, func(string) string { return "---" }))
 
 	for _, vuln := range vs {
+		row := []string{formatOsvUrl(vuln), vuln.Severity}
+		if epss {
+			row = append(row, formatEpss(vuln.Epss))
+		}
+		row = append(row, vuln.PackageEcosystem, vuln.PackageName, vuln.PackageVersion, formatPackageUrl(vuln.PackageUrl), markdownBoolean(vuln.FixAvailable), formatFixedVersion(vuln.FixedVersion))
+		if age {
+			row = append(row, formatAge(vuln.Published))
+		}
+		if reachability {
+			row = append(row, formatReachable(vuln.Reachable))
+		}
 		if groupName == scanner.Acknowledged {
-			md += fmt.Sprintf(
-				"| %v | %v | %v | %v | %v | %v | %v | %v |\n",
-				fmt.Sprintf("https://osv.dev/%s", vuln.Id),
-				vuln.Severity,
-				vuln.PackageEcosystem,
-				vuln.PackageName,
-				vuln.PackageVersion,
-				markdownBoolean(vuln.FixAvailable),
-				vuln.AckReason,
-				vuln.Source,
-			)
-		} else {
-			md += fmt.Sprintf(
-				"| %v | %v | %v | %v | %v | %v | %v |\n",
-				fmt.Sprintf("https://osv.dev/%s", vuln.Id),
-				vuln.Severity,
-				vuln.PackageEcosystem,
-				vuln.PackageName,
-				vuln.PackageVersion,
-				markdownBoolean(vuln.FixAvailable),
-				vuln.Source,
-			)
+			row = append(row, vuln.AckReason)
 		}
+		row = append(row, formatSources(vuln))
+
+		md += formatMarkdownRow(row)
 	}
 
 	return
 }
 
+// formatMarkdownRow formats the given cells as a row of a markdown table, see formatMarkdownCell
+func formatMarkdownRow(cells []string) string {
+	return fmt.Sprintf("| %v |\n", strings.Join(pie.Map(cells, formatMarkdownCell), " | "))
+}
+
+// markdownCellReplacer escapes the characters which end a cell or a code span, and joins the lines,
+// as a line break ends the row of a markdown table
+var markdownCellReplacer = strings.NewReplacer("|", "\\|", "`", "\\`", "\r\n", " ", "\n", " ", "\r", " ")
+
+// formatMarkdownCell formats the value of a cell so that it stays within its cell of a markdown table,
+// truncating it if it is longer than maxIssueCellLength
+func formatMarkdownCell(value string) string {
+	return markdownCellReplacer.Replace(truncate(value, maxIssueCellLength))
+}
+
+// formatReachable formats whether the vulnerable code is called, so that reachable vulnerabilities stand out
+func formatReachable(reachable *bool) string {
+	if reachable == nil {
+		return "-"
+	} else if *reachable {
+		return "🎯 reachable"
+	}
+
+	return "💤 potentially unreachable"
+}
+
+// formatAge formats how long ago the vulnerability was published, flagging those published over staleVulnerabilityAge ago,
+// or returns a dash if its publication date is unknown
+func formatAge(published time.Time) string {
+	if published.IsZero() {
+		return "-"
+	}
+
+	age := now().Sub(published)
+	var text string
+	switch days := int(age.Hours() / 24); days {
+	case 0:
+		text = "published today"
+	case 1:
+		text = "published 1 day ago"
+	default:
+		text = fmt.Sprintf("published %v days ago", days)
+	}
+	if age >= staleVulnerabilityAge {
+		return "🕰️ " + text
+	}
+
+	return text
+}
+
+// formatFixedVersion returns the version to upgrade to, or a dash if no fix is known
+func formatFixedVersion(version string) string {
+	if version == "" {
+		return "-"
+	}
+
+	return version
+}
+
+// formatPackageUrl returns the package URL (purl) of the vulnerable package, or a dash if it is unknown
+func formatPackageUrl(purl string) string {
+	if purl == "" {
+		return "-"
+	}
+
+	return purl
+}
+
+// formatEpss formats an EPSS score as a percentage
+func formatEpss(score float64) string {
+	if score < 0 {
+		return "unknown"
+	}
+
+	return fmt.Sprintf("%.2f%%", score*100)
+}
+
+// formatSources returns the lockfiles the vulnerability was found in, falling back to its source file name
+func formatSources(v scanner.Vulnerability) string {
+	if len(v.Sources) == 0 {
+		return v.Source
+	}
+
+	return strings.Join(v.Sources, ", ")
+}
+
+// formatOsvUrl returns the OSV URL of the vulnerability, followed by its aliases if any
+func formatOsvUrl(v scanner.Vulnerability) string {
+	url := fmt.Sprintf("https://osv.dev/%s", v.Id)
+	if len(v.Aliases) == 0 {
+		return url
+	}
+
+	return fmt.Sprintf("%v (%v)", url, strings.Join(v.Aliases, ", "))
+}
+
 // markdownBoolean returns a markdown emoji for a boolean value
 func markdownBoolean(b bool) string {
 	if b {
@@ -161,6 +599,11 @@ func markdownBoolean(b bool) string {
 	return "❌"
 }
 
+// formatCloseComment returns the comment posted on the issue when it is closed
+func formatCloseComment() string {
+	return fmt.Sprintf("✅ All previously reported vulnerabilities are resolved as of %s.", now().Local().Format("2006-01-02"))
+}
+
 // getVulnReportHeader returns the header for the vulnerability report
 func getVulnReportHeader() string {
 	currentTime := now().Local()
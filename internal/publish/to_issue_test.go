@@ -1,14 +1,23 @@
 package publish
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sheriff/internal/config"
 	"sheriff/internal/repository"
 	"sheriff/internal/scanner"
+	"sheriff/internal/state"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Severities are grouped by severity score kind
@@ -64,31 +73,31 @@ func TestFormatGitlabIssue(t *testing.T) {
 
 	got := formatIssue(scanner.Report{
 		Vulnerabilities: mockVulnerabilities,
-	})
+	}, IssueOpts{})
 
 	want := `
 ## Severity: CRITICAL
-| OSV URL | CVSS | Ecosystem | Package | Version | Fix Available | Source |
-| --- | --- | --- | --- | --- | --- | --- |
-| https://osv.dev/test1 | 10.00 | ecosystem | name | version | ❌ | test |
+| OSV URL | CVSS | Ecosystem | Package | Version | Package URL | Fix Available | Fixed In | Source |
+| --- | --- | --- | --- | --- | --- | --- | --- | --- |
+| https://osv.dev/test1 | 10.00 | ecosystem | name | version | - | ❌ | - | test |
 
 ## Severity: MODERATE
-| OSV URL | CVSS | Ecosystem | Package | Version | Fix Available | Source |
-| --- | --- | --- | --- | --- | --- | --- |
-| https://osv.dev/test3 | 5.00 | ecosystem | name | version | ❌ | test |
+| OSV URL | CVSS | Ecosystem | Package | Version | Package URL | Fix Available | Fixed In | Source |
+| --- | --- | --- | --- | --- | --- | --- | --- | --- |
+| https://osv.dev/test3 | 5.00 | ecosystem | name | version | - | ❌ | - | test |
 
 ## Severity: LOW
-| OSV URL | CVSS | Ecosystem | Package | Version | Fix Available | Source |
-| --- | --- | --- | --- | --- | --- | --- |
-| https://osv.dev/test2 | 0.00 | ecosystem | name | version | ❌ | test |
+| OSV URL | CVSS | Ecosystem | Package | Version | Package URL | Fix Available | Fixed In | Source |
+| --- | --- | --- | --- | --- | --- | --- | --- | --- |
+| https://osv.dev/test2 | 0.00 | ecosystem | name | version | - | ❌ | - | test |
 
 ## Severity: ACKNOWLEDGED
 
 💡 These vulnerabilities have been acknowledged by the team and are not considered a risk.
 
-| OSV URL | CVSS | Ecosystem | Package | Version | Fix Available | Reason | Source |
-| --- | --- | --- | --- | --- | --- | --- | --- |
-| https://osv.dev/test4 | 0.00 | ecosystem | name | version | ❌ | This only happens in Windows, and imagine running serious software in Windows! | test |
+| OSV URL | CVSS | Ecosystem | Package | Version | Package URL | Fix Available | Fixed In | Reason | Source |
+| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |
+| https://osv.dev/test4 | 0.00 | ecosystem | name | version | - | ❌ | - | This only happens in Windows, and imagine running serious software in Windows! | test |
 `
 
 	assert.NotEmpty(t, got)
@@ -135,20 +144,282 @@ func TestFormatGitlabIssueSortWithinGroup(t *testing.T) {
 
 	got := formatIssue(scanner.Report{
 		Vulnerabilities: mockVulnerabilities,
-	})
+	}, IssueOpts{})
 
 	want := `
 ## Severity: HIGH
-| OSV URL | CVSS | Ecosystem | Package | Version | Fix Available | Source |
-| --- | --- | --- | --- | --- | --- | --- |
-| https://osv.dev/test2 | 8.9 | ecosystem | name | version | ❌ | test |
-| https://osv.dev/test3 | 8.5 | ecosystem | name | version | ❌ | test |
-| https://osv.dev/test1 | 8.00 | ecosystem | name | version | ❌ | test |
+| OSV URL | CVSS | Ecosystem | Package | Version | Package URL | Fix Available | Fixed In | Source |
+| --- | --- | --- | --- | --- | --- | --- | --- | --- |
+| https://osv.dev/test2 | 8.9 | ecosystem | name | version | - | ❌ | - | test |
+| https://osv.dev/test3 | 8.5 | ecosystem | name | version | - | ❌ | - | test |
+| https://osv.dev/test1 | 8.00 | ecosystem | name | version | - | ❌ | - | test |
 `
 	assert.NotEmpty(t, got)
 	assert.Contains(t, got, want)
 }
 
+func TestFormatGitlabIssueListsAliases(t *testing.T) {
+	got := formatIssueTable(scanner.High, []scanner.Vulnerability{
+		{
+			Id:               "GHSA-xxxx-yyyy-zzzz",
+			Aliases:          []string{"CVE-2021-1234", "PYSEC-2021-1"},
+			PackageName:      "name",
+			PackageVersion:   "version",
+			PackageEcosystem: "ecosystem",
+			Source:           "test",
+			Severity:         "8.0",
+		},
+	}, false)
+
+	assert.Contains(t, got, "| https://osv.dev/GHSA-xxxx-yyyy-zzzz (CVE-2021-1234, PYSEC-2021-1) | 8.0 | ecosystem | name | version | - | ❌ | - | test |\n")
+	assert.Equal(t, 1, strings.Count(got, "https://osv.dev/"))
+}
+
+func TestFormatGitlabIssueListsSources(t *testing.T) {
+	got := formatIssueTable(scanner.High, []scanner.Vulnerability{
+		{
+			Id:               "CVE-2021-1234",
+			PackageName:      "name",
+			PackageVersion:   "version",
+			PackageEcosystem: "ecosystem",
+			Source:           "go.mod",
+			Sources:          []string{"go.mod", "tools/go.mod"},
+			Severity:         "8.0",
+		},
+	}, false)
+
+	assert.Contains(t, got, "| https://osv.dev/CVE-2021-1234 | 8.0 | ecosystem | name | version | - | ❌ | - | go.mod, tools/go.mod |\n")
+}
+
+func TestFormatGitlabIssueEscapesCells(t *testing.T) {
+	got := formatIssueTable(scanner.Acknowledged, []scanner.Vulnerability{
+		{
+			Id:               "CVE-2021-1234",
+			PackageName:      "name|with`pipe",
+			PackageVersion:   "version",
+			PackageEcosystem: "ecosystem",
+			Source:           "go.mod",
+			Severity:         "8.0",
+			AckReason:        "Not used\nin production | " + strings.Repeat("a", 300),
+		},
+	}, false)
+
+	rows := strings.Split(strings.TrimSpace(got[strings.Index(got, "| OSV URL"):]), "\n")
+	require.Len(t, rows, 3)
+	row := rows[2]
+	assert.True(t, strings.HasPrefix(row, "| https://osv.dev/CVE-2021-1234 | 8.0 | ecosystem | name\\|with\\`pipe | version |"))
+	assert.Contains(t, row, "| Not used in production \\| aaa")
+	assert.Contains(t, row, "…")
+	// Escaped pipes do not separate cells, so the row has as many cells as the header
+	assert.Equal(t, strings.Count(rows[0], " | "), strings.Count(strings.ReplaceAll(row, "\\|", ""), " | "))
+}
+
+func TestFormatGitlabIssueWithEpss(t *testing.T) {
+	got := formatIssueTable(scanner.High, []scanner.Vulnerability{
+		{Id: "CVE-2021-1234", PackageName: "name", PackageVersion: "version", PackageEcosystem: "ecosystem", Source: "go.mod", Severity: "8.0", Epss: 0.1234},
+		{Id: "GHSA-xxxx-yyyy-zzzz", PackageName: "name", PackageVersion: "version", PackageEcosystem: "ecosystem", Source: "go.mod", Severity: "8.0", Epss: scanner.EpssUnknown},
+	}, true)
+
+	assert.Contains(t, got, "| OSV URL | CVSS | EPSS | Ecosystem | Package | Version | Package URL | Fix Available | Fixed In | Source |\n")
+	assert.Contains(t, got, "| https://osv.dev/CVE-2021-1234 | 8.0 | 12.34% | ecosystem | name | version | - | ❌ | - | go.mod |\n")
+	assert.Contains(t, got, "| https://osv.dev/GHSA-xxxx-yyyy-zzzz | 8.0 | unknown | ecosystem | name | version | - | ❌ | - | go.mod |\n")
+}
+
+func TestFormatGitlabIssueWithReachability(t *testing.T) {
+	yes, no := true, false
+	got := formatIssueTable(scanner.High, []scanner.Vulnerability{
+		{Id: "GO-2023-0001", PackageName: "name", PackageVersion: "version", PackageEcosystem: "Go", Source: "go.mod", Severity: "8.0", Reachable: &yes},
+		{Id: "GO-2023-0002", PackageName: "name", PackageVersion: "version", PackageEcosystem: "Go", Source: "go.mod", Severity: "8.0", Reachable: &no},
+	}, false)
+
+	assert.Contains(t, got, "| OSV URL | CVSS | Ecosystem | Package | Version | Package URL | Fix Available | Fixed In | Reachable | Source |\n")
+	assert.Contains(t, got, "| https://osv.dev/GO-2023-0001 | 8.0 | Go | name | version | - | ❌ | - | 🎯 reachable | go.mod |\n")
+	assert.Contains(t, got, "| https://osv.dev/GO-2023-0002 | 8.0 | Go | name | version | - | ❌ | - | 💤 potentially unreachable | go.mod |\n")
+}
+
+func TestFormatGitlabIssueWithFixedVersion(t *testing.T) {
+	got := formatIssueTable(scanner.High, []scanner.Vulnerability{
+		{Id: "CVE-2021-1234", PackageName: "name", PackageVersion: "1.0.0", PackageEcosystem: "npm", Source: "package-lock.json", Severity: "8.0", FixAvailable: true, FixedVersion: "1.0.1"},
+	}, false)
+
+	assert.Contains(t, got, "| https://osv.dev/CVE-2021-1234 | 8.0 | npm | name | 1.0.0 | - | ✅ | 1.0.1 | package-lock.json |\n")
+}
+
+func TestFormatGitlabIssueWithPackageUrlFromOsvReport(t *testing.T) {
+	osvOutput := `{"results": [{"source": {"path": "/tmp/project/package-lock.json", "type": "lockfile"}, "packages": [{
+		"package": {"name": "semver", "version": "7.3.7", "ecosystem": "npm"},
+		"vulnerabilities": [{"id": "GHSA-c2qf-rxjj-qqgw", "affected": [{"package": {"name": "semver", "ecosystem": "npm", "purl": "pkg:npm/semver"}}]}],
+		"groups": [{"ids": ["GHSA-c2qf-rxjj-qqgw"], "max_severity": "7.5"}]
+	}]}]}`
+	var osvReport scanner.OsvReport
+	require.NoError(t, json.Unmarshal([]byte(osvOutput), &osvReport))
+	osvScanner, err := scanner.NewOsvScanner(scanner.OsvOpts{})
+	require.NoError(t, err)
+
+	report := osvScanner.GenerateReport(repository.Project{Path: "group/project"}, &osvReport)
+
+	assert.Contains(t, formatIssue(report, IssueOpts{}), "| https://osv.dev/GHSA-c2qf-rxjj-qqgw | 7.5 | npm | semver | 7.3.7 | pkg:npm/semver@7.3.7 | ❌ | - | package-lock.json |\n")
+	assert.Contains(t, formatReportsJSONForConsole([]scanner.Report{report}), `"package_urls":["pkg:npm/semver@7.3.7"]`)
+}
+
+func TestFormatGitlabIssueWithPublishedDateFromOsvReport(t *testing.T) {
+	origNow := now
+	now = func() time.Time { return time.Date(2025, 9, 3, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = origNow }()
+
+	osvOutput := `{"results": [{"source": {"path": "/tmp/project/package-lock.json", "type": "lockfile"}, "packages": [{
+		"package": {"name": "semver", "version": "7.3.7", "ecosystem": "npm"},
+		"vulnerabilities": [
+			{"id": "GHSA-c2qf-rxjj-qqgw", "published": "2023-06-21T06:30:28Z"},
+			{"id": "GHSA-xxxx-yyyy-zzzz", "published": "2025-08-24T08:00:00Z"}
+		],
+		"groups": [{"ids": ["GHSA-c2qf-rxjj-qqgw"], "max_severity": "7.5"}, {"ids": ["GHSA-xxxx-yyyy-zzzz"], "max_severity": "5.3"}]
+	}]}]}`
+	var osvReport scanner.OsvReport
+	require.NoError(t, json.Unmarshal([]byte(osvOutput), &osvReport))
+	osvScanner, err := scanner.NewOsvScanner(scanner.OsvOpts{})
+	require.NoError(t, err)
+
+	report := osvScanner.GenerateReport(repository.Project{Path: "group/project"}, &osvReport)
+
+	require.Len(t, report.Vulnerabilities, 2)
+	assert.Equal(t, time.Date(2023, 6, 21, 6, 30, 28, 0, time.UTC), report.Vulnerabilities[0].Published)
+	got := formatIssue(report, IssueOpts{})
+	assert.Contains(t, got, "| Fix Available | Fixed In | Age | Source |\n")
+	assert.Contains(t, got, "| https://osv.dev/GHSA-c2qf-rxjj-qqgw | 7.5 | npm | semver | 7.3.7 | - | ❌ | - | 🕰️ published 805 days ago | package-lock.json |\n")
+	assert.Contains(t, got, "| https://osv.dev/GHSA-xxxx-yyyy-zzzz | 5.3 | npm | semver | 7.3.7 | - | ❌ | - | published 10 days ago | package-lock.json |\n")
+	assert.Contains(t, got, "<summary>Vulnerabilities by age, from the oldest</summary>\n\n"+
+		"- 🕰️ published 805 days ago `GHSA-c2qf-rxjj-qqgw` in semver 7.3.7 (MODERATE)\n"+
+		"- published 10 days ago `GHSA-xxxx-yyyy-zzzz` in semver 7.3.7 (MODERATE)\n")
+}
+
+func TestFormatAge(t *testing.T) {
+	origNow := now
+	now = func() time.Time { return time.Date(2025, 9, 3, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = origNow }()
+
+	assert.Equal(t, "-", formatAge(time.Time{}))
+	assert.Equal(t, "published today", formatAge(time.Date(2025, 9, 3, 8, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "published 1 day ago", formatAge(time.Date(2025, 9, 2, 8, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "published 364 days ago", formatAge(time.Date(2024, 9, 4, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "🕰️ published 365 days ago", formatAge(time.Date(2024, 9, 3, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestFormatGitlabIssueWithDetails(t *testing.T) {
+	vuln := scanner.Vulnerability{
+		Id:                "GHSA-xxxx-yyyy-zzzz",
+		PackageName:       "name",
+		PackageVersion:    "version",
+		Severity:          "8.0",
+		SeverityScoreKind: scanner.High,
+		Summary:           "Remote code execution in <name>",
+		Details:           "Some details about the vulnerability.",
+	}
+
+	got := formatIssue(scanner.Report{Vulnerabilities: []scanner.Vulnerability{vuln}}, IssueOpts{Verbose: true})
+
+	want := `
+<details>
+<summary><b>GHSA-xxxx-yyyy-zzzz</b> (name version): Remote code execution in &lt;name&gt;</summary>
+
+Some details about the vulnerability.
+
+</details>
+`
+	assert.Contains(t, got, "| https://osv.dev/GHSA-xxxx-yyyy-zzzz |")
+	assert.Contains(t, got, "### Details")
+	assert.Contains(t, got, want)
+
+	t.Run("OmitsDetailsWhenNotVerbose", func(t *testing.T) {
+		got := formatIssue(scanner.Report{Vulnerabilities: []scanner.Vulnerability{vuln}}, IssueOpts{})
+
+		assert.NotContains(t, got, "<details>")
+	})
+}
+
+func TestFormatGitlabIssueWithTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "issue.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte(`# {{ .Project.Path }}
+{{ range $kind := severityOrder }}{{ range $.Vulnerabilities }}{{ if eq .SeverityScoreKind $kind }}- {{ .Id }} ({{ $kind }}) in {{ markdownCell .PackageName }} fixable: {{ markdownBoolean (ne .FixedVersion "") }}
+{{ end }}{{ end }}{{ end }}`), 0644))
+	tmpl, err := ParseIssueTemplate(path)
+	require.NoError(t, err)
+
+	report := scanner.Report{
+		Project: repository.Project{Path: "group/project"},
+		Vulnerabilities: []scanner.Vulnerability{
+			{Id: "low", PackageName: "a|b", SeverityScoreKind: scanner.Low},
+			{Id: "critical", PackageName: "lodash", SeverityScoreKind: scanner.Critical, FixedVersion: "4.17.21"},
+		},
+	}
+
+	got := formatIssue(report, IssueOpts{Template: tmpl})
+
+	assert.True(t, strings.HasPrefix(got, "# group/project\n- critical (CRITICAL) in lodash fixable: ✅\n- low (LOW) in a\\|b fixable: ❌\n"), got)
+	assert.Contains(t, got, "<!-- sheriff-vulnerabilities:", "the vulnerabilities are listed for the next run whatever the template")
+	assert.NotContains(t, got, "This issue lists all the vulnerabilities")
+}
+
+func TestParseIssueTemplateInvalid(t *testing.T) {
+	dir := t.TempDir()
+	testCases := map[string]string{
+		"syntax":        "{{ .Project.Path ",
+		"unknown field": "{{ .Unknown }}",
+		"unknown func":  "{{ shout .Project.Path }}",
+	}
+
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".tmpl")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+			_, err := ParseIssueTemplate(path)
+
+			assert.ErrorContains(t, err, "issue template")
+		})
+	}
+
+	_, err := ParseIssueTemplate(filepath.Join(dir, "missing.tmpl"))
+	assert.ErrorContains(t, err, "failed to read issue template")
+}
+
+func TestFormatLicenseViolations(t *testing.T) {
+	got := formatIssue(scanner.Report{
+		IsVulnerable: true,
+		LicenseViolations: []scanner.LicenseViolation{
+			{PackageName: "left-pad", PackageVersion: "1.3.0", PackageEcosystem: "npm", Licenses: []string{"WTFPL"}, Sources: []string{"package-lock.json", "web/package-lock.json"}},
+		},
+	}, IssueOpts{})
+
+	assert.Contains(t, got, "## License violations")
+	assert.Contains(t, got, "| Ecosystem | Package | Version | Licenses | Source |\n")
+	assert.Contains(t, got, "| npm | left-pad | 1.3.0 | WTFPL | package-lock.json, web/package-lock.json |\n")
+	assert.Empty(t, formatLicenseViolations(nil))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("  short\n", 10))
+	assert.Equal(t, "éééé…", truncate("ééééé", 4))
+}
+
+func TestFormatExpiredAcks(t *testing.T) {
+	got := formatIssue(scanner.Report{ExpiredAcks: []string{"CVE-1", "CVE-2"}}, IssueOpts{})
+
+	assert.Contains(t, got, "### Expired Acknowledgements")
+	assert.Contains(t, got, "- `CVE-1`\n- `CVE-2`\n")
+	assert.Empty(t, formatExpiredAcks(nil))
+}
+
+func TestFormatSuppressedVulns(t *testing.T) {
+	got := formatIssue(scanner.Report{Suppressed: []scanner.Vulnerability{
+		{Id: "GO-2024-0001", PackageName: "golang.org/x/net", PackageVersion: "0.1.0", Sources: []string{"tools/go.mod"}},
+	}}, IssueOpts{})
+
+	assert.Contains(t, got, "### Suppressed Vulnerabilities")
+	assert.Contains(t, got, "- `GO-2024-0001` in golang.org/x/net 0.1.0 (tools/go.mod)\n")
+	assert.Empty(t, formatSuppressedVulns(nil))
+}
+
 func TestMarkdownBoolean(t *testing.T) {
 	testCases := map[bool]string{
 		true:  "✅",
@@ -165,7 +436,7 @@ func TestMarkdownBoolean(t *testing.T) {
 
 func TestPublishAsGitlabIssues(t *testing.T) {
 	mockGitlabService := &mockGitlabService{}
-	mockGitlabService.On("OpenVulnerabilityIssue", mock.Anything, mock.Anything).Return(&repository.Issue{WebURL: "https://my-issue.com"}, nil)
+	mockGitlabService.On("OpenVulnerabilityIssue", mock.Anything, "Custom title", mock.Anything, []string{"alice"}).Return(&repository.Issue{WebURL: "https://my-issue.com"}, nil)
 
 	mockRepoService := &mockRepoService{}
 	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
@@ -182,7 +453,7 @@ func TestPublishAsGitlabIssues(t *testing.T) {
 		},
 	}
 
-	_ = PublishAsIssues(reports, mockRepoService)
+	_ = PublishAsIssues(reports, IssueOpts{Title: "Custom title", Assignees: []string{"alice"}}, mockRepoService)
 	mockGitlabService.AssertExpectations(t)
 	mockRepoService.AssertExpectations(t)
 
@@ -192,6 +463,241 @@ func TestPublishAsGitlabIssues(t *testing.T) {
 
 }
 
+func TestPublishAsIssuesDryRun(t *testing.T) {
+	mockGitlabService := &mockGitlabService{}
+	mockRepoService := &mockRepoService{}
+
+	reports := []scanner.Report{
+		{IsVulnerable: true, Vulnerabilities: []scanner.Vulnerability{{Id: "test1"}}},
+		{IsVulnerable: false},
+	}
+
+	warn := PublishAsIssues(reports, IssueOpts{DryRun: true}, mockRepoService)
+
+	assert.Nil(t, warn)
+	mockRepoService.AssertNotCalled(t, "Provide", mock.Anything)
+	mockGitlabService.AssertNotCalled(t, "OpenVulnerabilityIssue", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockGitlabService.AssertNotCalled(t, "CloseVulnerabilityIssue", mock.Anything, mock.Anything, mock.Anything)
+	assert.Empty(t, reports[0].IssueUrl)
+}
+
+func TestPublishAsIssuesClosesWithComment(t *testing.T) {
+	origNow := now
+	now = func() time.Time { return time.Date(2024, 5, 6, 12, 0, 0, 0, time.Local) }
+	defer func() { now = origNow }()
+
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("CloseVulnerabilityIssue", mock.Anything, "title", "✅ All previously reported vulnerabilities are resolved as of 2024-05-06.").Return(nil)
+
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	reports := []scanner.Report{{Project: repository.Project{Repository: repository.Gitlab}, IsVulnerable: false}}
+
+	warn := PublishAsIssues(reports, IssueOpts{Title: "title", Comment: true}, mockRepoService)
+
+	assert.Nil(t, warn)
+	mockGitlabService.AssertExpectations(t)
+}
+
+func TestPublishAsIssuesClosesAfterCleanCount(t *testing.T) {
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("CloseVulnerabilityIssue", repository.Project{Path: "group/clean-long-enough", Repository: repository.Gitlab}, "title", "").Return(nil)
+
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	previous := state.State{Projects: map[string]state.ProjectState{
+		"group/clean-long-enough": {CleanRuns: 2},
+		"group/clean-once":        {CleanRuns: 1},
+	}}
+	reports := []scanner.Report{
+		{Project: repository.Project{Path: "group/clean-long-enough", Repository: repository.Gitlab}},
+		{Project: repository.Project{Path: "group/clean-once", Repository: repository.Gitlab}},
+		{Project: repository.Project{Path: "group/clean-now", Repository: repository.Gitlab}},
+	}
+
+	warn := PublishAsIssues(reports, IssueOpts{Title: "title", CloseAfterCleanCount: 3, Previous: previous}, mockRepoService)
+
+	assert.Nil(t, warn)
+	mockGitlabService.AssertExpectations(t)
+	mockGitlabService.AssertNumberOfCalls(t, "CloseVulnerabilityIssue", 1)
+}
+
+func TestPublishAsIssuesClosesQuietly(t *testing.T) {
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("CloseVulnerabilityIssue", mock.Anything, "title", "").Return(nil)
+
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	reports := []scanner.Report{{Project: repository.Project{Repository: repository.Gitlab}, IsVulnerable: false}}
+
+	warn := PublishAsIssues(reports, IssueOpts{Title: "title"}, mockRepoService)
+
+	assert.Nil(t, warn)
+	mockGitlabService.AssertExpectations(t)
+}
+
+func TestPublishAsIssuesWithProjectIssue(t *testing.T) {
+	enabled, disabled := true, false
+	testCases := []struct {
+		name       string
+		visibility repository.Visibility
+		issue      *bool
+		want       bool
+	}{
+		{"private by default", repository.Private, nil, true},
+		{"internal by default", repository.Internal, nil, true},
+		{"private opting out", repository.Private, &disabled, false},
+		{"public by default", repository.Public, nil, false},
+		{"public opting in", repository.Public, &enabled, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			project := repository.Project{Path: "group/project", Repository: repository.Gitlab, Visibility: tc.visibility}
+			mockGitlabService := &mockGitlabService{}
+			mockGitlabService.On("OpenVulnerabilityIssue", project, "title", mock.Anything, mock.Anything).Return(&repository.Issue{WebURL: "https://my-issue.com"}, nil)
+			mockRepoService := &mockRepoService{}
+			mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+			reports := []scanner.Report{{
+				Project:         project,
+				ProjectConfig:   config.ProjectConfig{Report: config.ProjectReport{To: config.ProjectReportTo{Issue: tc.issue}}},
+				IsVulnerable:    true,
+				Vulnerabilities: []scanner.Vulnerability{{Id: "test1"}},
+			}}
+
+			warn := PublishAsIssues(reports, IssueOpts{Title: "title", EnableProjectIssue: true}, mockRepoService)
+
+			assert.Nil(t, warn)
+			if tc.want {
+				mockGitlabService.AssertExpectations(t)
+			} else {
+				mockRepoService.AssertNotCalled(t, "Provide", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestPublishAsIssuesIgnoresProjectIssueUnlessEnabled(t *testing.T) {
+	disabled := false
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("OpenVulnerabilityIssue", mock.Anything, "title", mock.Anything, mock.Anything).Return(&repository.Issue{}, nil)
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	reports := []scanner.Report{{
+		Project:         repository.Project{Repository: repository.Gitlab, Visibility: repository.Public},
+		ProjectConfig:   config.ProjectConfig{Report: config.ProjectReport{To: config.ProjectReportTo{Issue: &disabled}}},
+		IsVulnerable:    true,
+		Vulnerabilities: []scanner.Vulnerability{{Id: "test1"}},
+	}}
+
+	_ = PublishAsIssues(reports, IssueOpts{Title: "title"}, mockRepoService)
+
+	mockGitlabService.AssertExpectations(t)
+}
+
+func TestFindNewVulnerabilities(t *testing.T) {
+	semver := scanner.Vulnerability{Id: "CVE-2022-25883", PackageName: "semver"}
+	lodash := scanner.Vulnerability{Id: "CVE-2021-23337", PackageName: "lodash"}
+	express := scanner.Vulnerability{Id: "CVE-2024-1234", PackageName: "express"}
+	previousBody := formatIssue(scanner.Report{Vulnerabilities: []scanner.Vulnerability{semver, lodash}}, IssueOpts{})
+
+	got := findNewVulnerabilities([]scanner.Vulnerability{semver, express, lodash}, previousBody)
+
+	assert.Equal(t, []scanner.Vulnerability{express}, got)
+
+	t.Run("AllNewWithoutPreviousList", func(t *testing.T) {
+		assert.Equal(t, []scanner.Vulnerability{semver}, findNewVulnerabilities([]scanner.Vulnerability{semver}, ""))
+		assert.Equal(t, []scanner.Vulnerability{semver}, findNewVulnerabilities([]scanner.Vulnerability{semver}, "| https://osv.dev/CVE-2022-25883 |"))
+	})
+}
+
+func TestPublishAsIssuesSetsNewVulnerabilities(t *testing.T) {
+	semver := scanner.Vulnerability{Id: "CVE-2022-25883", PackageName: "semver"}
+	express := scanner.Vulnerability{Id: "CVE-2024-1234", PackageName: "express"}
+	previousBody := formatIssue(scanner.Report{Vulnerabilities: []scanner.Vulnerability{semver}}, IssueOpts{})
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("OpenVulnerabilityIssue", mock.Anything, "title", mock.Anything, mock.Anything).Return(&repository.Issue{WebURL: "https://my-issue.com", PreviousBody: previousBody}, nil)
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	reports := []scanner.Report{{
+		Project:         repository.Project{Repository: repository.Gitlab},
+		IsVulnerable:    true,
+		Vulnerabilities: []scanner.Vulnerability{semver, express},
+	}}
+
+	warn := PublishAsIssues(reports, IssueOpts{Title: "title"}, mockRepoService)
+
+	assert.NoError(t, warn)
+	assert.Equal(t, []scanner.Vulnerability{express}, reports[0].NewVulnerabilities)
+}
+
+func TestPublishAsIssuesAttachesRawReport(t *testing.T) {
+	raw := []byte(`{"results": []}`)
+	project := repository.Project{Path: "group/project", Repository: repository.Gitlab}
+	var body string
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("UploadIssueAttachment", project, "osv-scanner.json", raw).Return("[osv-scanner.json](/uploads/abc/osv-scanner.json)", nil).Once()
+	mockGitlabService.On("OpenVulnerabilityIssue", project, "title", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		body = args.String(2)
+	}).Return(&repository.Issue{WebURL: "https://my-issue.com"}, nil)
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	reports := []scanner.Report{{Project: project, IsVulnerable: true, Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-1"}}, RawOsvReport: raw}}
+
+	warn := PublishAsIssues(reports, IssueOpts{Title: "title", AttachRawReport: true}, mockRepoService)
+
+	assert.NoError(t, warn)
+	mockGitlabService.AssertExpectations(t)
+	assert.Contains(t, body, "Raw osv-scanner report of this scan: [osv-scanner.json](/uploads/abc/osv-scanner.json)")
+}
+
+func TestPublishAsIssuesDoesNotAttachRawReportUnlessEnabled(t *testing.T) {
+	mockGitlabService := &mockGitlabService{}
+	mockGitlabService.On("OpenVulnerabilityIssue", mock.Anything, "title", mock.Anything, mock.Anything).Return(&repository.Issue{WebURL: "https://my-issue.com"}, nil)
+	mockRepoService := &mockRepoService{}
+	mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+	reports := []scanner.Report{{Project: repository.Project{Repository: repository.Gitlab}, IsVulnerable: true, RawOsvReport: []byte(`{}`)}}
+
+	warn := PublishAsIssues(reports, IssueOpts{Title: "title"}, mockRepoService)
+
+	assert.NoError(t, warn)
+	mockGitlabService.AssertNotCalled(t, "UploadIssueAttachment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPublishAsIssuesOpensIssueWhenRawReportUploadFails(t *testing.T) {
+	for name, uploadErr := range map[string]error{
+		"failed":        errors.New("413 Request Entity Too Large"),
+		"not supported": fmt.Errorf("uploading: %w", repository.ErrNotSupported),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var body string
+			mockGitlabService := &mockGitlabService{}
+			mockGitlabService.On("UploadIssueAttachment", mock.Anything, mock.Anything, mock.Anything).Return("", uploadErr)
+			mockGitlabService.On("OpenVulnerabilityIssue", mock.Anything, "title", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				body = args.String(2)
+			}).Return(&repository.Issue{WebURL: "https://my-issue.com"}, nil).Once()
+			mockRepoService := &mockRepoService{}
+			mockRepoService.On("Provide", repository.Gitlab).Return(mockGitlabService)
+
+			reports := []scanner.Report{{Project: repository.Project{Repository: repository.Gitlab}, IsVulnerable: true, RawOsvReport: []byte(`{}`)}}
+
+			warn := PublishAsIssues(reports, IssueOpts{Title: "title", AttachRawReport: true}, mockRepoService)
+
+			assert.NoError(t, warn)
+			mockGitlabService.AssertExpectations(t)
+			assert.NotContains(t, body, "Raw osv-scanner report")
+		})
+	}
+}
+
 func TestGitlabIssueReportHeader(t *testing.T) {
 	origNow := now
 	now = func() time.Time {
@@ -232,17 +738,47 @@ func (c *mockGitlabService) GetProjectList(paths []string) ([]repository.Project
 	return args.Get(0).([]repository.Project), args.Error(1)
 }
 
-func (c *mockGitlabService) CloseVulnerabilityIssue(project repository.Project) error {
-	args := c.Called(project)
+func (c *mockGitlabService) CloseVulnerabilityIssue(project repository.Project, title string, comment string) error {
+	args := c.Called(project, title, comment)
 	return args.Error(0)
 }
 
-func (c *mockGitlabService) OpenVulnerabilityIssue(project repository.Project, report string) (*repository.Issue, error) {
-	args := c.Called(project, report)
+func (c *mockGitlabService) OpenVulnerabilityIssue(project repository.Project, title string, report string, assignees []string) (*repository.Issue, error) {
+	args := c.Called(project, title, report, assignees)
 	return args.Get(0).(*repository.Issue), args.Error(1)
 }
 
-func (c *mockGitlabService) Download(project repository.Project, dir string) error {
+func (c *mockGitlabService) Download(ctx context.Context, project repository.Project, dir string) (string, error) {
 	args := c.Called(project.RepoUrl, dir)
+	return args.String(0), args.Error(1)
+}
+
+func (c *mockGitlabService) GetLatestCommitSha(project repository.Project) (string, error) {
+	args := c.Called(project)
+	return args.String(0), args.Error(1)
+}
+
+func (c *mockGitlabService) GetFile(project repository.Project, path string) ([]byte, bool, error) {
+	args := c.Called(project, path)
+	return args.Get(0).([]byte), args.Bool(1), args.Error(2)
+}
+
+func (c *mockGitlabService) CreateBranch(project repository.Project, branch string) error {
+	args := c.Called(project, branch)
 	return args.Error(0)
 }
+
+func (c *mockGitlabService) CommitFile(project repository.Project, branch string, path string, content []byte, message string) error {
+	args := c.Called(project, branch, path, content, message)
+	return args.Error(0)
+}
+
+func (c *mockGitlabService) OpenMergeRequest(project repository.Project, branch string, title string, description string) (string, error) {
+	args := c.Called(project, branch, title, description)
+	return args.String(0), args.Error(1)
+}
+
+func (c *mockGitlabService) UploadIssueAttachment(project repository.Project, filename string, content []byte) (string, error) {
+	args := c.Called(project, filename, content)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,117 @@
+package publish
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"sheriff/internal/scanner"
+)
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Failures  []junitMessage `xml:"failure"`
+	Skipped   []junitMessage `xml:"skipped"`
+	Error     *junitMessage  `xml:"error"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// PublishAsJUnit writes the reports as a JUnit XML file to the given path,
+// so that CI systems show the vulnerabilities as test failures.
+func PublishAsJUnit(reports []scanner.Report, path string) error {
+	out, err := formatReportsAsJUnit(reports)
+	if err != nil {
+		return errors.Join(errors.New("failed to format junit report"), err)
+	}
+
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return errors.Join(fmt.Errorf("failed to write junit report to %v", path), err)
+	}
+
+	return nil
+}
+
+// formatReportsAsJUnit formats the reports as a JUnit XML document.
+// Each project is a test case, with a failure per vulnerability.
+// Acknowledged vulnerabilities are skipped instead, and projects which could not be scanned are errors.
+func formatReportsAsJUnit(reports []scanner.Report) ([]byte, error) {
+	suite := junitTestSuite{Name: "sheriff", TestCases: []junitTestCase{}}
+	for _, r := range reports {
+		tc := junitTestCase{Name: r.Project.Path, ClassName: string(r.Project.Repository)}
+		if r.Error {
+			tc.Error = &junitMessage{Message: "failed to scan project"}
+			suite.Errors++
+		}
+
+		for _, v := range r.Vulnerabilities {
+			msg := junitMessage{
+				Message: fmt.Sprintf("%v (%v)", v.Id, v.SeverityScoreKind),
+				Type:    string(v.SeverityScoreKind),
+				Text:    formatJUnitVulnerability(v),
+			}
+			if v.SeverityScoreKind == scanner.Acknowledged {
+				tc.Skipped = append(tc.Skipped, msg)
+			} else {
+				tc.Failures = append(tc.Failures, msg)
+			}
+		}
+
+		if len(tc.Failures) > 0 {
+			suite.Failures++
+		} else if len(tc.Skipped) > 0 && tc.Error == nil {
+			suite.Skipped++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	out, err := xml.MarshalIndent(junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Skipped:  suite.Skipped,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), out...), nil
+}
+
+// formatJUnitVulnerability formats the vulnerable package of a vulnerability as the text of its failure
+func formatJUnitVulnerability(v scanner.Vulnerability) string {
+	text := fmt.Sprintf("%v %v (%v) in %v\nhttps://osv.dev/%v", v.PackageName, v.PackageVersion, v.PackageEcosystem, v.Source, v.Id)
+	if v.AckReason != "" {
+		text += fmt.Sprintf("\nAcknowledged: %v", v.AckReason)
+	}
+
+	return text
+}
@@ -0,0 +1,73 @@
+package publish
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"sheriff/internal/repository"
+	"sheriff/internal/scanner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatReportsAsJUnit(t *testing.T) {
+	reports := []scanner.Report{
+		{
+			Project:      repository.Project{Path: "group/project1", Repository: repository.Gitlab},
+			IsVulnerable: true,
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "CVE-2021-1234", PackageName: "pkg", PackageVersion: "1.0.0", SeverityScoreKind: scanner.Critical},
+				{Id: "CVE-2021-1235", PackageName: "other", PackageVersion: "2.0.0", SeverityScoreKind: scanner.Acknowledged, AckReason: "not used"},
+			},
+		},
+		{
+			Project:         repository.Project{Path: "group/project2", Repository: repository.Gitlab},
+			Vulnerabilities: []scanner.Vulnerability{},
+		},
+		{
+			Project: repository.Project{Path: "group/project3", Repository: repository.Github},
+			Error:   true,
+		},
+	}
+
+	out, err := formatReportsAsJUnit(reports)
+	require.NoError(t, err)
+
+	var got junitTestSuites
+	require.NoError(t, xml.Unmarshal(out, &got))
+
+	assert.Equal(t, 3, got.Tests)
+	assert.Equal(t, 1, got.Failures)
+	assert.Equal(t, 1, got.Errors)
+	require.Len(t, got.Suites, 1)
+	require.Len(t, got.Suites[0].TestCases, 3)
+
+	vulnerable := got.Suites[0].TestCases[0]
+	assert.Equal(t, "group/project1", vulnerable.Name)
+	require.Len(t, vulnerable.Failures, 1)
+	assert.Equal(t, "CVE-2021-1234 (CRITICAL)", vulnerable.Failures[0].Message)
+	require.Len(t, vulnerable.Skipped, 1)
+	assert.Equal(t, "CVE-2021-1235 (ACKNOWLEDGED)", vulnerable.Skipped[0].Message)
+	assert.Contains(t, vulnerable.Skipped[0].Text, "not used")
+
+	clean := got.Suites[0].TestCases[1]
+	assert.Empty(t, clean.Failures)
+	assert.Empty(t, clean.Skipped)
+	assert.Nil(t, clean.Error)
+
+	errored := got.Suites[0].TestCases[2]
+	assert.NotNil(t, errored.Error)
+}
+
+func TestPublishAsJUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xml")
+
+	err := PublishAsJUnit([]scanner.Report{{Project: repository.Project{Path: "group/project"}}}, path)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `<testcase name="group/project"`)
+}
@@ -0,0 +1,88 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sheriff/internal/scanner"
+	"strings"
+	"time"
+)
+
+// PublishAsMetrics writes metrics of the patrol to the given path, in the Prometheus text exposition format,
+// so that they can be scraped by the textfile collector of the node exporter.
+// The file is replaced atomically, so that the collector never reads a partially written file.
+func PublishAsMetrics(reports []scanner.Report, duration time.Duration, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to create metrics file next to %v", path), err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(formatReportsAsMetrics(reports, duration))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// The temporary file is only readable by its owner
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to write metrics to %v", path), err)
+	}
+
+	return nil
+}
+
+// formatReportsAsMetrics formats the metrics of the reports in the Prometheus text exposition format.
+// The vulnerabilities are counted by severity, with a line for every severity kind even if there is none of it.
+// Projects which could not be scanned count as scanned, but neither as vulnerable nor towards the vulnerabilities.
+// The scan duration of every project is labelled with its path, including the projects which could not be scanned.
+func formatReportsAsMetrics(reports []scanner.Report, duration time.Duration) []byte {
+	var vulnerable, errored int
+	bySeverity := make(map[scanner.SeverityScoreKind]int)
+	for _, r := range reports {
+		if r.Error {
+			errored++
+			continue
+		}
+		if r.IsVulnerable {
+			vulnerable++
+		}
+		for _, v := range r.Vulnerabilities {
+			bySeverity[v.SeverityScoreKind]++
+		}
+	}
+
+	var out strings.Builder
+	writeMetric(&out, "sheriff_projects_scanned", "Number of projects for which a scan was attempted", len(reports))
+	writeMetric(&out, "sheriff_projects_vulnerable", "Number of successfully scanned projects which are vulnerable", vulnerable)
+
+	out.WriteString("# HELP sheriff_vulnerabilities_total Number of vulnerabilities found in the scanned projects, by severity\n")
+	out.WriteString("# TYPE sheriff_vulnerabilities_total gauge\n")
+	for _, kind := range severityScoreOrder() {
+		fmt.Fprintf(&out, "sheriff_vulnerabilities_total{severity=%q} %v\n", strings.ToLower(string(kind)), bySeverity[kind])
+	}
+
+	writeMetric(&out, "sheriff_scan_errors_total", "Number of projects which could not be scanned", errored)
+	writeMetric(&out, "sheriff_scan_duration_seconds", "Duration of the scan of all the projects, in seconds", duration.Seconds())
+
+	out.WriteString("# HELP sheriff_project_scan_duration_seconds Duration of the download and scan of each project, in seconds\n")
+	out.WriteString("# TYPE sheriff_project_scan_duration_seconds gauge\n")
+	for _, r := range reports {
+		fmt.Fprintf(&out, "sheriff_project_scan_duration_seconds{project=%q} %v\n", r.Project.Path, r.Duration.Seconds())
+	}
+
+	return []byte(out.String())
+}
+
+// writeMetric writes a gauge without labels, with its help and type lines
+func writeMetric(out *strings.Builder, name string, help string, value any) {
+	fmt.Fprintf(out, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(out, "# TYPE %v gauge\n", name)
+	fmt.Fprintf(out, "%v %v\n", name, value)
+}
@@ -0,0 +1,81 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"sheriff/internal/repository"
+	"sheriff/internal/scanner"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatReportsAsMetrics(t *testing.T) {
+	reports := []scanner.Report{
+		{
+			Project:      repository.Project{Path: "group/vulnerable"},
+			IsVulnerable: true,
+			Duration:     3 * time.Second,
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "CVE-2021-1234", SeverityScoreKind: scanner.Critical},
+				{Id: "CVE-2021-1235", SeverityScoreKind: scanner.Critical},
+				{Id: "CVE-2021-1236", SeverityScoreKind: scanner.Acknowledged},
+			},
+		},
+		{Project: repository.Project{Path: "group/low"}, Duration: 250 * time.Millisecond, Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1237", SeverityScoreKind: scanner.Low}}},
+		{Project: repository.Project{Path: "group/failed"}, Error: true},
+	}
+
+	got := string(formatReportsAsMetrics(reports, 12500*time.Millisecond))
+
+	want := `# HELP sheriff_projects_scanned Number of projects for which a scan was attempted
+# TYPE sheriff_projects_scanned gauge
+sheriff_projects_scanned 3
+# HELP sheriff_projects_vulnerable Number of successfully scanned projects which are vulnerable
+# TYPE sheriff_projects_vulnerable gauge
+sheriff_projects_vulnerable 1
+# HELP sheriff_vulnerabilities_total Number of vulnerabilities found in the scanned projects, by severity
+# TYPE sheriff_vulnerabilities_total gauge
+sheriff_vulnerabilities_total{severity="critical"} 2
+sheriff_vulnerabilities_total{severity="high"} 0
+sheriff_vulnerabilities_total{severity="moderate"} 0
+sheriff_vulnerabilities_total{severity="low"} 1
+sheriff_vulnerabilities_total{severity="unknown"} 0
+sheriff_vulnerabilities_total{severity="acknowledged"} 1
+# HELP sheriff_scan_errors_total Number of projects which could not be scanned
+# TYPE sheriff_scan_errors_total gauge
+sheriff_scan_errors_total 1
+# HELP sheriff_scan_duration_seconds Duration of the scan of all the projects, in seconds
+# TYPE sheriff_scan_duration_seconds gauge
+sheriff_scan_duration_seconds 12.5
+# HELP sheriff_project_scan_duration_seconds Duration of the download and scan of each project, in seconds
+# TYPE sheriff_project_scan_duration_seconds gauge
+sheriff_project_scan_duration_seconds{project="group/vulnerable"} 3
+sheriff_project_scan_duration_seconds{project="group/low"} 0.25
+sheriff_project_scan_duration_seconds{project="group/failed"} 0
+`
+	assert.Equal(t, want, got)
+}
+
+func TestPublishAsMetricsReplacesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sheriff.prom")
+	require.NoError(t, os.WriteFile(path, []byte("stale"), 0644))
+
+	err := PublishAsMetrics([]scanner.Report{{}}, time.Second, path)
+
+	require.NoError(t, err)
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(got), "sheriff_projects_scanned 1\n")
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the temporary file should have been renamed")
+}
+
+func TestPublishAsMetricsFailsWithMissingDir(t *testing.T) {
+	err := PublishAsMetrics(nil, time.Second, filepath.Join(t.TempDir(), "missing", "sheriff.prom"))
+
+	assert.ErrorContains(t, err, "failed to create metrics file")
+}
@@ -0,0 +1,124 @@
+package publish
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sheriff/internal/scanner"
+	"time"
+
+	"github.com/elliotchance/pie/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// pagerDutyEventsURL is the URL of the PagerDuty Events API v2
+var pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyTimeout is the timeout of a single request to the PagerDuty Events API
+const pagerDutyTimeout = 30 * time.Second
+
+// pagerDutyEvent is a trigger event of the PagerDuty Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"` // Events with the same key are grouped into a single incident
+	Payload     pagerDutyPayload `json:"payload"`
+	Links       []pagerDutyLink  `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string              `json:"summary"`
+	Source        string              `json:"source"`
+	Severity      string              `json:"severity"`
+	CustomDetails pagerDutyCustomInfo `json:"custom_details"`
+}
+
+type pagerDutyCustomInfo struct {
+	Project         string   `json:"project"`
+	Vulnerabilities []string `json:"critical_vulnerabilities"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// PublishAsPagerDutyEvents triggers a PagerDuty event for each project with critical vulnerabilities,
+// using the project path as deduplication key so that each project pages at most once until it is resolved.
+// Projects without critical vulnerabilities do not page.
+func PublishAsPagerDutyEvents(reports []scanner.Report, routingKey string) (warn error) {
+	client := &http.Client{Timeout: pagerDutyTimeout}
+	for _, e := range formatPagerDutyEvents(reports, routingKey) {
+		if err := postPagerDutyEvent(client, e); err != nil {
+			log.Error().Err(err).Str("project", e.DedupKey).Msg("Failed to trigger PagerDuty event")
+			warn = errors.Join(fmt.Errorf("failed to trigger PagerDuty event for project %v", e.DedupKey), err, warn)
+			continue
+		}
+
+		log.Info().Str("project", e.DedupKey).Msg("Triggered PagerDuty event")
+	}
+
+	return
+}
+
+// formatPagerDutyEvents returns the trigger events of the reports with at least one critical vulnerability
+func formatPagerDutyEvents(reports []scanner.Report, routingKey string) (events []pagerDutyEvent) {
+	for _, r := range reports {
+		if r.Error {
+			continue
+		}
+
+		critical := pie.Filter(r.Vulnerabilities, func(v scanner.Vulnerability) bool { return v.SeverityScoreKind == scanner.Critical })
+		if len(critical) == 0 {
+			continue
+		}
+
+		var links []pagerDutyLink
+		if r.Project.WebURL != "" {
+			links = append(links, pagerDutyLink{Href: r.Project.WebURL, Text: "Project"})
+		}
+		if r.IssueUrl != "" {
+			links = append(links, pagerDutyLink{Href: r.IssueUrl, Text: "Full report"})
+		}
+
+		events = append(events, pagerDutyEvent{
+			RoutingKey:  routingKey,
+			EventAction: "trigger",
+			DedupKey:    r.Project.Path,
+			Payload: pagerDutyPayload{
+				Summary:  fmt.Sprintf("%v critical vulnerabilities found in %v", len(critical), r.Project.Path),
+				Source:   "sheriff",
+				Severity: "critical",
+				CustomDetails: pagerDutyCustomInfo{
+					Project:         r.Project.Path,
+					Vulnerabilities: pie.Map(critical, func(v scanner.Vulnerability) string { return v.Id }),
+				},
+			},
+			Links: links,
+		})
+	}
+
+	return
+}
+
+// postPagerDutyEvent sends the event to the PagerDuty Events API
+func postPagerDutyEvent(client *http.Client, event pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Join(errors.New("failed to marshal PagerDuty event"), err)
+	}
+
+	resp, err := client.Post(pagerDutyEventsURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Join(errors.New("failed to send PagerDuty event"), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to send PagerDuty event, status: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package publish
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sheriff/internal/repository"
+	"sheriff/internal/scanner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatPagerDutyEventsOnlyCritical(t *testing.T) {
+	reports := []scanner.Report{
+		{
+			Project:  repository.Project{Path: "group/critical", WebURL: "https://gitlab.com/group/critical"},
+			IssueUrl: "https://gitlab.com/group/critical/-/issues/1",
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "CVE-2021-1234", SeverityScoreKind: scanner.Critical},
+				{Id: "CVE-2021-1235", SeverityScoreKind: scanner.High},
+			},
+		},
+		{
+			Project:         repository.Project{Path: "group/high"},
+			Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1236", SeverityScoreKind: scanner.High}},
+		},
+		{
+			Project:         repository.Project{Path: "group/acknowledged"},
+			Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1237", SeverityScoreKind: scanner.Acknowledged}},
+		},
+		{
+			Project: repository.Project{Path: "group/errored"},
+			Error:   true,
+		},
+	}
+
+	got := formatPagerDutyEvents(reports, "routing-key")
+
+	require.Len(t, got, 1)
+	assert.Equal(t, "routing-key", got[0].RoutingKey)
+	assert.Equal(t, "trigger", got[0].EventAction)
+	assert.Equal(t, "group/critical", got[0].DedupKey)
+	assert.Equal(t, "critical", got[0].Payload.Severity)
+	assert.Equal(t, "1 critical vulnerabilities found in group/critical", got[0].Payload.Summary)
+	assert.Equal(t, []string{"CVE-2021-1234"}, got[0].Payload.CustomDetails.Vulnerabilities)
+	assert.Len(t, got[0].Links, 2)
+}
+
+func TestPublishAsPagerDutyEvents(t *testing.T) {
+	var got []pagerDutyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e pagerDutyEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		got = append(got, e)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	defaultURL := pagerDutyEventsURL
+	pagerDutyEventsURL = server.URL
+	defer func() { pagerDutyEventsURL = defaultURL }()
+
+	err := PublishAsPagerDutyEvents([]scanner.Report{
+		{Project: repository.Project{Path: "group/critical"}, Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234", SeverityScoreKind: scanner.Critical}}},
+		{Project: repository.Project{Path: "group/clean"}, Vulnerabilities: []scanner.Vulnerability{}},
+	}, "routing-key")
+
+	assert.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "group/critical", got[0].DedupKey)
+}
+
+func TestPublishAsPagerDutyEventsFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid routing key", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	defaultURL := pagerDutyEventsURL
+	pagerDutyEventsURL = server.URL
+	defer func() { pagerDutyEventsURL = defaultURL }()
+
+	err := PublishAsPagerDutyEvents([]scanner.Report{
+		{Project: repository.Project{Path: "group/critical"}, Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234", SeverityScoreKind: scanner.Critical}}},
+	}, "routing-key")
+
+	assert.Error(t, err)
+}
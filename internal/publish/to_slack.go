@@ -4,8 +4,11 @@ import (
 	"cmp"
 	"errors"
 	"fmt"
+	"sheriff/internal/config"
+	"sheriff/internal/repository"
 	"sheriff/internal/scanner"
 	"sheriff/internal/slack"
+	"sheriff/internal/state"
 	"slices"
 	"strings"
 	"sync"
@@ -16,14 +19,37 @@ import (
 	goslack "github.com/slack-go/slack"
 )
 
+// shortShaLength is the number of characters of a commit sha shown in messages
+const shortShaLength = 8
+
 // PublishAsGeneralSlackMessage publishes a report of the vulnerabilities scanned to a list of slack channels
 func PublishAsGeneralSlackMessage(channelNames []string, reports []scanner.Report, paths []string, s slack.IService) error {
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(channelNames))
 	vulnerableReportsByMaxSeverityKind := groupVulnReportsByMaxSeverityKind(reports)
 
-	summary := formatSummary(vulnerableReportsByMaxSeverityKind, len(reports), paths)
+	summary := formatSummary(vulnerableReportsByMaxSeverityKind, len(reports), summarizePatrol(reports), paths)
 	threadMsgs := formatReportMessage(vulnerableReportsByMaxSeverityKind)
+	threadMsgs = append(threadMsgs, formatFailedReportsMessage(reports)...)
+	threadMsgs = append(threadMsgs, formatNoManifestsMessage(reports)...)
+	threadMsgs = append(threadMsgs, formatConfigWarningsMessage(reports)...)
+
+	return publishToSlackChannels(channelNames, summary, threadMsgs, s)
+}
+
+// PublishAsDeltaSlackMessage publishes only the vulnerabilities which changed since the previous run
+// to a list of slack channels: the newly introduced and the resolved vulnerabilities of each project.
+func PublishAsDeltaSlackMessage(channelNames []string, reports []scanner.Report, previous state.State, paths []string, s slack.IService) error {
+	deltas := computeReportDeltas(reports, previous)
+
+	summary := formatDeltaSummary(deltas, len(reports), paths)
+	threadMsgs := formatDeltaMessage(deltas)
+
+	return publishToSlackChannels(channelNames, summary, threadMsgs, s)
+}
+
+// publishToSlackChannels posts the summary to each of the given channels, with the thread messages as replies to it
+func publishToSlackChannels(channelNames []string, summary []goslack.MsgOption, threadMsgs []goslack.MsgOption, s slack.IService) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(channelNames))
 	for _, slackChannel := range channelNames {
 		log.Info().Str("slackChannel", slackChannel).Msg("Posting report to slack channel")
 		wg.Add(1)
@@ -46,7 +72,17 @@ func PublishAsGeneralSlackMessage(channelNames []string, reports []scanner.Repor
 	return outErr
 }
 
-func PublishAsSpecificChannelSlackMessage(reports []scanner.Report, s slack.IService) (warn error) {
+// SlackOpts are the options of the messages posted to the slack channels of the projects
+type SlackOpts struct {
+	Thread  bool // Post the list of vulnerabilities in the thread of the summary, rather than as separate messages after it
+	Actions bool // Add buttons to open the issue and acknowledge the vulnerabilities, which requires interactivity in the workspace
+	// Only list the vulnerabilities which are new since the previous issue of the project, see scanner.Report.NewVulnerabilities.
+	// Projects whose issue was not updated still get the full list.
+	NewOnly bool
+}
+
+// PublishAsSpecificChannelSlackMessage publishes the report of each project to its own slack channel, if configured
+func PublishAsSpecificChannelSlackMessage(reports []scanner.Report, opts SlackOpts, s slack.IService) (warn error) {
 	configuredReports := pie.Filter(reports, func(r scanner.Report) bool { return r.ProjectConfig.Report.To.SlackChannel != "" })
 
 	var wg sync.WaitGroup
@@ -55,9 +91,14 @@ func PublishAsSpecificChannelSlackMessage(reports []scanner.Report, s slack.ISer
 
 		go func() {
 			defer wg.Done()
-			message := formatSpecificChannelSlackMessage(report)
+			message := formatSpecificChannelSlackMessage(report, opts)
 
-			_, err := s.PostMessage(report.ProjectConfig.Report.To.SlackChannel, message...)
+			var err error
+			if opts.Thread {
+				err = publishAsGeneralSlackMessageSingleChannel(report.ProjectConfig.Report.To.SlackChannel, message[:1], message[1:], s)
+			} else {
+				err = publishAsFlatSlackMessages(report.ProjectConfig.Report.To.SlackChannel, message, s)
+			}
 			if err != nil {
 				log.Error().Err(err).Str("channel", report.ProjectConfig.Report.To.SlackChannel).Msg("Failed to post slack report")
 				err = fmt.Errorf("failed to post slack report to channel %v", report.ProjectConfig.Report.To.SlackChannel)
@@ -71,7 +112,13 @@ func PublishAsSpecificChannelSlackMessage(reports []scanner.Report, s slack.ISer
 	return
 }
 
-func formatSpecificChannelSlackMessage(report scanner.Report) []goslack.MsgOption {
+// formatSpecificChannelSlackMessage formats the report of a project for its own channel.
+// The first message is a summary with the counts of vulnerabilities, followed by as many messages as needed
+// to list all the vulnerabilities within the length limit of slack.
+// The members and user groups of the project's slack-mention are mentioned in the summary.
+// If opts.Actions is set, the summary ends with buttons to open the issue and acknowledge the vulnerabilities.
+// If opts.NewOnly is set, only the vulnerabilities which are new since the previous issue are listed, and counted apart.
+func formatSpecificChannelSlackMessage(report scanner.Report, opts SlackOpts) []goslack.MsgOption {
 	// Count of vulnerabilities by severity
 	nCritical := len(pie.Filter(report.Vulnerabilities, func(v scanner.Vulnerability) bool { return v.SeverityScoreKind == scanner.Critical }))
 	nHigh := len(pie.Filter(report.Vulnerabilities, func(v scanner.Vulnerability) bool { return v.SeverityScoreKind == scanner.High }))
@@ -83,6 +130,9 @@ func formatSpecificChannelSlackMessage(report scanner.Report) []goslack.MsgOptio
 	// Texts
 	title := fmt.Sprintf("Sheriff Report %v", time.Now().Format("2006-01-02"))
 	subtitle := fmt.Sprintf("Project: <%s|*%s*>", report.Project.WebURL, report.Project.Path)
+	if revision := formatRevision(report); revision != "" {
+		subtitle += fmt.Sprintf(" at %s", revision)
+	}
 	var subtitleFullReport string
 	if report.IssueUrl != "" {
 		subtitleFullReport = fmt.Sprintf("Full report: <%s|*Full report*>", report.IssueUrl)
@@ -110,6 +160,12 @@ func formatSpecificChannelSlackMessage(report scanner.Report) []goslack.MsgOptio
 		goslack.NewTextBlockObject("mrkdwn", unknownCount, false, false),
 		goslack.NewTextBlockObject("mrkdwn", ackCount, false, false),
 	}
+	// The new vulnerabilities are only known if the issue was updated
+	listed := report.Vulnerabilities
+	if opts.NewOnly && report.IssueUrl != "" {
+		listed = report.NewVulnerabilities
+		countsBlocks = append(countsBlocks, goslack.NewTextBlockObject("mrkdwn", fmt.Sprintf("New since last report: *%v*", len(listed)), false, false))
+	}
 	countsBlock := goslack.NewSectionBlock(nil, countsBlocks, nil)
 
 	blocks := []goslack.Block{
@@ -119,8 +175,108 @@ func formatSpecificChannelSlackMessage(report scanner.Report) []goslack.MsgOptio
 		countsTitleBlock,
 		countsBlock,
 	}
+	if len(report.ProjectConfig.Report.To.SlackMention) > 0 {
+		blocks = append(blocks, formatMentionsBlock(report.ProjectConfig.Report.To.SlackMention))
+	}
+	if opts.Actions {
+		blocks = append(blocks, formatActionsBlock(report))
+	}
 
-	return []goslack.MsgOption{goslack.MsgOptionBlocks(blocks...)}
+	return append([]goslack.MsgOption{goslack.MsgOptionBlocks(blocks...)}, formatChunkedMessages(formatVulnerabilityList(listed))...)
+}
+
+// formatMentionsBlock creates the block mentioning the members and user groups responsible for a project,
+// so that slack notifies them of its report. The mentions are validated in the configuration of the project.
+func formatMentionsBlock(mentions []string) *goslack.SectionBlock {
+	formatted := pie.Map(mentions, func(m string) string {
+		switch {
+		case strings.HasPrefix(m, "@"):
+			return fmt.Sprintf("<!%s>", strings.TrimPrefix(m, "@"))
+		case strings.HasPrefix(m, "S"):
+			return fmt.Sprintf("<!subteam^%s>", m)
+		default:
+			return fmt.Sprintf("<@%s>", m)
+		}
+	})
+
+	return goslack.NewSectionBlock(goslack.NewTextBlockObject("mrkdwn", "cc "+strings.Join(formatted, " "), false, false), nil, nil, goslack.SectionBlockOptionBlockID("mentions"))
+}
+
+// formatActionsBlock creates the buttons of the summary of a project: one linking to its issue if any,
+// and one to acknowledge its vulnerabilities, linking to the sheriff configuration of the project.
+// The acknowledge button carries the path of the project, so that its callback can update the configuration later on.
+func formatActionsBlock(report scanner.Report) *goslack.ActionBlock {
+	var elements []goslack.BlockElement
+	if report.IssueUrl != "" {
+		openIssue := goslack.NewButtonBlockElement("open_issue", report.Project.Path, goslack.NewTextBlockObject("plain_text", "Open issue", false, false))
+		openIssue.URL = report.IssueUrl
+		elements = append(elements, openIssue)
+	}
+
+	acknowledge := goslack.NewButtonBlockElement("acknowledge", report.Project.Path, goslack.NewTextBlockObject("plain_text", "Acknowledge", false, false))
+	acknowledge.Style = goslack.StylePrimary
+	acknowledge.URL = formatProjectConfigUrl(report)
+	elements = append(elements, acknowledge)
+
+	return goslack.NewActionBlock("actions", elements...)
+}
+
+// formatProjectConfigUrl returns the url to edit the sheriff configuration of the project on its default branch,
+// where vulnerabilities are acknowledged. It is empty if the default branch is unknown.
+func formatProjectConfigUrl(report scanner.Report) string {
+	branch := report.Project.DefaultBranch
+	if branch == "" {
+		return ""
+	}
+
+	// Gitlab nests the repository pages under /-/, Github does not
+	prefix := ""
+	if report.Project.Repository == repository.Gitlab {
+		prefix = "/-"
+	}
+
+	return fmt.Sprintf("%s%s/edit/%s/%s", report.Project.WebURL, prefix, branch, config.ProjectConfigFileName)
+}
+
+// formatVulnerabilityList formats the vulnerabilities as a bullet list, one vulnerability per line,
+// from the most to the least severe
+func formatVulnerabilityList(vs []scanner.Vulnerability) string {
+	order := severityScoreOrder()
+	vs = slices.Clone(vs)
+	slices.SortStableFunc(vs, func(a, b scanner.Vulnerability) int {
+		return cmp.Compare(slices.Index(order, a.SeverityScoreKind), slices.Index(order, b.SeverityScoreKind))
+	})
+
+	var text strings.Builder
+	for _, v := range vs {
+		text.WriteString(fmt.Sprintf("• `%v` %v@%v (%v)", v.Id, v.PackageName, v.PackageVersion, v.SeverityScoreKind))
+		if v.FixedVersion != "" {
+			text.WriteString(fmt.Sprintf(", fixed in `%v`", v.FixedVersion))
+		}
+		text.WriteString("\n")
+	}
+
+	return text.String()
+}
+
+// formatRevision formats the scanned revision of the project as a slack link to its commit,
+// falling back to a link to its default branch. It is empty if neither is known.
+func formatRevision(report scanner.Report) string {
+	// Gitlab nests the repository pages under /-/, Github does not
+	prefix := ""
+	if report.Project.Repository == repository.Gitlab {
+		prefix = "/-"
+	}
+
+	if sha := report.CommitSha; sha != "" {
+		return fmt.Sprintf("<%s%s/commit/%s|`%s`>", report.Project.WebURL, prefix, sha, sha[:min(len(sha), shortShaLength)])
+	}
+
+	if branch := report.Project.DefaultBranch; branch != "" {
+		return fmt.Sprintf("<%s%s/tree/%s|`%s`>", report.Project.WebURL, prefix, branch, branch)
+	}
+
+	return ""
 }
 
 func formatSubtitleList(entity string, list []string) *goslack.ContextBlock {
@@ -137,7 +293,7 @@ func formatSubtitleList(entity string, list []string) *goslack.ContextBlock {
 }
 
 // formatSummary creates a message block with a summary of the reports
-func formatSummary(reportsBySeverityKind map[scanner.SeverityScoreKind][]scanner.Report, totalReports int, paths []string) []goslack.MsgOption {
+func formatSummary(reportsBySeverityKind map[scanner.SeverityScoreKind][]scanner.Report, totalReports int, patrol patrolSummary, paths []string) []goslack.MsgOption {
 	title := goslack.NewHeaderBlock(
 		goslack.NewTextBlockObject(
 			"plain_text",
@@ -148,7 +304,7 @@ func formatSummary(reportsBySeverityKind map[scanner.SeverityScoreKind][]scanner
 	subtitleGroups := formatSubtitleList("targets", paths)
 	subtitleCount := goslack.NewContextBlock("subtitleCount", goslack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Total projects scanned: %v", totalReports), false, false))
 
-	counts := pie.Map(severityScoreOrder, func(kind scanner.SeverityScoreKind) *goslack.TextBlockObject {
+	counts := pie.Map(severityScoreOrder(), func(kind scanner.SeverityScoreKind) *goslack.TextBlockObject {
 		if group, ok := reportsBySeverityKind[kind]; ok {
 			return goslack.NewTextBlockObject("mrkdwn", fmt.Sprintf("%v: *%v*", kind, len(group)), false, false)
 		}
@@ -161,6 +317,17 @@ func formatSummary(reportsBySeverityKind map[scanner.SeverityScoreKind][]scanner
 		counts,
 		nil,
 	)
+	uniqueCount := goslack.NewContextBlock("uniqueCount", goslack.NewTextBlockObject(
+		"mrkdwn",
+		fmt.Sprintf("Unique vulnerabilities: *%v*, affecting *%v* distinct packages", patrol.UniqueVulnerabilities, patrol.UniquePackages),
+		false, false,
+	))
+	// Failed scans are counted apart from the severities, as nothing is known of their vulnerabilities
+	failedCount := goslack.NewContextBlock("failedCount", goslack.NewTextBlockObject(
+		"mrkdwn",
+		fmt.Sprintf("Unsuccessfully scanned projects: *%v*", len(reportsBySeverityKind[failedScansGroup])),
+		false, false,
+	))
 
 	blocks := []goslack.Block{
 		title,
@@ -168,6 +335,8 @@ func formatSummary(reportsBySeverityKind map[scanner.SeverityScoreKind][]scanner
 		subtitleCount,
 		countsTitle,
 		countsBlock,
+		uniqueCount,
+		failedCount,
 	}
 
 	options := []goslack.MsgOption{goslack.MsgOptionBlocks(blocks...)}
@@ -177,15 +346,21 @@ func formatSummary(reportsBySeverityKind map[scanner.SeverityScoreKind][]scanner
 // formatReportMessage formats the reports as a slack message, splitting the message into chunks if necessary
 func formatReportMessage(reportsBySeverityKind map[scanner.SeverityScoreKind][]scanner.Report) (msgOptions []goslack.MsgOption) {
 	text := strings.Builder{}
-	for _, kind := range severityScoreOrder {
+	for _, kind := range severityScoreOrder() {
 		if group, ok := reportsBySeverityKind[kind]; ok {
 			if len(group) == 0 {
 				continue
 			}
 
 			text.WriteString(fmt.Sprintf("Projects with vulnerabilities of *%v* severity\n", kind))
+			group = slices.Clone(group)
+			slices.SortFunc(group, compareReportsByUrgency)
 			for _, r := range group {
-				projectName := fmt.Sprintf("<%s|*%s*>\n", r.Project.WebURL, r.Project.Name)
+				projectName := fmt.Sprintf("<%s|*%s*>", r.Project.WebURL, r.Project.Name)
+				if revision := formatRevision(r); revision != "" {
+					projectName += fmt.Sprintf(" at %s", revision)
+				}
+				projectName += "\n"
 				var reportUrl string
 				if r.IssueUrl != "" {
 					reportUrl = fmt.Sprintf("\t<%s|Full report>\t\t", r.IssueUrl)
@@ -193,6 +368,9 @@ func formatReportMessage(reportsBySeverityKind map[scanner.SeverityScoreKind][]s
 					reportUrl = "\t_full report unavailable_\t\t"
 				}
 				vulnerabilityCount := fmt.Sprintf("\tVulnerability count: *%v*", len(r.Vulnerabilities))
+				if epss := getMaxEpss(r); epss > 0 {
+					vulnerabilityCount += fmt.Sprintf("\tHighest EPSS: *%v*", formatEpss(epss))
+				}
 
 				text.WriteString(projectName)
 				text.WriteString(reportUrl)
@@ -203,15 +381,235 @@ func formatReportMessage(reportsBySeverityKind map[scanner.SeverityScoreKind][]s
 		}
 	}
 
-	textString := text.String()
-	if len(textString) == 0 {
+	return formatChunkedMessages(text.String())
+}
+
+// compareReportsByUrgency orders the reports of a severity group so that the most urgent projects come first:
+// those most likely to be exploited if EPSS scores are known, then those with the most vulnerabilities.
+// Ties are broken by project name, so that the messages of two runs with the same findings are identical.
+func compareReportsByUrgency(a, b scanner.Report) int {
+	return cmp.Or(
+		cmp.Compare(getMaxEpss(b), getMaxEpss(a)),
+		cmp.Compare(len(b.Vulnerabilities), len(a.Vulnerabilities)),
+		cmp.Compare(a.Project.Name, b.Project.Name),
+	)
+}
+
+// getMaxEpss returns the highest EPSS score of the vulnerabilities of the report,
+// which is zero if EPSS is disabled and scanner.EpssUnknown if no score is known
+func getMaxEpss(r scanner.Report) float64 {
+	if len(r.Vulnerabilities) == 0 {
+		return scanner.EpssUnknown
+	}
+
+	return pie.Max(pie.Map(r.Vulnerabilities, func(v scanner.Vulnerability) float64 { return v.Epss }))
+}
+
+// reportDelta contains the vulnerabilities of a project which changed since the previous run
+type reportDelta struct {
+	Report     scanner.Report
+	Introduced []state.Vulnerability
+	Resolved   []state.Vulnerability
+}
+
+// computeReportDeltas compares the current reports against the previous state.
+// Vulnerabilities are matched by their id and package name. Projects without changes,
+// and projects which failed to be scanned, are left out.
+func computeReportDeltas(reports []scanner.Report, previous state.State) (deltas []reportDelta) {
+	current := state.FromReports(reports, previous)
+	key := func(v state.Vulnerability) string { return v.Id + "|" + v.PackageName }
+
+	for _, r := range reports {
+		if r.Error {
+			continue
+		}
+
+		currentVulns := current.Projects[r.Project.Path].Vulnerabilities
+		previousVulns := previous.Projects[r.Project.Path].Vulnerabilities
+
+		currentKeys := make(map[string]bool, len(currentVulns))
+		for _, v := range currentVulns {
+			currentKeys[key(v)] = true
+		}
+		previousKeys := make(map[string]bool, len(previousVulns))
+		for _, v := range previousVulns {
+			previousKeys[key(v)] = true
+		}
+
+		delta := reportDelta{
+			Report:     r,
+			Introduced: pie.Filter(currentVulns, func(v state.Vulnerability) bool { return !previousKeys[key(v)] }),
+			Resolved:   pie.Filter(previousVulns, func(v state.Vulnerability) bool { return !currentKeys[key(v)] }),
+		}
+
+		if len(delta.Introduced) > 0 || len(delta.Resolved) > 0 {
+			deltas = append(deltas, delta)
+		}
+	}
+
+	return
+}
+
+// formatDeltaSummary creates a message block with a summary of the changes since the previous run
+func formatDeltaSummary(deltas []reportDelta, totalReports int, paths []string) []goslack.MsgOption {
+	var nIntroduced, nResolved int
+	for _, d := range deltas {
+		nIntroduced += len(d.Introduced)
+		nResolved += len(d.Resolved)
+	}
+
+	title := goslack.NewHeaderBlock(
+		goslack.NewTextBlockObject(
+			"plain_text",
+			fmt.Sprintf("Security Scan Changes %v", time.Now().Format("2006-01-02")),
+			true, false,
+		),
+	)
+	subtitleGroups := formatSubtitleList("targets", paths)
+	subtitleCount := goslack.NewContextBlock("subtitleCount", goslack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Total projects scanned: %v", totalReports), false, false))
+
+	countsTitle := goslack.NewSectionBlock(goslack.NewTextBlockObject("mrkdwn", "*Changes since last run*", false, false), nil, nil)
+	countsBlock := goslack.NewSectionBlock(
+		nil,
+		[]*goslack.TextBlockObject{
+			goslack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Projects with changes: *%v*", len(deltas)), false, false),
+			goslack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Newly introduced: *%v*", nIntroduced), false, false),
+			goslack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Resolved: *%v*", nResolved), false, false),
+		},
+		nil,
+	)
+
+	blocks := []goslack.Block{
+		title,
+		subtitleGroups,
+		subtitleCount,
+		countsTitle,
+		countsBlock,
+	}
+
+	return []goslack.MsgOption{goslack.MsgOptionBlocks(blocks...)}
+}
+
+// formatDeltaMessage formats the changes of each project as a slack message, splitting the message into chunks if necessary
+func formatDeltaMessage(deltas []reportDelta) (msgOptions []goslack.MsgOption) {
+	text := strings.Builder{}
+	for _, d := range deltas {
+		text.WriteString(fmt.Sprintf("<%s|*%s*>\n", d.Report.Project.WebURL, d.Report.Project.Name))
+		if len(d.Introduced) > 0 {
+			text.WriteString(fmt.Sprintf("\tNewly introduced: *%v*\n", len(d.Introduced)))
+			text.WriteString(formatDeltaVulnerabilities(d.Introduced))
+		}
+		if len(d.Resolved) > 0 {
+			text.WriteString(fmt.Sprintf("\tResolved: *%v*\n", len(d.Resolved)))
+			text.WriteString(formatDeltaVulnerabilities(d.Resolved))
+		}
+		text.WriteString("\n")
+	}
+
+	return formatChunkedMessages(text.String())
+}
+
+// formatFailedReportsMessage formats the projects which could not be scanned, with the reason of the failure,
+// splitting the message into chunks if necessary. It returns no message if all the projects were scanned.
+func formatFailedReportsMessage(reports []scanner.Report) []goslack.MsgOption {
+	failed := pie.Filter(reports, func(r scanner.Report) bool { return r.Error })
+	if len(failed) == 0 {
+		return nil
+	}
+
+	text := strings.Builder{}
+	text.WriteString("*Unsuccessfully scanned projects*\n")
+	for _, r := range failed {
+		text.WriteString(fmt.Sprintf("<%s|*%s*>\n", r.Project.WebURL, r.Project.Name))
+		text.WriteString(fmt.Sprintf("\tReason: %v\n", formatErrorReason(r)))
+	}
+
+	return formatChunkedMessages(text.String())
+}
+
+// formatNoManifestsMessage formats the projects in which no lockfile or manifest was found to scan, which are
+// not known to be safe, splitting the message into chunks if necessary. It returns no message if there is no such project.
+func formatNoManifestsMessage(reports []scanner.Report) []goslack.MsgOption {
+	unscanned := pie.Filter(reports, func(r scanner.Report) bool { return r.NoManifests && !r.Error })
+	if len(unscanned) == 0 {
+		return nil
+	}
+
+	text := strings.Builder{}
+	text.WriteString("*Projects without lockfiles or manifests to scan*\n")
+	for _, r := range unscanned {
+		text.WriteString(fmt.Sprintf("<%s|*%s*>\n", r.Project.WebURL, r.Project.Name))
+	}
+
+	return formatChunkedMessages(text.String())
+}
+
+// formatConfigWarningsMessage formats the projects whose configuration has problems which did not prevent the scan,
+// splitting the message into chunks if necessary. It returns no message if there is no such project.
+func formatConfigWarningsMessage(reports []scanner.Report) []goslack.MsgOption {
+	warned := pie.Filter(reports, func(r scanner.Report) bool { return len(r.ConfigWarnings) > 0 })
+	if len(warned) == 0 {
+		return nil
+	}
+
+	text := strings.Builder{}
+	text.WriteString("*Projects with configuration warnings*\n")
+	for _, r := range warned {
+		text.WriteString(fmt.Sprintf("<%s|*%s*>\n", r.Project.WebURL, r.Project.Name))
+		for _, w := range r.ConfigWarnings {
+			text.WriteString(fmt.Sprintf("\t• `%v`\n", w))
+		}
+	}
+
+	return formatChunkedMessages(text.String())
+}
+
+// formatErrorReason describes why the scan of a project failed, in a human readable way
+func formatErrorReason(r scanner.Report) string {
+	var reason string
+	switch r.ErrorKind {
+	case scanner.CloneFailed:
+		reason = "failed to clone the project"
+	case scanner.ConfigInvalid:
+		reason = "invalid project configuration"
+	case scanner.Timeout:
+		reason = "the scan timed out"
+	case scanner.ScanFailed:
+		reason = "failed to scan the project"
+	default:
+		reason = "unknown error"
+	}
+	if r.ErrorMessage != "" {
+		reason += fmt.Sprintf(" (`%v`)", r.ErrorMessage)
+	}
+
+	return reason
+}
+
+// formatDeltaVulnerabilities formats a list of vulnerabilities as a bullet list, one vulnerability per line,
+// with the version fixing it if any
+func formatDeltaVulnerabilities(vs []state.Vulnerability) string {
+	var text strings.Builder
+	for _, v := range vs {
+		text.WriteString(fmt.Sprintf("\t\t• `%v` %v@%v (%v)", v.Id, v.PackageName, v.PackageVersion, v.SeverityScoreKind))
+		if v.FixedVersion != "" {
+			text.WriteString(fmt.Sprintf(", fixed in `%v`", v.FixedVersion))
+		}
+		text.WriteString("\n")
+	}
+
+	return text.String()
+}
+
+// formatChunkedMessages formats the text as slack messages, splitting it into chunks if necessary.
+// It returns no message if the text is empty.
+func formatChunkedMessages(text string) (msgOptions []goslack.MsgOption) {
+	if len(text) == 0 {
 		return
 	}
 
 	// Slack has a 3001 character limit for messages
-	splitText := splitMessage(textString, 3000)
-
-	for _, chunk := range splitText {
+	for _, chunk := range splitMessage(text, 3000) {
 		msgOptions = append(msgOptions, goslack.MsgOptionBlocks(goslack.NewSectionBlock(goslack.NewTextBlockObject("mrkdwn", chunk, false, false), nil, nil)))
 	}
 
@@ -238,6 +636,17 @@ func publishAsGeneralSlackMessageSingleChannel(channelName string, summary []gos
 	return
 }
 
+// publishAsFlatSlackMessages posts each of the messages to the channel, one after the other
+func publishAsFlatSlackMessages(channelName string, msgs []goslack.MsgOption, s slack.IService) error {
+	for _, msg := range msgs {
+		if _, err := s.PostMessage(channelName, msg); err != nil {
+			return errors.Join(errors.New("failed to post slack message"), err)
+		}
+	}
+
+	return nil
+}
+
 // splitMessage splits a string into chunks of at most maxLen characters.
 // Each chunk is determined by the closest newline character
 func splitMessage(s string, maxLen int) []string {
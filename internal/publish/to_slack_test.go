@@ -1,14 +1,21 @@
 package publish
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"sheriff/internal/config"
 	"sheriff/internal/repository"
 	"sheriff/internal/scanner"
+	"sheriff/internal/state"
+	"strings"
 	"testing"
 
+	"github.com/elliotchance/pie/v2"
 	"github.com/slack-go/slack"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPublishAsGeneralSlackMessage(t *testing.T) {
@@ -52,6 +59,33 @@ func TestPublishAsGeneralSlackMessageToMultipleChannel(t *testing.T) {
 	mockSlackService.AssertExpectations(t)
 }
 
+func TestPublishAsGeneralSlackMessageFailingChannelDoesNotBlockOthers(t *testing.T) {
+	mockSlackService := &mockSlackService{}
+	mockSlackService.On("PostMessage", "failing", mock.Anything).Return("", errors.New("channel_not_found"))
+	mockSlackService.On("PostMessage", "working", mock.Anything).Return("1234.5678", nil)
+	report := []scanner.Report{
+		{
+			IsVulnerable: true,
+			Vulnerabilities: []scanner.Vulnerability{
+				{
+					Id:                "CVE-2021-1234",
+					SeverityScoreKind: scanner.High,
+				},
+			},
+		},
+	}
+
+	err := PublishAsGeneralSlackMessage([]string{"failing", "working"}, report, []string{"path/to/group"}, mockSlackService)
+
+	assert.NotNil(t, err)
+	assert.ErrorContains(t, err, "channel_not_found")
+	// The working channel still receives the summary and its thread reply
+	workingCalls := pie.Filter(mockSlackService.Calls, func(c mock.Call) bool { return c.Arguments.Get(0) == "working" })
+	assert.Len(t, workingCalls, 2)
+	failingCalls := pie.Filter(mockSlackService.Calls, func(c mock.Call) bool { return c.Arguments.Get(0) == "failing" })
+	assert.Len(t, failingCalls, 1)
+}
+
 func TestPublishAsSpecificChannelSlackMessage(t *testing.T) {
 	mockSlackService := &mockSlackService{}
 	mockSlackService.On("PostMessage", "channel", mock.Anything).Return("", nil)
@@ -65,10 +99,185 @@ func TestPublishAsSpecificChannelSlackMessage(t *testing.T) {
 		ProjectConfig: config.ProjectConfig{Report: config.ProjectReport{To: config.ProjectReportTo{SlackChannel: "channel"}}},
 	}
 
-	_ = PublishAsSpecificChannelSlackMessage([]scanner.Report{report}, mockSlackService)
+	_ = PublishAsSpecificChannelSlackMessage([]scanner.Report{report}, SlackOpts{Thread: true}, mockSlackService)
 
 	mockSlackService.AssertExpectations(t)
-	mockSlackService.AssertNumberOfCalls(t, "PostMessage", 1)
+	// The summary, and the list of vulnerabilities in its thread
+	mockSlackService.AssertNumberOfCalls(t, "PostMessage", 2)
+}
+
+func TestPublishAsSpecificChannelSlackMessageThreaded(t *testing.T) {
+	testCases := []struct {
+		thread   bool
+		threadTs string
+	}{
+		{true, "1234.5678"},
+		{false, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("thread %v", tc.thread), func(t *testing.T) {
+			mockSlackService := &mockSlackService{}
+			mockSlackService.On("PostMessage", "channel", mock.Anything).Return("1234.5678", nil)
+			report := scanner.Report{
+				IsVulnerable:    true,
+				Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234", SeverityScoreKind: scanner.High}},
+				ProjectConfig:   config.ProjectConfig{Report: config.ProjectReport{To: config.ProjectReportTo{SlackChannel: "channel"}}},
+			}
+
+			err := PublishAsSpecificChannelSlackMessage([]scanner.Report{report}, SlackOpts{Thread: tc.thread}, mockSlackService)
+
+			require.NoError(t, err)
+			require.Len(t, mockSlackService.Calls, 2)
+			summaryOpts := mockSlackService.Calls[0].Arguments.Get(1).([]slack.MsgOption)
+			_, values, err := slack.UnsafeApplyMsgOptions("", "", "", summaryOpts...)
+			require.NoError(t, err)
+			assert.Empty(t, values.Get("thread_ts"))
+
+			listOpts := mockSlackService.Calls[1].Arguments.Get(1).([]slack.MsgOption)
+			_, values, err = slack.UnsafeApplyMsgOptions("", "", "", listOpts...)
+			require.NoError(t, err)
+			assert.Equal(t, tc.threadTs, values.Get("thread_ts"))
+			assert.Contains(t, values.Get("blocks"), "CVE-2021-1234")
+		})
+	}
+}
+
+func TestFormatSpecificChannelSlackMessageSplitsLongReports(t *testing.T) {
+	var vulns []scanner.Vulnerability
+	for i := range 200 {
+		vulns = append(vulns, scanner.Vulnerability{
+			Id:                fmt.Sprintf("CVE-2021-%04d", i),
+			PackageName:       "github.com/some/package",
+			PackageVersion:    "1.2.3",
+			SeverityScoreKind: scanner.High,
+			FixedVersion:      "1.2.4",
+		})
+	}
+	report := scanner.Report{IsVulnerable: true, Vulnerabilities: vulns}
+
+	formatted := formatSpecificChannelSlackMessage(report, SlackOpts{})
+
+	require.Greater(t, len(formatted), 2)
+	var listed int
+	for _, msg := range formatted[1:] {
+		_, values, err := slack.UnsafeApplyMsgOptions("", "", "", msg)
+		require.NoError(t, err)
+		var blocks []map[string]any
+		require.NoError(t, json.Unmarshal([]byte(values.Get("blocks")), &blocks))
+		text := blocks[0]["text"].(map[string]any)["text"].(string)
+		assert.LessOrEqual(t, len(text), 3000)
+		listed += strings.Count(text, "• `CVE-2021-")
+	}
+	assert.Equal(t, 200, listed)
+}
+
+func TestFormatSpecificChannelSlackMessageWithActions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		project repository.Project
+		wantUrl string
+	}{
+		{"gitlab", repository.Project{Path: "group/project", WebURL: "https://gitlab.com/group/project", DefaultBranch: "main", Repository: repository.Gitlab}, "https://gitlab.com/group/project/-/edit/main/sheriff.toml"},
+		{"github", repository.Project{Path: "org/project", WebURL: "https://github.com/org/project", DefaultBranch: "main", Repository: repository.Github}, "https://github.com/org/project/edit/main/sheriff.toml"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := scanner.Report{Project: tc.project, IssueUrl: tc.project.WebURL + "/issues/1"}
+
+			formatted := formatSpecificChannelSlackMessage(report, SlackOpts{Actions: true})
+
+			_, values, err := slack.UnsafeApplyMsgOptions("", "", "", formatted[0])
+			require.NoError(t, err)
+			var parsed slack.Blocks
+			require.NoError(t, json.Unmarshal([]byte(values.Get("blocks")), &parsed))
+			blocks := parsed.BlockSet
+			actions, ok := blocks[len(blocks)-1].(*slack.ActionBlock)
+			require.True(t, ok, "the summary should end with the actions")
+			require.Len(t, actions.Elements.ElementSet, 2)
+
+			openIssue := actions.Elements.ElementSet[0].(*slack.ButtonBlockElement)
+			assert.Equal(t, "open_issue", openIssue.ActionID)
+			assert.Equal(t, report.IssueUrl, openIssue.URL)
+
+			acknowledge := actions.Elements.ElementSet[1].(*slack.ButtonBlockElement)
+			assert.Equal(t, "acknowledge", acknowledge.ActionID)
+			assert.Equal(t, tc.project.Path, acknowledge.Value)
+			assert.Equal(t, tc.wantUrl, acknowledge.URL)
+		})
+	}
+}
+
+func TestFormatSpecificChannelSlackMessageWithoutActions(t *testing.T) {
+	formatted := formatSpecificChannelSlackMessage(scanner.Report{IssueUrl: "https://gitlab.com/group/project/-/issues/1"}, SlackOpts{})
+
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", formatted[0])
+	require.NoError(t, err)
+	assert.NotContains(t, values.Get("blocks"), `"type":"actions"`)
+}
+
+func TestFormatSpecificChannelSlackMessageWithMentions(t *testing.T) {
+	report := scanner.Report{
+		Project:       repository.Project{Path: "group/project", WebURL: "https://gitlab.com/group/project"},
+		ProjectConfig: config.ProjectConfig{Report: config.ProjectReport{To: config.ProjectReportTo{SlackChannel: "channel", SlackMention: []string{"U0123ABCD", "S0456EFGH", "@here"}}}},
+	}
+
+	formatted := formatSpecificChannelSlackMessage(report, SlackOpts{Actions: true})
+
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", formatted[0])
+	require.NoError(t, err)
+	var parsed slack.Blocks
+	require.NoError(t, json.Unmarshal([]byte(values.Get("blocks")), &parsed))
+	mentions := pie.Filter(parsed.BlockSet, func(b slack.Block) bool { return b.ID() == "mentions" })
+	require.Len(t, mentions, 1)
+	assert.Equal(t, "cc <@U0123ABCD> <!subteam^S0456EFGH> <!here>", mentions[0].(*slack.SectionBlock).Text.Text)
+}
+
+func TestFormatSpecificChannelSlackMessageWithoutMentions(t *testing.T) {
+	formatted := formatSpecificChannelSlackMessage(scanner.Report{}, SlackOpts{})
+
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", formatted[0])
+	require.NoError(t, err)
+	assert.NotContains(t, values.Get("blocks"), `"block_id":"mentions"`)
+}
+
+func TestFormatSpecificChannelSlackMessageNewOnly(t *testing.T) {
+	report := scanner.Report{
+		IsVulnerable:       true,
+		IssueUrl:           "https://gitlab.com/group/project/-/issues/1",
+		Vulnerabilities:    []scanner.Vulnerability{{Id: "CVE-2022-25883"}, {Id: "CVE-2024-1234"}},
+		NewVulnerabilities: []scanner.Vulnerability{{Id: "CVE-2024-1234"}},
+	}
+
+	formatted := formatSpecificChannelSlackMessage(report, SlackOpts{NewOnly: true})
+
+	require.Len(t, formatted, 2)
+	_, summary, err := slack.UnsafeApplyMsgOptions("", "", "", formatted[0])
+	require.NoError(t, err)
+	assert.Contains(t, summary.Get("blocks"), "(total 2)")
+	assert.Contains(t, summary.Get("blocks"), "New since last report: *1*")
+	_, list, err := slack.UnsafeApplyMsgOptions("", "", "", formatted[1])
+	require.NoError(t, err)
+	assert.Contains(t, list.Get("blocks"), "CVE-2024-1234")
+	assert.NotContains(t, list.Get("blocks"), "CVE-2022-25883")
+
+	t.Run("ListsAllWithoutIssue", func(t *testing.T) {
+		report.IssueUrl = ""
+
+		formatted := formatSpecificChannelSlackMessage(report, SlackOpts{NewOnly: true})
+
+		require.Len(t, formatted, 2)
+		_, list, err := slack.UnsafeApplyMsgOptions("", "", "", formatted[1])
+		require.NoError(t, err)
+		assert.Contains(t, list.Get("blocks"), "CVE-2022-25883")
+	})
+}
+
+func TestFormatSpecificChannelSlackMessageWithoutVulnerabilities(t *testing.T) {
+	formatted := formatSpecificChannelSlackMessage(scanner.Report{}, SlackOpts{})
+
+	assert.Len(t, formatted, 1)
 }
 
 func TestFormatSummary(t *testing.T) {
@@ -90,14 +299,102 @@ func TestFormatSummary(t *testing.T) {
 			IsVulnerable:    false,
 			Vulnerabilities: []scanner.Vulnerability{},
 		},
+		{
+			// Only vulnerable because of its licenses
+			IsVulnerable:      true,
+			Vulnerabilities:   []scanner.Vulnerability{},
+			LicenseViolations: []scanner.LicenseViolation{{PackageName: "left-pad", Licenses: []string{"WTFPL"}}},
+		},
 	}
 
-	msgOpts := formatSummary(groupVulnReportsByMaxSeverityKind(report), len(report), []string{"path/to/group", "path/to/project"})
+	msgOpts := formatSummary(groupVulnReportsByMaxSeverityKind(report), len(report), summarizePatrol(report), []string{"path/to/group", "path/to/project"})
 
 	assert.NotNil(t, msgOpts)
 	assert.Len(t, msgOpts, 1)
 }
 
+func TestFormatSummaryCountsUniqueVulnerabilities(t *testing.T) {
+	shared := scanner.Vulnerability{Id: "CVE-2022-25883", PackageName: "semver", PackageEcosystem: "npm", SeverityScoreKind: scanner.High}
+	reports := []scanner.Report{
+		{IsVulnerable: true, Vulnerabilities: []scanner.Vulnerability{shared}},
+		{IsVulnerable: true, Vulnerabilities: []scanner.Vulnerability{shared}},
+	}
+
+	msgOpts := formatSummary(groupVulnReportsByMaxSeverityKind(reports), len(reports), summarizePatrol(reports), nil)
+
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", msgOpts[0])
+	require.NoError(t, err)
+	assert.Contains(t, values.Get("blocks"), "Unique vulnerabilities: *1*, affecting *1* distinct packages")
+}
+
+func TestGroupVulnReportsByMaxSeverityKindAcknowledged(t *testing.T) {
+	acknowledged := scanner.Report{
+		Project:      repository.Project{Name: "acknowledged"},
+		IsVulnerable: true,
+		Vulnerabilities: []scanner.Vulnerability{
+			{Id: "CVE-2021-1234", Severity: "9.8", SeverityScoreKind: scanner.Acknowledged, AckReason: "not used"},
+			{Id: "CVE-2021-1235", Severity: "8.1", SeverityScoreKind: scanner.Acknowledged},
+		},
+	}
+	partiallyAcknowledged := scanner.Report{
+		Project:      repository.Project{Name: "partially-acknowledged"},
+		IsVulnerable: true,
+		Vulnerabilities: []scanner.Vulnerability{
+			{Id: "CVE-2021-1236", Severity: "9.8", SeverityScoreKind: scanner.Acknowledged},
+			{Id: "CVE-2021-1237", Severity: "5.0", SeverityScoreKind: scanner.Moderate},
+		},
+	}
+
+	got := groupVulnReportsByMaxSeverityKind([]scanner.Report{acknowledged, partiallyAcknowledged})
+
+	assert.Equal(t, map[scanner.SeverityScoreKind][]scanner.Report{
+		scanner.Acknowledged: {acknowledged},
+		scanner.Moderate:     {partiallyAcknowledged},
+	}, got)
+
+	msgOpts := formatSummary(got, 2, summarizePatrol([]scanner.Report{acknowledged, partiallyAcknowledged}), nil)
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", msgOpts[0])
+	require.NoError(t, err)
+	assert.Contains(t, values.Get("blocks"), "CRITICAL: *0*")
+	assert.Contains(t, values.Get("blocks"), "ACKNOWLEDGED: *1*")
+}
+
+func TestGroupVulnReportsByMaxSeverityKindFailed(t *testing.T) {
+	failed := scanner.NewFailedReport(repository.Project{Name: "failed"}, scanner.ScanFailed, "osv-scanner crashed")
+	timedOut := scanner.Report{
+		Project:         repository.Project{Name: "timed-out"},
+		IsVulnerable:    true,
+		Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234", SeverityScoreKind: scanner.Low}},
+		Error:           true,
+		ErrorKind:       scanner.Timeout,
+	}
+	vulnerable := scanner.Report{
+		Project:         repository.Project{Name: "vulnerable"},
+		IsVulnerable:    true,
+		Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1235", SeverityScoreKind: scanner.Low}},
+	}
+	reports := []scanner.Report{failed, timedOut, vulnerable}
+
+	got := groupVulnReportsByMaxSeverityKind(reports)
+
+	assert.Equal(t, map[scanner.SeverityScoreKind][]scanner.Report{
+		failedScansGroup: {failed, timedOut},
+		scanner.Low:      {vulnerable},
+	}, got)
+
+	msgOpts := formatSummary(got, len(reports), summarizePatrol(reports), nil)
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", msgOpts[0])
+	require.NoError(t, err)
+	assert.Contains(t, values.Get("blocks"), "LOW: *1*")
+	assert.Contains(t, values.Get("blocks"), "UNKNOWN: *0*")
+	assert.Contains(t, values.Get("blocks"), "Unsuccessfully scanned projects: *2*")
+	for _, msg := range formatReportMessage(got) {
+		_, values, err := slack.UnsafeApplyMsgOptions("", "", "", msg)
+		require.NoError(t, err)
+		assert.NotContains(t, values.Get("blocks"), "timed-out")
+	}
+}
+
 func TestFormatReportMessage(t *testing.T) {
 	reportBySeverityKind := map[scanner.SeverityScoreKind][]scanner.Report{
 		scanner.Critical: {
@@ -139,6 +436,168 @@ func TestFormatReportMessage(t *testing.T) {
 	assert.Len(t, formatted, 1)
 }
 
+func TestFormatReportMessageSortsByEpss(t *testing.T) {
+	reportBySeverityKind := map[scanner.SeverityScoreKind][]scanner.Report{
+		scanner.High: {
+			{
+				Project:         repository.Project{Name: "unlikely", WebURL: "http://example.com"},
+				IsVulnerable:    true,
+				Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234", SeverityScoreKind: scanner.High, Epss: 0.01}},
+			},
+			{
+				Project:         repository.Project{Name: "unknown", WebURL: "http://example2.com"},
+				IsVulnerable:    true,
+				Vulnerabilities: []scanner.Vulnerability{{Id: "GHSA-xxxx-yyyy-zzzz", SeverityScoreKind: scanner.High, Epss: scanner.EpssUnknown}},
+			},
+			{
+				Project:         repository.Project{Name: "likely", WebURL: "http://example3.com"},
+				IsVulnerable:    true,
+				Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1235", SeverityScoreKind: scanner.High, Epss: 0.9}},
+			},
+		},
+	}
+
+	formatted := formatReportMessage(reportBySeverityKind)
+	require.Len(t, formatted, 1)
+
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", formatted[0])
+	require.NoError(t, err)
+	text := values.Get("blocks")
+
+	assert.Less(t, strings.Index(text, "*likely*"), strings.Index(text, "*unlikely*"))
+	assert.Less(t, strings.Index(text, "*unlikely*"), strings.Index(text, "*unknown*"))
+	assert.Contains(t, text, "Highest EPSS: *90.00%*")
+}
+
+func TestFormatReportMessageSortsByVulnerabilityCount(t *testing.T) {
+	vulns := func(n int) []scanner.Vulnerability {
+		return make([]scanner.Vulnerability, n)
+	}
+	reportBySeverityKind := map[scanner.SeverityScoreKind][]scanner.Report{
+		scanner.High: {
+			{Project: repository.Project{Name: "one"}, IsVulnerable: true, Vulnerabilities: vulns(1)},
+			{Project: repository.Project{Name: "three-b"}, IsVulnerable: true, Vulnerabilities: vulns(3)},
+			{Project: repository.Project{Name: "five"}, IsVulnerable: true, Vulnerabilities: vulns(5)},
+			{Project: repository.Project{Name: "three-a"}, IsVulnerable: true, Vulnerabilities: vulns(3)},
+		},
+	}
+
+	formatted := formatReportMessage(reportBySeverityKind)
+	require.Len(t, formatted, 1)
+
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", formatted[0])
+	require.NoError(t, err)
+	text := values.Get("blocks")
+
+	assert.Less(t, strings.Index(text, "*five*"), strings.Index(text, "*three-a*"))
+	assert.Less(t, strings.Index(text, "*three-a*"), strings.Index(text, "*three-b*"))
+	assert.Less(t, strings.Index(text, "*three-b*"), strings.Index(text, "*one*"))
+}
+
+func TestFormatRevision(t *testing.T) {
+	testCases := []struct {
+		name   string
+		report scanner.Report
+		want   string
+	}{
+		{
+			"gitlab commit",
+			scanner.Report{Project: repository.Project{WebURL: "https://gitlab.com/group/project", DefaultBranch: "main", Repository: repository.Gitlab}, CommitSha: "0123456789abcdef"},
+			"<https://gitlab.com/group/project/-/commit/0123456789abcdef|`01234567`>",
+		},
+		{
+			"github commit",
+			scanner.Report{Project: repository.Project{WebURL: "https://github.com/owner/repo", Repository: repository.Github}, CommitSha: "0123456789abcdef"},
+			"<https://github.com/owner/repo/commit/0123456789abcdef|`01234567`>",
+		},
+		{
+			"falls back to branch",
+			scanner.Report{Project: repository.Project{WebURL: "https://gitlab.com/group/project", DefaultBranch: "main", Repository: repository.Gitlab}},
+			"<https://gitlab.com/group/project/-/tree/main|`main`>",
+		},
+		{
+			"unknown",
+			scanner.Report{Project: repository.Project{WebURL: "https://github.com/owner/repo", Repository: repository.Github}},
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, formatRevision(tc.report))
+		})
+	}
+}
+
+func TestComputeReportDeltas(t *testing.T) {
+	previous := state.State{Projects: map[string]state.ProjectState{
+		"group/project1": {Vulnerabilities: []state.Vulnerability{
+			{Id: "CVE-1", PackageName: "pkg1", SeverityScoreKind: scanner.High},
+			{Id: "CVE-2", PackageName: "pkg2", SeverityScoreKind: scanner.Low},
+		}},
+		"group/project2": {Vulnerabilities: []state.Vulnerability{
+			{Id: "CVE-3", PackageName: "pkg3", SeverityScoreKind: scanner.Critical},
+		}},
+	}}
+	reports := []scanner.Report{
+		{
+			Project: repository.Project{Path: "group/project1", Name: "project1"},
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "CVE-1", PackageName: "pkg1", SeverityScoreKind: scanner.High},
+				{Id: "CVE-4", PackageName: "pkg4", SeverityScoreKind: scanner.Critical},
+			},
+		},
+		{
+			// Unchanged project
+			Project:         repository.Project{Path: "group/project2", Name: "project2"},
+			Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-3", PackageName: "pkg3", SeverityScoreKind: scanner.Critical}},
+		},
+	}
+
+	deltas := computeReportDeltas(reports, previous)
+
+	assert.Len(t, deltas, 1)
+	assert.Equal(t, "group/project1", deltas[0].Report.Project.Path)
+	assert.Equal(t, []state.Vulnerability{{Id: "CVE-4", PackageName: "pkg4", SeverityScoreKind: scanner.Critical}}, deltas[0].Introduced)
+	assert.Equal(t, []state.Vulnerability{{Id: "CVE-2", PackageName: "pkg2", SeverityScoreKind: scanner.Low}}, deltas[0].Resolved)
+
+	t.Run("FormatsDeltaMessage", func(t *testing.T) {
+		msgOpts := formatDeltaMessage(deltas)
+
+		assert.Len(t, msgOpts, 1)
+	})
+}
+
+func TestComputeReportDeltasSkipsErroredReports(t *testing.T) {
+	previous := state.State{Projects: map[string]state.ProjectState{
+		"group/project": {Vulnerabilities: []state.Vulnerability{{Id: "CVE-1", PackageName: "pkg1"}}},
+	}}
+	reports := []scanner.Report{{Project: repository.Project{Path: "group/project"}, Error: true}}
+
+	deltas := computeReportDeltas(reports, previous)
+
+	assert.Empty(t, deltas)
+}
+
+func TestPublishAsDeltaSlackMessage(t *testing.T) {
+	mockSlackService := &mockSlackService{}
+	mockSlackService.On("PostMessage", "channel", mock.Anything).Return("", nil)
+	reports := []scanner.Report{
+		{
+			Project:         repository.Project{Path: "group/project"},
+			IsVulnerable:    true,
+			Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-2021-1234"}},
+		},
+	}
+
+	err := PublishAsDeltaSlackMessage([]string{"channel"}, reports, state.State{}, []string{"group"}, mockSlackService)
+
+	assert.Nil(t, err)
+	mockSlackService.AssertExpectations(t)
+	// Summary & a single message in thread
+	mockSlackService.AssertNumberOfCalls(t, "PostMessage", 2)
+}
+
 func TestSplitMessage(t *testing.T) {
 	testCases := map[string][]string{
 		// Case with no newlines at all, simply split by maxLen
@@ -164,3 +623,76 @@ func (c *mockSlackService) PostMessage(channelName string, options ...slack.MsgO
 	args := c.Called(channelName, options)
 	return args.String(0), args.Error(1)
 }
+
+func TestFormatDeltaVulnerabilitiesWithFixedVersion(t *testing.T) {
+	got := formatDeltaVulnerabilities([]state.Vulnerability{
+		{Id: "CVE-1", PackageName: "pkg", PackageVersion: "1.0.0", SeverityScoreKind: scanner.High, FixedVersion: "1.0.1"},
+		{Id: "CVE-2", PackageName: "pkg", PackageVersion: "1.0.0", SeverityScoreKind: scanner.Low},
+	})
+
+	assert.Equal(t, "\t\t• `CVE-1` pkg@1.0.0 (HIGH), fixed in `1.0.1`\n\t\t• `CVE-2` pkg@1.0.0 (LOW)\n", got)
+}
+
+func TestFormatFailedReportsMessage(t *testing.T) {
+	reports := []scanner.Report{
+		{Project: repository.Project{Name: "scanned", WebURL: "https://gitlab.com/group/scanned"}},
+		scanner.NewFailedReport(repository.Project{Name: "failed", WebURL: "https://gitlab.com/group/failed"}, scanner.ConfigInvalid, "failed to get project configuration of group/failed"),
+	}
+
+	formatted := formatFailedReportsMessage(reports)
+
+	require.Len(t, formatted, 1)
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", formatted...)
+	require.NoError(t, err)
+	blocks := values.Get("blocks")
+	assert.Contains(t, blocks, "Unsuccessfully scanned projects")
+	assert.Contains(t, blocks, "https://gitlab.com/group/failed|*failed*")
+	assert.Contains(t, blocks, "Reason: invalid project configuration (`failed to get project configuration of group/failed`)")
+	assert.NotContains(t, blocks, "scanned|")
+}
+
+func TestFormatFailedReportsMessageWithoutFailures(t *testing.T) {
+	assert.Empty(t, formatFailedReportsMessage([]scanner.Report{{IsVulnerable: true}}))
+}
+
+func TestFormatNoManifestsMessage(t *testing.T) {
+	reports := []scanner.Report{
+		{Project: repository.Project{Name: "app", WebURL: "https://gitlab.com/group/app"}},
+		{Project: repository.Project{Name: "docs", WebURL: "https://gitlab.com/group/docs"}, NoManifests: true},
+	}
+
+	formatted := formatNoManifestsMessage(reports)
+
+	require.Len(t, formatted, 1)
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", formatted...)
+	require.NoError(t, err)
+	blocks := values.Get("blocks")
+	assert.Contains(t, blocks, "Projects without lockfiles or manifests to scan")
+	assert.Contains(t, blocks, "https://gitlab.com/group/docs|*docs*")
+	assert.NotContains(t, blocks, "app")
+}
+
+func TestFormatNoManifestsMessageWithoutSuchProjects(t *testing.T) {
+	assert.Empty(t, formatNoManifestsMessage([]scanner.Report{{IsVulnerable: true}}))
+}
+
+func TestFormatConfigWarningsMessage(t *testing.T) {
+	reports := []scanner.Report{
+		{Project: repository.Project{Name: "clean", WebURL: "https://gitlab.com/group/clean"}},
+		{
+			Project:        repository.Project{Name: "warned", WebURL: "https://gitlab.com/group/warned"},
+			ConfigWarnings: []string{"unknown keys in sheriff.toml: reprot"},
+		},
+	}
+
+	formatted := formatConfigWarningsMessage(reports)
+
+	require.Len(t, formatted, 1)
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", formatted...)
+	require.NoError(t, err)
+	blocks := values.Get("blocks")
+	assert.Contains(t, blocks, "Projects with configuration warnings")
+	assert.Contains(t, blocks, "https://gitlab.com/group/warned|*warned*")
+	assert.Contains(t, blocks, "unknown keys in sheriff.toml: reprot")
+	assert.NotContains(t, blocks, "clean")
+}
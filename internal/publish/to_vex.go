@@ -0,0 +1,211 @@
+package publish
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sheriff/internal/scanner"
+	"strconv"
+	"time"
+)
+
+// CycloneDX VEX analysis states of the vulnerabilities, see formatVexAnalysis
+const (
+	vexStateNotAffected = "not_affected" // Acknowledged in the configuration of the project
+	vexStateExploitable = "exploitable"  // Not acknowledged, which CycloneDX calls exploitable rather than affected
+)
+
+// vexDocument is a CycloneDX document made of VEX statements, see https://cyclonedx.org/capabilities/vex/
+type vexDocument struct {
+	BomFormat       string             `json:"bomFormat"`
+	SpecVersion     string             `json:"specVersion"`
+	Version         int                `json:"version"`
+	Metadata        vexMetadata        `json:"metadata"`
+	Components      []vexComponent     `json:"components"`
+	Vulnerabilities []vexVulnerability `json:"vulnerabilities"`
+}
+
+type vexMetadata struct {
+	Timestamp string   `json:"timestamp"`
+	Tools     vexTools `json:"tools"`
+}
+
+type vexTools struct {
+	Components []vexComponent `json:"components"`
+}
+
+// vexComponent is a scanned project, whose vulnerable packages are its nested components
+type vexComponent struct {
+	Type               string                 `json:"type"`
+	BomRef             string                 `json:"bom-ref,omitempty"`
+	Name               string                 `json:"name"`
+	Version            string                 `json:"version,omitempty"`
+	Purl               string                 `json:"purl,omitempty"`
+	ExternalReferences []vexExternalReference `json:"externalReferences,omitempty"`
+	Components         []vexComponent         `json:"components,omitempty"`
+}
+
+type vexExternalReference struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+type vexVulnerability struct {
+	BomRef      string         `json:"bom-ref"`
+	Id          string         `json:"id"`
+	Source      vexSource      `json:"source"`
+	References  []vexReference `json:"references,omitempty"`
+	Ratings     []vexRating    `json:"ratings"`
+	Description string         `json:"description,omitempty"`
+	Analysis    vexAnalysis    `json:"analysis"`
+	Affects     []vexAffect    `json:"affects"`
+}
+
+type vexSource struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// vexReference is another identifier of the same vulnerability, e.g. the CVE of a GHSA
+type vexReference struct {
+	Id     string    `json:"id"`
+	Source vexSource `json:"source"`
+}
+
+type vexRating struct {
+	Score    *float64 `json:"score,omitempty"` // CVSS score, nil if it is unknown
+	Severity string   `json:"severity"`
+	Method   string   `json:"method"`
+}
+
+type vexAnalysis struct {
+	State         string `json:"state"`
+	Justification string `json:"justification,omitempty"`
+	Detail        string `json:"detail,omitempty"`
+}
+
+type vexAffect struct {
+	Ref string `json:"ref"` // bom-ref of the vulnerable package
+}
+
+// PublishAsVex writes the reports as a CycloneDX VEX document to the given path,
+// so that supply-chain tooling knows which vulnerabilities affect the projects.
+func PublishAsVex(reports []scanner.Report, path string) error {
+	out, err := formatReportsAsVex(reports)
+	if err != nil {
+		return errors.Join(errors.New("failed to format vex report"), err)
+	}
+
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return errors.Join(fmt.Errorf("failed to write vex report to %v", path), err)
+	}
+
+	return nil
+}
+
+// formatReportsAsVex formats the reports as a CycloneDX VEX document in JSON.
+// Each project is a component, with its vulnerable packages as nested components, and each vulnerability of a package
+// is a statement about it: not affected if it was acknowledged, with its reason, and exploitable otherwise.
+// Projects which could not be scanned are left out, as nothing is known about them.
+func formatReportsAsVex(reports []scanner.Report) ([]byte, error) {
+	doc := vexDocument{
+		BomFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: vexMetadata{
+			Timestamp: now().UTC().Format(time.RFC3339),
+			Tools:     vexTools{Components: []vexComponent{{Type: "application", Name: "sheriff"}}},
+		},
+		Components:      []vexComponent{},
+		Vulnerabilities: []vexVulnerability{},
+	}
+
+	for _, r := range reports {
+		if r.Error {
+			continue
+		}
+
+		project := vexComponent{Type: "application", BomRef: r.Project.Path, Name: r.Project.Path}
+		if r.Project.WebURL != "" {
+			project.ExternalReferences = []vexExternalReference{{Type: "vcs", Url: r.Project.WebURL}}
+		}
+
+		packages := make(map[string]bool)
+		for _, v := range r.Vulnerabilities {
+			ref := fmt.Sprintf("%v#%v@%v", r.Project.Path, v.PackageName, v.PackageVersion)
+			if !packages[ref] {
+				packages[ref] = true
+				project.Components = append(project.Components, vexComponent{Type: "library", BomRef: ref, Name: v.PackageName, Version: v.PackageVersion, Purl: v.PackageUrl})
+			}
+
+			doc.Vulnerabilities = append(doc.Vulnerabilities, vexVulnerability{
+				BomRef:      fmt.Sprintf("%v#%v", ref, v.Id),
+				Id:          v.Id,
+				Source:      formatVexSource(v.Id),
+				References:  formatVexReferences(v.Aliases),
+				Ratings:     []vexRating{formatVexRating(v)},
+				Description: v.Summary,
+				Analysis:    formatVexAnalysis(v),
+				Affects:     []vexAffect{{Ref: ref}},
+			})
+		}
+
+		doc.Components = append(doc.Components, project)
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func formatVexSource(id string) vexSource {
+	return vexSource{Name: "OSV", Url: fmt.Sprintf("https://osv.dev/%s", id)}
+}
+
+func formatVexReferences(aliases []string) (refs []vexReference) {
+	for _, alias := range aliases {
+		refs = append(refs, vexReference{Id: alias, Source: formatVexSource(alias)})
+	}
+
+	return
+}
+
+// formatVexRating rates the vulnerability with its CVSS score. The severity of acknowledged vulnerabilities is
+// derived from their score, as their SeverityScoreKind no longer tells it.
+func formatVexRating(v scanner.Vulnerability) vexRating {
+	kind := v.SeverityScoreKind
+	if kind == scanner.Acknowledged {
+		kind = scanner.GetSeverityScoreKind(v.Severity)
+	}
+
+	rating := vexRating{Severity: "unknown", Method: "other"}
+	switch kind {
+	case scanner.Critical:
+		rating.Severity = "critical"
+	case scanner.High:
+		rating.Severity = "high"
+	case scanner.Moderate:
+		rating.Severity = "medium"
+	case scanner.Low:
+		rating.Severity = "low"
+	}
+	if score, err := strconv.ParseFloat(v.Severity, 64); err == nil {
+		rating.Score = &score
+	}
+
+	return rating
+}
+
+// formatVexAnalysis tells whether the project is affected by the vulnerability.
+// Acknowledged vulnerabilities are not affecting it, for the reason given in its configuration.
+func formatVexAnalysis(v scanner.Vulnerability) vexAnalysis {
+	if v.SeverityScoreKind != scanner.Acknowledged {
+		return vexAnalysis{State: vexStateExploitable}
+	}
+
+	analysis := vexAnalysis{State: vexStateNotAffected, Detail: v.AckReason}
+	if v.Reachable != nil && !*v.Reachable {
+		analysis.Justification = "code_not_reachable"
+	}
+
+	return analysis
+}
@@ -0,0 +1,89 @@
+package publish
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sheriff/internal/repository"
+	"sheriff/internal/scanner"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatReportsAsVex(t *testing.T) {
+	origNow := now
+	now = func() time.Time { return time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = origNow }()
+
+	unreachable := false
+	reports := []scanner.Report{
+		{
+			Project:      repository.Project{Path: "group/project1", WebURL: "https://gitlab.com/group/project1"},
+			IsVulnerable: true,
+			Vulnerabilities: []scanner.Vulnerability{
+				{Id: "GHSA-c2qf-rxjj-qqgw", Aliases: []string{"CVE-2022-25883"}, PackageName: "semver", PackageVersion: "7.3.7", PackageUrl: "pkg:npm/semver@7.3.7", Severity: "7.5", SeverityScoreKind: scanner.High, Summary: "ReDoS in semver"},
+				{Id: "CVE-2021-23337", PackageName: "lodash", PackageVersion: "4.17.20", Severity: "9.8", SeverityScoreKind: scanner.Acknowledged, AckReason: "Only used in tests"},
+				{Id: "CVE-2020-8203", PackageName: "lodash", PackageVersion: "4.17.20", SeverityScoreKind: scanner.Acknowledged, Reachable: &unreachable},
+			},
+		},
+		{Project: repository.Project{Path: "group/clean"}, Vulnerabilities: []scanner.Vulnerability{}},
+		{Project: repository.Project{Path: "group/failed"}, Error: true},
+	}
+
+	out, err := formatReportsAsVex(reports)
+	require.NoError(t, err)
+
+	var got vexDocument
+	require.NoError(t, json.Unmarshal(out, &got))
+
+	assert.Equal(t, "CycloneDX", got.BomFormat)
+	assert.Equal(t, "2024-05-06T12:00:00Z", got.Metadata.Timestamp)
+	require.Len(t, got.Components, 2)
+	assert.Equal(t, "group/project1", got.Components[0].BomRef)
+	assert.Equal(t, []vexComponent{
+		{Type: "library", BomRef: "group/project1#semver@7.3.7", Name: "semver", Version: "7.3.7", Purl: "pkg:npm/semver@7.3.7"},
+		{Type: "library", BomRef: "group/project1#lodash@4.17.20", Name: "lodash", Version: "4.17.20"},
+	}, got.Components[0].Components)
+	assert.Equal(t, "group/clean", got.Components[1].BomRef)
+
+	require.Len(t, got.Vulnerabilities, 3)
+	active := got.Vulnerabilities[0]
+	assert.Equal(t, "GHSA-c2qf-rxjj-qqgw", active.Id)
+	assert.Equal(t, vexAnalysis{State: vexStateExploitable}, active.Analysis)
+	assert.Equal(t, []vexAffect{{Ref: "group/project1#semver@7.3.7"}}, active.Affects)
+	assert.Equal(t, []vexReference{{Id: "CVE-2022-25883", Source: vexSource{Name: "OSV", Url: "https://osv.dev/CVE-2022-25883"}}}, active.References)
+	require.Len(t, active.Ratings, 1)
+	assert.Equal(t, "high", active.Ratings[0].Severity)
+	assert.Equal(t, 7.5, *active.Ratings[0].Score)
+
+	acknowledged := got.Vulnerabilities[1]
+	assert.Equal(t, vexAnalysis{State: vexStateNotAffected, Detail: "Only used in tests"}, acknowledged.Analysis)
+	assert.Equal(t, []vexAffect{{Ref: "group/project1#lodash@4.17.20"}}, acknowledged.Affects)
+	assert.Equal(t, "critical", acknowledged.Ratings[0].Severity)
+
+	unreachableAck := got.Vulnerabilities[2]
+	assert.Equal(t, vexAnalysis{State: vexStateNotAffected, Justification: "code_not_reachable"}, unreachableAck.Analysis)
+	assert.Equal(t, "unknown", unreachableAck.Ratings[0].Severity)
+	assert.Nil(t, unreachableAck.Ratings[0].Score)
+}
+
+func TestPublishAsVex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vex.json")
+
+	err := PublishAsVex([]scanner.Report{}, path)
+
+	require.NoError(t, err)
+	out, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"bomFormat": "CycloneDX"`)
+	assert.Contains(t, string(out), `"vulnerabilities": []`)
+}
+
+func TestPublishAsVexFailsWithMissingDir(t *testing.T) {
+	err := PublishAsVex([]scanner.Report{}, filepath.Join(t.TempDir(), "missing", "vex.json"))
+
+	assert.ErrorContains(t, err, "failed to write vex report")
+}
@@ -0,0 +1,128 @@
+// Package ratelimit provides an HTTP transport which throttles the requests to an API,
+// and waits and retries the requests rejected because of rate limits.
+package ratelimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+// maxAttempts is the maximum number of times a rate limited request is sent
+const maxAttempts = 4
+
+// maxWait is the longest wait before retrying a rate limited request.
+// Longer waits requested by the API are not honored, and the rate limited response is returned instead.
+const maxWait = 5 * time.Minute
+
+// defaultBackoff is the initial wait before retrying a rate limited request,
+// when the API does not tell how long to wait
+var defaultBackoff = 2 * time.Second
+
+// now is a function that returns the current time
+var now = time.Now
+
+type transport struct {
+	base    http.RoundTripper
+	limiter *rate.Limiter
+}
+
+// NewTransport wraps the given transport, or http.DefaultTransport if nil, so that at most requestsPerSecond requests are sent per second.
+// A limit of 0 or less disables the throttling, while rate limited requests are still retried.
+// Requests rejected because of rate limits are retried after the wait requested by the API,
+// from the Retry-After or X-RateLimit-Reset headers, or with an exponential backoff otherwise.
+func NewTransport(base http.RoundTripper, requestsPerSecond float64) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if requestsPerSecond > 0 {
+		// Without bursts, so that the requests are evenly spread as the APIs expect
+		limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), 1)
+	}
+
+	return &transport{base: base, limiter: limiter}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	for attempt := 1; ; attempt++ {
+		if err := t.limiter.Wait(req.Context()); err != nil {
+			return nil, errors.Join(errors.New("failed to wait for rate limiter"), err)
+		}
+
+		if resp, err = t.base.RoundTrip(req); err != nil || !isRateLimited(resp) || attempt == maxAttempts {
+			return resp, err
+		}
+
+		wait := retryAfter(resp, attempt)
+		if wait > maxWait {
+			log.Warn().Str("url", req.URL.Redacted()).Dur("retry_after", wait).Msg("Hit API rate limit, not waiting that long")
+			return resp, nil
+		}
+
+		// The request can only be sent again if its body can be read again
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		retryReq := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if retryReq.Body, err = req.GetBody(); err != nil {
+				return resp, nil
+			}
+		}
+
+		// The body of the rate limited response is discarded so that the connection can be reused
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		log.Warn().Str("url", req.URL.Redacted()).Int("attempt", attempt).Dur("retry_after", wait).Msg("Hit API rate limit, backing off dynamically")
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+
+		req = retryReq
+	}
+}
+
+// isRateLimited returns whether the response rejects the request because of a rate limit.
+// GitHub rejects rate limited requests with a 403 instead of a 429 in some cases, which are told apart by their headers.
+func isRateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+	default:
+		return false
+	}
+}
+
+// retryAfter returns how long to wait before retrying the rate limited request.
+// The Retry-After header takes precedence over the X-RateLimit-Reset (GitHub) and RateLimit-Reset (GitLab) headers.
+// Without any of them, the wait grows exponentially with the attempts.
+func retryAfter(resp *http.Response, attempt int) time.Duration {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			return time.Duration(max(seconds, 0)) * time.Second
+		}
+		if date, err := http.ParseTime(v); err == nil {
+			return max(date.Sub(now()), 0)
+		}
+	}
+
+	for _, h := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		if reset, err := strconv.ParseInt(resp.Header.Get(h), 10, 64); err == nil {
+			return max(time.Unix(reset, 0).Sub(now()), 0)
+		}
+	}
+
+	return defaultBackoff * time.Duration(1<<(attempt-1))
+}
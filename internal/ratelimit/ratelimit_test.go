@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetriesAfterRetryAfter(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, 0)}
+
+	start := time.Now()
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"title":"issue"}`))
+	elapsed := time.Since(start)
+
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, []string{`{"title":"issue"}`, `{"title":"issue"}`}, bodies, "the body is sent again")
+	assert.GreaterOrEqual(t, elapsed, time.Second, "should have waited as requested by Retry-After")
+}
+
+func TestRetriesGithubRateLimitedForbidden(t *testing.T) {
+	defaultBackoffBackup := defaultBackoff
+	defaultBackoff = time.Millisecond
+	defer func() { defaultBackoff = defaultBackoffBackup }()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, 0)}
+
+	resp, err := client.Get(server.URL)
+
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, calls)
+}
+
+func TestDoesNotRetryForbidden(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, 0)}
+
+	resp, err := client.Get(server.URL)
+
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+}
+
+func TestGivesUpAfterMaxAttempts(t *testing.T) {
+	defaultBackoffBackup := defaultBackoff
+	defaultBackoff = time.Millisecond
+	defer func() { defaultBackoff = defaultBackoffBackup }()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, 0)}
+
+	resp, err := client.Get(server.URL)
+
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, maxAttempts, calls)
+}
+
+func TestThrottlesRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, 20)}
+
+	start := time.Now()
+	for range 3 {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// The first request is sent right away, the following ones every 50ms
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestRetryAfter(t *testing.T) {
+	fixedNow := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	nowBackup := now
+	now = func() time.Time { return fixedNow }
+	defer func() { now = nowBackup }()
+
+	testCases := []struct {
+		name    string
+		headers map[string]string
+		attempt int
+		want    time.Duration
+	}{
+		{"retry after seconds", map[string]string{"Retry-After": "30"}, 1, 30 * time.Second},
+		{"retry after date", map[string]string{"Retry-After": fixedNow.Add(time.Minute).Format(http.TimeFormat)}, 1, time.Minute},
+		{"github reset", map[string]string{"X-RateLimit-Reset": strconv.FormatInt(fixedNow.Add(10*time.Second).Unix(), 10)}, 1, 10 * time.Second},
+		{"gitlab reset", map[string]string{"RateLimit-Reset": strconv.FormatInt(fixedNow.Add(5*time.Second).Unix(), 10)}, 1, 5 * time.Second},
+		{"reset in the past", map[string]string{"X-RateLimit-Reset": strconv.FormatInt(fixedNow.Add(-time.Minute).Unix(), 10)}, 1, 0},
+		{"no header", map[string]string{}, 3, 4 * defaultBackoff},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			for k, v := range tc.headers {
+				resp.Header.Set(k, v)
+			}
+
+			assert.Equal(t, tc.want, retryAfter(resp, tc.attempt))
+		})
+	}
+}
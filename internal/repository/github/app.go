@@ -0,0 +1,160 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sheriff/internal/log"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+	"golang.org/x/oauth2"
+)
+
+// appJWTLifetime is the lifetime of the JSON Web Tokens authenticating as the GitHub App, at most 10 minutes for GitHub
+const appJWTLifetime = 9 * time.Minute
+
+// appJWTClockDrift is how far in the past the JSON Web Tokens are issued, to allow for clock drift with GitHub
+const appJWTClockDrift = time.Minute
+
+// AppCredentials are the credentials of an installation of a GitHub App.
+// If they are set, sheriff authenticates with tokens of the installation instead of a personal access token.
+type AppCredentials struct {
+	AppID          int64
+	InstallationID int64
+	PrivateKey     []byte // PEM encoded private key of the app, as generated by GitHub
+}
+
+// IsSet returns whether the credentials of a GitHub App are given
+func (c AppCredentials) IsSet() bool {
+	return c.AppID != 0
+}
+
+// newTokenSource returns the source of the tokens authenticating the requests to GitHub.
+// With app credentials, the tokens are installation tokens requested through the given client, and renewed once expired.
+// Otherwise the token is used as is, and nil is returned if it is empty.
+func newTokenSource(token string, app AppCredentials, httpClient *http.Client, baseURL string) (oauth2.TokenSource, error) {
+	if !app.IsSet() {
+		if token == "" {
+			return nil, nil
+		}
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}), nil
+	}
+
+	if app.InstallationID == 0 {
+		return nil, errors.New("github app installation id is required with the app id")
+	}
+	key, err := parsePrivateKey(app.PrivateKey)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to parse github app private key"), err)
+	}
+
+	appClient, err := newClient(&http.Client{Transport: &appTransport{base: httpClient.Transport, appID: app.AppID, key: key}}, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return oauth2.ReuseTokenSource(nil, installationTokenSource{apps: appClient.Apps, installationID: app.InstallationID}), nil
+}
+
+// installationTokenSource requests a new installation token of the GitHub App every time a token is needed
+type installationTokenSource struct {
+	apps           *github.AppsService // Authenticated as the app itself, see appTransport
+	installationID int64
+}
+
+func (s installationTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	t, _, err := s.apps.CreateInstallationToken(ctx, s.installationID, nil)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to create token of github app installation %v", s.installationID), err)
+	}
+
+	// The token is minted at runtime, so unknown to the logs configured beforehand
+	log.AddSecret(t.GetToken())
+
+	return &oauth2.Token{AccessToken: t.GetToken(), Expiry: t.GetExpiresAt().Time}, nil
+}
+
+// appTransport authenticates the requests as the GitHub App, with a JSON Web Token signed by its private key
+type appTransport struct {
+	base  http.RoundTripper
+	appID int64
+	key   *rsa.PrivateKey
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := signAppJWT(t.appID, t.key, time.Now())
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to sign github app token"), err)
+	}
+
+	// A RoundTripper must not modify the given request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// signAppJWT returns a JSON Web Token issued by the GitHub App at the given time, signed with RS256 as GitHub requires
+func signAppJWT(appID int64, key *rsa.PrivateKey, issuedAt time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": issuedAt.Add(-appJWTClockDrift).Unix(),
+		"exp": issuedAt.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey parses a PEM encoded RSA private key, in the PKCS #1 format of the keys generated by GitHub or in PKCS #8
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github app private key must be an RSA key")
+	}
+
+	return rsaKey, nil
+}
@@ -0,0 +1,108 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sheriff/internal/compress"
+	"sheriff/internal/log"
+	"sheriff/internal/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServiceWithAppCredentials(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tokensCreated := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v3/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		if !isValidAppJWT(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &key.PublicKey, "7") {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		tokensCreated++
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "ghs_installation", "expires_at": time.Now().Add(time.Hour).Format(time.RFC3339)})
+	})
+	mux.HandleFunc("GET /api/v3/repos/owner/repo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghs_installation" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"name": "repo"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s, err := New("", AppCredentials{AppID: 7, InstallationID: 42, PrivateKey: privateKey}, server.URL, 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+	require.NoError(t, err)
+
+	repo, _, err := s.client.GetRepository("owner", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "repo", repo.GetName())
+
+	// The archive downloads are authenticated with the same installation token
+	token, err := s.tokens.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "ghs_installation", token.AccessToken)
+	assert.Equal(t, 1, tokensCreated, "the installation token should be reused until it expires")
+	assert.Equal(t, "token [REDACTED]", log.Redact("token ghs_installation"), "the installation token should be redacted")
+}
+
+func TestNewServiceWithInvalidAppPrivateKey(t *testing.T) {
+	_, err := New("", AppCredentials{AppID: 7, InstallationID: 42, PrivateKey: []byte("not a key")}, "", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+	assert.ErrorContains(t, err, "failed to parse github app private key")
+}
+
+func TestNewServiceWithAppCredentialsWithoutInstallation(t *testing.T) {
+	_, err := New("", AppCredentials{AppID: 7, PrivateKey: []byte("key")}, "", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+	assert.ErrorContains(t, err, "installation id is required")
+}
+
+func TestNewServiceWithoutToken(t *testing.T) {
+	s, err := New("", AppCredentials{}, "", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+	require.NoError(t, err)
+	assert.Nil(t, s.tokens)
+}
+
+// isValidAppJWT returns whether the token is signed with the key of the app, and issued by it
+func isValidAppJWT(t *testing.T, token string, key *rsa.PublicKey, appID string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+		return false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims struct {
+		Iss string `json:"iss"`
+		Exp int64  `json:"exp"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+
+	return claims.Iss == appID && claims.Exp > time.Now().Unix()
+}
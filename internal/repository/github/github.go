@@ -6,8 +6,12 @@ import (
 	"fmt"
 
 	"net/http"
+	"net/url"
+	"sheriff/internal/cache"
 	"sheriff/internal/compress"
+	"sheriff/internal/ratelimit"
 	"sheriff/internal/repository"
+	"sheriff/internal/retry"
 	"strings"
 	"time"
 
@@ -18,30 +22,92 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// archiveResponseTimeout is the longest wait for the response to a request downloading an archive to start,
+// while the download itself is only bounded by the download timeout
+const archiveResponseTimeout = 30 * time.Second
+
+// Number of attempts, and initial backoff between them, of the requests creating or updating issues
+const (
+	issueRequestAttempts = 3
+	issueRequestBackoff  = time.Second
+)
+
 type githubService struct {
-	client     iGithubClient
-	httpClient *http.Client
-	token      string
+	client          iGithubClient
+	httpClient      *http.Client       // Client downloading the archives, see archiveResponseTimeout
+	tokens          oauth2.TokenSource // Source of the tokens authenticating the requests, nil if anonymous
+	archiveLimits   compress.Limits
+	archiveCache    *cache.ArchiveCache
+	downloadTimeout time.Duration // Deadline of the download of each archive, none if not set
+	pageSize        int           // Number of items per page of the listings, the default of the API if not set
+	filter          repository.ProjectFilter
+	issueAttempts   int           // Attempts of the requests creating or updating issues, once if not set
+	issueBackoff    time.Duration // Initial backoff between the attempts of the requests creating or updating issues
 }
 
 // newGithubRepo creates a new GitHub repository service
-func New(token string) githubService {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(context.Background(), ts)
-	client := github.NewClient(tc)
-	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
+// The baseURL points to a GitHub Enterprise Server instance, github.com is used if empty.
+// API requests are throttled to apiRateLimit per second, and retried when rate limited (see ratelimit.NewTransport).
+// Listings are fetched in pages of pageSize items, up to repository.MaxPageSize, which is used if 0.
+// Downloaded archives are extracted within the given limits, and kept in the archive cache if it is not nil.
+// Each download is aborted after downloadTimeout, 0 disabling the deadline.
+// Archived and forked repositories of the owners are only listed if the filter includes them.
+// If the app credentials are set, the requests are authenticated as the GitHub App installation instead of with the token.
+func New(token string, app AppCredentials, baseURL string, apiRateLimit float64, pageSize int, downloadTimeout time.Duration, archiveLimits compress.Limits, archiveCache *cache.ArchiveCache, filter repository.ProjectFilter) (githubService, error) {
+	if pageSize < 0 || pageSize > repository.MaxPageSize {
+		return githubService{}, fmt.Errorf("invalid page size %v, must be between 1 and %v", pageSize, repository.MaxPageSize)
+	} else if pageSize == 0 {
+		pageSize = repository.MaxPageSize
 	}
 
+	rateLimitedClient := &http.Client{Transport: ratelimit.NewTransport(nil, apiRateLimit)}
+	ts, err := newTokenSource(token, app, rateLimitedClient, baseURL)
+	if err != nil {
+		return githubService{}, err
+	}
+	// The oauth2 client sends the requests through the rate limited client
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, rateLimitedClient)
+	client, err := newClient(oauth2.NewClient(ctx, ts), baseURL)
+	if err != nil {
+		return githubService{}, err
+	}
+	archiveTransport := http.DefaultTransport.(*http.Transport).Clone()
+	archiveTransport.ResponseHeaderTimeout = archiveResponseTimeout
+	httpClient := &http.Client{Transport: archiveTransport}
+
 	s := githubService{
-		client:     &githubClient{client: client},
-		httpClient: httpClient,
-		token:      token,
+		client:          &githubClient{client: client},
+		httpClient:      httpClient,
+		tokens:          ts,
+		archiveLimits:   archiveLimits,
+		archiveCache:    archiveCache,
+		downloadTimeout: downloadTimeout,
+		pageSize:        pageSize,
+		filter:          filter,
+		issueAttempts:   issueRequestAttempts,
+		issueBackoff:    issueRequestBackoff,
+	}
+
+	return s, nil
+}
+
+// newClient creates a GitHub client sending its requests through the given http client.
+// The baseURL points to a GitHub Enterprise Server instance, github.com is used if empty.
+func newClient(httpClient *http.Client, baseURL string) (*github.Client, error) {
+	client := github.NewClient(httpClient)
+	if baseURL == "" {
+		return client, nil
 	}
 
-	return s
+	if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid github url %v, must be an absolute http(s) url", baseURL)
+	}
+	client, err := client.WithEnterpriseURLs(baseURL, baseURL)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to configure github enterprise urls"), err)
+	}
+
+	return client, nil
 }
 
 func (s githubService) GetProjectList(paths []string) (projects []repository.Project, warn error) {
@@ -51,7 +117,13 @@ func (s githubService) GetProjectList(paths []string) (projects []repository.Pro
 		g.Go(func() error {
 			repos, err := s.getPathRepos(path)
 			reposChan <- repos
-			if err != nil {
+			if errors.Is(err, repository.ErrPathNotFound) {
+				log.Warn().Str("path", path).Msg("Path not found, neither as an owner nor as a repository, skipping it")
+				return nil
+			} else if errors.Is(err, repository.ErrPathEmpty) {
+				log.Warn().Str("path", path).Msg("Owner has no repositories to scan, skipping it")
+				return nil
+			} else if err != nil {
 				return err
 			}
 
@@ -68,14 +140,16 @@ func (s githubService) GetProjectList(paths []string) (projects []repository.Pro
 		allRepos = append(allRepos, repos...)
 	}
 
-	projects = pie.Map(allRepos, mapGithubProject)
+	projects = filterUniqueProjects(pie.Map(allRepos, mapGithubProject))
 
 	return
 }
 
-// CloseVulnerabilityIssue closes the vulnerability issue for the given project
-func (s githubService) CloseVulnerabilityIssue(project repository.Project) (err error) {
-	issue, err := s.getVulnerabilityIssue(project.GroupOrOwner, project.Name)
+// CloseVulnerabilityIssue closes the vulnerability issue with the given title for the given project,
+// together with its open duplicates if any.
+// If comment is not empty, it is posted on the issue before closing it.
+func (s githubService) CloseVulnerabilityIssue(project repository.Project, title string, comment string) (err error) {
+	issue, duplicates, err := s.getVulnerabilityIssue(project.GroupOrOwner, project.Name, title)
 	if err != nil {
 		return fmt.Errorf("failed to fetch current list of issues: %w", err)
 	}
@@ -87,31 +161,66 @@ func (s githubService) CloseVulnerabilityIssue(project repository.Project) (err
 		log.Info().Str("project", project.Path).Msg("Issue already closed")
 		return nil
 	}
+	for _, i := range append([]*github.Issue{issue}, duplicates...) {
+		if err := s.closeIssue(project, i.GetNumber(), comment); err != nil {
+			return err
+		}
+	}
+	log.Info().Str("project", project.Path).Msg("Issue closed")
+	return nil
+}
+
+// closeIssue closes the issue with the given number, posting the comment on it first if it is not empty
+func (s githubService) closeIssue(project repository.Project, number int, comment string) error {
+	if comment != "" {
+		if _, _, err := s.client.CreateComment(project.GroupOrOwner, project.Name, number, &github.IssueComment{Body: &comment}); err != nil {
+			log.Warn().Err(err).Str("project", project.Path).Int("issue", number).Msg("Failed to comment on issue, closing it anyway")
+		}
+	}
 	state := "closed"
-	_, _, err = s.client.UpdateIssue(project.GroupOrOwner, project.Name, issue.GetNumber(), &github.IssueRequest{
-		State: &state,
+	err := s.retryIssueRequest(func() (err error) {
+		_, _, err = s.client.UpdateIssue(project.GroupOrOwner, project.Name, number, &github.IssueRequest{
+			State: &state,
+		})
+		return
 	})
 	if err != nil {
 		return fmt.Errorf("failed to update issue: %w", err)
 	}
-	log.Info().Str("project", project.Path).Msg("Issue closed")
 	return nil
 }
 
-// OpenVulnerabilityIssue opens or updates the vulnerability issue for the given project
-func (s githubService) OpenVulnerabilityIssue(project repository.Project, report string) (issue *repository.Issue, err error) {
-	vulnTitle := repository.VulnerabilityIssueTitle
-	ghIssue, err := s.getVulnerabilityIssue(project.GroupOrOwner, project.Name)
+// closeDuplicateIssues closes the duplicates of the vulnerability issue, with a comment pointing to it.
+// Failures are only logged, as the issue itself is up to date.
+func (s githubService) closeDuplicateIssues(project repository.Project, issue *github.Issue, duplicates []*github.Issue) {
+	comment := fmt.Sprintf("Closed as a duplicate of #%d, which sheriff keeps up to date.", issue.GetNumber())
+	for _, d := range duplicates {
+		if err := s.closeIssue(project, d.GetNumber(), comment); err != nil {
+			log.Warn().Err(err).Str("project", project.Path).Int("issue", d.GetNumber()).Msg("Failed to close duplicate issue")
+		} else {
+			log.Info().Str("project", project.Path).Int("issue", d.GetNumber()).Int("duplicateOf", issue.GetNumber()).Msg("Closed duplicate issue")
+		}
+	}
+}
+
+// OpenVulnerabilityIssue opens or updates the vulnerability issue with the given title for the given project
+func (s githubService) OpenVulnerabilityIssue(project repository.Project, title string, report string, assignees []string) (issue *repository.Issue, err error) {
+	ghIssue, duplicates, err := s.getVulnerabilityIssue(project.GroupOrOwner, project.Name, title)
 	if err != nil {
 		return nil, fmt.Errorf("[%v] Failed to fetch current list of issues: %w", project.Path, err)
 	}
 	if ghIssue == nil {
 		log.Info().Str("project", project.Path).Msg("Creating new issue")
 		newIssue := &github.IssueRequest{
-			Title: &vulnTitle,
-			Body:  &report,
+			Title:     &title,
+			Body:      &report,
+			Assignees: s.getValidAssignees(project, assignees),
 		}
-		created, _, err := s.client.CreateIssue(project.GroupOrOwner, project.Name, newIssue)
+		var created *github.Issue
+		err := s.retryIssueRequest(func() (err error) {
+			created, _, err = s.client.CreateIssue(project.GroupOrOwner, project.Name, newIssue)
+			return
+		})
 		if err != nil {
 			return nil, fmt.Errorf("[%v] failed to create new issue: %w", project.Path, err)
 		}
@@ -123,31 +232,117 @@ func (s githubService) OpenVulnerabilityIssue(project repository.Project, report
 		Body:  &report,
 		State: &state,
 	}
-	edited, _, err := s.client.UpdateIssue(project.GroupOrOwner, project.Name, ghIssue.GetNumber(), updatedIssue)
+	var edited *github.Issue
+	err = s.retryIssueRequest(func() (err error) {
+		edited, _, err = s.client.UpdateIssue(project.GroupOrOwner, project.Name, ghIssue.GetNumber(), updatedIssue)
+		return
+	})
 	if err != nil {
 		return nil, fmt.Errorf("[%v] Failed to update issue: %w", project.Path, err)
 	}
 	if edited.GetState() != "open" {
 		return nil, errors.New("failed to reopen issue")
 	}
-	return mapGithubIssuePtr(edited), nil
+	issue = mapGithubIssuePtr(edited)
+	issue.PreviousBody = ghIssue.GetBody()
+	s.closeDuplicateIssues(project, ghIssue, duplicates)
+	return issue, nil
+}
+
+// retryIssueRequest sends a request creating or updating an issue, retrying it with backoff
+// as long as it fails with a transient error, see isTransientError
+func (s githubService) retryIssueRequest(request func() error) error {
+	return retry.DoIf(context.Background(), request, isTransientError, s.issueAttempts, s.issueBackoff)
 }
 
-// getVulnerabilityIssue returns the vulnerability issue for the given repo (by title)
-func (s githubService) getVulnerabilityIssue(owner, repo string) (*github.Issue, error) {
+// isTransientError returns whether the request may succeed if sent again, which is the case of network errors
+// and of the 5xx responses of the GitHub API, but not of the 4xx responses which reject the request itself
+func isTransientError(err error) bool {
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return true
+	}
+
+	return errResp.Response.StatusCode >= http.StatusInternalServerError
+}
+
+// isNotFoundError returns whether the error is a 404 response of the GitHub API
+func isNotFoundError(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// markRejectedError returns the error of a 4xx response of the GitHub API as a repository.RejectedError,
+// so that it is not retried
+func markRejectedError(err error) error {
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return err
+	}
+
+	return repository.NewRejectedError(errResp.Response.StatusCode, err)
+}
+
+// getValidAssignees returns the given usernames which can be assigned to issues of the project
+// Usernames which cannot be assigned are skipped with a warning, so that the issue is still created.
+func (s githubService) getValidAssignees(project repository.Project, usernames []string) *[]string {
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	valid := make([]string, 0, len(usernames))
+	for _, username := range usernames {
+		ok, _, err := s.client.IsAssignee(project.GroupOrOwner, project.Name, username)
+		if err != nil {
+			log.Warn().Err(err).Str("project", project.Path).Str("username", username).Msg("Failed to check issue assignee, skipping")
+			continue
+		}
+
+		if !ok {
+			log.Warn().Str("project", project.Path).Str("username", username).Msg("User cannot be assigned to issues of this project, skipping")
+			continue
+		}
+
+		valid = append(valid, username)
+	}
+
+	return &valid
+}
+
+// getVulnerabilityIssue returns the vulnerability issue for the given repo (by title),
+// and the other open issues with that title, which duplicate it.
+// Several issues are open if e.g. two runs raced to create it, in which case the oldest one is kept.
+// Otherwise, it is the most recent of the closed issues.
+func (s githubService) getVulnerabilityIssue(owner, repo, title string) (issue *github.Issue, duplicates []*github.Issue, err error) {
+	issues, err := s.getVulnerabilityIssues(owner, repo, title)
+	if err != nil || len(issues) == 0 {
+		return nil, nil, err
+	}
+
+	open := pie.Filter(issues, func(i *github.Issue) bool { return i.GetState() == "open" })
+	if len(open) == 0 {
+		return issues[0], nil, nil
+	}
+
+	open = pie.SortUsing(open, func(a, b *github.Issue) bool { return a.GetNumber() < b.GetNumber() })
+
+	return open[0], open[1:], nil
+}
+
+// getVulnerabilityIssues returns the issues of the given repo with exactly the given title, from the most recent
+func (s githubService) getVulnerabilityIssues(owner, repo, title string) (matches []*github.Issue, err error) {
 	opts := &github.IssueListByRepoOptions{
 		State:       "all",
-		ListOptions: github.ListOptions{PerPage: 100},
+		ListOptions: github.ListOptions{PerPage: s.pageSize},
 	}
-	vulnTitle := repository.VulnerabilityIssueTitle
 	for {
 		issues, resp, err := s.client.ListRepositoryIssues(owner, repo, opts)
 		if err != nil {
 			return nil, err
 		}
 		for _, issue := range issues {
-			if issue != nil && issue.GetTitle() == vulnTitle {
-				return issue, nil
+			if issue != nil && issue.GetTitle() == title {
+				matches = append(matches, issue)
 			}
 		}
 		if resp.NextPage == 0 {
@@ -155,7 +350,7 @@ func (s githubService) getVulnerabilityIssue(owner, repo string) (*github.Issue,
 		}
 		opts.Page = resp.NextPage
 	}
-	return nil, nil
+	return matches, nil
 }
 func mapGithubIssue(i github.Issue) repository.Issue {
 	return repository.Issue{
@@ -172,38 +367,184 @@ func mapGithubIssuePtr(i *github.Issue) *repository.Issue {
 	return &issue
 }
 
-func (s githubService) Download(project repository.Project, dir string) (err error) {
+// Download downloads and extracts the archive of the repository's scanned branch (see Project.ScannedRef) into the given directory,
+// pinned to the latest commit of the branch whenever it is known.
+// If the archive cache is enabled, the archive is only downloaded if the repository changed since it was cached.
+func (s githubService) Download(ctx context.Context, project repository.Project, dir string) (sha string, err error) {
+	defer func() { err = markRejectedError(err) }()
+
+	sha, shaErr := s.GetLatestCommitSha(project)
+	if shaErr != nil && project.Ref != "" {
+		// Downloading without pinning the commit would download the default branch instead
+		return "", shaErr
+	} else if shaErr != nil {
+		log.Warn().Err(shaErr).Str("project", project.Path).Msg("Failed to get latest commit of project, downloading it without pinning the commit")
+	}
+	if archive, ok := s.archiveCache.Open(cache.ProjectKey(project), sha); ok {
+		defer archive.Close()
+		log.Info().Str("project", project.Path).Str("sha", sha).Msg("Project unchanged since last download, using cached archive")
+		return sha, compress.ExtractTarGz(archive, dir, s.archiveLimits)
+	}
+
 	// Get archive download URL using GitHub API
-	archiveURL, _, err := s.client.GetArchiveLink(project.GroupOrOwner, project.Name, github.Tarball, &github.RepositoryContentGetOptions{})
+	archiveURL, _, err := s.client.GetArchiveLink(project.GroupOrOwner, project.Name, github.Tarball, &github.RepositoryContentGetOptions{Ref: sha})
 	if err != nil {
-		return fmt.Errorf("failed to get GitHub archive link: %w", err)
+		return "", fmt.Errorf("failed to get GitHub archive link: %w", err)
 	}
 
 	log.Debug().Str("archiveURL", archiveURL.String()).Msg("Got GitHub archive URL")
 
-	// Create request with context
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	// The deadline covers the whole download, which is streamed into the extraction below
+	if s.downloadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.downloadTimeout)
+		defer cancel()
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", archiveURL.String(), nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	// The archive of private repositories can only be downloaded with the token
+	if s.tokens != nil {
+		token, err := s.tokens.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to get GitHub token: %w", err)
+		}
+		token.SetAuthHeader(req)
 	}
 
 	// Download the archive from the URL using the shared HTTP client
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to download GitHub archive: %w", err)
+		return "", fmt.Errorf("failed to download GitHub archive: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download GitHub archive, status: %s", resp.Status)
+		return "", repository.NewRejectedError(resp.StatusCode, fmt.Errorf("failed to download GitHub archive, status: %s", resp.Status))
+	}
+
+	if s.archiveCache == nil || sha == "" {
+		return sha, compress.ExtractTarGz(resp.Body, dir, s.archiveLimits)
+	}
+
+	// The archive is streamed, so it is extracted from the cache once stored
+	if err := s.archiveCache.Store(cache.ProjectKey(project), sha, resp.Body); err != nil {
+		return "", errors.Join(errors.New("failed to cache GitHub archive"), err)
+	}
+	archive, ok := s.archiveCache.Open(cache.ProjectKey(project), sha)
+	if !ok {
+		return "", errors.New("failed to open cached GitHub archive")
+	}
+	defer archive.Close()
+
+	return sha, compress.ExtractTarGz(archive, dir, s.archiveLimits)
+}
+
+// GetLatestCommitSha returns the sha of the latest commit of the repository's scanned branch,
+// which is the one downloaded and under which its archive is cached.
+func (s githubService) GetLatestCommitSha(project repository.Project) (string, error) {
+	ref := project.ScannedRef()
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	sha, _, err := s.client.GetCommitSHA1(project.GroupOrOwner, project.Name, ref)
+	if err != nil {
+		return "", errors.Join(errors.New("failed to get latest commit"), err)
+	}
+
+	return sha, nil
+}
+
+func (s githubService) GetFile(project repository.Project, path string) (content []byte, found bool, err error) {
+	file, err := s.getFile(project, path, project.DefaultBranch)
+	if err != nil || file == nil {
+		return nil, false, err
+	}
+
+	decoded, err := file.GetContent()
+	if err != nil {
+		return nil, false, errors.Join(fmt.Errorf("failed to decode file %v", path), err)
+	}
+
+	return []byte(decoded), true, nil
+}
+
+func (s githubService) CreateBranch(project repository.Project, branch string) error {
+	sha, err := s.GetLatestCommitSha(project)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := s.client.CreateRef(project.GroupOrOwner, project.Name, &github.Reference{
+		Ref:    github.Ptr("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: &sha},
+	}); err != nil {
+		return errors.Join(fmt.Errorf("failed to create branch %v", branch), err)
+	}
+
+	return nil
+}
+
+func (s githubService) CommitFile(project repository.Project, branch string, path string, content []byte, message string) error {
+	existing, err := s.getFile(project, path, branch)
+	if err != nil {
+		return err
+	}
+
+	opts := &github.RepositoryContentFileOptions{
+		Message: &message,
+		Content: content,
+		Branch:  &branch,
+	}
+	if existing != nil {
+		// Updating a file requires the sha of the blob it replaces
+		opts.SHA = existing.SHA
+	}
+	if _, _, err := s.client.CreateFile(project.GroupOrOwner, project.Name, path, opts); err != nil {
+		return errors.Join(fmt.Errorf("failed to commit file %v", path), err)
+	}
+
+	return nil
+}
+
+func (s githubService) OpenMergeRequest(project repository.Project, branch string, title string, description string) (string, error) {
+	pr, _, err := s.client.CreatePullRequest(project.GroupOrOwner, project.Name, &github.NewPullRequest{
+		Title: &title,
+		Body:  &description,
+		Head:  &branch,
+		Base:  &project.DefaultBranch,
+	})
+	if err != nil {
+		return "", errors.Join(errors.New("failed to open pull request"), err)
 	}
 
-	return compress.ExtractTarGz(resp.Body, dir)
+	return pr.GetHTMLURL(), nil
+}
+
+// UploadIssueAttachment is not supported, as the GitHub API cannot upload files to be linked from issues
+func (s githubService) UploadIssueAttachment(project repository.Project, filename string, content []byte) (string, error) {
+	return "", fmt.Errorf("uploading %v to %v: %w", filename, project.Path, repository.ErrNotSupported)
 }
 
+// getFile returns the file at the given path of the ref, or nil if there is no such file
+func (s githubService) getFile(project repository.Project, path string, ref string) (*github.RepositoryContent, error) {
+	file, _, err := s.client.GetContents(project.GroupOrOwner, project.Name, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if isNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to get file %v", path), err)
+	} else if file == nil {
+		return nil, fmt.Errorf("%v is a directory", path)
+	}
+
+	return file, nil
+}
+
+// getPathRepos returns the repositories of the path, which is either an owner or a repository.
+// Paths which do not exist return repository.ErrPathNotFound, and owners without repositories repository.ErrPathEmpty.
 func (s githubService) getPathRepos(path string) (repositories []github.Repository, err error) {
 	parts := strings.Split(path, "/")
 
@@ -211,7 +552,9 @@ func (s githubService) getPathRepos(path string) (repositories []github.Reposito
 		return s.getOwnerRepos(parts[0])
 	} else if len(parts) == 2 {
 		repo, err := s.getOwnerRepository(parts[0], parts[1])
-		if err != nil {
+		if isNotFoundError(err) {
+			return nil, errors.Join(fmt.Errorf("repository %s", path), repository.ErrPathNotFound)
+		} else if err != nil {
 			return nil, errors.Join(fmt.Errorf("failed to get repository %s", path), err)
 		} else if repo == nil {
 			return nil, errors.New("repository unexpectedly nil")
@@ -229,18 +572,38 @@ func (s githubService) getOwnerRepos(owner string) (repos []github.Repository, e
 	if err != nil {
 		// Try again as `user`
 		repoPtrs, err = s.getUserRepos(owner)
-		if err != nil {
+		if isNotFoundError(err) {
+			return nil, errors.Join(fmt.Errorf("owner %v", owner), repository.ErrPathNotFound)
+		} else if err != nil {
 			return nil, errors.Join(fmt.Errorf("could not fetch repos for owner %v", owner), err)
 		}
 	}
 
-	repos = derefRepoPtrs(owner, repoPtrs)
+	repos = pie.Filter(derefRepoPtrs(owner, repoPtrs), s.isListed)
+	if len(repos) == 0 {
+		return nil, errors.Join(fmt.Errorf("owner %v", owner), repository.ErrPathEmpty)
+	}
 
 	return
 }
 
+// isListed returns whether a repository of an owner is listed, which excludes archived and forked repositories
+// unless the filter includes them
+func (s githubService) isListed(r github.Repository) bool {
+	if r.GetArchived() && !s.filter.IncludeArchived {
+		log.Debug().Str("repository", r.GetFullName()).Msg("Skipping archived repository")
+		return false
+	}
+	if r.GetFork() && !s.filter.IncludeForks {
+		log.Debug().Str("repository", r.GetFullName()).Msg("Skipping forked repository")
+		return false
+	}
+
+	return true
+}
+
 func (s githubService) getOrganizationRepos(org string) (repos []*github.Repository, err error) {
-	repos, err = getGithubPaginatedResults(func(listOpts github.ListOptions) ([]*github.Repository, *github.Response, error) {
+	repos, err = getGithubPaginatedResults(s.pageSize, func(listOpts github.ListOptions) ([]*github.Repository, *github.Response, error) {
 		opts := &github.RepositoryListByOrgOptions{
 			ListOptions: listOpts,
 		}
@@ -251,7 +614,7 @@ func (s githubService) getOrganizationRepos(org string) (repos []*github.Reposit
 }
 
 func (s githubService) getUserRepos(user string) (repos []*github.Repository, err error) {
-	repos, err = getGithubPaginatedResults(func(listOpts github.ListOptions) ([]*github.Repository, *github.Response, error) {
+	repos, err = getGithubPaginatedResults(s.pageSize, func(listOpts github.ListOptions) ([]*github.Repository, *github.Response, error) {
 		opts := &github.RepositoryListByUserOptions{
 			Type:        "owner",
 			ListOptions: listOpts,
@@ -262,9 +625,10 @@ func (s githubService) getUserRepos(user string) (repos []*github.Repository, er
 	return
 }
 
-func getGithubPaginatedResults[T interface{}](paginatedFunc func(github.ListOptions) ([]T, *github.Response, error)) (results []T, err error) {
+// getGithubPaginatedResults returns the results of all the pages of the listing, fetched with pages of perPage items
+func getGithubPaginatedResults[T interface{}](perPage int, paginatedFunc func(github.ListOptions) ([]T, *github.Response, error)) (results []T, err error) {
 	opts := github.ListOptions{
-		PerPage: 100,
+		PerPage: perPage,
 		Page:    1,
 	}
 	for {
@@ -310,6 +674,20 @@ func derefRepoPtrs(owner string, repoPtrs []*github.Repository) (repos []github.
 	return
 }
 
+// filterUniqueProjects removes the projects which appear more than once, e.g. when given both an organization and one of its repositories
+func filterUniqueProjects(projects []repository.Project) (filteredProjects []repository.Project) {
+	projectIds := make(map[int]bool)
+
+	for _, project := range projects {
+		if _, ok := projectIds[project.ID]; !ok {
+			projectIds[project.ID] = true
+			filteredProjects = append(filteredProjects, project)
+		}
+	}
+
+	return
+}
+
 func mapGithubProject(r github.Repository) repository.Project {
 	var groupName = ""
 	owner := r.GetOwner()
@@ -318,17 +696,33 @@ func mapGithubProject(r github.Repository) repository.Project {
 	}
 
 	return repository.Project{
-		ID:           int(valueOrEmpty(r.ID)),
-		Name:         valueOrEmpty(r.Name),
-		GroupOrOwner: valueOrEmpty(&groupName),
-		Path:         valueOrEmpty(r.FullName),
-		Slug:         valueOrEmpty(r.Name),
-		WebURL:       valueOrEmpty(r.HTMLURL),
-		RepoUrl:      valueOrEmpty(r.HTMLURL),
-		Repository:   repository.Github,
+		ID:            int(valueOrEmpty(r.ID)),
+		Name:          valueOrEmpty(r.Name),
+		GroupOrOwner:  valueOrEmpty(&groupName),
+		Path:          valueOrEmpty(r.FullName),
+		DefaultBranch: valueOrEmpty(r.DefaultBranch),
+		Slug:          valueOrEmpty(r.Name),
+		WebURL:        valueOrEmpty(r.HTMLURL),
+		RepoUrl:       valueOrEmpty(r.HTMLURL),
+		Repository:    repository.Github,
+		Visibility:    getVisibility(r),
 	}
 }
 
+// getVisibility returns the visibility of the repository, which is only told apart from private
+// when the API returns it (e.g. internal repositories of GitHub Enterprise)
+func getVisibility(r github.Repository) repository.Visibility {
+	if v := r.GetVisibility(); v != "" {
+		return repository.Visibility(v)
+	} else if r.Private == nil {
+		return ""
+	} else if r.GetPrivate() {
+		return repository.Private
+	}
+
+	return repository.Public
+}
+
 func valueOrEmpty[T interface{}](val *T) (r T) {
 	if val != nil {
 		return *val
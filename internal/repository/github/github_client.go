@@ -22,6 +22,13 @@ type iGithubClient interface {
 	ListRepositoryIssues(owner string, repo string, opts *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error)
 	CreateIssue(owner string, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
 	UpdateIssue(owner string, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
+	CreateComment(owner string, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error)
+	IsAssignee(owner string, repo string, user string) (bool, *github.Response, error)
+	GetCommitSHA1(owner string, repo string, ref string) (string, *github.Response, error)
+	GetContents(owner string, repo string, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, *github.Response, error)
+	CreateFile(owner string, repo string, path string, opts *github.RepositoryContentFileOptions) (*github.RepositoryContentResponse, *github.Response, error)
+	CreateRef(owner string, repo string, ref *github.Reference) (*github.Reference, *github.Response, error)
+	CreatePullRequest(owner string, repo string, pull *github.NewPullRequest) (*github.PullRequest, *github.Response, error)
 }
 
 type githubClient struct {
@@ -45,6 +52,19 @@ func (c *githubClient) UpdateIssue(owner, repo string, number int, issue *github
 	defer cancel()
 	return c.client.Issues.Edit(ctx, owner, repo, number, issue)
 }
+
+func (c *githubClient) CreateComment(owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.client.Issues.CreateComment(ctx, owner, repo, number, comment)
+}
+
+func (c *githubClient) IsAssignee(owner, repo, user string) (bool, *github.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.client.Issues.IsAssignee(ctx, owner, repo, user)
+}
+
 func (c *githubClient) GetRepository(owner string, repo string) (*github.Repository, *github.Response, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
@@ -68,3 +88,34 @@ func (c *githubClient) GetArchiveLink(owner string, repo string, archiveFormat g
 	defer cancel()
 	return c.client.Repositories.GetArchiveLink(ctx, owner, repo, archiveFormat, opts, 3)
 }
+
+func (c *githubClient) GetCommitSHA1(owner string, repo string, ref string) (string, *github.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.client.Repositories.GetCommitSHA1(ctx, owner, repo, ref, "")
+}
+
+func (c *githubClient) GetContents(owner string, repo string, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, *github.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	file, _, resp, err := c.client.Repositories.GetContents(ctx, owner, repo, path, opts)
+	return file, resp, err
+}
+
+func (c *githubClient) CreateFile(owner string, repo string, path string, opts *github.RepositoryContentFileOptions) (*github.RepositoryContentResponse, *github.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.client.Repositories.CreateFile(ctx, owner, repo, path, opts)
+}
+
+func (c *githubClient) CreateRef(owner string, repo string, ref *github.Reference) (*github.Reference, *github.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.client.Git.CreateRef(ctx, owner, repo, ref)
+}
+
+func (c *githubClient) CreatePullRequest(owner string, repo string, pull *github.NewPullRequest) (*github.PullRequest, *github.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.client.PullRequests.Create(ctx, owner, repo, pull)
+}
@@ -1,22 +1,80 @@
 package github
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sheriff/internal/cache"
+	"sheriff/internal/compress"
 	"sheriff/internal/repository"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/elliotchance/pie/v2"
 	"github.com/google/go-github/v68/github"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
 )
 
+func TestNewService(t *testing.T) {
+	s, err := New("token", AppCredentials{}, "", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://api.github.com/", s.client.(*githubClient).client.BaseURL.String())
+}
+
+func TestNewServiceWithEnterpriseURL(t *testing.T) {
+	s, err := New("token", AppCredentials{}, "https://github.example.com", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://github.example.com/api/v3/", s.client.(*githubClient).client.BaseURL.String())
+	assert.Equal(t, "https://github.example.com/api/uploads/", s.client.(*githubClient).client.UploadURL.String())
+}
+
+func TestNewServiceWithInvalidEnterpriseURL(t *testing.T) {
+	_, err := New("token", AppCredentials{}, "github.example.com", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+	assert.NotNil(t, err)
+}
+
+func TestNewServiceWithInvalidPageSize(t *testing.T) {
+	for _, pageSize := range []int{-1, 101} {
+		_, err := New("token", AppCredentials{}, "", 0, pageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+		assert.ErrorContains(t, err, fmt.Sprintf("invalid page size %v, must be between 1 and 100", pageSize))
+	}
+}
+
+func TestGetProjectListUsesPageSize(t *testing.T) {
+	mockService := mockService{}
+	mockService.On("GetOrganizationRepositories", "user", mock.Anything).Return([]*github.Repository{}, &github.Response{}, errors.New("error"))
+	mockService.On("GetUserRepositories", "user", mock.MatchedBy(func(opts *github.RepositoryListByUserOptions) bool {
+		return opts.PerPage == 20
+	})).Return([]*github.Repository{{Name: github.Ptr("Hello World")}}, &github.Response{}, nil)
+	mockService.On("ListRepositoryIssues", "user", "repo", mock.MatchedBy(func(opts *github.IssueListByRepoOptions) bool {
+		return opts.PerPage == 20
+	})).Return([]*github.Issue{}, &github.Response{}, nil)
+
+	svc := githubService{client: &mockService, pageSize: 20}
+
+	projects, err := svc.GetProjectList([]string{"user"})
+	require.Nil(t, err)
+	assert.Len(t, projects, 1)
+	_, err = svc.getVulnerabilityIssues("user", "repo", "title")
+	require.NoError(t, err)
+
+	mockService.AssertExpectations(t)
+}
+
 func TestGetProjectListOrganizationRepos(t *testing.T) {
 	mockService := mockService{}
 	mockService.On("GetOrganizationRepositories", "org", mock.Anything).Return([]*github.Repository{{Name: github.Ptr("Hello World")}}, &github.Response{}, nil)
@@ -36,6 +94,66 @@ func TestGetProjectListOrganizationRepos(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestMapGithubProjectVisibility(t *testing.T) {
+	testCases := []struct {
+		repo github.Repository
+		want repository.Visibility
+	}{
+		{github.Repository{Visibility: github.Ptr("internal"), Private: github.Ptr(true)}, repository.Internal},
+		{github.Repository{Private: github.Ptr(true)}, repository.Private},
+		{github.Repository{Private: github.Ptr(false)}, repository.Public},
+		{github.Repository{}, ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, mapGithubProject(tc.repo).Visibility)
+	}
+}
+
+func TestGetProjectListSkipsArchivedAndForkedRepos(t *testing.T) {
+	repos := []*github.Repository{
+		{ID: github.Ptr(int64(1)), FullName: github.Ptr("org/active")},
+		{ID: github.Ptr(int64(2)), FullName: github.Ptr("org/archived"), Archived: github.Ptr(true)},
+		{ID: github.Ptr(int64(3)), FullName: github.Ptr("org/fork"), Fork: github.Ptr(true)},
+	}
+
+	testCases := map[string]struct {
+		filter repository.ProjectFilter
+		want   []string
+	}{
+		"default":          {repository.ProjectFilter{}, []string{"org/active"}},
+		"include archived": {repository.ProjectFilter{IncludeArchived: true}, []string{"org/active", "org/archived"}},
+		"include forks":    {repository.ProjectFilter{IncludeForks: true}, []string{"org/active", "org/fork"}},
+		"include both":     {repository.ProjectFilter{IncludeArchived: true, IncludeForks: true}, []string{"org/active", "org/archived", "org/fork"}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mockService := mockService{}
+			mockService.On("GetOrganizationRepositories", "org", mock.Anything).Return(repos, &github.Response{}, nil)
+
+			svc := githubService{client: &mockService, filter: tc.filter}
+
+			projects, err := svc.GetProjectList([]string{"org"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, pie.Map(projects, func(p repository.Project) string { return p.Path }))
+		})
+	}
+}
+
+func TestGetProjectSpecificArchivedRepo(t *testing.T) {
+	mockService := mockService{}
+	mockService.On("GetRepository", "owner", "repo").Return(&github.Repository{FullName: github.Ptr("owner/repo"), Archived: github.Ptr(true)}, &github.Response{}, nil)
+
+	svc := githubService{client: &mockService}
+
+	projects, err := svc.GetProjectList([]string{"owner/repo"})
+
+	assert.Nil(t, err)
+	assert.Len(t, projects, 1, "a repository targeted by its own path is always listed")
+}
+
 func TestGetProjectListUserRepos(t *testing.T) {
 	mockService := mockService{}
 	mockService.On("GetOrganizationRepositories", "user", mock.Anything).Return([]*github.Repository{}, &github.Response{}, errors.New("error"))
@@ -75,6 +193,80 @@ func TestGetProjectSpecificRepo(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestGetProjectListSkipsNotFoundAndEmptyPaths(t *testing.T) {
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	mockService := mockService{}
+	mockService.On("GetOrganizationRepositories", "org", mock.Anything).Return([]*github.Repository{{Name: github.Ptr("Hello World")}}, &github.Response{}, nil)
+	mockService.On("GetOrganizationRepositories", "empty", mock.Anything).Return([]*github.Repository{}, &github.Response{}, nil)
+	mockService.On("GetOrganizationRepositories", "missing", mock.Anything).Return([]*github.Repository(nil), (*github.Response)(nil), notFound)
+	mockService.On("GetUserRepositories", "missing", mock.Anything).Return([]*github.Repository(nil), (*github.Response)(nil), notFound)
+	mockService.On("GetRepository", "org", "missing").Return((*github.Repository)(nil), (*github.Response)(nil), notFound)
+
+	svc := githubService{client: &mockService}
+
+	projects, warn := svc.GetProjectList([]string{"org", "empty", "missing", "org/missing"})
+
+	assert.Nil(t, warn)
+	require.Len(t, projects, 1)
+	assert.Equal(t, "Hello World", projects[0].Name)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetPathReposNotFoundOrEmpty(t *testing.T) {
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	mockService := mockService{}
+	mockService.On("GetOrganizationRepositories", "empty", mock.Anything).Return([]*github.Repository{{Name: github.Ptr("archived"), Archived: github.Ptr(true)}}, &github.Response{}, nil)
+	mockService.On("GetOrganizationRepositories", "missing", mock.Anything).Return([]*github.Repository(nil), (*github.Response)(nil), notFound)
+	mockService.On("GetUserRepositories", "missing", mock.Anything).Return([]*github.Repository(nil), (*github.Response)(nil), notFound)
+	mockService.On("GetRepository", "owner", "missing").Return((*github.Repository)(nil), (*github.Response)(nil), notFound)
+	mockService.On("GetRepository", "owner", "broken").Return((*github.Repository)(nil), (*github.Response)(nil), errors.New("timeout"))
+
+	svc := githubService{client: &mockService}
+
+	testCases := []struct {
+		path string
+		want error
+	}{
+		{"empty", repository.ErrPathEmpty},
+		{"missing", repository.ErrPathNotFound},
+		{"owner/missing", repository.ErrPathNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			_, err := svc.getPathRepos(tc.path)
+
+			assert.ErrorIs(t, err, tc.want)
+		})
+	}
+
+	_, err := svc.getPathRepos("owner/broken")
+	assert.ErrorContains(t, err, "failed to get repository owner/broken")
+	assert.NotErrorIs(t, err, repository.ErrPathNotFound)
+}
+
+func TestGetProjectListWithOverlappingPaths(t *testing.T) {
+	repo1 := &github.Repository{ID: github.Ptr(int64(1)), Name: github.Ptr("repo1")}
+	repo2 := &github.Repository{ID: github.Ptr(int64(2)), Name: github.Ptr("repo2")}
+
+	mockService := mockService{}
+	mockService.On("GetOrganizationRepositories", "org", mock.Anything).Return([]*github.Repository{repo1, repo2}, &github.Response{}, nil)
+	mockService.On("GetRepository", "org", "repo1").Return(repo1, &github.Response{}, nil)
+
+	svc := githubService{
+		client: &mockService,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+
+	projects, err := svc.GetProjectList([]string{"org", "org/repo1"})
+
+	assert.Nil(t, err)
+	assert.Len(t, projects, 2)
+	assert.ElementsMatch(t, []int{1, 2}, []int{projects[0].ID, projects[1].ID})
+	mockService.AssertExpectations(t)
+}
+
 func TestGetProjectListWithNextPage(t *testing.T) {
 	project1 := &github.Repository{ID: github.Ptr(int64(1))}
 	project2 := &github.Repository{ID: github.Ptr(int64(2))}
@@ -98,6 +290,7 @@ func TestGetProjectListWithNextPage(t *testing.T) {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		pageSize: repository.MaxPageSize,
 	}
 
 	projects, err := svc.GetProjectList([]string{"org"})
@@ -135,7 +328,8 @@ func TestDownload(t *testing.T) {
 
 	// Setup mock client
 	mockService := mockService{}
-	mockService.On("GetArchiveLink", "owner", "test-project", github.Tarball, mock.Anything).Return(archiveURL, &github.Response{}, nil)
+	mockService.On("GetCommitSHA1", "owner", "test-project", "HEAD").Return("abc123", &github.Response{}, nil)
+	mockService.On("GetArchiveLink", "owner", "test-project", github.Tarball, &github.RepositoryContentGetOptions{Ref: "abc123"}).Return(archiveURL, &github.Response{}, nil)
 
 	svc := githubService{
 		client: &mockService,
@@ -152,10 +346,11 @@ func TestDownload(t *testing.T) {
 		Path:         "owner/test-project",
 	}
 
-	err = svc.Download(testProject, tempDir)
+	sha, err := svc.Download(context.Background(), testProject, tempDir)
 
 	// Verify no errors
 	assert.NoError(t, err)
+	assert.Equal(t, "abc123", sha)
 	mockService.AssertExpectations(t)
 
 	// Verify files were extracted correctly (same verification as GitLab test)
@@ -172,6 +367,155 @@ func TestDownload(t *testing.T) {
 	assert.NoError(t, err, "src directory should exist")
 }
 
+func TestDownloadAppliesDownloadTimeout(t *testing.T) {
+	stubArchive, err := os.ReadFile("../testdata/sample-archive.tar.gz")
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(stubArchive)
+	}))
+	defer server.Close()
+
+	archiveURL, err := url.Parse(server.URL + "/archive.tar.gz")
+	require.NoError(t, err)
+
+	mockService := mockService{}
+	mockService.On("GetCommitSHA1", "owner", "test-project", "HEAD").Return("abc123", &github.Response{}, nil)
+	mockService.On("GetArchiveLink", "owner", "test-project", github.Tarball, mock.Anything).Return(archiveURL, &github.Response{}, nil)
+
+	var deadline time.Time
+	var hasDeadline bool
+	svc := githubService{
+		client: &mockService,
+		httpClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			deadline, hasDeadline = r.Context().Deadline()
+			return http.DefaultTransport.RoundTrip(r)
+		})},
+		archiveLimits:   compress.NewLimits(1 << 20),
+		downloadTimeout: 10 * time.Minute,
+	}
+
+	start := time.Now()
+	_, err = svc.Download(context.Background(), repository.Project{Name: "test-project", GroupOrOwner: "owner", Path: "owner/test-project"}, t.TempDir())
+
+	require.NoError(t, err)
+	require.True(t, hasDeadline, "the download request should have a deadline")
+	assert.WithinDuration(t, start.Add(10*time.Minute), deadline, time.Minute)
+}
+
+func TestDownloadPrivateRepoRequiresToken(t *testing.T) {
+	stubArchive, err := os.ReadFile("../testdata/sample-archive.tar.gz")
+	require.NoError(t, err)
+
+	// Serve the archive only to authenticated requests, as github does for private repositories
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write(stubArchive)
+	}))
+	defer server.Close()
+
+	archiveURL, err := url.Parse(server.URL + "/archive.tar.gz")
+	require.NoError(t, err)
+
+	testProject := repository.Project{Name: "test-project", GroupOrOwner: "owner", Path: "owner/test-project"}
+
+	testCases := map[string]struct {
+		tokens  oauth2.TokenSource
+		wantErr bool
+	}{
+		"with token":    {tokens: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}), wantErr: false},
+		"without token": {tokens: nil, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mockService := mockService{}
+			mockService.On("GetCommitSHA1", "owner", "test-project", "HEAD").Return("abc123", &github.Response{}, nil)
+			mockService.On("GetArchiveLink", "owner", "test-project", github.Tarball, mock.Anything).Return(archiveURL, &github.Response{}, nil)
+
+			svc := githubService{
+				client:        &mockService,
+				httpClient:    &http.Client{Timeout: 30 * time.Second},
+				tokens:        tc.tokens,
+				archiveLimits: compress.NewLimits(1 << 20),
+			}
+
+			_, err := svc.Download(context.Background(), testProject, t.TempDir())
+
+			if tc.wantErr {
+				assert.ErrorContains(t, err, "404")
+				var rejected *repository.RejectedError
+				require.ErrorAs(t, err, &rejected)
+				assert.Equal(t, http.StatusNotFound, rejected.StatusCode)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDownloadFromCacheWhenUnchanged(t *testing.T) {
+	stubArchive, err := os.ReadFile("../testdata/sample-archive.tar.gz")
+	require.NoError(t, err)
+
+	archiveCache, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+
+	project := repository.Project{ID: 123, Name: "test-project", GroupOrOwner: "owner", Path: "owner/test-project", DefaultBranch: "main", Repository: repository.Github}
+	require.NoError(t, archiveCache.Store(cache.ProjectKey(project), "abc123", bytes.NewReader(stubArchive)))
+
+	mockService := mockService{}
+	mockService.On("GetCommitSHA1", "owner", "test-project", "main").Return("abc123", &github.Response{}, nil)
+
+	svc := githubService{client: &mockService, httpClient: &http.Client{Timeout: 30 * time.Second}, archiveCache: archiveCache}
+
+	dir := t.TempDir()
+	sha, err := svc.Download(context.Background(), project, dir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", sha)
+	assert.FileExists(t, filepath.Join(dir, "README.md"))
+	mockService.AssertExpectations(t)
+	mockService.AssertNotCalled(t, "GetArchiveLink", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDownloadStoresInCacheWhenChanged(t *testing.T) {
+	stubArchive, err := os.ReadFile("../testdata/sample-archive.tar.gz")
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(stubArchive)
+	}))
+	defer server.Close()
+	archiveURL, err := url.Parse(server.URL + "/archive.tar.gz")
+	require.NoError(t, err)
+
+	archiveCache, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+
+	project := repository.Project{ID: 123, Name: "test-project", GroupOrOwner: "owner", Path: "owner/test-project", DefaultBranch: "main", Repository: repository.Github}
+
+	mockService := mockService{}
+	mockService.On("GetCommitSHA1", "owner", "test-project", "main").Return("new", &github.Response{}, nil)
+	mockService.On("GetArchiveLink", "owner", "test-project", github.Tarball, &github.RepositoryContentGetOptions{Ref: "new"}).Return(archiveURL, &github.Response{}, nil)
+
+	svc := githubService{client: &mockService, httpClient: &http.Client{Timeout: 30 * time.Second}, archiveCache: archiveCache}
+
+	dir := t.TempDir()
+	sha, err := svc.Download(context.Background(), project, dir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "new", sha)
+	assert.FileExists(t, filepath.Join(dir, "README.md"))
+	mockService.AssertExpectations(t)
+	_, ok := archiveCache.Open(cache.ProjectKey(project), "new")
+	assert.True(t, ok)
+}
+
 func TestOpenVulnerabilityIssue(t *testing.T) {
 	title := repository.VulnerabilityIssueTitle
 	mockClient := mockService{}
@@ -180,13 +524,124 @@ func TestOpenVulnerabilityIssue(t *testing.T) {
 
 	svc := githubService{client: &mockClient}
 
-	i, err := svc.OpenVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, "report")
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, repository.VulnerabilityIssueTitle, "report", nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, i)
 	assert.Equal(t, repository.VulnerabilityIssueTitle, i.Title)
 	mockClient.AssertExpectations(t)
 }
 
+func TestOpenVulnerabilityIssueClosesDuplicates(t *testing.T) {
+	title := repository.VulnerabilityIssueTitle
+	issue := func(number int, state string, body string) *github.Issue {
+		return &github.Issue{Number: github.Ptr(number), State: github.Ptr(state), Title: github.Ptr(title), Body: github.Ptr(body)}
+	}
+	mockClient := mockService{}
+	// Most recent first, as listed by GitHub
+	mockClient.On("ListRepositoryIssues", "group", "repo", mock.Anything).Return([]*github.Issue{
+		issue(7, "open", "duplicate"),
+		issue(5, "closed", "old"),
+		issue(3, "open", "previous"),
+	}, &github.Response{}, nil)
+	mockClient.On("UpdateIssue", "group", "repo", 3, mock.MatchedBy(func(r *github.IssueRequest) bool { return r.Body != nil })).Return(issue(3, "open", "report"), &github.Response{}, nil).Once()
+	mockClient.On("CreateComment", "group", "repo", 7, mock.MatchedBy(func(c *github.IssueComment) bool { return strings.Contains(c.GetBody(), "duplicate of #3") })).Return(&github.IssueComment{}, &github.Response{}, nil).Once()
+	mockClient.On("UpdateIssue", "group", "repo", 7, &github.IssueRequest{State: github.Ptr("closed")}).Return(issue(7, "closed", "duplicate"), &github.Response{}, nil).Once()
+
+	svc := githubService{client: &mockClient}
+
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, title, "report", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "previous", i.PreviousBody, "the oldest open issue is kept")
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "UpdateIssue", "group", "repo", 5, mock.Anything)
+}
+
+func TestOpenVulnerabilityIssueKeepsPreviousBody(t *testing.T) {
+	title := repository.VulnerabilityIssueTitle
+	mockClient := mockService{}
+	mockClient.On("ListRepositoryIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*github.Issue{{Number: github.Ptr(3), Title: &title, Body: github.Ptr("previous report")}}, &github.Response{}, nil)
+	mockClient.On("UpdateIssue", "group", "repo", 3, mock.MatchedBy(func(i *github.IssueRequest) bool { return *i.Body == "report" })).Return(&github.Issue{Title: &title, State: github.Ptr("open")}, &github.Response{}, nil)
+
+	svc := githubService{client: &mockClient}
+
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, title, "report", nil)
+
+	assert.Nil(t, err)
+	require.NotNil(t, i)
+	assert.Equal(t, "previous report", i.PreviousBody)
+	mockClient.AssertExpectations(t)
+}
+
+func TestOpenVulnerabilityIssueSkipsInvalidAssignees(t *testing.T) {
+	title := repository.VulnerabilityIssueTitle
+	mockClient := mockService{}
+	mockClient.On("ListRepositoryIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*github.Issue{}, &github.Response{}, nil)
+	mockClient.On("IsAssignee", "group", "repo", "alice").Return(true, &github.Response{}, nil)
+	mockClient.On("IsAssignee", "group", "repo", "mallory").Return(false, &github.Response{}, nil)
+	mockClient.On("IsAssignee", "group", "repo", "bob").Return(false, &github.Response{}, errors.New("error"))
+	mockClient.On("CreateIssue", "group", "repo", mock.MatchedBy(func(i *github.IssueRequest) bool {
+		return assert.ObjectsAreEqual([]string{"alice"}, *i.Assignees)
+	})).Return(&github.Issue{Title: &title}, &github.Response{}, nil)
+
+	svc := githubService{client: &mockClient}
+
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, title, "report", []string{"alice", "mallory", "bob"})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, i)
+	mockClient.AssertExpectations(t)
+}
+
+func TestOpenVulnerabilityIssueRetriesServerErrors(t *testing.T) {
+	title := repository.VulnerabilityIssueTitle
+	unavailable := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusServiceUnavailable, Request: &http.Request{Method: http.MethodPost, URL: &url.URL{}}}}
+	mockClient := mockService{}
+	mockClient.On("ListRepositoryIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*github.Issue{}, &github.Response{}, nil)
+	mockClient.On("CreateIssue", "group", "repo", mock.Anything).Return((*github.Issue)(nil), nil, unavailable).Once()
+	mockClient.On("CreateIssue", "group", "repo", mock.Anything).Return(&github.Issue{Title: &title}, &github.Response{}, nil).Once()
+
+	svc := githubService{client: &mockClient, issueAttempts: 3, issueBackoff: time.Microsecond}
+
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, title, "report", nil)
+
+	assert.Nil(t, err)
+	require.NotNil(t, i)
+	assert.Equal(t, title, i.Title)
+	mockClient.AssertNumberOfCalls(t, "CreateIssue", 2)
+}
+
+func TestOpenVulnerabilityIssueDoesNotRetryClientErrors(t *testing.T) {
+	unprocessable := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnprocessableEntity, Request: &http.Request{Method: http.MethodPost, URL: &url.URL{}}}}
+	mockClient := mockService{}
+	mockClient.On("ListRepositoryIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*github.Issue{}, &github.Response{}, nil)
+	mockClient.On("CreateIssue", "group", "repo", mock.Anything).Return((*github.Issue)(nil), nil, unprocessable)
+
+	svc := githubService{client: &mockClient, issueAttempts: 3, issueBackoff: time.Microsecond}
+
+	_, err := svc.OpenVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, repository.VulnerabilityIssueTitle, "report", nil)
+
+	assert.ErrorIs(t, err, unprocessable)
+	mockClient.AssertNumberOfCalls(t, "CreateIssue", 1)
+}
+
+func TestCloseVulnerabilityIssueRetriesServerErrors(t *testing.T) {
+	title := repository.VulnerabilityIssueTitle
+	state := "open"
+	badGateway := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway, Request: &http.Request{Method: http.MethodPatch, URL: &url.URL{}}}}
+	mockClient := mockService{}
+	mockClient.On("ListRepositoryIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*github.Issue{{Title: &title, State: &state}}, &github.Response{}, nil)
+	mockClient.On("UpdateIssue", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return((*github.Issue)(nil), nil, badGateway).Once()
+	mockClient.On("UpdateIssue", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&github.Issue{}, &github.Response{}, nil).Once()
+
+	svc := githubService{client: &mockClient, issueAttempts: 3, issueBackoff: time.Microsecond}
+
+	err := svc.CloseVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, title, "")
+
+	assert.Nil(t, err)
+	mockClient.AssertNumberOfCalls(t, "UpdateIssue", 2)
+}
+
 func TestCloseVulnerabilityIssue(t *testing.T) {
 	title := repository.VulnerabilityIssueTitle
 	state := "open"
@@ -196,7 +651,23 @@ func TestCloseVulnerabilityIssue(t *testing.T) {
 
 	svc := githubService{client: &mockClient}
 
-	err := svc.CloseVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"})
+	err := svc.CloseVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, repository.VulnerabilityIssueTitle, "")
+	assert.Nil(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestCloseVulnerabilityIssueWithComment(t *testing.T) {
+	title := repository.VulnerabilityIssueTitle
+	state := "open"
+	number := 3
+	mockClient := mockService{}
+	mockClient.On("ListRepositoryIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*github.Issue{{Title: &title, State: &state, Number: &number}}, &github.Response{}, nil)
+	mockClient.On("CreateComment", "group", "repo", 3, mock.MatchedBy(func(c *github.IssueComment) bool { return *c.Body == "all good" })).Return(&github.IssueComment{}, &github.Response{}, nil)
+	mockClient.On("UpdateIssue", "group", "repo", 3, mock.Anything).Return(&github.Issue{}, &github.Response{}, nil)
+
+	svc := githubService{client: &mockClient}
+
+	err := svc.CloseVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, title, "all good")
 	assert.Nil(t, err)
 	mockClient.AssertExpectations(t)
 }
@@ -207,11 +678,85 @@ func TestCloseVulnerabilityIssueNoIssue(t *testing.T) {
 
 	svc := githubService{client: &mockClient}
 
-	err := svc.CloseVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"})
+	err := svc.CloseVulnerabilityIssue(repository.Project{GroupOrOwner: "group", Name: "repo"}, repository.VulnerabilityIssueTitle, "")
 	assert.Nil(t, err)
 	mockClient.AssertExpectations(t)
 }
 
+func TestGetFile(t *testing.T) {
+	mockClient := mockService{}
+	mockClient.On("GetContents", "group", "repo", "sheriff.toml", &github.RepositoryContentGetOptions{Ref: "main"}).Return(&github.RepositoryContent{Content: github.Ptr("ignored = []")}, &github.Response{}, nil)
+
+	svc := githubService{client: &mockClient}
+
+	content, found, err := svc.GetFile(repository.Project{GroupOrOwner: "group", Name: "repo", DefaultBranch: "main"}, "sheriff.toml")
+
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "ignored = []", string(content))
+}
+
+func TestGetFileNotFound(t *testing.T) {
+	mockClient := mockService{}
+	mockClient.On("GetContents", "group", "repo", "sheriff.toml", mock.Anything).Return((*github.RepositoryContent)(nil), nil, &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}})
+
+	svc := githubService{client: &mockClient}
+
+	_, found, err := svc.GetFile(repository.Project{GroupOrOwner: "group", Name: "repo", DefaultBranch: "main"}, "sheriff.toml")
+
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestCommitFileUpdatesExistingFile(t *testing.T) {
+	mockClient := mockService{}
+	mockClient.On("GetContents", "group", "repo", "sheriff.toml", &github.RepositoryContentGetOptions{Ref: "fix"}).Return(&github.RepositoryContent{SHA: github.Ptr("abc")}, &github.Response{}, nil)
+	mockClient.On("CreateFile", "group", "repo", "sheriff.toml", &github.RepositoryContentFileOptions{
+		Message: github.Ptr("message"),
+		Content: []byte("content"),
+		Branch:  github.Ptr("fix"),
+		SHA:     github.Ptr("abc"),
+	}).Return(&github.RepositoryContentResponse{}, &github.Response{}, nil)
+
+	svc := githubService{client: &mockClient}
+
+	err := svc.CommitFile(repository.Project{GroupOrOwner: "group", Name: "repo"}, "fix", "sheriff.toml", []byte("content"), "message")
+
+	assert.Nil(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestUploadIssueAttachmentNotSupported(t *testing.T) {
+	svc := githubService{client: &mockService{}}
+
+	_, err := svc.UploadIssueAttachment(repository.Project{Path: "owner/repo"}, "osv-scanner.json", []byte(`{}`))
+
+	assert.ErrorIs(t, err, repository.ErrNotSupported)
+}
+
+func TestOpenMergeRequest(t *testing.T) {
+	mockClient := mockService{}
+	mockClient.On("GetCommitSHA1", "group", "repo", "main").Return("abc", &github.Response{}, nil)
+	mockClient.On("CreateRef", "group", "repo", &github.Reference{Ref: github.Ptr("refs/heads/fix"), Object: &github.GitObject{SHA: github.Ptr("abc")}}).Return(&github.Reference{}, &github.Response{}, nil)
+	mockClient.On("CreatePullRequest", "group", "repo", &github.NewPullRequest{
+		Title: github.Ptr("title"),
+		Body:  github.Ptr("description"),
+		Head:  github.Ptr("fix"),
+		Base:  github.Ptr("main"),
+	}).Return(&github.PullRequest{HTMLURL: github.Ptr("https://github.com/group/repo/pull/1")}, &github.Response{}, nil)
+
+	svc := githubService{client: &mockClient}
+	project := repository.Project{GroupOrOwner: "group", Name: "repo", DefaultBranch: "main"}
+
+	err := svc.CreateBranch(project, "fix")
+	assert.Nil(t, err)
+	url, err := svc.OpenMergeRequest(project, "fix", "title", "description")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://github.com/group/repo/pull/1", url)
+	mockClient.AssertExpectations(t)
+}
+
 type mockService struct {
 	mock.Mock
 }
@@ -281,3 +826,72 @@ func (c *mockService) UpdateIssue(owner string, repo string, number int, issue *
 	}
 	return args.Get(0).(*github.Issue), r, args.Error(2)
 }
+
+func (c *mockService) IsAssignee(owner string, repo string, user string) (bool, *github.Response, error) {
+	args := c.Called(owner, repo, user)
+	var r *github.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*github.Response)
+	}
+	return args.Bool(0), r, args.Error(2)
+}
+
+func (c *mockService) CreateComment(owner string, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	args := c.Called(owner, repo, number, comment)
+	var r *github.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*github.Response)
+	}
+	return args.Get(0).(*github.IssueComment), r, args.Error(2)
+}
+
+func (c *mockService) GetCommitSHA1(owner string, repo string, ref string) (string, *github.Response, error) {
+	args := c.Called(owner, repo, ref)
+	var r *github.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*github.Response)
+	}
+	return args.String(0), r, args.Error(2)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func (c *mockService) GetContents(owner string, repo string, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, *github.Response, error) {
+	args := c.Called(owner, repo, path, opts)
+	var r *github.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*github.Response)
+	}
+	return args.Get(0).(*github.RepositoryContent), r, args.Error(2)
+}
+
+func (c *mockService) CreateFile(owner string, repo string, path string, opts *github.RepositoryContentFileOptions) (*github.RepositoryContentResponse, *github.Response, error) {
+	args := c.Called(owner, repo, path, opts)
+	var r *github.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*github.Response)
+	}
+	return args.Get(0).(*github.RepositoryContentResponse), r, args.Error(2)
+}
+
+func (c *mockService) CreateRef(owner string, repo string, ref *github.Reference) (*github.Reference, *github.Response, error) {
+	args := c.Called(owner, repo, ref)
+	var r *github.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*github.Response)
+	}
+	return args.Get(0).(*github.Reference), r, args.Error(2)
+}
+
+func (c *mockService) CreatePullRequest(owner string, repo string, pull *github.NewPullRequest) (*github.PullRequest, *github.Response, error) {
+	args := c.Called(owner, repo, pull)
+	var r *github.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*github.Response)
+	}
+	return args.Get(0).(*github.PullRequest), r, args.Error(2)
+}
@@ -2,36 +2,132 @@ package gitlab
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"sheriff/internal/cache"
 	"sheriff/internal/compress"
+	"sheriff/internal/ratelimit"
 	"sheriff/internal/repository"
+	"sheriff/internal/retry"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/elliotchance/pie/v2"
 	"github.com/rs/zerolog/log"
 	gitlab "gitlab.com/gitlab-org/api/client-go"
 )
 
+// errTokenPermission is returned when the GitLab API rejects the token, which is the case when it is expired
+// or does not have the scopes sheriff needs
+var errTokenPermission = errors.New("gitlab token is missing required scope 'api' or is expired")
+
+// errJobToken is returned by the features which the GitLab API does not allow to CI job tokens
+var errJobToken = errors.New("not allowed with a CI job token, use an access token with the 'api' scope instead")
+
+// AuthMode is how sheriff authenticates to the GitLab API
+type AuthMode string
+
+const (
+	AuthModePat      AuthMode = "pat"       // Personal, group or project access token, sent in the PRIVATE-TOKEN header
+	AuthModeJobToken AuthMode = "job-token" // CI job token (CI_JOB_TOKEN) of a GitLab CI job, sent in the JOB-TOKEN header
+)
+
+// Number of attempts, and initial backoff between them, of the requests creating or updating issues
+const (
+	issueRequestAttempts = 3
+	issueRequestBackoff  = time.Second
+)
+
+// recursiveSuffix ends the paths of the groups whose projects are listed with those of all their subgroups,
+// without falling back to a project of that path
+const recursiveSuffix = "/**"
+
 type gitlabService struct {
-	client iclient
-	token  string
+	client          iclient
+	token           string
+	authMode        AuthMode
+	archiveLimits   compress.Limits
+	archiveCache    *cache.ArchiveCache
+	downloadTimeout time.Duration // Deadline of the download of each archive, none if not set
+	pageSize        int           // Number of items per page of the listings, the default of the API if not set
+	filter          repository.ProjectFilter
+	issueAttempts   int           // Attempts of the requests creating or updating issues, once if not set
+	issueBackoff    time.Duration // Initial backoff between the attempts of the requests creating or updating issues
 }
 
 // newGitlabRepo creates a new GitLab repository service
-func New(token string) (*gitlabService, error) {
-	c, err := gitlab.NewClient(token)
+// The token is sent as an access token, or as a CI job token depending on the authMode (access token if empty).
+// As CI job tokens can only access a few endpoints of the API, groups cannot be listed and issues cannot be managed with them.
+// The baseURL points to a self-managed GitLab instance, gitlab.com is used if empty.
+// API requests are throttled to apiRateLimit per second, and retried when rate limited (see ratelimit.NewTransport).
+// Listings are fetched in pages of pageSize items, up to repository.MaxPageSize, which is used if 0.
+// Downloaded archives are extracted within the given limits, and kept in the archive cache if it is not nil.
+// Each download is aborted after downloadTimeout, 0 disabling the deadline.
+// Archived projects of the groups are only listed if the filter includes them.
+func New(token string, authMode AuthMode, baseURL string, apiRateLimit float64, pageSize int, downloadTimeout time.Duration, archiveLimits compress.Limits, archiveCache *cache.ArchiveCache, filter repository.ProjectFilter) (*gitlabService, error) {
+	if pageSize < 0 || pageSize > repository.MaxPageSize {
+		return nil, fmt.Errorf("invalid page size %v, must be between 1 and %v", pageSize, repository.MaxPageSize)
+	} else if pageSize == 0 {
+		pageSize = repository.MaxPageSize
+	}
+
+	opts := []gitlab.ClientOptionFunc{
+		gitlab.WithHTTPClient(&http.Client{Transport: ratelimit.NewTransport(nil, apiRateLimit)}),
+	}
+	if baseURL != "" {
+		if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid gitlab url %v, must be an absolute http(s) url", baseURL)
+		}
+		opts = append(opts, gitlab.WithBaseURL(baseURL))
+	}
+
+	var c *gitlab.Client
+	var err error
+	switch authMode {
+	case "", AuthModePat:
+		authMode = AuthModePat
+		c, err = gitlab.NewClient(token, opts...)
+	case AuthModeJobToken:
+		log.Warn().Msg("Authenticating to GitLab with a CI job token, which can neither list the projects of groups nor manage issues")
+		c, err = gitlab.NewJobClient(token, opts...)
+	default:
+		return nil, fmt.Errorf("invalid gitlab auth mode %v, must be %v or %v", authMode, AuthModePat, AuthModeJobToken)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	s := gitlabService{client: &client{client: c}, token: token}
+	s := gitlabService{
+		client:          &client{client: c},
+		token:           token,
+		authMode:        authMode,
+		archiveLimits:   archiveLimits,
+		archiveCache:    archiveCache,
+		downloadTimeout: downloadTimeout,
+		pageSize:        pageSize,
+		filter:          filter,
+		issueAttempts:   issueRequestAttempts,
+		issueBackoff:    issueRequestBackoff,
+	}
 
 	return &s, nil
 }
 
 func (s gitlabService) GetProjectList(paths []string) (projects []repository.Project, warn error) {
+	// Fail fast before going through all groups, which would each fail with a less obvious error.
+	// CI job tokens cannot fetch their user, they are only checked by the actual API calls.
+	if s.authMode != AuthModeJobToken {
+		if err := s.validateToken(); err != nil {
+			return nil, err
+		}
+	}
+
 	projects, pwarn := s.gatherProjectsFromGroupsOrProjects(paths)
 	if pwarn != nil {
 		pwarn = errors.Join(errors.New("errors occured when gathering projects"), pwarn)
@@ -44,9 +140,15 @@ func (s gitlabService) GetProjectList(paths []string) (projects []repository.Pro
 	return projects, warn
 }
 
-// CloseVulnerabilityIssue closes the vulnerability issue for the given project
-func (s gitlabService) CloseVulnerabilityIssue(project repository.Project) (err error) {
-	issue, err := s.getVulnerabilityIssue(project)
+// CloseVulnerabilityIssue closes the vulnerability issue with the given title for the given project,
+// together with its open duplicates if any.
+// If comment is not empty, it is posted on the issue before closing it.
+func (s gitlabService) CloseVulnerabilityIssue(project repository.Project, title string, comment string) (err error) {
+	if s.authMode == AuthModeJobToken {
+		return errors.Join(errors.New("failed to close issue"), errJobToken)
+	}
+
+	issue, duplicates, err := s.getVulnerabilityIssue(project, title)
 	if err != nil {
 		return errors.Join(errors.New("failed to fetch current list of issues"), err)
 	}
@@ -61,8 +163,31 @@ func (s gitlabService) CloseVulnerabilityIssue(project repository.Project) (err
 		return
 	}
 
-	issue, _, err = s.client.UpdateIssue(project.ID, issue.IID, &gitlab.UpdateIssueOptions{
-		StateEvent: gitlab.Ptr("close"),
+	for _, i := range append([]*gitlab.Issue{issue}, duplicates...) {
+		if err := s.closeIssue(project, i.IID, comment); err != nil {
+			return err
+		}
+	}
+
+	log.Info().Str("project", project.Path).Msg("Issue closed")
+
+	return
+}
+
+// closeIssue closes the issue with the given IID, posting the comment on it first if it is not empty
+func (s gitlabService) closeIssue(project repository.Project, iid int, comment string) error {
+	if comment != "" {
+		if _, _, err := s.client.CreateIssueNote(project.ID, iid, &gitlab.CreateIssueNoteOptions{Body: &comment}); err != nil {
+			log.Warn().Err(err).Str("project", project.Path).Int("issue", iid).Msg("Failed to comment on issue, closing it anyway")
+		}
+	}
+
+	var issue *gitlab.Issue
+	err := s.retryIssueRequest(func() (err error) {
+		issue, _, err = s.client.UpdateIssue(project.ID, iid, &gitlab.UpdateIssueOptions{
+			StateEvent: gitlab.Ptr("close"),
+		})
+		return
 	})
 	if err != nil {
 		return errors.Join(errors.New("failed to update issue"), err)
@@ -72,14 +197,29 @@ func (s gitlabService) CloseVulnerabilityIssue(project repository.Project) (err
 		return errors.New("failed to close issue")
 	}
 
-	log.Info().Str("project", project.Path).Msg("Issue closed")
+	return nil
+}
 
-	return
+// closeDuplicateIssues closes the duplicates of the vulnerability issue, with a comment pointing to it.
+// Failures are only logged, as the issue itself is up to date.
+func (s gitlabService) closeDuplicateIssues(project repository.Project, issue *gitlab.Issue, duplicates []*gitlab.Issue) {
+	comment := fmt.Sprintf("Closed as a duplicate of #%d, which sheriff keeps up to date.", issue.IID)
+	for _, d := range duplicates {
+		if err := s.closeIssue(project, d.IID, comment); err != nil {
+			log.Warn().Err(err).Str("project", project.Path).Int("issue", d.IID).Msg("Failed to close duplicate issue")
+		} else {
+			log.Info().Str("project", project.Path).Int("issue", d.IID).Int("duplicateOf", issue.IID).Msg("Closed duplicate issue")
+		}
+	}
 }
 
-// OpenVulnerabilityIssue opens or updates the vulnerability issue for the given project
-func (s gitlabService) OpenVulnerabilityIssue(project repository.Project, report string) (issue *repository.Issue, err error) {
-	gitlabIssue, err := s.getVulnerabilityIssue(project)
+// OpenVulnerabilityIssue opens or updates the vulnerability issue with the given title for the given project
+func (s gitlabService) OpenVulnerabilityIssue(project repository.Project, title string, report string, assignees []string) (issue *repository.Issue, err error) {
+	if s.authMode == AuthModeJobToken {
+		return nil, errors.Join(fmt.Errorf("[%v] failed to open or update issue", project.Path), errJobToken)
+	}
+
+	gitlabIssue, duplicates, err := s.getVulnerabilityIssue(project, title)
 	if err != nil {
 		return nil, errors.Join(fmt.Errorf("[%v] Failed to fetch current list of issues", project.Path), err)
 	}
@@ -87,9 +227,14 @@ func (s gitlabService) OpenVulnerabilityIssue(project repository.Project, report
 	if gitlabIssue == nil {
 		log.Info().Str("project", project.Path).Msg("Creating new issue")
 
-		gitlabIssue, _, err := s.client.CreateIssue(project.ID, &gitlab.CreateIssueOptions{
-			Title:       gitlab.Ptr(repository.VulnerabilityIssueTitle),
+		opts := &gitlab.CreateIssueOptions{
+			Title:       gitlab.Ptr(title),
 			Description: &report,
+			AssigneeIDs: s.getUserIDs(assignees),
+		}
+		err := s.retryIssueRequest(func() (err error) {
+			gitlabIssue, _, err = s.client.CreateIssue(project.ID, opts)
+			return
 		})
 		if err != nil {
 			return nil, errors.Join(fmt.Errorf("[%v] failed to create new issue", project.Path), err)
@@ -100,9 +245,13 @@ func (s gitlabService) OpenVulnerabilityIssue(project repository.Project, report
 
 	log.Info().Str("project", project.Path).Int("issue", gitlabIssue.IID).Msg("Updating existing issue")
 
-	if updatedIssue, _, err := s.client.UpdateIssue(project.ID, gitlabIssue.IID, &gitlab.UpdateIssueOptions{
-		Description: &report,
-		StateEvent:  gitlab.Ptr("reopen"),
+	var updatedIssue *gitlab.Issue
+	if err := s.retryIssueRequest(func() (err error) {
+		updatedIssue, _, err = s.client.UpdateIssue(project.ID, gitlabIssue.IID, &gitlab.UpdateIssueOptions{
+			Description: &report,
+			StateEvent:  gitlab.Ptr("reopen"),
+		})
+		return
 	}); err != nil {
 		return nil, errors.Join(fmt.Errorf("[%v] Failed to update issue", project.Path), err)
 	} else {
@@ -111,24 +260,219 @@ func (s gitlabService) OpenVulnerabilityIssue(project repository.Project, report
 		}
 
 		issue = mapIssuePtr(updatedIssue)
+		issue.PreviousBody = gitlabIssue.Description
 	}
 
+	s.closeDuplicateIssues(project, gitlabIssue, duplicates)
+
 	return
 }
 
-func (s gitlabService) Download(project repository.Project, dir string) (err error) {
-	archiveData, _, err := s.client.Archive(project.ID, &gitlab.ArchiveOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to download archive: %w", err)
-	}
+// Download downloads and extracts the archive of the project's scanned branch (see Project.ScannedRef) into the given directory,
+// pinned to the latest commit of the branch whenever it is known.
+// If the archive cache is enabled, the archive is only downloaded if the project changed since it was cached.
+func (s gitlabService) Download(ctx context.Context, project repository.Project, dir string) (sha string, err error) {
+	defer func() { err = markRejectedError(err) }()
 
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	sha, shaErr := s.GetLatestCommitSha(project)
+	if shaErr != nil && project.Ref != "" {
+		// Downloading without pinning the commit would download the default branch instead
+		return "", shaErr
+	} else if shaErr != nil {
+		log.Warn().Err(shaErr).Str("project", project.Path).Msg("Failed to get latest commit of project, downloading it without pinning the commit")
+	}
+	if archive, ok := s.archiveCache.Open(cache.ProjectKey(project), sha); ok {
+		defer archive.Close()
+		log.Info().Str("project", project.Path).Str("sha", sha).Msg("Project unchanged since last download, using cached archive")
+		return sha, compress.ExtractTarGz(archive, dir, s.archiveLimits)
+	}
+
+	opts := &gitlab.ArchiveOptions{}
+	if sha != "" {
+		opts.SHA = gitlab.Ptr(sha)
+	}
+	if s.downloadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.downloadTimeout)
+		defer cancel()
+	}
+	archiveData, _, err := s.client.Archive(project.ID, opts, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to download archive: %w", err)
+	}
+
+	if err := s.archiveCache.Store(cache.ProjectKey(project), sha, bytes.NewReader(archiveData)); err != nil {
+		log.Warn().Err(err).Str("project", project.Path).Msg("Failed to cache archive")
 	}
 
 	// Extract archive to directory using the shared compress package
-	return compress.ExtractTarGz(bytes.NewReader(archiveData), dir)
+	return sha, compress.ExtractTarGz(bytes.NewReader(archiveData), dir, s.archiveLimits)
+}
+
+// GetLatestCommitSha returns the sha of the latest commit of the project's scanned branch,
+// which is the one downloaded and under which its archive is cached.
+func (s gitlabService) GetLatestCommitSha(project repository.Project) (string, error) {
+	ref := project.ScannedRef()
+	if ref == "" {
+		return "", errors.New("project has no default branch")
+	}
+
+	branch, _, err := s.client.GetBranch(project.ID, ref)
+	if err != nil {
+		return "", errors.Join(fmt.Errorf("failed to get branch %v", ref), err)
+	} else if branch == nil || branch.Commit == nil {
+		return "", fmt.Errorf("branch %v has no commit", ref)
+	}
+
+	return branch.Commit.ID, nil
+}
+
+func (s gitlabService) GetFile(project repository.Project, path string) (content []byte, found bool, err error) {
+	file, err := s.getFile(project, path, project.DefaultBranch)
+	if err != nil || file == nil {
+		return nil, false, err
+	}
+
+	content, err = base64.StdEncoding.DecodeString(file.Content)
+	if err != nil {
+		return nil, false, errors.Join(fmt.Errorf("failed to decode file %v", path), err)
+	}
+
+	return content, true, nil
+}
+
+func (s gitlabService) CreateBranch(project repository.Project, branch string) error {
+	if project.DefaultBranch == "" {
+		return errors.New("project has no default branch")
+	}
+
+	if _, _, err := s.client.CreateBranch(project.ID, &gitlab.CreateBranchOptions{
+		Branch: &branch,
+		Ref:    &project.DefaultBranch,
+	}); err != nil {
+		return errors.Join(fmt.Errorf("failed to create branch %v", branch), err)
+	}
+
+	return nil
+}
+
+func (s gitlabService) CommitFile(project repository.Project, branch string, path string, content []byte, message string) error {
+	existing, err := s.getFile(project, path, branch)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		_, _, err = s.client.CreateFile(project.ID, path, &gitlab.CreateFileOptions{
+			Branch:        &branch,
+			Content:       gitlab.Ptr(string(content)),
+			CommitMessage: &message,
+		})
+	} else {
+		_, _, err = s.client.UpdateFile(project.ID, path, &gitlab.UpdateFileOptions{
+			Branch:        &branch,
+			Content:       gitlab.Ptr(string(content)),
+			CommitMessage: &message,
+			LastCommitID:  &existing.LastCommitID,
+		})
+	}
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to commit file %v", path), err)
+	}
+
+	return nil
+}
+
+func (s gitlabService) OpenMergeRequest(project repository.Project, branch string, title string, description string) (string, error) {
+	mr, _, err := s.client.CreateMergeRequest(project.ID, &gitlab.CreateMergeRequestOptions{
+		Title:              &title,
+		Description:        &description,
+		SourceBranch:       &branch,
+		TargetBranch:       &project.DefaultBranch,
+		RemoveSourceBranch: gitlab.Ptr(true),
+	})
+	if err != nil {
+		return "", errors.Join(errors.New("failed to open merge request"), err)
+	}
+
+	return mr.WebURL, nil
+}
+
+func (s gitlabService) UploadIssueAttachment(project repository.Project, filename string, content []byte) (string, error) {
+	upload, _, err := s.client.UploadProjectMarkdown(project.ID, bytes.NewReader(content), filename)
+	if err != nil {
+		return "", errors.Join(fmt.Errorf("failed to upload %v", filename), err)
+	}
+
+	return upload.Markdown, nil
+}
+
+// getFile returns the file at the given path of the ref, or nil if there is no such file
+func (s gitlabService) getFile(project repository.Project, path string, ref string) (*gitlab.File, error) {
+	file, _, err := s.client.GetFile(project.ID, path, &gitlab.GetFileOptions{Ref: &ref})
+	if errors.Is(err, gitlab.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to get file %v", path), err)
+	}
+
+	return file, nil
+}
+
+// validateToken checks that the token can be used to access the GitLab API, by fetching the user it belongs to.
+// Only permission errors are returned, other errors are left for the actual API calls to surface.
+func (s gitlabService) validateToken() error {
+	_, _, err := s.client.CurrentUser()
+	if isPermissionError(err) {
+		return errors.Join(errTokenPermission, err)
+	} else if err != nil {
+		log.Warn().Err(err).Msg("Failed to validate gitlab token, continuing anyway")
+	}
+
+	return nil
+}
+
+// retryIssueRequest sends a request creating or updating an issue, retrying it with backoff
+// as long as it fails with a transient error, see isTransientError
+func (s gitlabService) retryIssueRequest(request func() error) error {
+	return retry.DoIf(context.Background(), request, isTransientError, s.issueAttempts, s.issueBackoff)
+}
+
+// isTransientError returns whether the request may succeed if sent again, which is the case of network errors
+// and of the 5xx responses of the GitLab API, but not of the 4xx responses which reject the request itself
+func isTransientError(err error) bool {
+	var errResp *gitlab.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return true
+	}
+
+	return errResp.Response.StatusCode >= http.StatusInternalServerError
+}
+
+// markRejectedError returns the error of a 4xx response of the GitLab API as a repository.RejectedError,
+// so that it is not retried
+func markRejectedError(err error) error {
+	var errResp *gitlab.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return err
+	}
+
+	return repository.NewRejectedError(errResp.Response.StatusCode, err)
+}
+
+// isPermissionError returns whether the error is a 401 or 403 response of the GitLab API
+func isPermissionError(err error) bool {
+	var errResp *gitlab.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return false
+	}
+
+	return errResp.Response.StatusCode == http.StatusUnauthorized || errResp.Response.StatusCode == http.StatusForbidden
 }
 
 // This function receives a list of paths which can be gitlab projects or groups
@@ -136,7 +480,13 @@ func (s gitlabService) Download(project repository.Project, dir string) (err err
 func (s gitlabService) gatherProjectsFromGroupsOrProjects(paths []string) (projects []repository.Project, warn error) {
 	for _, path := range paths {
 		gp, gpwarn, gerr := s.getProjectsFromGroupOrProject(path)
-		if gerr != nil {
+		if errors.Is(gerr, repository.ErrPathNotFound) {
+			log.Warn().Str("path", path).Msg("Path not found, neither as a group nor as a project, skipping it")
+			continue
+		} else if errors.Is(gerr, repository.ErrPathEmpty) {
+			log.Warn().Str("path", path).Msg("Group has no projects to scan, skipping it")
+			continue
+		} else if gerr != nil {
 			log.Error().Err(gerr).Str("group", path).Msg("Failed to fetch group")
 			gerr = errors.Join(fmt.Errorf("failed to fetch group %v", path), gerr)
 			warn = errors.Join(gerr, warn)
@@ -160,12 +510,50 @@ func (s gitlabService) gatherProjectsFromGroupsOrProjects(paths []string) (proje
 //
 //	If it succeeds then it returns all projects of that group & its subgroups.
 //	If it fails then it tries to get the path as a project.
+//
+// A path ending with /** must be a group, and is never tried as a project.
+// With a CI job token, which cannot list the projects of groups, the path can only be a project.
+// Paths which do not exist return repository.ErrPathNotFound, and groups without projects repository.ErrPathEmpty.
 func (s gitlabService) getProjectsFromGroupOrProject(path string) (projects []repository.Project, warn error, err error) {
+	if s.authMode == AuthModeJobToken {
+		if strings.HasSuffix(path, recursiveSuffix) {
+			return nil, errors.Join(fmt.Errorf("failed to get group %v", strings.TrimSuffix(path, recursiveSuffix)), errJobToken), nil
+		}
+
+		p, _, perr := s.client.GetProject(path, &gitlab.GetProjectOptions{})
+		if errors.Is(perr, gitlab.ErrNotFound) {
+			return nil, nil, errors.Join(fmt.Errorf("project %v", path), repository.ErrPathNotFound)
+		} else if perr != nil {
+			return nil, errors.Join(fmt.Errorf("failed to get project %v, groups cannot be listed with a CI job token", path), perr), nil
+		} else if p == nil {
+			return nil, fmt.Errorf("unexpected nil project %v", path), nil
+		}
+
+		return []repository.Project{mapProject(*p)}, nil, nil
+	}
+
+	if group, ok := strings.CutSuffix(path, recursiveSuffix); ok {
+		gp, gpwarn, gperr := s.listGroupProjects(group)
+		if errors.Is(gperr, gitlab.ErrNotFound) {
+			return nil, nil, errors.Join(fmt.Errorf("group %v", group), repository.ErrPathNotFound)
+		} else if gperr != nil {
+			return nil, errors.Join(fmt.Errorf("failed to get group %v", group), gperr), nil
+		} else if len(gp) == 0 && gpwarn == nil {
+			return nil, nil, errors.Join(fmt.Errorf("group %v", group), repository.ErrPathEmpty)
+		}
+
+		return pie.Map(gp, mapProject), gpwarn, nil
+	}
+
 	gp, gpwarn, gperr := s.listGroupProjects(path)
 	if gperr != nil {
 		log.Debug().Str("path", path).Msg("failed to fetch as group. trying as project")
 		p, _, perr := s.client.GetProject(path, &gitlab.GetProjectOptions{})
-		if perr != nil {
+		if perr != nil && isPermissionError(perr) {
+			return nil, errors.Join(fmt.Errorf("failed to get project %v", path), errTokenPermission, perr), nil
+		} else if errors.Is(perr, gitlab.ErrNotFound) && errors.Is(gperr, gitlab.ErrNotFound) {
+			return nil, nil, errors.Join(fmt.Errorf("group or project %v", path), repository.ErrPathNotFound)
+		} else if perr != nil {
 			return nil, errors.Join(fmt.Errorf("failed to get group %v", path), gperr), nil
 		} else if p == nil {
 			return nil, fmt.Errorf("unexpected nil project %v", path), nil
@@ -174,44 +562,99 @@ func (s gitlabService) getProjectsFromGroupOrProject(path string) (projects []re
 		return []repository.Project{mapProject(*p)}, nil, nil
 	}
 
+	if len(gp) == 0 && gpwarn == nil {
+		return nil, nil, errors.Join(fmt.Errorf("group %v", path), repository.ErrPathEmpty)
+	}
+
 	ps := pie.Map(gp, mapProject)
 
 	return ps, gpwarn, nil
 }
 
-// getVulnerabilityIssue returns the vulnerability issue for the given project
-func (s gitlabService) getVulnerabilityIssue(project repository.Project) (issue *gitlab.Issue, err error) {
-	issues, _, err := s.client.ListProjectIssues(project.ID, &gitlab.ListProjectIssuesOptions{
-		Search: gitlab.Ptr(repository.VulnerabilityIssueTitle),
+// getUserIDs resolves the given usernames to user IDs
+// Usernames which cannot be resolved are skipped with a warning, so that the issue is still created.
+func (s gitlabService) getUserIDs(usernames []string) *[]int {
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(usernames))
+	for _, username := range usernames {
+		users, _, err := s.client.ListUsers(&gitlab.ListUsersOptions{Username: gitlab.Ptr(username)})
+		if err != nil {
+			log.Warn().Err(err).Str("username", username).Msg("Failed to look up issue assignee, skipping")
+			continue
+		}
+
+		user := pie.First(pie.Filter(users, func(u *gitlab.User) bool { return u != nil && u.Username == username }))
+		if user == nil {
+			log.Warn().Str("username", username).Msg("Issue assignee not found, skipping")
+			continue
+		}
+
+		ids = append(ids, user.ID)
+	}
+
+	return &ids
+}
+
+// getVulnerabilityIssue returns the vulnerability issue with exactly the given title for the given project,
+// and the other open issues with that title, which duplicate it.
+// Several issues are open if e.g. two runs raced to create it, in which case the oldest one is kept.
+// Otherwise, it is the most recent of the closed issues.
+func (s gitlabService) getVulnerabilityIssue(project repository.Project, title string) (issue *gitlab.Issue, duplicates []*gitlab.Issue, err error) {
+	issues, err := s.getVulnerabilityIssues(project, title)
+	if err != nil || len(issues) == 0 {
+		return nil, nil, err
+	}
+
+	open := pie.Filter(issues, func(i *gitlab.Issue) bool { return i.State == "opened" })
+	if len(open) == 0 {
+		return issues[0], nil, nil
+	}
+
+	open = pie.SortUsing(open, func(a, b *gitlab.Issue) bool { return a.IID < b.IID })
+
+	return open[0], open[1:], nil
+}
+
+// getVulnerabilityIssues returns the issues with exactly the given title for the given project, from the most recent.
+// It goes through all pages of the search results, as the issues may not be on the first one.
+func (s gitlabService) getVulnerabilityIssues(project repository.Project, title string) (matches []*gitlab.Issue, err error) {
+	opts := &gitlab.ListProjectIssuesOptions{
+		Search: gitlab.Ptr(title),
 		In:     gitlab.Ptr("title"),
-	})
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to fetch current list of issues")
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: s.pageSize,
+		},
 	}
 
-	if len(issues) > 0 {
-		if issues[0] == nil {
-			return nil, fmt.Errorf("unexpected nil issue %v", project.Path)
+	for {
+		issues, resp, err := s.client.ListProjectIssues(project.ID, opts)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch current list of issues")
+			return nil, err
+		}
+
+		for _, issue := range issues {
+			if issue != nil && issue.Title == title {
+				matches = append(matches, issue)
+			}
 		}
 
-		issue = issues[0]
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
 	}
 
-	return
+	return matches, nil
 }
 
 // listGroupProjects returns the list of projects for the given group ID
 func (s gitlabService) listGroupProjects(path string) (projects []gitlab.Project, warn error, err error) {
-	projectPtrs, response, err := s.client.ListGroupProjects(path,
-		&gitlab.ListGroupProjectsOptions{
-			Archived:         gitlab.Ptr(false),
-			Simple:           gitlab.Ptr(true),
-			IncludeSubGroups: gitlab.Ptr(true),
-			WithShared:       gitlab.Ptr(false),
-			ListOptions: gitlab.ListOptions{
-				Page: 1,
-			},
-		})
+	projectPtrs, response, err := s.client.ListGroupProjects(path, s.listGroupProjectsOptions(1))
 	if err != nil {
 		return nil, nil, errors.Join(errors.New("failed to fetch list of projects"), err)
 	}
@@ -234,6 +677,24 @@ func (s gitlabService) listGroupProjects(path string) (projects []gitlab.Project
 	return
 }
 
+// listGroupProjectsOptions returns the options to list the given page of the projects of a group and its subgroups.
+// Their full representation is listed, as the simple one lacks their visibility.
+func (s gitlabService) listGroupProjectsOptions(page int) *gitlab.ListGroupProjectsOptions {
+	opts := &gitlab.ListGroupProjectsOptions{
+		IncludeSubGroups: gitlab.Ptr(true),
+		WithShared:       gitlab.Ptr(false),
+		ListOptions: gitlab.ListOptions{
+			Page:    page,
+			PerPage: s.pageSize,
+		},
+	}
+	if !s.filter.IncludeArchived {
+		opts.Archived = gitlab.Ptr(false)
+	}
+
+	return opts
+}
+
 func ToChan[T any](s []T) <-chan T {
 	ch := make(chan T, len(s))
 	for _, e := range s {
@@ -254,16 +715,7 @@ func (s gitlabService) listGroupNextProjects(path string, totalPages int) (proje
 		go func(reportsChan chan<- []gitlab.Project) {
 			defer wg.Done()
 			log.Info().Str("path", path).Int("page", p).Msg("Fetching projects of next page")
-			projectPtrs, _, err := s.client.ListGroupProjects(path,
-				&gitlab.ListGroupProjectsOptions{
-					Archived:         gitlab.Ptr(false),
-					Simple:           gitlab.Ptr(true),
-					IncludeSubGroups: gitlab.Ptr(true),
-					WithShared:       gitlab.Ptr(false),
-					ListOptions: gitlab.ListOptions{
-						Page: p,
-					},
-				})
+			projectPtrs, _, err := s.client.ListGroupProjects(path, s.listGroupProjectsOptions(p))
 			if err != nil {
 				log.Error().Err(err).Str("path", path).Int("page", p).Msg("Failed to fetch projects of next page, these projects will be missing.")
 				warnChan <- err
@@ -327,14 +779,16 @@ func mapProject(p gitlab.Project) repository.Project {
 	}
 
 	return repository.Project{
-		ID:           p.ID,
-		Name:         p.Name,
-		Slug:         p.Path,
-		GroupOrOwner: group,
-		Path:         p.PathWithNamespace,
-		WebURL:       p.WebURL,
-		RepoUrl:      p.HTTPURLToRepo,
-		Repository:   repository.Gitlab,
+		ID:            p.ID,
+		Name:          p.Name,
+		Slug:          p.Path,
+		GroupOrOwner:  group,
+		Path:          p.PathWithNamespace,
+		DefaultBranch: p.DefaultBranch,
+		WebURL:        p.WebURL,
+		RepoUrl:       p.HTTPURLToRepo,
+		Repository:    repository.Gitlab,
+		Visibility:    repository.Visibility(p.Visibility),
 	}
 }
 
@@ -1,12 +1,25 @@
 package gitlab
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sheriff/internal/cache"
+	"sheriff/internal/compress"
 	"sheriff/internal/repository"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/elliotchance/pie/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -14,14 +27,105 @@ import (
 )
 
 func TestNewService(t *testing.T) {
-	s, err := New("token")
+	s, err := New("token", AuthModePat, "", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
 
 	assert.Nil(t, err)
 	assert.NotNil(t, s)
+	assert.Equal(t, "https://gitlab.com/api/v4/", s.client.(*client).client.BaseURL().String())
+}
+
+func TestNewServiceWithBaseURL(t *testing.T) {
+	s, err := New("token", AuthModePat, "https://gitlab.example.com", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://gitlab.example.com/api/v4/", s.client.(*client).client.BaseURL().String())
+}
+
+func TestNewServiceWithInvalidBaseURL(t *testing.T) {
+	_, err := New("token", AuthModePat, "gitlab.example.com", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+	assert.NotNil(t, err)
+}
+
+func TestNewServiceWithInvalidAuthMode(t *testing.T) {
+	_, err := New("token", "oauth", "", 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+	assert.ErrorContains(t, err, "invalid gitlab auth mode oauth")
+}
+
+func TestNewServiceWithInvalidPageSize(t *testing.T) {
+	for _, pageSize := range []int{-1, 101} {
+		_, err := New("token", AuthModePat, "", 0, pageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+
+		assert.ErrorContains(t, err, fmt.Sprintf("invalid page size %v, must be between 1 and 100", pageSize))
+	}
+}
+
+func TestNewServiceSendsTokenForAuthMode(t *testing.T) {
+	testCases := []struct {
+		mode       AuthMode
+		wantHeader string
+	}{
+		{"", "PRIVATE-TOKEN"},
+		{AuthModePat, "PRIVATE-TOKEN"},
+		{AuthModeJobToken, "JOB-TOKEN"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			var headers http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers = r.Header.Clone()
+				_, _ = w.Write([]byte(`{"id": 1, "path_with_namespace": "group/project"}`))
+			}))
+			defer server.Close()
+
+			svc, err := New("secret", tc.mode, server.URL, 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+			require.NoError(t, err)
+			_, _, err = svc.client.GetProject("group/project", &gitlab.GetProjectOptions{})
+
+			require.NoError(t, err)
+			assert.Equal(t, "secret", headers.Get(tc.wantHeader))
+			for _, other := range []string{"PRIVATE-TOKEN", "JOB-TOKEN", "Authorization"} {
+				if other != tc.wantHeader {
+					assert.Empty(t, headers.Get(other))
+				}
+			}
+		})
+	}
+}
+
+func TestGetProjectListWithJobToken(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("GetProject", "group/project", mock.Anything, mock.Anything).Return(&gitlab.Project{ID: 1, PathWithNamespace: "group/project"}, &gitlab.Response{}, nil)
+	svc := gitlabService{client: &mockClient, authMode: AuthModeJobToken}
+
+	projects, warn := svc.GetProjectList([]string{"group/project", "group/**"})
+
+	require.Len(t, projects, 1)
+	assert.Equal(t, "group/project", projects[0].Path)
+	assert.ErrorIs(t, warn, errJobToken)
+	assert.ErrorContains(t, warn, "failed to get group group")
+	mockClient.AssertNotCalled(t, "CurrentUser", mock.Anything)
+	mockClient.AssertNotCalled(t, "ListGroupProjects", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestVulnerabilityIssueWithJobToken(t *testing.T) {
+	mockClient := mockClient{}
+	svc := gitlabService{client: &mockClient, authMode: AuthModeJobToken}
+
+	_, err := svc.OpenVulnerabilityIssue(repository.Project{Path: "group/project"}, repository.VulnerabilityIssueTitle, "report", nil)
+	assert.ErrorIs(t, err, errJobToken)
+
+	err = svc.CloseVulnerabilityIssue(repository.Project{Path: "group/project"}, repository.VulnerabilityIssueTitle, "")
+	assert.ErrorIs(t, err, errJobToken)
+
+	mockClient.AssertNotCalled(t, "ListProjectIssues", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestGetProjectListWithTopLevelGroup(t *testing.T) {
 	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
 	mockClient.On("ListGroupProjects", "group", mock.Anything, mock.Anything).Return([]*gitlab.Project{{Name: "Hello World"}}, &gitlab.Response{}, nil)
 
 	svc := gitlabService{client: &mockClient}
@@ -34,8 +138,25 @@ func TestGetProjectListWithTopLevelGroup(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestGetProjectListCapturesVisibility(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
+	mockClient.On("ListGroupProjects", "group", mock.Anything, mock.Anything).Return([]*gitlab.Project{
+		{ID: 1, Name: "private", PathWithNamespace: "group/private", Visibility: gitlab.PrivateVisibility},
+		{ID: 2, Name: "public", PathWithNamespace: "group/public", Visibility: gitlab.PublicVisibility},
+	}, &gitlab.Response{}, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	projects, err := svc.GetProjectList([]string{"group"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []repository.Visibility{repository.Private, repository.Public}, []repository.Visibility{projects[0].Visibility, projects[1].Visibility})
+}
+
 func TestGetProjectListWithSubGroup(t *testing.T) {
 	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
 	mockClient.On("ListGroupProjects", "group/subgroup", mock.Anything, mock.Anything).Return([]*gitlab.Project{{Name: "Hello World"}}, &gitlab.Response{}, nil)
 
 	svc := gitlabService{client: &mockClient}
@@ -48,8 +169,144 @@ func TestGetProjectListWithSubGroup(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestGetProjectListWithRecursiveGroup(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
+	mockClient.On("ListGroupProjects", "group/platform", mock.Anything, mock.Anything).Return([]*gitlab.Project{
+		{ID: 1, PathWithNamespace: "group/platform/api"},
+		{ID: 2, PathWithNamespace: "group/platform/infra/terraform"},
+	}, &gitlab.Response{}, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	projects, err := svc.GetProjectList([]string{"group/platform/**"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"group/platform/api", "group/platform/infra/terraform"}, pie.Map(projects, func(p repository.Project) string { return p.Path }))
+	mockClient.AssertExpectations(t)
+}
+
+func TestGetProjectListWithRecursiveGroupDoesNotFallBackToProject(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
+	mockClient.On("ListGroupProjects", "group/project", mock.Anything, mock.Anything).Return([]*gitlab.Project{}, &gitlab.Response{}, errors.New("no group"))
+
+	svc := gitlabService{client: &mockClient}
+
+	projects, err := svc.GetProjectList([]string{"group/project/**"})
+
+	assert.ErrorContains(t, err, "failed to get group group/project")
+	assert.Empty(t, projects)
+	mockClient.AssertNotCalled(t, "GetProject", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetProjectListSkipsNotFoundAndEmptyPaths(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
+	mockClient.On("ListGroupProjects", "group", mock.Anything, mock.Anything).Return([]*gitlab.Project{{ID: 1, PathWithNamespace: "group/project"}}, &gitlab.Response{}, nil)
+	mockClient.On("ListGroupProjects", "empty", mock.Anything, mock.Anything).Return([]*gitlab.Project{}, &gitlab.Response{}, nil)
+	mockClient.On("ListGroupProjects", "missing", mock.Anything, mock.Anything).Return([]*gitlab.Project(nil), (*gitlab.Response)(nil), gitlab.ErrNotFound)
+	mockClient.On("GetProject", "missing", mock.Anything, mock.Anything).Return((*gitlab.Project)(nil), (*gitlab.Response)(nil), gitlab.ErrNotFound)
+
+	svc := gitlabService{client: &mockClient}
+
+	projects, warn := svc.GetProjectList([]string{"group", "empty", "missing"})
+
+	assert.Nil(t, warn)
+	require.Len(t, projects, 1)
+	assert.Equal(t, "group/project", projects[0].Path)
+	mockClient.AssertExpectations(t)
+}
+
+func TestGetProjectsFromGroupOrProjectNotFoundOrEmpty(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("ListGroupProjects", "empty", mock.Anything, mock.Anything).Return([]*gitlab.Project{}, &gitlab.Response{}, nil)
+	mockClient.On("ListGroupProjects", "missing", mock.Anything, mock.Anything).Return([]*gitlab.Project(nil), (*gitlab.Response)(nil), gitlab.ErrNotFound)
+	mockClient.On("GetProject", "missing", mock.Anything, mock.Anything).Return((*gitlab.Project)(nil), (*gitlab.Response)(nil), gitlab.ErrNotFound)
+	mockClient.On("ListGroupProjects", "broken", mock.Anything, mock.Anything).Return([]*gitlab.Project(nil), (*gitlab.Response)(nil), errors.New("timeout"))
+	mockClient.On("GetProject", "broken", mock.Anything, mock.Anything).Return((*gitlab.Project)(nil), (*gitlab.Response)(nil), gitlab.ErrNotFound)
+
+	svc := gitlabService{client: &mockClient}
+
+	testCases := []struct {
+		path string
+		want error
+	}{
+		{"empty", repository.ErrPathEmpty},
+		{"empty/**", repository.ErrPathEmpty},
+		{"missing", repository.ErrPathNotFound},
+		{"missing/**", repository.ErrPathNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			_, _, err := svc.getProjectsFromGroupOrProject(tc.path)
+
+			assert.ErrorIs(t, err, tc.want)
+		})
+	}
+
+	// A group which fails to be listed is not reported as missing, even if it is not a project either
+	_, warn, err := svc.getProjectsFromGroupOrProject("broken")
+	assert.Nil(t, err)
+	assert.ErrorContains(t, warn, "failed to get group broken")
+}
+
+func TestGetProjectsFromGroupOrProjectNotFoundWithJobToken(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("GetProject", "group/missing", mock.Anything, mock.Anything).Return((*gitlab.Project)(nil), (*gitlab.Response)(nil), gitlab.ErrNotFound)
+
+	svc := gitlabService{client: &mockClient, authMode: AuthModeJobToken}
+
+	_, _, err := svc.getProjectsFromGroupOrProject("group/missing")
+
+	assert.ErrorIs(t, err, repository.ErrPathNotFound)
+}
+
+func TestGetProjectListUsesPageSize(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
+	mockClient.On("ListGroupProjects", "group", mock.MatchedBy(func(opts *gitlab.ListGroupProjectsOptions) bool {
+		return opts.PerPage == 20
+	}), mock.Anything).Return([]*gitlab.Project{{Name: "Hello World"}}, &gitlab.Response{}, nil)
+	mockClient.On("ListProjectIssues", mock.Anything, mock.MatchedBy(func(opts *gitlab.ListProjectIssuesOptions) bool {
+		return opts.PerPage == 20
+	}), mock.Anything).Return([]*gitlab.Issue{}, &gitlab.Response{}, nil)
+
+	svc := gitlabService{client: &mockClient, pageSize: 20}
+
+	projects, err := svc.GetProjectList([]string{"group"})
+	require.Nil(t, err)
+	assert.Len(t, projects, 1)
+	_, err = svc.getVulnerabilityIssues(repository.Project{ID: 1}, "title")
+	require.NoError(t, err)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestGetProjectListArchivedProjects(t *testing.T) {
+	for _, includeArchived := range []bool{false, true} {
+		t.Run(fmt.Sprintf("include archived %v", includeArchived), func(t *testing.T) {
+			mockClient := mockClient{}
+			mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
+			mockClient.On("ListGroupProjects", "group", mock.MatchedBy(func(opts *gitlab.ListGroupProjectsOptions) bool {
+				// Archived projects are only excluded if not included, listing all projects otherwise
+				return (opts.Archived == nil) == includeArchived
+			}), mock.Anything).Return([]*gitlab.Project{{Name: "Hello World"}}, &gitlab.Response{}, nil)
+
+			svc := gitlabService{client: &mockClient, filter: repository.ProjectFilter{IncludeArchived: includeArchived}}
+
+			projects, err := svc.GetProjectList([]string{"group"})
+
+			assert.Nil(t, err)
+			assert.Len(t, projects, 1)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
 func TestGetProjectListWithProjects(t *testing.T) {
 	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
 	mockClient.On("ListGroupProjects", "group/subgroup/project", mock.Anything, mock.Anything).Return([]*gitlab.Project{}, &gitlab.Response{}, errors.New("no group"))
 	mockClient.On("GetProject", "group/subgroup/project", mock.Anything, mock.Anything).Return(&gitlab.Project{Name: "Hello World", PathWithNamespace: "group/subgroup/project"}, &gitlab.Response{}, nil)
 
@@ -68,6 +325,7 @@ func TestGetProjectListWithGroupAndProjects(t *testing.T) {
 	project2 := &gitlab.Project{ID: 2, PathWithNamespace: "group/project"}
 
 	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
 	mockClient.On("ListGroupProjects", "group", mock.Anything, mock.Anything).Return([]*gitlab.Project{project1, project2}, &gitlab.Response{}, nil)
 	mockClient.On("ListGroupProjects", "group/subgroup", mock.Anything, mock.Anything).Return([]*gitlab.Project{project1}, &gitlab.Response{}, nil)
 	mockClient.On("ListGroupProjects", project1.PathWithNamespace, mock.Anything, mock.Anything).Return([]*gitlab.Project{}, &gitlab.Response{}, errors.New("no group"))
@@ -90,9 +348,9 @@ func TestGetProjectListWithNextPage(t *testing.T) {
 	project2 := &gitlab.Project{ID: 2}
 
 	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, nil)
 	mockClient.On("ListGroupProjects", "group/subgroup", &gitlab.ListGroupProjectsOptions{
 		Archived:         gitlab.Ptr(false),
-		Simple:           gitlab.Ptr(true),
 		IncludeSubGroups: gitlab.Ptr(true),
 		WithShared:       gitlab.Ptr(false),
 		ListOptions: gitlab.ListOptions{
@@ -101,7 +359,6 @@ func TestGetProjectListWithNextPage(t *testing.T) {
 	}, mock.Anything).Return([]*gitlab.Project{project1}, &gitlab.Response{NextPage: 2, TotalPages: 2}, nil)
 	mockClient.On("ListGroupProjects", "group/subgroup", &gitlab.ListGroupProjectsOptions{
 		Archived:         gitlab.Ptr(false),
-		Simple:           gitlab.Ptr(true),
 		IncludeSubGroups: gitlab.Ptr(true),
 		WithShared:       gitlab.Ptr(false),
 		ListOptions: gitlab.ListOptions{
@@ -120,14 +377,85 @@ func TestGetProjectListWithNextPage(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestGetProjectListWithForbiddenToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":"insufficient_scope"}`))
+	}))
+	defer server.Close()
+
+	svc, err := New("token", AuthModePat, server.URL, 0, repository.MaxPageSize, 0, compress.NewLimits(1<<20), nil, repository.ProjectFilter{})
+	require.NoError(t, err)
+
+	projects, err := svc.GetProjectList([]string{"group"})
+
+	assert.Empty(t, projects)
+	assert.ErrorIs(t, err, errTokenPermission)
+	assert.ErrorContains(t, err, "gitlab token is missing required scope 'api' or is expired")
+}
+
+func TestGetProjectListWithFailingTokenValidation(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("CurrentUser", mock.Anything).Return(&gitlab.User{}, &gitlab.Response{}, errors.New("timeout"))
+	mockClient.On("ListGroupProjects", "group", mock.Anything, mock.Anything).Return([]*gitlab.Project{{Name: "Hello World"}}, &gitlab.Response{}, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	projects, err := svc.GetProjectList([]string{"group"})
+
+	assert.Nil(t, err)
+	assert.Len(t, projects, 1)
+	mockClient.AssertExpectations(t)
+}
+
+func TestIsPermissionError(t *testing.T) {
+	errorResponse := func(status int) error {
+		return &gitlab.ErrorResponse{Response: &http.Response{StatusCode: status, Request: &http.Request{Method: http.MethodGet, URL: &url.URL{}}}}
+	}
+
+	assert.True(t, isPermissionError(errorResponse(http.StatusUnauthorized)))
+	assert.True(t, isPermissionError(errors.Join(errors.New("wrapped"), errorResponse(http.StatusForbidden))))
+	assert.False(t, isPermissionError(errorResponse(http.StatusInternalServerError)))
+	assert.False(t, isPermissionError(gitlab.ErrNotFound))
+	assert.False(t, isPermissionError(nil))
+}
+
 func TestCloseVulnerabilityIssue(t *testing.T) {
 	mockClient := mockClient{}
-	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{{IID: 2, State: "opened"}}, nil, nil)
+	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{{IID: 2, State: "opened", Title: repository.VulnerabilityIssueTitle}}, nil, nil)
+	mockClient.On("UpdateIssue", 1, 2, mock.Anything, mock.Anything).Return(&gitlab.Issue{State: "closed"}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	err := svc.CloseVulnerabilityIssue(repository.Project{ID: 1}, repository.VulnerabilityIssueTitle, "")
+
+	assert.Nil(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestCloseVulnerabilityIssueWithComment(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{{IID: 2, State: "opened", Title: repository.VulnerabilityIssueTitle}}, nil, nil)
+	mockClient.On("CreateIssueNote", 1, 2, mock.MatchedBy(func(opt *gitlab.CreateIssueNoteOptions) bool { return *opt.Body == "all good" }), mock.Anything).Return(&gitlab.Note{}, nil, nil)
 	mockClient.On("UpdateIssue", 1, 2, mock.Anything, mock.Anything).Return(&gitlab.Issue{State: "closed"}, nil, nil)
 
 	svc := gitlabService{client: &mockClient}
 
-	err := svc.CloseVulnerabilityIssue(repository.Project{ID: 1})
+	err := svc.CloseVulnerabilityIssue(repository.Project{ID: 1}, repository.VulnerabilityIssueTitle, "all good")
+
+	assert.Nil(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestCloseVulnerabilityIssueCommentFails(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{{IID: 2, State: "opened", Title: repository.VulnerabilityIssueTitle}}, nil, nil)
+	mockClient.On("CreateIssueNote", 1, 2, mock.Anything, mock.Anything).Return(&gitlab.Note{}, nil, errors.New("error"))
+	mockClient.On("UpdateIssue", 1, 2, mock.Anything, mock.Anything).Return(&gitlab.Issue{State: "closed"}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	err := svc.CloseVulnerabilityIssue(repository.Project{ID: 1}, repository.VulnerabilityIssueTitle, "all good")
 
 	assert.Nil(t, err)
 	mockClient.AssertExpectations(t)
@@ -135,11 +463,11 @@ func TestCloseVulnerabilityIssue(t *testing.T) {
 
 func TestCloseVulnerabilityIssueAlreadyClosed(t *testing.T) {
 	mockClient := mockClient{}
-	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{{State: "closed"}}, nil, nil)
+	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{{State: "closed", Title: repository.VulnerabilityIssueTitle}}, nil, nil)
 
 	svc := gitlabService{client: &mockClient}
 
-	err := svc.CloseVulnerabilityIssue(repository.Project{})
+	err := svc.CloseVulnerabilityIssue(repository.Project{}, repository.VulnerabilityIssueTitle, "")
 
 	assert.Nil(t, err)
 	mockClient.AssertExpectations(t)
@@ -151,7 +479,7 @@ func TestCloseVulnerabilityIssueNoIssue(t *testing.T) {
 
 	svc := gitlabService{client: &mockClient}
 
-	err := svc.CloseVulnerabilityIssue(repository.Project{})
+	err := svc.CloseVulnerabilityIssue(repository.Project{}, repository.VulnerabilityIssueTitle, "")
 
 	assert.Nil(t, err)
 	mockClient.AssertExpectations(t)
@@ -164,10 +492,147 @@ func TestOpenVulnerabilityIssue(t *testing.T) {
 
 	svc := gitlabService{client: &mockClient}
 
-	i, err := svc.OpenVulnerabilityIssue(repository.Project{}, "report")
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{}, repository.VulnerabilityIssueTitle, "report", nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, i)
 	assert.Equal(t, "666", i.Title)
+	assert.Empty(t, i.PreviousBody)
+}
+
+func TestOpenVulnerabilityIssueClosesDuplicates(t *testing.T) {
+	title := repository.VulnerabilityIssueTitle
+	mockClient := mockClient{}
+	// Most recent first, as listed by GitLab
+	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{
+		{IID: 7, State: "opened", Title: title},
+		{IID: 5, State: "closed", Title: title},
+		{IID: 3, State: "opened", Title: title, Description: "previous"},
+	}, nil, nil)
+	mockClient.On("UpdateIssue", 1, 3, mock.MatchedBy(func(opts *gitlab.UpdateIssueOptions) bool { return opts.Description != nil }), mock.Anything).Return(&gitlab.Issue{IID: 3, State: "opened"}, nil, nil).Once()
+	mockClient.On("CreateIssueNote", 1, 7, mock.MatchedBy(func(opt *gitlab.CreateIssueNoteOptions) bool { return strings.Contains(*opt.Body, "duplicate of #3") }), mock.Anything).Return(&gitlab.Note{}, nil, nil).Once()
+	mockClient.On("UpdateIssue", 1, 7, &gitlab.UpdateIssueOptions{StateEvent: gitlab.Ptr("close")}, mock.Anything).Return(&gitlab.Issue{IID: 7, State: "closed"}, nil, nil).Once()
+
+	svc := gitlabService{client: &mockClient}
+
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{ID: 1}, title, "report", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "previous", i.PreviousBody, "the oldest open issue is kept")
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "UpdateIssue", 1, 5, mock.Anything, mock.Anything)
+}
+
+func TestCloseVulnerabilityIssueClosesDuplicates(t *testing.T) {
+	title := repository.VulnerabilityIssueTitle
+	mockClient := mockClient{}
+	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{
+		{IID: 7, State: "opened", Title: title},
+		{IID: 3, State: "opened", Title: title},
+	}, nil, nil)
+	mockClient.On("UpdateIssue", 1, 3, mock.Anything, mock.Anything).Return(&gitlab.Issue{State: "closed"}, nil, nil).Once()
+	mockClient.On("UpdateIssue", 1, 7, mock.Anything, mock.Anything).Return(&gitlab.Issue{State: "closed"}, nil, nil).Once()
+
+	svc := gitlabService{client: &mockClient}
+
+	err := svc.CloseVulnerabilityIssue(repository.Project{ID: 1}, title, "")
+
+	assert.Nil(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestOpenVulnerabilityIssueRetriesServerErrors(t *testing.T) {
+	unavailable := &gitlab.ErrorResponse{Response: &http.Response{StatusCode: http.StatusServiceUnavailable, Request: &http.Request{Method: http.MethodPost, URL: &url.URL{}}}}
+	mockClient := mockClient{}
+	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{}, nil, nil)
+	mockClient.On("CreateIssue", mock.Anything, mock.Anything, mock.Anything).Return((*gitlab.Issue)(nil), nil, unavailable).Once()
+	mockClient.On("CreateIssue", mock.Anything, mock.Anything, mock.Anything).Return(&gitlab.Issue{Title: "666"}, nil, nil).Once()
+
+	svc := gitlabService{client: &mockClient, issueAttempts: 3, issueBackoff: time.Microsecond}
+
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{}, repository.VulnerabilityIssueTitle, "report", nil)
+
+	assert.Nil(t, err)
+	require.NotNil(t, i)
+	assert.Equal(t, "666", i.Title)
+	mockClient.AssertNumberOfCalls(t, "CreateIssue", 2)
+}
+
+func TestOpenVulnerabilityIssueDoesNotRetryClientErrors(t *testing.T) {
+	forbidden := &gitlab.ErrorResponse{Response: &http.Response{StatusCode: http.StatusForbidden, Request: &http.Request{Method: http.MethodPost, URL: &url.URL{}}}}
+	mockClient := mockClient{}
+	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{}, nil, nil)
+	mockClient.On("CreateIssue", mock.Anything, mock.Anything, mock.Anything).Return((*gitlab.Issue)(nil), nil, forbidden)
+
+	svc := gitlabService{client: &mockClient, issueAttempts: 3, issueBackoff: time.Microsecond}
+
+	_, err := svc.OpenVulnerabilityIssue(repository.Project{}, repository.VulnerabilityIssueTitle, "report", nil)
+
+	assert.ErrorIs(t, err, forbidden)
+	mockClient.AssertNumberOfCalls(t, "CreateIssue", 1)
+}
+
+func TestIsTransientError(t *testing.T) {
+	response := func(status int) error {
+		return &gitlab.ErrorResponse{Response: &http.Response{StatusCode: status, Request: &http.Request{Method: http.MethodPut, URL: &url.URL{}}}}
+	}
+
+	assert.True(t, isTransientError(response(http.StatusBadGateway)))
+	assert.True(t, isTransientError(response(http.StatusServiceUnavailable)))
+	assert.True(t, isTransientError(errors.New("connection reset by peer")))
+	assert.False(t, isTransientError(response(http.StatusBadRequest)))
+	assert.False(t, isTransientError(response(http.StatusForbidden)))
+}
+
+func TestOpenVulnerabilityIssueOnSecondPage(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("ListProjectIssues", 1, mock.MatchedBy(func(opt *gitlab.ListProjectIssuesOptions) bool { return opt.Page == 1 }), mock.Anything).Return([]*gitlab.Issue{{IID: 1, Title: repository.VulnerabilityIssueTitle + " (old)"}}, &gitlab.Response{NextPage: 2}, nil).Once()
+	mockClient.On("ListProjectIssues", 1, mock.MatchedBy(func(opt *gitlab.ListProjectIssuesOptions) bool { return opt.Page == 2 }), mock.Anything).Return([]*gitlab.Issue{{IID: 2, Title: repository.VulnerabilityIssueTitle, Description: "previous report"}}, &gitlab.Response{NextPage: 0}, nil).Once()
+	mockClient.On("UpdateIssue", 1, 2, mock.Anything, mock.Anything).Return(&gitlab.Issue{IID: 2, State: "opened", Title: repository.VulnerabilityIssueTitle}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{ID: 1}, repository.VulnerabilityIssueTitle, "report", nil)
+
+	assert.Nil(t, err)
+	require.NotNil(t, i)
+	assert.Equal(t, "previous report", i.PreviousBody)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "CreateIssue", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOpenVulnerabilityIssueWithCustomTitle(t *testing.T) {
+	title := "Sheriff - 🐳 Container report"
+	mockClient := mockClient{}
+	mockClient.On("ListProjectIssues", 1, mock.MatchedBy(func(opt *gitlab.ListProjectIssuesOptions) bool { return *opt.Search == title }), mock.Anything).Return([]*gitlab.Issue{{IID: 1, Title: repository.VulnerabilityIssueTitle}}, &gitlab.Response{NextPage: 0}, nil)
+	mockClient.On("CreateIssue", 1, mock.MatchedBy(func(opt *gitlab.CreateIssueOptions) bool { return *opt.Title == title }), mock.Anything).Return(&gitlab.Issue{IID: 2, Title: title}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{ID: 1}, title, "report", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, title, i.Title)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "UpdateIssue", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOpenVulnerabilityIssueWithAssignees(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("ListProjectIssues", mock.Anything, mock.Anything, mock.Anything).Return([]*gitlab.Issue{}, nil, nil)
+	mockClient.On("ListUsers", mock.MatchedBy(func(opt *gitlab.ListUsersOptions) bool { return *opt.Username == "alice" }), mock.Anything).Return([]*gitlab.User{{ID: 10, Username: "alice"}}, nil, nil)
+	mockClient.On("ListUsers", mock.MatchedBy(func(opt *gitlab.ListUsersOptions) bool { return *opt.Username == "unknown" }), mock.Anything).Return([]*gitlab.User{}, nil, nil)
+	mockClient.On("ListUsers", mock.MatchedBy(func(opt *gitlab.ListUsersOptions) bool { return *opt.Username == "bob" }), mock.Anything).Return([]*gitlab.User{}, nil, errors.New("error"))
+	mockClient.On("CreateIssue", 1, mock.MatchedBy(func(opt *gitlab.CreateIssueOptions) bool {
+		return assert.ObjectsAreEqual([]int{10}, *opt.AssigneeIDs)
+	}), mock.Anything).Return(&gitlab.Issue{IID: 2}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	i, err := svc.OpenVulnerabilityIssue(repository.Project{ID: 1}, repository.VulnerabilityIssueTitle, "report", []string{"alice", "unknown", "bob"})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, i)
+	mockClient.AssertExpectations(t)
 }
 
 func TestFilterUniqueProjects(t *testing.T) {
@@ -202,6 +667,47 @@ func TestDereferenceProjectsPointers(t *testing.T) {
 	assert.Equal(t, 2, dereferencedProjects[1].ID)
 	assert.Equal(t, 2, errCount)
 }
+func TestDownloadExceedingArchiveLimits(t *testing.T) {
+	stubArchive, err := os.ReadFile("../testdata/sample-archive.tar.gz")
+	require.NoError(t, err)
+
+	mockClient := mockClient{}
+	mockClient.On("Archive", 123, mock.Anything, mock.Anything).Return(stubArchive, &gitlab.Response{}, nil)
+
+	svc := gitlabService{client: &mockClient, archiveLimits: compress.NewLimits(10)}
+
+	_, err = svc.Download(context.Background(), repository.Project{ID: 123, Path: "group/project"}, t.TempDir())
+
+	assert.ErrorContains(t, err, "exceeds the maximum size")
+	assert.ErrorIs(t, err, compress.ErrLimitExceeded)
+}
+
+func TestDownloadMarksRejectedRequests(t *testing.T) {
+	testCases := map[int]bool{
+		http.StatusNotFound:           true,
+		http.StatusUnauthorized:       true,
+		http.StatusForbidden:          true,
+		http.StatusTooManyRequests:    false,
+		http.StatusServiceUnavailable: false,
+	}
+
+	for status, wantRejected := range testCases {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			errResp := &gitlab.ErrorResponse{Response: &http.Response{StatusCode: status, Request: &http.Request{Method: http.MethodGet, URL: &url.URL{}}}}
+			mockClient := mockClient{}
+			mockClient.On("Archive", 123, mock.Anything, mock.Anything).Return([]byte(nil), &gitlab.Response{}, errResp)
+
+			svc := gitlabService{client: &mockClient}
+
+			_, err := svc.Download(context.Background(), repository.Project{ID: 123, Path: "group/project"}, t.TempDir())
+
+			var rejected *repository.RejectedError
+			assert.Equal(t, wantRejected, errors.As(err, &rejected), err)
+			assert.ErrorContains(t, err, "failed to download archive")
+		})
+	}
+}
+
 func TestDownload(t *testing.T) {
 	// Create temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "sheriff-clone-test-")
@@ -224,10 +730,12 @@ func TestDownload(t *testing.T) {
 		Path:         "group/project",
 	}
 
-	err = svc.Download(testProject, tempDir)
+	sha, err := svc.Download(context.Background(), testProject, tempDir)
 
 	// Verify no errors
 	assert.NoError(t, err)
+	// The commit is unknown without a default branch
+	assert.Empty(t, sha)
 	mockClient.AssertExpectations(t)
 
 	// Verify files were extracted correctly
@@ -244,6 +752,193 @@ func TestDownload(t *testing.T) {
 	assert.NoError(t, err, "src directory should exist")
 }
 
+func TestDownloadPinsLatestCommit(t *testing.T) {
+	stubArchive, err := os.ReadFile("../testdata/sample-archive.tar.gz")
+	require.NoError(t, err)
+
+	project := repository.Project{ID: 123, Path: "group/project", DefaultBranch: "main", Repository: repository.Gitlab}
+
+	mockClient := mockClient{}
+	mockClient.On("GetBranch", 123, "main", mock.Anything).Return(&gitlab.Branch{Commit: &gitlab.Commit{ID: "abc123"}}, &gitlab.Response{}, nil)
+	mockClient.On("Archive", 123, &gitlab.ArchiveOptions{SHA: gitlab.Ptr("abc123")}, mock.Anything).Return(stubArchive, &gitlab.Response{}, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	sha, err := svc.Download(context.Background(), project, t.TempDir())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", sha)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDownloadRefFailsWithoutBranch(t *testing.T) {
+	project := repository.Project{ID: 123, Path: "group/project", DefaultBranch: "main", Ref: "release", Repository: repository.Gitlab}
+
+	mockClient := mockClient{}
+	mockClient.On("GetBranch", 123, "release", mock.Anything).Return((*gitlab.Branch)(nil), &gitlab.Response{}, errors.New("404 Not Found"))
+
+	svc := gitlabService{client: &mockClient}
+
+	_, err := svc.Download(context.Background(), project, t.TempDir())
+
+	assert.ErrorContains(t, err, "failed to get branch release")
+	mockClient.AssertNotCalled(t, "Archive", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDownloadFromCacheWhenUnchanged(t *testing.T) {
+	stubArchive, err := os.ReadFile("../testdata/sample-archive.tar.gz")
+	require.NoError(t, err)
+
+	archiveCache, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+
+	project := repository.Project{ID: 123, Path: "group/project", DefaultBranch: "main", Repository: repository.Gitlab}
+	require.NoError(t, archiveCache.Store(cache.ProjectKey(project), "abc123", bytes.NewReader(stubArchive)))
+
+	mockClient := mockClient{}
+	mockClient.On("GetBranch", 123, "main", mock.Anything).Return(&gitlab.Branch{Commit: &gitlab.Commit{ID: "abc123"}}, &gitlab.Response{}, nil)
+
+	svc := gitlabService{client: &mockClient, archiveCache: archiveCache}
+
+	dir := t.TempDir()
+	sha, err := svc.Download(context.Background(), project, dir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", sha)
+	assert.FileExists(t, filepath.Join(dir, "README.md"))
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "Archive", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDownloadStoresInCacheWhenChanged(t *testing.T) {
+	stubArchive, err := os.ReadFile("../testdata/sample-archive.tar.gz")
+	require.NoError(t, err)
+
+	archiveCache, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+
+	project := repository.Project{ID: 123, Path: "group/project", DefaultBranch: "main", Repository: repository.Gitlab}
+	require.NoError(t, archiveCache.Store(cache.ProjectKey(project), "old", bytes.NewReader(stubArchive)))
+
+	mockClient := mockClient{}
+	mockClient.On("GetBranch", 123, "main", mock.Anything).Return(&gitlab.Branch{Commit: &gitlab.Commit{ID: "new"}}, &gitlab.Response{}, nil)
+	mockClient.On("Archive", 123, &gitlab.ArchiveOptions{SHA: gitlab.Ptr("new")}, mock.Anything).Return(stubArchive, &gitlab.Response{}, nil)
+
+	svc := gitlabService{client: &mockClient, archiveCache: archiveCache}
+
+	sha, err := svc.Download(context.Background(), project, t.TempDir())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "new", sha)
+	mockClient.AssertExpectations(t)
+	_, ok := archiveCache.Open(cache.ProjectKey(project), "new")
+	assert.True(t, ok)
+}
+
+func TestGetFile(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("GetFile", 1, "sheriff.toml", &gitlab.GetFileOptions{Ref: gitlab.Ptr("main")}, mock.Anything).Return(&gitlab.File{Content: base64.StdEncoding.EncodeToString([]byte("ignored = []"))}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	content, found, err := svc.GetFile(repository.Project{ID: 1, DefaultBranch: "main"}, "sheriff.toml")
+
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "ignored = []", string(content))
+}
+
+func TestGetFileNotFound(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("GetFile", 1, "sheriff.toml", mock.Anything, mock.Anything).Return((*gitlab.File)(nil), nil, gitlab.ErrNotFound)
+
+	svc := gitlabService{client: &mockClient}
+
+	_, found, err := svc.GetFile(repository.Project{ID: 1, DefaultBranch: "main"}, "sheriff.toml")
+
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestCommitFileCreatesMissingFile(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("GetFile", 1, "sheriff.toml", &gitlab.GetFileOptions{Ref: gitlab.Ptr("fix")}, mock.Anything).Return((*gitlab.File)(nil), nil, gitlab.ErrNotFound)
+	mockClient.On("CreateFile", 1, "sheriff.toml", &gitlab.CreateFileOptions{
+		Branch:        gitlab.Ptr("fix"),
+		Content:       gitlab.Ptr("content"),
+		CommitMessage: gitlab.Ptr("message"),
+	}, mock.Anything).Return(&gitlab.FileInfo{}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	err := svc.CommitFile(repository.Project{ID: 1}, "fix", "sheriff.toml", []byte("content"), "message")
+
+	assert.Nil(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestCommitFileUpdatesExistingFile(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("GetFile", 1, "sheriff.toml", mock.Anything, mock.Anything).Return(&gitlab.File{LastCommitID: "abc"}, nil, nil)
+	mockClient.On("UpdateFile", 1, "sheriff.toml", &gitlab.UpdateFileOptions{
+		Branch:        gitlab.Ptr("fix"),
+		Content:       gitlab.Ptr("content"),
+		CommitMessage: gitlab.Ptr("message"),
+		LastCommitID:  gitlab.Ptr("abc"),
+	}, mock.Anything).Return(&gitlab.FileInfo{}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	err := svc.CommitFile(repository.Project{ID: 1}, "fix", "sheriff.toml", []byte("content"), "message")
+
+	assert.Nil(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestUploadIssueAttachment(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("UploadProjectMarkdown", 1, mock.Anything, "osv-scanner.json", mock.Anything).Return(&gitlab.ProjectMarkdownUploadedFile{Markdown: "[osv-scanner.json](/uploads/abc/osv-scanner.json)"}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+
+	markdown, err := svc.UploadIssueAttachment(repository.Project{ID: 1}, "osv-scanner.json", []byte(`{"results": []}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "[osv-scanner.json](/uploads/abc/osv-scanner.json)", markdown)
+	content, _ := io.ReadAll(mockClient.Calls[0].Arguments.Get(1).(io.Reader))
+	assert.Equal(t, `{"results": []}`, string(content))
+}
+
+func TestUploadIssueAttachmentFails(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("UploadProjectMarkdown", 1, mock.Anything, "osv-scanner.json", mock.Anything).Return((*gitlab.ProjectMarkdownUploadedFile)(nil), nil, errors.New("413 Request Entity Too Large"))
+
+	svc := gitlabService{client: &mockClient}
+
+	_, err := svc.UploadIssueAttachment(repository.Project{ID: 1}, "osv-scanner.json", []byte(`{}`))
+
+	assert.ErrorContains(t, err, "failed to upload osv-scanner.json")
+}
+
+func TestOpenMergeRequest(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("CreateBranch", 1, &gitlab.CreateBranchOptions{Branch: gitlab.Ptr("fix"), Ref: gitlab.Ptr("main")}, mock.Anything).Return(&gitlab.Branch{}, nil, nil)
+	mockClient.On("CreateMergeRequest", 1, mock.MatchedBy(func(opt *gitlab.CreateMergeRequestOptions) bool {
+		return *opt.SourceBranch == "fix" && *opt.TargetBranch == "main" && *opt.Title == "title"
+	}), mock.Anything).Return(&gitlab.MergeRequest{BasicMergeRequest: gitlab.BasicMergeRequest{WebURL: "https://gitlab.com/group/project/-/merge_requests/1"}}, nil, nil)
+
+	svc := gitlabService{client: &mockClient}
+	project := repository.Project{ID: 1, DefaultBranch: "main"}
+
+	err := svc.CreateBranch(project, "fix")
+	assert.Nil(t, err)
+	url, err := svc.OpenMergeRequest(project, "fix", "title", "description")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://gitlab.com/group/project/-/merge_requests/1", url)
+	mockClient.AssertExpectations(t)
+}
+
 type mockClient struct {
 	mock.Mock
 }
@@ -293,6 +988,15 @@ func (c *mockClient) UpdateIssue(projectId interface{}, issueId int, opt *gitlab
 	return args.Get(0).(*gitlab.Issue), r, args.Error(2)
 }
 
+func (c *mockClient) CreateIssueNote(projectId interface{}, issueId int, opt *gitlab.CreateIssueNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Note, *gitlab.Response, error) {
+	args := c.Called(projectId, issueId, opt, options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).(*gitlab.Note), r, args.Error(2)
+}
+
 func (c *mockClient) Archive(pid interface{}, opt *gitlab.ArchiveOptions, options ...gitlab.RequestOptionFunc) ([]byte, *gitlab.Response, error) {
 	args := c.Called(pid, opt, options)
 	var r *gitlab.Response
@@ -301,3 +1005,84 @@ func (c *mockClient) Archive(pid interface{}, opt *gitlab.ArchiveOptions, option
 	}
 	return args.Get(0).([]byte), r, args.Error(2)
 }
+
+func (c *mockClient) ListUsers(opt *gitlab.ListUsersOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.User, *gitlab.Response, error) {
+	args := c.Called(opt, options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).([]*gitlab.User), r, args.Error(2)
+}
+
+func (c *mockClient) GetBranch(pid interface{}, branch string, options ...gitlab.RequestOptionFunc) (*gitlab.Branch, *gitlab.Response, error) {
+	args := c.Called(pid, branch, options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).(*gitlab.Branch), r, args.Error(2)
+}
+
+func (c *mockClient) CurrentUser(options ...gitlab.RequestOptionFunc) (*gitlab.User, *gitlab.Response, error) {
+	args := c.Called(options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).(*gitlab.User), r, args.Error(2)
+}
+
+func (c *mockClient) GetFile(pid interface{}, fileName string, opt *gitlab.GetFileOptions, options ...gitlab.RequestOptionFunc) (*gitlab.File, *gitlab.Response, error) {
+	args := c.Called(pid, fileName, opt, options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).(*gitlab.File), r, args.Error(2)
+}
+
+func (c *mockClient) CreateFile(pid interface{}, fileName string, opt *gitlab.CreateFileOptions, options ...gitlab.RequestOptionFunc) (*gitlab.FileInfo, *gitlab.Response, error) {
+	args := c.Called(pid, fileName, opt, options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).(*gitlab.FileInfo), r, args.Error(2)
+}
+
+func (c *mockClient) UpdateFile(pid interface{}, fileName string, opt *gitlab.UpdateFileOptions, options ...gitlab.RequestOptionFunc) (*gitlab.FileInfo, *gitlab.Response, error) {
+	args := c.Called(pid, fileName, opt, options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).(*gitlab.FileInfo), r, args.Error(2)
+}
+
+func (c *mockClient) CreateBranch(pid interface{}, opt *gitlab.CreateBranchOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Branch, *gitlab.Response, error) {
+	args := c.Called(pid, opt, options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).(*gitlab.Branch), r, args.Error(2)
+}
+
+func (c *mockClient) CreateMergeRequest(pid interface{}, opt *gitlab.CreateMergeRequestOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	args := c.Called(pid, opt, options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).(*gitlab.MergeRequest), r, args.Error(2)
+}
+
+func (c *mockClient) UploadProjectMarkdown(pid interface{}, content io.Reader, filename string, options ...gitlab.RequestOptionFunc) (*gitlab.ProjectMarkdownUploadedFile, *gitlab.Response, error) {
+	args := c.Called(pid, content, filename, options)
+	var r *gitlab.Response
+	if resp := args.Get(1); resp != nil {
+		r = args.Get(1).(*gitlab.Response)
+	}
+	return args.Get(0).(*gitlab.ProjectMarkdownUploadedFile), r, args.Error(2)
+}
@@ -3,9 +3,12 @@ package provider
 import (
 	"errors"
 	"fmt"
+	"sheriff/internal/cache"
+	"sheriff/internal/compress"
 	"sheriff/internal/repository"
 	"sheriff/internal/repository/github"
 	"sheriff/internal/repository/gitlab"
+	"time"
 )
 
 // IProvider is the interface of the repository service as needed by sheriff
@@ -18,13 +21,26 @@ type provider struct {
 	githubService repository.IRepositoryService
 }
 
-func NewProvider(gitlabToken string, githubToken string) (IProvider, error) {
-	gitlabService, err := gitlab.New(gitlabToken)
+// NewProvider creates the repository services of all supported platforms.
+// The gitlabAuthMode tells whether the gitlabToken is an access token or a CI job token, see gitlab.AuthMode.
+// The gitlabURL points to a self-managed GitLab instance, gitlab.com is used if empty.
+// The githubURL points to a GitHub Enterprise Server instance, github.com is used if empty.
+// If the githubApp credentials are set, GitHub is accessed as that app installation rather than with the githubToken.
+// The API requests to each platform are throttled to apiRateLimit per second, 0 disabling the throttling.
+// Listings are fetched in pages of pageSize items, up to repository.MaxPageSize, which is used if 0.
+// Each archive download is aborted after downloadTimeout, 0 disabling the deadline.
+// Downloaded archives are extracted within the given limits, and kept in the archive cache if it is not nil.
+// The filter selects the projects listed from the groups and owners to scan.
+func NewProvider(gitlabToken string, gitlabAuthMode gitlab.AuthMode, gitlabURL string, githubToken string, githubApp github.AppCredentials, githubURL string, apiRateLimit float64, pageSize int, downloadTimeout time.Duration, archiveLimits compress.Limits, archiveCache *cache.ArchiveCache, filter repository.ProjectFilter) (IProvider, error) {
+	gitlabService, err := gitlab.New(gitlabToken, gitlabAuthMode, gitlabURL, apiRateLimit, pageSize, downloadTimeout, archiveLimits, archiveCache, filter)
 	if err != nil {
 		return nil, errors.Join(fmt.Errorf("failed to create gitlab provider"), err)
 	}
 
-	githubService := github.New(githubToken)
+	githubService, err := github.New(githubToken, githubApp, githubURL, apiRateLimit, pageSize, downloadTimeout, archiveLimits, archiveCache, filter)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to create github provider"), err)
+	}
 
 	return provider{
 		gitlabService: gitlabService,
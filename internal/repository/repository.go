@@ -1,7 +1,45 @@
 package repository
 
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// VulnerabilityIssueTitle is the default title of the vulnerability issue
 const VulnerabilityIssueTitle = "Sheriff - 🚨 Vulnerability report"
 
+// ErrPathNotFound tells that a path to scan is neither a group (or owner) nor a project
+var ErrPathNotFound = errors.New("path not found")
+
+// ErrPathEmpty tells that a path to scan is a group (or owner) without any project to scan
+var ErrPathEmpty = errors.New("path has no projects to scan")
+
+// ErrNotSupported tells that an operation is not supported by the platform of the project
+var ErrNotSupported = errors.New("not supported by the platform")
+
+// RejectedError is the error of a request which the platform rejected with a 4xx response, e.g. because the project
+// does not exist or the token is invalid. Unlike network errors and 5xx responses, it would fail again if retried.
+type RejectedError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *RejectedError) Error() string { return e.Err.Error() }
+
+func (e *RejectedError) Unwrap() error { return e.Err }
+
+// NewRejectedError returns the error as a RejectedError if the status code of its response rejects the request,
+// or as is otherwise. 408 and 429 responses only tell to try again later, so they do not reject the request.
+func NewRejectedError(statusCode int, err error) error {
+	if statusCode < http.StatusBadRequest || statusCode >= http.StatusInternalServerError ||
+		statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests {
+		return err
+	}
+
+	return &RejectedError{StatusCode: statusCode, Err: err}
+}
+
 type RepositoryType string
 
 const (
@@ -9,15 +47,46 @@ const (
 	Github RepositoryType = "github"
 )
 
+// Visibility tells who can see a project
+type Visibility string
+
+const (
+	Private  Visibility = "private"
+	Internal Visibility = "internal" // Visible to all the users of the instance or enterprise
+	Public   Visibility = "public"
+)
+
 type Project struct {
-	ID           int
-	Name         string
-	Slug         string
-	GroupOrOwner string
-	Path         string
-	WebURL       string
-	RepoUrl      string
-	Repository   RepositoryType
+	ID            int
+	Name          string
+	Slug          string
+	GroupOrOwner  string
+	Path          string
+	DefaultBranch string
+	WebURL        string
+	RepoUrl       string
+	Repository    RepositoryType
+	Ref           string     // Branch downloaded and scanned instead of the default branch, if set
+	Visibility    Visibility // Empty if unknown
+}
+
+// ScannedRef returns the branch of the project which is downloaded and scanned
+func (p Project) ScannedRef() string {
+	if p.Ref != "" {
+		return p.Ref
+	}
+
+	return p.DefaultBranch
+}
+
+// MaxPageSize is the largest number of items per page of the listings of the GitLab and GitHub APIs
+const MaxPageSize = 100
+
+// ProjectFilter selects the projects listed from the groups and owners to scan.
+// Projects targeted by their own path are always listed.
+type ProjectFilter struct {
+	IncludeArchived bool
+	IncludeForks    bool // Only applies to GitHub, whose listing does not tell the forks of GitLab
 }
 
 type Issue struct {
@@ -25,11 +94,39 @@ type Issue struct {
 	Title  string
 	WebURL string
 	Open   bool
+	// PreviousBody is the body of the issue before it was updated by OpenVulnerabilityIssue,
+	// empty if the issue was just created.
+	PreviousBody string
 }
 
 type IRepositoryService interface {
+	// GetProjectList returns the projects of the given groups (or owners) and projects.
+	// Paths which are not found or have no project to scan are logged and skipped without a warning,
+	// so that the caller can still scan the projects of the other paths.
 	GetProjectList(paths []string) (projects []Project, warn error)
-	CloseVulnerabilityIssue(project Project) error
-	OpenVulnerabilityIssue(project Project, report string) (*Issue, error)
-	Download(project Project, dir string) error
+	// CloseVulnerabilityIssue closes the issue with the given title, if any.
+	// If comment is not empty, it is posted on the issue before closing it.
+	CloseVulnerabilityIssue(project Project, title string, comment string) error
+	// OpenVulnerabilityIssue creates, updates or reopens the issue with the given title.
+	// The assignees (usernames) are only set when the issue is created.
+	OpenVulnerabilityIssue(project Project, title string, report string, assignees []string) (*Issue, error)
+	// Download downloads the project's default branch into the given directory.
+	// It returns the sha of the downloaded commit, which is empty if it is not known.
+	Download(ctx context.Context, project Project, dir string) (sha string, err error)
+	// GetLatestCommitSha returns the sha of the latest commit of the project's default branch,
+	// without downloading the project.
+	GetLatestCommitSha(project Project) (sha string, err error)
+	// GetFile returns the content of the file at the given path of the project's default branch.
+	// found is false if there is no such file.
+	GetFile(project Project, path string) (content []byte, found bool, err error)
+	// CreateBranch creates a branch with the given name from the project's default branch.
+	CreateBranch(project Project, branch string) error
+	// CommitFile commits the content of the file at the given path to the branch, creating the file if it does not exist.
+	CommitFile(project Project, branch string, path string, content []byte, message string) error
+	// OpenMergeRequest opens a merge request (pull request on GitHub) of the branch into the project's default branch.
+	// It returns the web URL of the merge request.
+	OpenMergeRequest(project Project, branch string, title string, description string) (webURL string, err error)
+	// UploadIssueAttachment uploads the file to the project, to be linked from its issues.
+	// It returns the markdown linking to the file, and an error wrapping ErrNotSupported on platforms which cannot upload files.
+	UploadIssueAttachment(project Project, filename string, content []byte) (markdown string, err error)
 }
@@ -0,0 +1,50 @@
+// Package retry provides a helper to retry operations which may fail transiently.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Do runs the operation until it succeeds, at most maxAttempts times.
+// Between attempts it waits with an exponential backoff: backoff * 2^(attempt-1).
+// It stops retrying as soon as the context is cancelled.
+func Do(ctx context.Context, operation func() error, maxAttempts int, backoff time.Duration) (err error) {
+	return DoIf(ctx, operation, func(error) bool { return true }, maxAttempts, backoff)
+}
+
+// DoIf runs the operation like Do, but only retries it while it fails with errors which are retryable.
+// A non-retryable error, e.g. a rejected request, is returned right away.
+func DoIf(ctx context.Context, operation func() error, retryable func(error) bool, maxAttempts int, backoff time.Duration) (err error) {
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = operation(); err == nil {
+			return nil
+		}
+
+		if !retryable(err) {
+			return err
+		}
+
+		if attempt == maxAttempts {
+			break
+		}
+
+		sleepDuration := backoff * time.Duration(1<<(attempt-1))
+		log.Warn().Err(err).Int("attempt", attempt).Dur("backoff", sleepDuration).Msg("Operation failed, retrying with exponential backoff")
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(sleepDuration):
+		}
+	}
+
+	return fmt.Errorf("operation failed after %d attempts: %w", maxAttempts, err)
+}
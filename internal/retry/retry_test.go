@@ -0,0 +1,74 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoSucceedsAfterTransientFailure(t *testing.T) {
+	attempts := 0
+	err := Do(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("transient error")
+		}
+		return nil
+	}, 3, time.Microsecond)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestDoFailsAfterMaxAttempts(t *testing.T) {
+	attempts := 0
+	wantErr := errors.New("permanent error")
+	err := Do(context.Background(), func() error {
+		attempts++
+		return wantErr
+	}, 3, time.Microsecond)
+
+	assert.ErrorIs(t, err, wantErr)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestDoRunsAtLeastOnce(t *testing.T) {
+	attempts := 0
+	_ = Do(context.Background(), func() error {
+		attempts++
+		return nil
+	}, 0, time.Microsecond)
+
+	assert.Equal(t, 1, attempts)
+}
+
+func TestDoStopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := Do(ctx, func() error {
+		attempts++
+		cancel()
+		return errors.New("transient error")
+	}, 3, time.Hour)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestDoIfStopsOnNonRetryableError(t *testing.T) {
+	attempts := 0
+	wantErr := errors.New("bad request")
+	err := DoIf(context.Background(), func() error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("transient error")
+		}
+		return wantErr
+	}, func(err error) bool { return !errors.Is(err, wantErr) }, 5, time.Microsecond)
+
+	assert.Equal(t, wantErr, err)
+	assert.Equal(t, 2, attempts)
+}
@@ -1,13 +1,26 @@
 package scanner
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"sheriff/internal/config"
 	"sheriff/internal/repository"
 	"sheriff/internal/shell"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/BurntSushi/toml"
 	"github.com/elliotchance/pie/v2"
 	"github.com/rs/zerolog/log"
 )
@@ -20,6 +33,10 @@ const (
 	WebKind        osvReferenceKind = "WEB"
 	PackageKind    osvReferenceKind = "PACKAGE"
 	osvTimeout                      = 5 * time.Minute
+	// osvOfflineDbMaxAge is the age after which a local OSV database is considered stale
+	osvOfflineDbMaxAge = 7 * 24 * time.Hour
+	// osvMinVersion is the oldest osv-scanner supporting the flags and output format sheriff relies on
+	osvMinVersion = "2.0.0"
 	// https://google.github.io/osv-scanner/output/#return-codes
 	osvReturnCodeSuccess    int = 0
 	osvReturnCodeVulnsFound int = 1
@@ -50,7 +67,8 @@ type osvRange struct {
 }
 
 type osvAffected struct {
-	Ranges []osvRange `json:"ranges"`
+	Package osvPackageInfo `json:"package"`
+	Ranges  []osvRange     `json:"ranges"`
 }
 
 // osvVulnerability represents a vulnerability as defined by the OSV schema.
@@ -63,13 +81,20 @@ type osvVulnerability struct {
 	References       []osvReference      `json:"references"`        // References related to the vulnerability.
 	DatabaseSpecific osvDatabaseSpecific `json:"database_specific"` // Database-specific information.
 	Affected         []osvAffected       `json:"affected"`          // List of affected packages.
+	Published        string              `json:"published"`         // Date the vulnerability was published, in RFC 3339 format.
+}
+
+// osvAnalysis is the result of the call analysis of a vulnerability.
+type osvAnalysis struct {
+	Called bool `json:"called"` // Whether the vulnerable code is called by the project.
 }
 
 // osvGroup represents a group of vulnerabilities.
 type osvGroup struct {
-	Ids         []string `json:"ids"`          // List of vulnerability IDs in the group.
-	Aliases     []string `json:"aliases"`      // Alternative identifiers for the group.
-	MaxSeverity string   `json:"max_severity"` // Maximum severity of the vulnerabilities in the group.
+	Ids                  []string               `json:"ids"`                  // List of vulnerability IDs in the group.
+	Aliases              []string               `json:"aliases"`              // Alternative identifiers for the group.
+	MaxSeverity          string                 `json:"max_severity"`         // Maximum severity of the vulnerabilities in the group.
+	ExperimentalAnalysis map[string]osvAnalysis `json:"experimentalAnalysis"` // Call analysis by vulnerability ID, only set with --call-analysis.
 }
 
 // osvPackageInfo contains information about a package.
@@ -77,13 +102,15 @@ type osvPackageInfo struct {
 	Name      string `json:"name"`      // Name of the package.
 	Version   string `json:"version"`   // Version of the package.
 	Ecosystem string `json:"ecosystem"` // Ecosystem to which the package belongs.
+	Purl      string `json:"purl"`      // Package URL without version, only set in the affected packages of a vulnerability.
 }
 
 // osvPackage represents a package and its associated vulnerabilities and groups.
 type osvPackage struct {
-	PackageInfo     osvPackageInfo     `json:"package"`         // Information about the package.
-	Vulnerabilities []osvVulnerability `json:"vulnerabilities"` // List of vulnerabilities associated with the package.
-	Groups          []osvGroup         `json:"groups"`          // List of groups associated with the package.
+	PackageInfo       osvPackageInfo     `json:"package"`            // Information about the package.
+	Vulnerabilities   []osvVulnerability `json:"vulnerabilities"`    // List of vulnerabilities associated with the package.
+	Groups            []osvGroup         `json:"groups"`             // List of groups associated with the package.
+	LicenseViolations []string           `json:"license_violations"` // Licenses of the package not in the allowlist, only set with --licenses.
 }
 
 // osvResult represents the result of a vulnerability scan.
@@ -94,27 +121,261 @@ type osvResult struct {
 
 // OsvReport represents a vulnerability report as returned by osv-scanner.
 type OsvReport struct {
-	Results []osvResult `json:"results"` // List of results in the report.
+	Results    []osvResult `json:"results"` // List of results in the report.
+	dir        string      // Directory which was scanned, to which the paths of the results are made relative
+	noPackages bool        // Set if osv-scanner found no lockfile or manifest to scan
+	raw        []byte      // JSON output of osv-scanner, only kept if requested, see OsvOpts.KeepRawReport
+}
+
+// OsvOpts are the options of the osv-scanner
+type OsvOpts struct {
+	OfflineDbPath string   // Local OSV database directory. If set, osv-scanner runs without network access.
+	ScanPaths     []string // Glob patterns, relative to the project directory, of the files and directories to scan. The whole directory is scanned if empty.
+	CallAnalysis  bool     // Analyse whether the vulnerable code is called by the project, for the ecosystems which support it.
+	// SPDX identifiers of the allowed licenses. If set, the packages with other licenses are reported as license violations.
+	AllowedLicenses []string
+	BinaryPath      string   // Path to the osv-scanner binary to run. If empty, osv-scanner is looked up in $PATH.
+	ExtraArgs       []string // Arguments passed as is to osv-scanner, after those set by sheriff
+	KeepRawReport   bool     // Keep the raw JSON output of osv-scanner in the reports, see Report.RawOsvReport
 }
 
 // osvScanner is a concrete implementation of the VulnScanner interface
 // that uses Google's osv-scanner to scan for vulnerabilities in a project directory.
-type osvScanner struct{}
+type osvScanner struct {
+	offlineDbPath string   // Local OSV database directory. If set, osv-scanner runs without network access.
+	scanPaths     []string // Glob patterns of the files and directories to scan, relative to the project directory
+	callAnalysis  bool     // Analyse whether the vulnerable code is called by the project
+	// SPDX identifiers of the allowed licenses, the licenses are not checked if empty
+	allowedLicenses []string
+	command         string   // Name or path of the osv-scanner binary
+	extraArgs       []string // Arguments passed as is to osv-scanner, after those set by sheriff
+	keepRawReport   bool     // Keep the raw JSON output of osv-scanner in the reports
+}
+
+// GetOsvScannerVersion returns the version of the osv-scanner found in $PATH, as reported by `osv-scanner --version`
+func GetOsvScannerVersion(ctx context.Context) (string, error) {
+	return getOsvScannerVersion(ctx, OsvCommandName)
+}
+
+// CheckOsvScanner checks that the osv-scanner binary at the given path, or in $PATH if empty, can be run
+// and is at least of version osvMinVersion. It is meant to be called before patrolling,
+// so that a missing or outdated osv-scanner fails the patrol at once instead of the scan of every project.
+func CheckOsvScanner(ctx context.Context, binaryPath string) error {
+	command := cmp.Or(binaryPath, OsvCommandName)
+	version, err := getOsvScannerVersion(ctx, command)
+	if err != nil {
+		return errors.Join(fmt.Errorf("cannot run %v, osv-scanner %v or later must be installed, see https://google.github.io/osv-scanner/installation/", command, osvMinVersion), err)
+	}
+
+	if compareVersions(strings.TrimPrefix(version, "v"), osvMinVersion) < 0 {
+		return fmt.Errorf("osv-scanner %v is outdated, sheriff requires version %v or later, see https://google.github.io/osv-scanner/installation/", version, osvMinVersion)
+	}
+	log.Info().Str("command", command).Str("version", version).Msg("Found osv-scanner")
+
+	return nil
+}
+
+// getOsvScannerVersion returns the version of the given osv-scanner command, as reported by `osv-scanner --version`
+func getOsvScannerVersion(ctx context.Context, command string) (string, error) {
+	cmdOut, err := shell.ShellCommandRunner.Run(ctx, shell.CommandInput{Name: command, Args: []string{"--version"}})
+	if err != nil {
+		return "", errors.Join(errors.New("failed to get osv-scanner version"), err)
+	}
+
+	// The version is on the first line, e.g. "osv-scanner version: 2.2.2", followed by the commit and build date
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(string(cmdOut.Output)), "\n")
+	if _, version, found := strings.Cut(firstLine, "version:"); found {
+		return strings.TrimSpace(version), nil
+	}
+
+	return strings.TrimSpace(firstLine), nil
+}
 
 // NewOsvScanner creates a new instance of osvScanner.
 // It is a vulnScanner that uses Google's osv-scanner to scan for vulnerabilities.
-func NewOsvScanner() VulnScanner[OsvReport] {
-	return &osvScanner{}
+// If opts.OfflineDbPath is not empty, osv-scanner runs in offline mode against the local database in that directory,
+// which must exist and have been updated recently.
+// If opts.BinaryPath is not empty, that binary is run instead of the osv-scanner in $PATH, and it must be executable.
+func NewOsvScanner(opts OsvOpts) (VulnScanner[OsvReport], error) {
+	command := OsvCommandName
+	if opts.BinaryPath != "" {
+		if _, err := exec.LookPath(opts.BinaryPath); err != nil {
+			return nil, errors.Join(fmt.Errorf("invalid osv-scanner binary %v, it must be an executable file", opts.BinaryPath), err)
+		}
+		log.Info().Str("path", opts.BinaryPath).Msg("Using custom osv-scanner binary")
+		command = opts.BinaryPath
+	}
+
+	if opts.OfflineDbPath != "" {
+		if err := validateOfflineDb(opts.OfflineDbPath, time.Now()); err != nil {
+			return nil, errors.Join(fmt.Errorf("invalid offline OSV database %v", opts.OfflineDbPath), err)
+		}
+		log.Info().Str("path", opts.OfflineDbPath).Msg("Using offline OSV database")
+	}
+
+	for _, pattern := range opts.ScanPaths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid scan path pattern %v", pattern)
+		}
+	}
+
+	return &osvScanner{
+		offlineDbPath:   opts.OfflineDbPath,
+		scanPaths:       opts.ScanPaths,
+		callAnalysis:    opts.CallAnalysis,
+		allowedLicenses: opts.AllowedLicenses,
+		command:         command,
+		extraArgs:       opts.ExtraArgs,
+		keepRawReport:   opts.KeepRawReport,
+	}, nil
+}
+
+// validateOfflineDb checks that the local OSV database directory exists,
+// and that its most recently modified file is not older than osvOfflineDbMaxAge.
+func validateOfflineDb(path string, now time.Time) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Join(errors.New("failed to read database directory"), err)
+	} else if !info.IsDir() {
+		return errors.New("database path is not a directory")
+	}
+
+	var lastUpdate time.Time
+	err = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(lastUpdate) {
+			lastUpdate = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to read database files"), err)
+	}
+
+	if lastUpdate.IsZero() {
+		return errors.New("database directory is empty, download it with osv-scanner --download-offline-databases")
+	} else if age := now.Sub(lastUpdate); age > osvOfflineDbMaxAge {
+		return fmt.Errorf("database is stale, last updated %v ago (maximum %v)", age.Round(time.Hour), osvOfflineDbMaxAge)
+	}
+
+	return nil
+}
+
+// args returns the arguments to run osv-scanner on the given targets.
+// Directories are scanned recursively, while files are scanned as lockfiles.
+// The extra arguments come right before the targets, so that they can override the flags set by sheriff.
+func (s *osvScanner) args(targets []string, configPath string) []string {
+	args := []string{"-r", "--verbosity", "error", "--format", "json"}
+	if configPath != "" {
+		args = append(args, "--config", configPath)
+	}
+	if s.offlineDbPath != "" {
+		args = append(args, "--offline-vulnerabilities", "--local-db-path", s.offlineDbPath)
+	}
+	if s.callAnalysis {
+		args = append(args, "--call-analysis=all")
+	}
+	if len(s.allowedLicenses) > 0 {
+		args = append(args, "--licenses="+strings.Join(s.allowedLicenses, ","))
+	}
+	args = append(args, s.extraArgs...)
+
+	var dirs []string
+	for _, t := range targets {
+		if info, err := os.Stat(t); err == nil && !info.IsDir() {
+			args = append(args, "--lockfile", t)
+		} else {
+			dirs = append(dirs, t)
+		}
+	}
+
+	return append(args, dirs...)
+}
+
+// targets returns the files and directories to scan within the given directory,
+// which are the ones matching the scan paths, or the whole directory if there are no scan paths.
+func (s *osvScanner) targets(dir string) (targets []string) {
+	if len(s.scanPaths) == 0 {
+		return []string{dir}
+	}
+
+	for _, pattern := range s.scanPaths {
+		// The pattern was validated when creating the scanner, so Glob cannot fail
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, m := range matches {
+			if !slices.Contains(targets, m) {
+				targets = append(targets, m)
+			}
+		}
+	}
+
+	return
+}
+
+// writeOsvConfigWithoutIgnores writes a temporary copy of the osv-scanner configuration file of the given directory,
+// without its ignored vulnerabilities. osv-scanner would otherwise drop them from its output, whereas they are
+// acknowledged by sheriff so that they show in the reports (see config.GetProjectConfiguration).
+// It returns the path of the copy, which is empty if the directory has no configuration file.
+func writeOsvConfigWithoutIgnores(dir string) (string, error) {
+	filename := filepath.Join(dir, config.OsvScannerConfigFileName)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return "", nil
+	}
+
+	var osvConfig map[string]any
+	if _, err := toml.DecodeFile(filename, &osvConfig); err != nil {
+		return "", errors.Join(fmt.Errorf("failed to decode %v", config.OsvScannerConfigFileName), err)
+	}
+	delete(osvConfig, "IgnoredVulns")
+
+	f, err := os.CreateTemp("", "osv-scanner-*.toml")
+	if err != nil {
+		return "", errors.Join(errors.New("failed to create temporary osv-scanner configuration"), err)
+	}
+	defer f.Close()
+
+	if err := toml.NewEncoder(f).Encode(osvConfig); err != nil {
+		os.Remove(f.Name())
+		return "", errors.Join(errors.New("failed to write temporary osv-scanner configuration"), err)
+	}
+
+	return f.Name(), nil
 }
 
 // Scan scans the specified directory for vulnerabilities using osv-scanner.
-func (s *osvScanner) Scan(dir string) (*OsvReport, error) {
+// Only the files and directories matching the scan paths are scanned, if any.
+// The vulnerabilities ignored in the osv-scanner configuration file of the directory are still reported,
+// to be acknowledged by sheriff instead.
+func (s *osvScanner) Scan(ctx context.Context, dir string) (*OsvReport, error) {
 	var report *OsvReport
 
+	targets := s.targets(dir)
+	if len(targets) == 0 {
+		log.Warn().Str("dir", dir).Strs("scanPaths", s.scanPaths).Msg("No files match the scan paths, nothing to scan")
+		return &OsvReport{dir: dir, noPackages: true}, nil
+	}
+
+	configPath, err := writeOsvConfigWithoutIgnores(dir)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to read osv-scanner configuration of project"), err)
+	} else if configPath != "" {
+		defer os.Remove(configPath)
+	}
+
 	cmdOut, err := shell.ShellCommandRunner.Run(
+		ctx,
 		shell.CommandInput{
-			Name:    OsvCommandName,
-			Args:    []string{"-r", "--verbosity", "error", "--format", "json", dir},
+			Name:    s.command,
+			Args:    s.args(targets, configPath),
 			Timeout: osvTimeout,
 		},
 	)
@@ -126,7 +387,7 @@ func (s *osvScanner) Scan(dir string) (*OsvReport, error) {
 		return nil, nil
 	} else if cmdOut.ExitCode == osvReturnCodeNoPackages {
 		log.Warn().Int("exitCode", cmdOut.ExitCode).Msg("osv-scanner did not find any packages to scan")
-		return nil, nil
+		return &OsvReport{dir: dir, noPackages: true}, nil
 	} else if cmdOut.ExitCode > 1 || cmdOut.ExitCode == -1 {
 		// Failed to run osv-scanner at all, or it returned an error
 		log.Debug().Int("exitCode", cmdOut.ExitCode).Msg("osv-scanner failed to run")
@@ -138,6 +399,10 @@ func (s *osvScanner) Scan(dir string) (*OsvReport, error) {
 	if err != nil {
 		return report, err
 	}
+	report.dir = dir
+	if s.keepRawReport {
+		report.raw = cmdOut.Output
+	}
 
 	return report, nil
 }
@@ -151,43 +416,202 @@ func (s *osvScanner) GenerateReport(p repository.Project, r *OsvReport) Report {
 			Vulnerabilities: []Vulnerability{},
 		}
 	}
+	if r.noPackages {
+		return Report{
+			Project:         p,
+			Vulnerabilities: []Vulnerability{},
+			NoManifests:     true,
+		}
+	}
 
 	var vs []Vulnerability
+	var lvs []LicenseViolation
 	for _, p := range r.Results {
 		for _, pkg := range p.Packages {
+			if len(pkg.LicenseViolations) > 0 {
+				lvs = append(lvs, LicenseViolation{
+					PackageName:      pkg.PackageInfo.Name,
+					PackageVersion:   pkg.PackageInfo.Version,
+					PackageEcosystem: pkg.PackageInfo.Ecosystem,
+					Licenses:         pkg.LicenseViolations,
+					Sources:          []string{r.relativePath(p.Source.Path)},
+				})
+			}
+
 			for _, v := range pkg.Vulnerabilities {
-				packageRef := pie.FirstOr(pie.Filter(v.References, func(ref osvReference) bool { return ref.Type == PackageKind }), osvReference{})
 				source := filepath.Base(p.Source.Path)
 				sevIdx := pie.FindFirstUsing(pkg.Groups, func(g osvGroup) bool { return pie.Contains(g.Ids, v.Id) || pie.Contains(g.Aliases, v.Id) })
 				var severity string
+				var reachable *bool
 				if sevIdx != -1 {
 					severity = pkg.Groups[sevIdx].MaxSeverity
+					if analysis, ok := pkg.Groups[sevIdx].ExperimentalAnalysis[v.Id]; ok {
+						reachable = &analysis.Called
+					}
 				} else {
 					severity = ""
 				}
 
 				vs = append(vs, Vulnerability{
 					Id:                v.Id,
+					Aliases:           v.Aliases,
 					PackageName:       pkg.PackageInfo.Name,
 					PackageVersion:    pkg.PackageInfo.Version,
-					PackageUrl:        packageRef.Url,
+					PackageUrl:        getPackageUrl(v, pkg.PackageInfo),
 					PackageEcosystem:  pkg.PackageInfo.Ecosystem,
 					Source:            source,
+					Sources:           []string{r.relativePath(p.Source.Path)},
 					Severity:          severity,
-					SeverityScoreKind: getSeverityScoreKind(severity),
+					SeverityScoreKind: GetSeverityScoreKind(severity),
 					Summary:           v.Summary,
 					Details:           v.Detail,
 					FixAvailable:      hasFixAvailable(v),
+					FixedVersion:      getFixedVersion(v, pkg.PackageInfo),
+					Reachable:         reachable,
+					Published:         getPublished(v),
 				})
 			}
 		}
 	}
 
+	vs = mergeDuplicatedVulnerabilities(vs)
+	vs = mergeAliasedVulnerabilities(vs)
+
 	return Report{
-		Project:         p,
-		IsVulnerable:    len(vs) > 0,
-		Vulnerabilities: vs,
+		Project:           p,
+		IsVulnerable:      len(vs) > 0,
+		Vulnerabilities:   vs,
+		LicenseViolations: mergeDuplicatedLicenseViolations(lvs),
+		RawOsvReport:      r.raw,
+	}
+}
+
+// mergeDuplicatedVulnerabilities collapses the vulnerabilities of the same package which are found
+// in several lockfiles (e.g. in a monorepo) into one, keeping track of all the lockfiles it was found in.
+func mergeDuplicatedVulnerabilities(vs []Vulnerability) (merged []Vulnerability) {
+	for _, v := range vs {
+		idx := slices.IndexFunc(merged, func(m Vulnerability) bool {
+			return m.Id == v.Id && m.PackageName == v.PackageName && m.PackageVersion == v.PackageVersion
+		})
+		if idx == -1 {
+			merged = append(merged, v)
+			continue
+		}
+
+		log.Debug().Str("id", v.Id).Strs("sources", v.Sources).Msg("Merging vulnerability found in several lockfiles")
+		merged[idx].Sources = mergeSources(merged[idx].Sources, v.Sources)
+		merged[idx].Reachable = mergeReachable(merged[idx].Reachable, v.Reachable)
+	}
+
+	return
+}
+
+// mergeAliasedVulnerabilities collapses the vulnerabilities of the same package which are reported
+// under several identifiers (e.g. both a GHSA and a CVE) into one.
+// The first vulnerability is kept, and the identifiers of the others are added to its aliases.
+func mergeAliasedVulnerabilities(vs []Vulnerability) (merged []Vulnerability) {
+	for _, v := range vs {
+		idx := slices.IndexFunc(merged, func(m Vulnerability) bool {
+			return m.PackageEcosystem == v.PackageEcosystem &&
+				m.PackageName == v.PackageName &&
+				m.PackageVersion == v.PackageVersion &&
+				m.Source == v.Source &&
+				slices.ContainsFunc(Identifiers(v), func(id string) bool { return slices.Contains(Identifiers(m), id) })
+		})
+		if idx == -1 {
+			merged = append(merged, v)
+			continue
+		}
+
+		log.Debug().Str("id", merged[idx].Id).Str("alias", v.Id).Msg("Merging vulnerability reported under an alias")
+		merged[idx].Sources = mergeSources(merged[idx].Sources, v.Sources)
+		merged[idx].Reachable = mergeReachable(merged[idx].Reachable, v.Reachable)
+		merged[idx].Published = mergePublished(merged[idx].Published, v.Published)
+		if merged[idx].FixedVersion == "" {
+			merged[idx].FixedVersion = v.FixedVersion
+		}
+		// Clip so that appending never writes into the aliases slice of the OSV report
+		merged[idx].Aliases = slices.Clip(merged[idx].Aliases)
+		for _, id := range Identifiers(v) {
+			if !slices.Contains(Identifiers(merged[idx]), id) {
+				merged[idx].Aliases = append(merged[idx].Aliases, id)
+			}
+		}
+	}
+
+	return
+}
+
+// mergeDuplicatedLicenseViolations collapses the license violations of the same package which is found
+// in several lockfiles into one, keeping track of all the lockfiles it was found in.
+func mergeDuplicatedLicenseViolations(lvs []LicenseViolation) (merged []LicenseViolation) {
+	for _, lv := range lvs {
+		idx := slices.IndexFunc(merged, func(m LicenseViolation) bool {
+			return m.PackageName == lv.PackageName && m.PackageVersion == lv.PackageVersion && m.PackageEcosystem == lv.PackageEcosystem
+		})
+		if idx == -1 {
+			merged = append(merged, lv)
+			continue
+		}
+
+		merged[idx].Sources = mergeSources(merged[idx].Sources, lv.Sources)
+	}
+
+	return
+}
+
+// mergeSources returns the sources of a, followed by those of b which are not in a
+func mergeSources(a []string, b []string) []string {
+	// Clip so that appending never writes into the sources of another vulnerability
+	merged := slices.Clip(a)
+	for _, src := range b {
+		if !slices.Contains(merged, src) {
+			merged = append(merged, src)
+		}
+	}
+
+	return merged
+}
+
+// mergeReachable returns whether a vulnerability merged from two others is reachable,
+// which it is as soon as one of them is. It is nil if neither was analysed.
+func mergeReachable(a *bool, b *bool) *bool {
+	if a == nil || (b != nil && *b) {
+		return b
+	}
+
+	return a
+}
+
+// mergePublished returns the publication date of a vulnerability merged from two others,
+// which is the earliest of them. It is zero if neither is known.
+func mergePublished(a time.Time, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
 	}
+
+	return a
+}
+
+// relativePath returns the given path of a result relative to the scanned directory.
+// When the scanned directory is not known, or the path is outside of it, only the file name is kept.
+func (r *OsvReport) relativePath(path string) string {
+	if r.dir != "" {
+		absDir, dirErr := filepath.Abs(r.dir)
+		absPath, pathErr := filepath.Abs(path)
+		if dirErr == nil && pathErr == nil {
+			if rel, err := filepath.Rel(absDir, absPath); err == nil && filepath.IsLocal(rel) {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+
+	return filepath.Base(path)
+}
+
+// Identifiers returns all the identifiers a vulnerability is known by
+func Identifiers(v Vulnerability) []string {
+	return append([]string{v.Id}, v.Aliases...)
 }
 
 // readOSVJson reads the JSON output from osv-scanner
@@ -197,8 +621,8 @@ func readOSVJson(data []byte) (report *OsvReport, err error) {
 	return
 }
 
-// getSeverityScoreKind returns the SeverityScoreKind based on the severity score from OSV
-func getSeverityScoreKind(severity string) SeverityScoreKind {
+// GetSeverityScoreKind returns the SeverityScoreKind of the given CVSS score, e.g. as reported by OSV
+func GetSeverityScoreKind(severity string) SeverityScoreKind {
 	if severity == "" {
 		log.Debug().Msg("Severity is empty, defaulting to Unknown")
 		return Unknown
@@ -233,3 +657,124 @@ func hasFixAvailable(v osvVulnerability) bool {
 	}
 	return false
 }
+
+// getPublished returns the date the vulnerability was published, which is zero if it is unknown or invalid
+func getPublished(v osvVulnerability) time.Time {
+	if v.Published == "" {
+		return time.Time{}
+	}
+
+	published, err := time.Parse(time.RFC3339, v.Published)
+	if err != nil {
+		log.Debug().Err(err).Str("id", v.Id).Str("published", v.Published).Msg("Failed to parse the publication date of vulnerability")
+		return time.Time{}
+	}
+
+	return published
+}
+
+// getFixedVersion returns the lowest version of the package fixing the vulnerability, see lowestFixedVersion.
+// It is empty if no fix is known.
+func getFixedVersion(v osvVulnerability, pkg osvPackageInfo) string {
+	var fixed []string
+	for _, a := range v.Affected {
+		// The vulnerability may affect several packages, each with their own fixes
+		if a.Package.Name != "" && a.Package.Name != pkg.Name {
+			continue
+		}
+		for _, r := range a.Ranges {
+			for _, e := range r.Events {
+				if e.Fixed != "" {
+					fixed = append(fixed, e.Fixed)
+				}
+			}
+		}
+	}
+
+	return lowestFixedVersion(fixed, pkg.Version)
+}
+
+// getPackageUrl returns the package URL (purl) of the installed version of the package, e.g. pkg:pypi/werkzeug@3.0.4,
+// from the purl of the package among those affected by the vulnerability. It is empty if the OSV data has none.
+func getPackageUrl(v osvVulnerability, pkg osvPackageInfo) string {
+	for _, a := range v.Affected {
+		if a.Package.Purl == "" || (a.Package.Name != "" && a.Package.Name != pkg.Name) {
+			continue
+		}
+
+		// The version goes before the qualifiers and subpath of the purl, if any
+		purl, suffix := a.Package.Purl, ""
+		if i := strings.IndexAny(purl, "?#"); i != -1 {
+			purl, suffix = purl[:i], purl[i:]
+		}
+		if pkg.Version != "" && !strings.Contains(purl, "@") {
+			purl += "@" + url.PathEscape(pkg.Version)
+		}
+
+		return purl + suffix
+	}
+
+	return ""
+}
+
+// lowestFixedVersion returns the lowest of the fixed versions which is above the installed version,
+// falling back to the lowest fixed version if none is above it. It is empty if there are no fixed versions.
+func lowestFixedVersion(fixed []string, installed string) string {
+	if above := pie.Filter(fixed, func(f string) bool { return compareVersions(f, installed) > 0 }); len(above) > 0 {
+		fixed = above
+	}
+	if len(fixed) == 0 {
+		return ""
+	}
+
+	return slices.MinFunc(fixed, compareVersions)
+}
+
+// compareVersions compares two versions component by component, where components are the runs of digits or letters.
+// Numeric components are compared as numbers and the others as strings, which orders the versions of most ecosystems
+// (e.g. 1.9.0 < 1.10.0) without knowing their exact scheme.
+func compareVersions(a string, b string) int {
+	pa, pb := splitVersion(a), splitVersion(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		var c int
+		if errA == nil && errB == nil {
+			c = cmp.Compare(na, nb)
+		} else {
+			c = strings.Compare(pa[i], pb[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(len(pa), len(pb))
+}
+
+// splitVersion splits a version into its components, dropping the separators (e.g. 1.2.3-rc1 into 1, 2, 3, rc, 1)
+func splitVersion(v string) (parts []string) {
+	var current strings.Builder
+	var currentIsDigit bool
+	for _, r := range v {
+		isDigit := unicode.IsDigit(r)
+		if !isDigit && !unicode.IsLetter(r) {
+			if current.Len() > 0 {
+				parts = append(parts, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		if current.Len() > 0 && isDigit != currentIsDigit {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+		current.WriteRune(r)
+		currentIsDigit = isDigit
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+
+	return
+}
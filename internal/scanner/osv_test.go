@@ -1,14 +1,22 @@
 package scanner
 
 import (
+	"context"
+	"fmt"
+	"maps"
 	"sheriff/internal/repository"
 	"sheriff/internal/shell"
+	"slices"
 	"testing"
 
 	"io"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/elliotchance/pie/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestReadOSVJson(t *testing.T) {
@@ -46,9 +54,12 @@ func TestScanReturnsFullReport(t *testing.T) {
 		shell.ShellCommandRunner = originalShellCommandRunner
 	}()
 
-	svc := NewOsvScanner()
+	svc, err := NewOsvScanner(OsvOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	report, err := svc.Scan("test-dir")
+	report, err := svc.Scan(context.Background(), "test-dir")
 
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(report.Results))
@@ -56,6 +67,66 @@ func TestScanReturnsFullReport(t *testing.T) {
 	assert.Equal(t, 1, len(report.Results[0].Packages[0].Vulnerabilities))
 }
 
+func TestGetOsvScannerVersion(t *testing.T) {
+	originalShellCommandRunner := shell.ShellCommandRunner
+	mockRunner := &mockCommandRunner{FixturePath: "testdata/osv-version.txt"}
+	shell.ShellCommandRunner = mockRunner
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	version, err := GetOsvScannerVersion(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2.2.2", version)
+	assert.Equal(t, shell.CommandInput{Name: OsvCommandName, Args: []string{"--version"}}, mockRunner.Input)
+}
+
+func TestGetOsvScannerVersionFails(t *testing.T) {
+	originalShellCommandRunner := shell.ShellCommandRunner
+	shell.ShellCommandRunner = &mockCommandRunner{FixturePath: "testdata/missing.txt"}
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	_, err := GetOsvScannerVersion(context.Background())
+
+	assert.ErrorContains(t, err, "failed to get osv-scanner version")
+}
+
+func TestCheckOsvScanner(t *testing.T) {
+	testCases := map[string]string{
+		"2.2.2":   "",
+		"2.0.0":   "",
+		"v2.10.0": "",
+		"1.9.2":   "osv-scanner 1.9.2 is outdated, sheriff requires version 2.0.0 or later",
+	}
+
+	for version, wantErr := range testCases {
+		t.Run(version, func(t *testing.T) {
+			binary := filepath.Join(t.TempDir(), "osv-scanner")
+			script := fmt.Sprintf("#!/bin/sh\necho 'osv-scanner version: %v'\necho 'commit: n/a'\n", version)
+			require.NoError(t, os.WriteFile(binary, []byte(script), 0755))
+
+			err := CheckOsvScanner(context.Background(), binary)
+
+			if wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckOsvScannerMissing(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "osv-scanner")
+
+	err := CheckOsvScanner(context.Background(), binary)
+
+	assert.ErrorContains(t, err, "cannot run "+binary+", osv-scanner 2.0.0 or later must be installed")
+}
+
 func TestScanWithZeroExitCodeReturnsEmptyReport(t *testing.T) {
 	// Mock the command runner
 	originalShellCommandRunner := shell.ShellCommandRunner
@@ -65,20 +136,352 @@ func TestScanWithZeroExitCodeReturnsEmptyReport(t *testing.T) {
 		shell.ShellCommandRunner = originalShellCommandRunner
 	}()
 
-	svc := NewOsvScanner()
+	svc, err := NewOsvScanner(OsvOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.Scan(context.Background(), "test-dir")
+
+	assert.Nil(t, err)
+	assert.Nil(t, report)
+}
+
+func TestScanKeepsRawReportIfRequested(t *testing.T) {
+	// Mock the command runner
+	originalShellCommandRunner := shell.ShellCommandRunner
+	shell.ShellCommandRunner = &mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: 1}
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	raw, err := readMockJsonData("testdata/osv-output.json")
+	require.NoError(t, err)
+
+	for _, keep := range []bool{false, true} {
+		svc, err := NewOsvScanner(OsvOpts{KeepRawReport: keep})
+		require.NoError(t, err)
+
+		report, err := svc.Scan(context.Background(), "test-dir")
+		require.NoError(t, err)
+		got := svc.GenerateReport(repository.Project{}, report)
+
+		if keep {
+			assert.Equal(t, raw, got.RawOsvReport)
+		} else {
+			assert.Nil(t, got.RawOsvReport)
+		}
+	}
+}
+
+func TestScanForwardsOfflineDbFlags(t *testing.T) {
+	dbPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dbPath, "all.zip"), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mock the command runner
+	originalShellCommandRunner := shell.ShellCommandRunner
+	runner := &mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: 0}
+	shell.ShellCommandRunner = runner
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	svc, err := NewOsvScanner(OsvOpts{OfflineDbPath: dbPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Scan(context.Background(), "test-dir")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-r", "--verbosity", "error", "--format", "json", "--offline-vulnerabilities", "--local-db-path", dbPath, "test-dir"}, runner.Input.Args)
+}
+
+func TestScanForwardsCallAnalysisFlag(t *testing.T) {
+	originalShellCommandRunner := shell.ShellCommandRunner
+	runner := &mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: 0}
+	shell.ShellCommandRunner = runner
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	svc, err := NewOsvScanner(OsvOpts{CallAnalysis: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Scan(context.Background(), "test-dir")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-r", "--verbosity", "error", "--format", "json", "--call-analysis=all", "test-dir"}, runner.Input.Args)
+}
+
+func TestScanForwardsAllowedLicenses(t *testing.T) {
+	originalShellCommandRunner := shell.ShellCommandRunner
+	runner := &mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: 0}
+	shell.ShellCommandRunner = runner
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	svc, err := NewOsvScanner(OsvOpts{AllowedLicenses: []string{"MIT", "Apache-2.0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Scan(context.Background(), "test-dir")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-r", "--verbosity", "error", "--format", "json", "--licenses=MIT,Apache-2.0", "test-dir"}, runner.Input.Args)
+}
+
+func TestScanRunsConfiguredBinaryWithExtraArgs(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "osv-scanner-pinned")
+	require.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\n"), 0755))
+
+	originalShellCommandRunner := shell.ShellCommandRunner
+	runner := &mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: 0}
+	shell.ShellCommandRunner = runner
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	svc, err := NewOsvScanner(OsvOpts{BinaryPath: binary, ExtraArgs: []string{"--no-resolve", "--experimental-no-default-plugins"}})
+	require.NoError(t, err)
+
+	_, err = svc.Scan(context.Background(), "test-dir")
+
+	assert.Nil(t, err)
+	assert.Equal(t, binary, runner.Input.Name)
+	assert.Equal(t, []string{"-r", "--verbosity", "error", "--format", "json", "--no-resolve", "--experimental-no-default-plugins", "test-dir"}, runner.Input.Args)
+}
+
+func TestNewOsvScannerInvalidBinary(t *testing.T) {
+	dir := t.TempDir()
+	notExecutable := filepath.Join(dir, "osv-scanner")
+	require.NoError(t, os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644))
+
+	for _, path := range []string{notExecutable, filepath.Join(dir, "missing"), dir} {
+		_, err := NewOsvScanner(OsvOpts{BinaryPath: path})
+
+		assert.ErrorContains(t, err, "invalid osv-scanner binary "+path+", it must be an executable file")
+	}
+}
+
+func TestScanForwardsScanPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"go.mod", "services/api/go.mod", "services/web/package-lock.json", "vendor/go.mod"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Mock the command runner
+	originalShellCommandRunner := shell.ShellCommandRunner
+	runner := &mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: 0}
+	shell.ShellCommandRunner = runner
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	svc, err := NewOsvScanner(OsvOpts{ScanPaths: []string{"go.mod", "services/*", "missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	report, err := svc.Scan("test-dir")
+	_, err = svc.Scan(context.Background(), dir)
 
 	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"-r", "--verbosity", "error", "--format", "json",
+		"--lockfile", filepath.Join(dir, "go.mod"),
+		filepath.Join(dir, "services/api"),
+		filepath.Join(dir, "services/web"),
+	}, runner.Input.Args)
+}
+
+func TestScanReportsVulnsIgnoredInOsvConfig(t *testing.T) {
+	dir := t.TempDir()
+	osvConfig := `
+[[IgnoredVulns]]
+id = "GO-2022-0968"
+reason = "No ssh servers are connected to or hosted in Go lang"
+
+[[PackageOverrides]]
+name = "lib"
+ecosystem = "Go"
+ignore = true
+`
+	if err := os.WriteFile(filepath.Join(dir, "osv-scanner.toml"), []byte(osvConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mock the command runner
+	originalShellCommandRunner := shell.ShellCommandRunner
+	runner := &configCapturingRunner{mockCommandRunner: mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: 0}}
+	shell.ShellCommandRunner = runner
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	svc, err := NewOsvScanner(OsvOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Scan(context.Background(), dir)
+
+	assert.Nil(t, err)
+	require.Len(t, runner.Input.Args, 8)
+	assert.Equal(t, "--config", runner.Input.Args[5])
+	assert.NoFileExists(t, runner.Input.Args[6], "the temporary configuration is removed")
+	assert.NotContains(t, runner.Config, "IgnoredVulns")
+	assert.Contains(t, runner.Config, "PackageOverrides")
+}
+
+func TestScanFailsWithInvalidOsvConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "osv-scanner.toml"), []byte("[[IgnoredVulns]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mock the command runner
+	originalShellCommandRunner := shell.ShellCommandRunner
+	runner := &mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: 1}
+	shell.ShellCommandRunner = runner
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	svc, err := NewOsvScanner(OsvOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.Scan(context.Background(), dir)
+
+	assert.Error(t, err)
 	assert.Nil(t, report)
+	assert.Nil(t, runner.Input.Args, "osv-scanner is not run")
+}
+
+func TestScanWithoutMatchingScanPaths(t *testing.T) {
+	// Mock the command runner
+	originalShellCommandRunner := shell.ShellCommandRunner
+	runner := &mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: 1}
+	shell.ShellCommandRunner = runner
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	svc, err := NewOsvScanner(OsvOpts{ScanPaths: []string{"missing/*.lock"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.Scan(context.Background(), t.TempDir())
+
+	assert.Nil(t, err)
+	require.NotNil(t, report)
+	assert.True(t, report.noPackages)
+	assert.Nil(t, runner.Input.Args, "osv-scanner is not run")
+}
+
+func TestScanWithoutPackagesReportsNoManifests(t *testing.T) {
+	// Mock the command runner
+	originalShellCommandRunner := shell.ShellCommandRunner
+	shell.ShellCommandRunner = &mockCommandRunner{FixturePath: "testdata/osv-output.json", ExitCode: osvReturnCodeNoPackages}
+
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	svc, err := NewOsvScanner(OsvOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.Scan(context.Background(), "test-dir")
+	require.NoError(t, err)
+
+	got := svc.GenerateReport(repository.Project{Path: "group/docs"}, report)
+
+	assert.True(t, got.NoManifests)
+	assert.False(t, got.IsVulnerable)
+	assert.Empty(t, got.Vulnerabilities)
+}
+
+func TestNewOsvScannerFailsWithInvalidScanPath(t *testing.T) {
+	_, err := NewOsvScanner(OsvOpts{ScanPaths: []string{"[invalid"}})
+
+	assert.NotNil(t, err)
+}
+
+func TestNewOsvScannerFailsWithMissingOfflineDb(t *testing.T) {
+	_, err := NewOsvScanner(OsvOpts{OfflineDbPath: filepath.Join(t.TempDir(), "missing")})
+
+	assert.NotNil(t, err)
+}
+
+func TestValidateOfflineDb(t *testing.T) {
+	now := time.Now()
+
+	t.Run("empty database", func(t *testing.T) {
+		err := validateOfflineDb(t.TempDir(), now)
+
+		assert.ErrorContains(t, err, "empty")
+	})
+
+	t.Run("stale database", func(t *testing.T) {
+		dbPath := t.TempDir()
+		file := filepath.Join(dbPath, "all.zip")
+		if err := os.WriteFile(file, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+		old := now.Add(-osvOfflineDbMaxAge - time.Hour)
+		if err := os.Chtimes(file, old, old); err != nil {
+			t.Fatal(err)
+		}
+
+		err := validateOfflineDb(dbPath, now)
+
+		assert.ErrorContains(t, err, "stale")
+	})
+
+	t.Run("fresh database", func(t *testing.T) {
+		dbPath := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dbPath, "all.zip"), []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := validateOfflineDb(dbPath, now)
+
+		assert.Nil(t, err)
+	})
 }
 
 type mockCommandRunner struct {
 	FixturePath string
 	ExitCode    int
+	Input       shell.CommandInput
 }
 
-func (m *mockCommandRunner) Run(shell.CommandInput) (shell.CommandOutput, error) {
+func (m *mockCommandRunner) Run(_ context.Context, input shell.CommandInput) (shell.CommandOutput, error) {
+	m.Input = input
 	out, err := readMockJsonData(m.FixturePath)
 	if err != nil {
 		return shell.CommandOutput{
@@ -93,6 +496,25 @@ func (m *mockCommandRunner) Run(shell.CommandInput) (shell.CommandOutput, error)
 	}, nil
 }
 
+// configCapturingRunner is a mockCommandRunner which keeps the content of the osv-scanner configuration file it is run with,
+// as the file is removed once the scan is done
+type configCapturingRunner struct {
+	mockCommandRunner
+	Config string
+}
+
+func (m *configCapturingRunner) Run(ctx context.Context, input shell.CommandInput) (shell.CommandOutput, error) {
+	if idx := slices.Index(input.Args, "--config"); idx != -1 {
+		content, err := os.ReadFile(input.Args[idx+1])
+		if err != nil {
+			return shell.CommandOutput{ExitCode: -1}, err
+		}
+		m.Config = string(content)
+	}
+
+	return m.mockCommandRunner.Run(ctx, input)
+}
+
 func TestGenerateReportOSV(t *testing.T) {
 	mockReport := createMockReport("10.0")
 	s := osvScanner{}
@@ -107,6 +529,7 @@ func TestGenerateReportOSV(t *testing.T) {
 		PackageVersion:    "version",
 		PackageEcosystem:  "ecosystem",
 		Source:            "test",
+		Sources:           []string{"test"},
 		Severity:          "10.0",
 		SeverityScoreKind: "CRITICAL",
 		Summary:           "test",
@@ -116,6 +539,113 @@ func TestGenerateReportOSV(t *testing.T) {
 	assert.Equal(t, want, got.Vulnerabilities[0])
 }
 
+func TestGenerateReportOSVMergesAliasedVulnerabilities(t *testing.T) {
+	mockReport := createMockReport("8.0")
+	pkg := &mockReport.Results[0].Packages[0]
+	pkg.Vulnerabilities = []osvVulnerability{
+		{Id: "GHSA-xxxx-yyyy-zzzz", Aliases: []string{"CVE-2021-1234"}},
+		{Id: "PYSEC-2021-1", Aliases: []string{"CVE-2021-1234"}},
+		{Id: "GHSA-aaaa-bbbb-cccc"},
+	}
+	pkg.Groups = []osvGroup{{Ids: []string{"GHSA-xxxx-yyyy-zzzz", "PYSEC-2021-1", "GHSA-aaaa-bbbb-cccc"}, MaxSeverity: "8.0"}}
+
+	s := osvScanner{}
+	got := s.GenerateReport(repository.Project{}, mockReport)
+
+	assert.Len(t, got.Vulnerabilities, 2)
+	assert.Equal(t, "GHSA-xxxx-yyyy-zzzz", got.Vulnerabilities[0].Id)
+	assert.Equal(t, []string{"CVE-2021-1234", "PYSEC-2021-1"}, got.Vulnerabilities[0].Aliases)
+	assert.Equal(t, "GHSA-aaaa-bbbb-cccc", got.Vulnerabilities[1].Id)
+	assert.Empty(t, got.Vulnerabilities[1].Aliases)
+	// The OSV report itself is left untouched
+	assert.Equal(t, []string{"CVE-2021-1234"}, pkg.Vulnerabilities[0].Aliases)
+}
+
+func TestGenerateReportOSVMergesDuplicatedVulnerabilities(t *testing.T) {
+	mockReport := createMockReport("8.0")
+	mockReport.dir = "/tmp/project"
+	mockReport.Results[0].Source.Path = "/tmp/project/go.mod"
+	mockReport.Results = append(mockReport.Results, mockReport.Results[0])
+	mockReport.Results[1].Source.Path = "/tmp/project/tools/go.mod"
+
+	s := osvScanner{}
+	got := s.GenerateReport(repository.Project{}, mockReport)
+
+	assert.Len(t, got.Vulnerabilities, 1)
+	assert.Equal(t, "go.mod", got.Vulnerabilities[0].Source)
+	assert.Equal(t, []string{"go.mod", "tools/go.mod"}, got.Vulnerabilities[0].Sources)
+}
+
+func TestGenerateReportOSVWithLicenseViolations(t *testing.T) {
+	mockReport := createMockReport("8.0")
+	mockReport.dir = "/tmp/project"
+	mockReport.Results[0].Source.Path = "/tmp/project/go.mod"
+	mockReport.Results[0].Packages[0].LicenseViolations = []string{"GPL-3.0"}
+	mockReport.Results = append(mockReport.Results, mockReport.Results[0])
+	mockReport.Results[1].Source.Path = "/tmp/project/tools/go.mod"
+
+	s := osvScanner{}
+	got := s.GenerateReport(repository.Project{}, mockReport)
+
+	assert.Equal(t, []LicenseViolation{{
+		PackageName:      mockReport.Results[0].Packages[0].PackageInfo.Name,
+		PackageVersion:   mockReport.Results[0].Packages[0].PackageInfo.Version,
+		PackageEcosystem: mockReport.Results[0].Packages[0].PackageInfo.Ecosystem,
+		Licenses:         []string{"GPL-3.0"},
+		Sources:          []string{"go.mod", "tools/go.mod"},
+	}}, got.LicenseViolations)
+}
+
+func TestGenerateReportOSVWithCallAnalysis(t *testing.T) {
+	mockReport := createMockReport("8.0")
+	pkg := &mockReport.Results[0].Packages[0]
+	pkg.Vulnerabilities = []osvVulnerability{{Id: "GO-2023-0001"}, {Id: "GO-2023-0002"}, {Id: "GO-2023-0003"}}
+	pkg.Groups = []osvGroup{{
+		Ids:                  []string{"GO-2023-0001", "GO-2023-0002", "GO-2023-0003"},
+		ExperimentalAnalysis: map[string]osvAnalysis{"GO-2023-0001": {Called: true}, "GO-2023-0002": {Called: false}},
+	}}
+
+	s := osvScanner{}
+	got := s.GenerateReport(repository.Project{}, mockReport)
+
+	assert.Len(t, got.Vulnerabilities, 3)
+	assert.Equal(t, true, *got.Vulnerabilities[0].Reachable)
+	assert.Equal(t, false, *got.Vulnerabilities[1].Reachable)
+	assert.Nil(t, got.Vulnerabilities[2].Reachable)
+}
+
+func TestMergeReachable(t *testing.T) {
+	yes, no := true, false
+
+	assert.Nil(t, mergeReachable(nil, nil))
+	assert.Equal(t, &no, mergeReachable(nil, &no))
+	assert.Equal(t, &no, mergeReachable(&no, nil))
+	assert.Equal(t, &yes, mergeReachable(&no, &yes))
+	assert.Equal(t, &yes, mergeReachable(&yes, &no))
+}
+
+func TestMergeDuplicatedVulnerabilitiesKeepsDifferentVersionsApart(t *testing.T) {
+	vs := []Vulnerability{
+		{Id: "CVE-2021-1234", PackageName: "a", PackageVersion: "1.0.0", Sources: []string{"go.mod"}},
+		{Id: "CVE-2021-1234", PackageName: "a", PackageVersion: "1.1.0", Sources: []string{"tools/go.mod"}},
+	}
+
+	got := mergeDuplicatedVulnerabilities(vs)
+
+	assert.Equal(t, vs, got)
+}
+
+func TestMergeAliasedVulnerabilitiesKeepsDifferentPackagesApart(t *testing.T) {
+	vs := []Vulnerability{
+		{Id: "GHSA-xxxx-yyyy-zzzz", Aliases: []string{"CVE-2021-1234"}, PackageName: "a"},
+		{Id: "CVE-2021-1234", PackageName: "b"},
+	}
+
+	got := mergeAliasedVulnerabilities(vs)
+
+	assert.Equal(t, vs, got)
+}
+
 func TestGenerateReportOSVHasCorrectSeverityKind(t *testing.T) {
 	s := osvScanner{}
 	testCases := map[string]SeverityScoreKind{
@@ -140,6 +670,19 @@ func TestGenerateReportOSVHasCorrectSeverityKind(t *testing.T) {
 	}
 }
 
+func TestGenerateReportOSVWithOverriddenSeverityThresholds(t *testing.T) {
+	defaults := maps.Clone(SeverityScoreThresholds)
+	t.Cleanup(func() { SeverityScoreThresholds = defaults })
+
+	SetSeverityScoreThresholds(map[SeverityScoreKind]float64{High: 7.0})
+
+	s := osvScanner{}
+	got := s.GenerateReport(repository.Project{}, createMockReport("7.5"))
+
+	assert.Equal(t, High, got.Vulnerabilities[0].SeverityScoreKind)
+	assert.Equal(t, 9.0, SeverityScoreThresholds[Critical])
+}
+
 func TestReportContainsHasAvailableFix(t *testing.T) {
 	s := osvScanner{}
 	mockReport := createMockReport("10.0", osvAffected{
@@ -163,6 +706,132 @@ func TestReportContainsHasAvailableFix(t *testing.T) {
 	assert.True(t, got.Vulnerabilities[0].FixAvailable)
 }
 
+func TestGenerateReportOSVWithFixedVersion(t *testing.T) {
+	s := osvScanner{}
+	mockReport := createMockReport("10.0",
+		osvAffected{
+			Package: osvPackageInfo{Name: "other"},
+			Ranges:  []osvRange{{Events: []osvEvent{{Introduced: "0"}, {Fixed: "0.0.1"}}}},
+		},
+		osvAffected{
+			Ranges: []osvRange{
+				{Events: []osvEvent{{Introduced: "0"}, {Fixed: "1.2.5"}}},
+				{Events: []osvEvent{{Introduced: "1.3.0"}, {Fixed: "1.10.1"}}},
+				{Events: []osvEvent{{Introduced: "2.0.0"}, {Fixed: "2.0.3"}}},
+			},
+		},
+	)
+	mockReport.Results[0].Packages[0].PackageInfo.Version = "1.9.0"
+
+	got := s.GenerateReport(repository.Project{}, mockReport)
+
+	assert.Len(t, got.Vulnerabilities, 1)
+	assert.True(t, got.Vulnerabilities[0].FixAvailable)
+	assert.Equal(t, "1.10.1", got.Vulnerabilities[0].FixedVersion)
+}
+
+func TestGenerateReportOSVWithPublishedDate(t *testing.T) {
+	mockReport := createMockReport("8.0")
+	pkg := &mockReport.Results[0].Packages[0]
+	pkg.Vulnerabilities = []osvVulnerability{
+		{Id: "GHSA-xxxx-yyyy-zzzz", Aliases: []string{"CVE-2021-1234"}, Published: "2024-07-18T17:18:46Z"},
+		{Id: "PYSEC-2021-1", Aliases: []string{"CVE-2021-1234"}, Published: "2021-03-01T10:00:00Z"},
+		{Id: "GHSA-aaaa-bbbb-cccc", Published: "yesterday"},
+	}
+	pkg.Groups = []osvGroup{{Ids: []string{"GHSA-xxxx-yyyy-zzzz", "PYSEC-2021-1", "GHSA-aaaa-bbbb-cccc"}, MaxSeverity: "8.0"}}
+
+	s := osvScanner{}
+	got := s.GenerateReport(repository.Project{}, mockReport)
+
+	require.Len(t, got.Vulnerabilities, 2)
+	// The earliest publication of the aliases is kept
+	assert.Equal(t, time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC), got.Vulnerabilities[0].Published)
+	assert.True(t, got.Vulnerabilities[1].Published.IsZero())
+}
+
+func TestGenerateReportOSVFixtureHasPublishedDate(t *testing.T) {
+	data, err := readMockJsonData("testdata/osv-output.json")
+	require.NoError(t, err)
+	report, err := readOSVJson(data)
+	require.NoError(t, err)
+
+	s := osvScanner{}
+	got := s.GenerateReport(repository.Project{}, report)
+
+	require.NotEmpty(t, got.Vulnerabilities)
+	assert.Equal(t, time.Date(2024, 7, 18, 17, 18, 46, 0, time.UTC), got.Vulnerabilities[0].Published)
+}
+
+func TestGetFixedVersion(t *testing.T) {
+	affected := func(fixed ...string) []osvAffected {
+		return []osvAffected{{Ranges: []osvRange{{Events: pie.Map(fixed, func(f string) osvEvent { return osvEvent{Fixed: f} })}}}}
+	}
+
+	testCases := []struct {
+		name      string
+		installed string
+		fixed     []string
+		want      string
+	}{
+		{"no fix", "1.0.0", nil, ""},
+		{"single fix", "1.0.0", []string{"1.0.1"}, "1.0.1"},
+		{"lowest fix above installed", "1.5.0", []string{"2.0.0", "1.2.0", "1.6.0"}, "1.6.0"},
+		{"no fix above installed", "3.0.0", []string{"2.0.0", "1.2.0"}, "1.2.0"},
+		{"pre-release", "1.0.0-rc1", []string{"1.0.0-rc2"}, "1.0.0-rc2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := getFixedVersion(osvVulnerability{Affected: affected(tc.fixed...)}, osvPackageInfo{Version: tc.installed})
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGenerateReportOSVWithPackageUrl(t *testing.T) {
+	data, err := readMockJsonData("testdata/osv-output.json")
+	require.NoError(t, err)
+	report, err := readOSVJson(data)
+	require.NoError(t, err)
+
+	got := (&osvScanner{}).GenerateReport(repository.Project{}, report)
+
+	urls := pie.Map(got.Vulnerabilities, func(v Vulnerability) string { return v.PackageUrl })
+	assert.Contains(t, urls, "pkg:pypi/sentry-sdk@1.45.1")
+	assert.Contains(t, urls, "pkg:pypi/werkzeug@3.0.4")
+}
+
+func TestGetPackageUrl(t *testing.T) {
+	testCases := []struct {
+		name     string
+		affected []osvAffected
+		want     string
+	}{
+		{"no purl", []osvAffected{{Package: osvPackageInfo{Name: "semver"}}}, ""},
+		{"purl of the package", []osvAffected{{Package: osvPackageInfo{Name: "semver", Purl: "pkg:npm/semver"}}}, "pkg:npm/semver@7.3.7"},
+		{"purl of another package", []osvAffected{{Package: osvPackageInfo{Name: "other", Purl: "pkg:npm/other"}}}, ""},
+		{"purl with qualifiers", []osvAffected{{Package: osvPackageInfo{Name: "semver", Purl: "pkg:npm/semver?repository_url=example.com"}}}, "pkg:npm/semver@7.3.7?repository_url=example.com"},
+		{"purl with version", []osvAffected{{Package: osvPackageInfo{Name: "semver", Purl: "pkg:npm/semver@7.0.0"}}}, "pkg:npm/semver@7.0.0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := getPackageUrl(osvVulnerability{Affected: tc.affected}, osvPackageInfo{Name: "semver", Version: "7.3.7"})
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, -1, compareVersions("1.9.0", "1.10.0"))
+	assert.Equal(t, 1, compareVersions("2.0", "1.99.99"))
+	assert.Equal(t, 0, compareVersions("v1.2.3", "v1.2.3"))
+	assert.Equal(t, -1, compareVersions("1.2", "1.2.1"))
+	assert.Equal(t, -1, compareVersions("1.0.0a1", "1.0.0b1"))
+}
+
 func createMockReport(maxSeverity string, affectedVersions ...osvAffected) *OsvReport {
 	return &OsvReport{
 		Results: []osvResult{
@@ -223,3 +892,57 @@ func readMockJsonData(filepath string) ([]byte, error) {
 
 	return byteValue, nil
 }
+
+func TestMergeReportsDeduplicatesAcrossScanners(t *testing.T) {
+	osv := Report{
+		Project: repository.Project{Path: "group/project"},
+		Vulnerabilities: []Vulnerability{
+			{Id: "GHSA-c2qf-rxjj-qqgw", Aliases: []string{"CVE-2022-25883"}, PackageName: "semver", PackageVersion: "7.3.7", PackageEcosystem: "npm", Sources: []string{"package-lock.json"}, Severity: "7.5", SeverityScoreKind: Moderate},
+		},
+	}
+	trivy := Report{
+		Project: repository.Project{Path: "group/project"},
+		Vulnerabilities: []Vulnerability{
+			{Id: "CVE-2022-25883", PackageName: "semver", PackageVersion: "7.3.7", PackageEcosystem: "npm", Sources: []string{"package-lock.json"}, Severity: "7.5", FixAvailable: true, FixedVersion: "7.5.2"},
+			{Id: "CVE-2023-0001", PackageName: "openssl", PackageVersion: "3.0.0", PackageEcosystem: "alpine", Sources: []string{"Dockerfile"}},
+		},
+	}
+
+	got := MergeReports([]Report{osv, trivy})
+
+	assert.Equal(t, "group/project", got.Project.Path)
+	assert.True(t, got.IsVulnerable)
+	assert.Len(t, got.Vulnerabilities, 2)
+	assert.Equal(t, "GHSA-c2qf-rxjj-qqgw", got.Vulnerabilities[0].Id)
+	assert.Equal(t, "7.5.2", got.Vulnerabilities[0].FixedVersion)
+	assert.Equal(t, []string{"CVE-2022-25883"}, got.Vulnerabilities[0].Aliases)
+	assert.Equal(t, "CVE-2023-0001", got.Vulnerabilities[1].Id)
+	// The reports of each scanner are left untouched
+	assert.Len(t, osv.Vulnerabilities, 1)
+	assert.Empty(t, osv.Vulnerabilities[0].FixedVersion)
+}
+
+func TestMergeReportsWithSingleReport(t *testing.T) {
+	r := Report{Project: repository.Project{Path: "group/project"}, Vulnerabilities: []Vulnerability{}}
+
+	assert.Equal(t, r, MergeReports([]Report{r}))
+}
+
+func TestMergeReportsNoManifests(t *testing.T) {
+	noManifests := Report{Vulnerabilities: []Vulnerability{}, NoManifests: true}
+	scanned := Report{Vulnerabilities: []Vulnerability{}}
+
+	assert.True(t, MergeReports([]Report{noManifests, noManifests}).NoManifests)
+	assert.False(t, MergeReports([]Report{noManifests, scanned}).NoManifests, "the project was scanned by the second scanner")
+}
+
+func TestMergeReportsKeepsLicenseViolations(t *testing.T) {
+	osv := Report{
+		LicenseViolations: []LicenseViolation{{PackageName: "left-pad", PackageVersion: "1.3.0", PackageEcosystem: "npm", Licenses: []string{"WTFPL"}}},
+	}
+	trivy := Report{Vulnerabilities: []Vulnerability{}}
+
+	got := MergeReports([]Report{osv, trivy})
+
+	assert.Equal(t, osv.LicenseViolations, got.LicenseViolations)
+}
@@ -0,0 +1,181 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sheriff/internal/repository"
+	"sheriff/internal/shell"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	TrivyCommandName = "trivy"
+	trivyTimeout     = 10 * time.Minute
+)
+
+// trivySeverityKinds maps the severities of trivy to our severity kinds,
+// for the vulnerabilities without a CVSS score
+var trivySeverityKinds = map[string]SeverityScoreKind{
+	"CRITICAL": Critical,
+	"HIGH":     High,
+	"MEDIUM":   Moderate,
+	"LOW":      Low,
+}
+
+// trivyCvss is the CVSS score of a vulnerability according to a given source (e.g. nvd or ghsa)
+type trivyCvss struct {
+	V3Score float64 `json:"V3Score"`
+}
+
+// trivyVulnerability is a vulnerability of a package found by trivy
+type trivyVulnerability struct {
+	VulnerabilityID  string               `json:"VulnerabilityID"`  // CVE or GHSA identifier
+	PkgName          string               `json:"PkgName"`          // Name of the vulnerable package
+	InstalledVersion string               `json:"InstalledVersion"` // Version of the package in the project
+	FixedVersion     string               `json:"FixedVersion"`     // Comma separated versions fixing the vulnerability, if any
+	Title            string               `json:"Title"`            // Short summary of the vulnerability
+	Description      string               `json:"Description"`      // Detailed description of the vulnerability
+	Severity         string               `json:"Severity"`         // One of CRITICAL, HIGH, MEDIUM, LOW or UNKNOWN
+	CVSS             map[string]trivyCvss `json:"CVSS"`             // CVSS scores by source
+	PkgIdentifier    trivyPkgIdentifier   `json:"PkgIdentifier"`    // Identifiers of the vulnerable package
+}
+
+// trivyPkgIdentifier are the identifiers of a package found by trivy
+type trivyPkgIdentifier struct {
+	PURL string `json:"PURL"` // Package URL of the installed version of the package
+}
+
+// trivyResult are the vulnerabilities found in a single target, e.g. a lockfile
+type trivyResult struct {
+	Target          string               `json:"Target"` // Path of the target, relative to the scanned directory
+	Type            string               `json:"Type"`   // Package manager of the target, e.g. npm or gomod
+	Vulnerabilities []trivyVulnerability `json:"Vulnerabilities"`
+}
+
+// TrivyReport represents a vulnerability report as returned by trivy.
+type TrivyReport struct {
+	Results []trivyResult `json:"Results"`
+}
+
+// trivyScanner is a concrete implementation of the VulnScanner interface
+// that uses Aqua Security's trivy to scan for vulnerabilities in a project directory.
+type trivyScanner struct{}
+
+// NewTrivyScanner creates a new instance of trivyScanner.
+// It is a vulnScanner that uses trivy to scan the filesystem of a project for vulnerabilities.
+func NewTrivyScanner() VulnScanner[TrivyReport] {
+	return &trivyScanner{}
+}
+
+// Scan scans the specified directory for vulnerabilities using trivy.
+func (s *trivyScanner) Scan(ctx context.Context, dir string) (*TrivyReport, error) {
+	cmdOut, err := shell.ShellCommandRunner.Run(
+		ctx,
+		shell.CommandInput{
+			Name:    TrivyCommandName,
+			Args:    []string{"fs", "--scanners", "vuln", "--format", "json", "--quiet", dir},
+			Timeout: trivyTimeout,
+		},
+	)
+	// Unlike osv-scanner, trivy exits with 0 when it finds vulnerabilities
+	if err != nil || cmdOut.ExitCode != 0 {
+		log.Debug().Int("exitCode", cmdOut.ExitCode).Msg("trivy failed to run")
+		return nil, errors.Join(fmt.Errorf("trivy exited with code %v", cmdOut.ExitCode), err)
+	}
+
+	report, err := readTrivyJson(cmdOut.Output)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to parse trivy report"), err)
+	}
+
+	return report, nil
+}
+
+// readTrivyJson reads the JSON output from trivy
+func readTrivyJson(data []byte) (report *TrivyReport, err error) {
+	err = json.Unmarshal(data, &report)
+	return
+}
+
+// GenerateReport generates a Report struct from the TrivyReport.
+func (s *trivyScanner) GenerateReport(p repository.Project, r *TrivyReport) Report {
+	vs := []Vulnerability{}
+	if r != nil {
+		for _, res := range r.Results {
+			for _, v := range res.Vulnerabilities {
+				severity := getTrivySeverity(v)
+				kind := GetSeverityScoreKind(severity)
+				if severity == "" {
+					kind = getTrivySeverityKind(v.Severity)
+				}
+				fixedVersion := lowestFixedVersion(splitTrivyFixedVersions(v.FixedVersion), v.InstalledVersion)
+
+				vs = append(vs, Vulnerability{
+					Id:                v.VulnerabilityID,
+					PackageName:       v.PkgName,
+					PackageVersion:    v.InstalledVersion,
+					PackageUrl:        v.PkgIdentifier.PURL,
+					PackageEcosystem:  res.Type,
+					Source:            filepath.Base(res.Target),
+					Sources:           []string{filepath.ToSlash(res.Target)},
+					Severity:          severity,
+					SeverityScoreKind: kind,
+					Summary:           v.Title,
+					Details:           v.Description,
+					FixAvailable:      fixedVersion != "",
+					FixedVersion:      fixedVersion,
+				})
+			}
+		}
+	}
+
+	vs = mergeDuplicatedVulnerabilities(vs)
+
+	return Report{
+		Project:         p,
+		IsVulnerable:    len(vs) > 0,
+		Vulnerabilities: vs,
+		// trivy lists a result for each lockfile or manifest it found, even without vulnerabilities
+		NoManifests: r != nil && len(r.Results) == 0,
+	}
+}
+
+// getTrivySeverity returns the highest CVSS v3 score of the vulnerability among all sources,
+// formatted like the scores of OSV. It is empty if the vulnerability has no CVSS score.
+func getTrivySeverity(v trivyVulnerability) string {
+	var score float64
+	for _, cvss := range v.CVSS {
+		score = max(score, cvss.V3Score)
+	}
+	if score == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%.1f", score)
+}
+
+// getTrivySeverityKind returns the severity kind of the given trivy severity
+func getTrivySeverityKind(severity string) SeverityScoreKind {
+	if kind, ok := trivySeverityKinds[severity]; ok {
+		return kind
+	}
+
+	return Unknown
+}
+
+// splitTrivyFixedVersions splits the comma separated fixed versions of trivy
+func splitTrivyFixedVersions(fixed string) (versions []string) {
+	for _, v := range strings.Split(fixed, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			versions = append(versions, v)
+		}
+	}
+
+	return
+}
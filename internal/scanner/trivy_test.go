@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"context"
+	"sheriff/internal/repository"
+	"sheriff/internal/shell"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrivyScanReturnsFullReport(t *testing.T) {
+	runner := &mockCommandRunner{FixturePath: "testdata/trivy-output.json", ExitCode: 0}
+	originalShellCommandRunner := shell.ShellCommandRunner
+	shell.ShellCommandRunner = runner
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	report, err := NewTrivyScanner().Scan(context.Background(), "test-dir")
+
+	require.NoError(t, err)
+	assert.Equal(t, TrivyCommandName, runner.Input.Name)
+	assert.Equal(t, []string{"fs", "--scanners", "vuln", "--format", "json", "--quiet", "test-dir"}, runner.Input.Args)
+	assert.Len(t, report.Results, 2)
+	assert.Len(t, report.Results[0].Vulnerabilities, 2)
+}
+
+func TestTrivyScanFails(t *testing.T) {
+	originalShellCommandRunner := shell.ShellCommandRunner
+	shell.ShellCommandRunner = &mockCommandRunner{FixturePath: "testdata/trivy-output.json", ExitCode: 1}
+	defer func() {
+		shell.ShellCommandRunner = originalShellCommandRunner
+	}()
+
+	report, err := NewTrivyScanner().Scan(context.Background(), "test-dir")
+
+	assert.Error(t, err)
+	assert.Nil(t, report)
+}
+
+func TestGenerateReportTrivy(t *testing.T) {
+	data, err := readMockJsonData("testdata/trivy-output.json")
+	require.NoError(t, err)
+	report, err := readTrivyJson(data)
+	require.NoError(t, err)
+
+	got := NewTrivyScanner().GenerateReport(repository.Project{Path: "group/project"}, report)
+
+	assert.True(t, got.IsVulnerable)
+	require.Len(t, got.Vulnerabilities, 2)
+	assert.Equal(t, Vulnerability{
+		Id:                "CVE-2022-25883",
+		PackageName:       "semver",
+		PackageVersion:    "7.3.7",
+		PackageUrl:        "pkg:npm/semver@7.3.7",
+		PackageEcosystem:  "npm",
+		Source:            "package-lock.json",
+		Sources:           []string{"frontend/package-lock.json"},
+		Severity:          "7.5",
+		SeverityScoreKind: Moderate,
+		Summary:           "nodejs-semver: Regular expression denial of service",
+		Details:           "Versions of the package semver before 7.5.2 are vulnerable to Regular Expression Denial of Service (ReDoS).",
+		FixAvailable:      true,
+		FixedVersion:      "7.5.2",
+	}, got.Vulnerabilities[0])
+	// Without a CVSS score, the severity of trivy is used
+	assert.Equal(t, "", got.Vulnerabilities[1].Severity)
+	assert.Equal(t, Moderate, got.Vulnerabilities[1].SeverityScoreKind)
+	assert.False(t, got.Vulnerabilities[1].FixAvailable)
+}
+
+func TestGenerateReportTrivyWithoutReport(t *testing.T) {
+	got := NewTrivyScanner().GenerateReport(repository.Project{}, nil)
+
+	assert.False(t, got.IsVulnerable)
+	assert.Empty(t, got.Vulnerabilities)
+	assert.False(t, got.NoManifests)
+}
+
+func TestGenerateReportTrivyWithoutResults(t *testing.T) {
+	report, err := readTrivyJson([]byte(`{"SchemaVersion": 2, "ArtifactName": "test-dir"}`))
+	require.NoError(t, err)
+
+	got := NewTrivyScanner().GenerateReport(repository.Project{}, report)
+
+	assert.False(t, got.IsVulnerable)
+	assert.True(t, got.NoManifests)
+}
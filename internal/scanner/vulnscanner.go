@@ -2,10 +2,25 @@
 package scanner
 
 import (
+	"context"
 	"sheriff/internal/config"
 	"sheriff/internal/repository"
+	"slices"
+	"time"
+
+	"github.com/elliotchance/pie/v2"
+	"github.com/rs/zerolog/log"
 )
 
+// Names of the scanners which can be selected to patrol with
+const (
+	OsvScannerName   = "osv"
+	TrivyScannerName = "trivy"
+)
+
+// ScannerNames are the names of all supported scanners
+var ScannerNames = []string{OsvScannerName, TrivyScannerName}
+
 type SeverityScoreKind string
 
 const (
@@ -17,6 +32,16 @@ const (
 	Acknowledged SeverityScoreKind = "ACKNOWLEDGED"
 )
 
+// ErrorKind tells why the scan of a project failed
+type ErrorKind string
+
+const (
+	CloneFailed   ErrorKind = "CLONE_FAILED"   // The project could not be downloaded
+	ScanFailed    ErrorKind = "SCAN_FAILED"    // A scanner failed, or the scan could not be prepared
+	ConfigInvalid ErrorKind = "CONFIG_INVALID" // The sheriff configuration of the project could not be read
+	Timeout       ErrorKind = "TIMEOUT"        // The scan took longer than the per-project timeout
+)
+
 // SeverityScoreThresholds are inferred from CSVSS reports we've seen in the wild.
 // The value represents the lower bound (inclusive) of the severity score kind.
 // They may need to be adjusted as we observe more vulnerabilities.
@@ -29,37 +54,185 @@ var SeverityScoreThresholds = map[SeverityScoreKind]float64{
 	Acknowledged: -2.0, // Arbitrary value to represent acknowledged vulnerabilities
 }
 
+// SetSeverityScoreThresholds overrides the lower bounds of the given severity score kinds.
+// It must be called before any report is generated, as it is not safe for concurrent use.
+func SetSeverityScoreThresholds(thresholds map[SeverityScoreKind]float64) {
+	for kind, value := range thresholds {
+		SeverityScoreThresholds[kind] = value
+	}
+}
+
+// MeetsSeverityThreshold returns true if the severity score kind is at or above the given threshold.
+func MeetsSeverityThreshold(kind SeverityScoreKind, threshold SeverityScoreKind) bool {
+	return SeverityScoreThresholds[kind] >= SeverityScoreThresholds[threshold]
+}
+
+// EpssUnknown is the EPSS score of the vulnerabilities whose score could not be fetched, e.g. because they have no CVE
+const EpssUnknown = -1.0
+
 // Vulnerability is a representation of what a vulnerability is within our scanner
 type Vulnerability struct {
 	Id                string
+	Aliases           []string // Other identifiers of the same vulnerability, e.g. the CVE of a GHSA
 	PackageName       string
 	PackageVersion    string
-	PackageUrl        string
+	PackageUrl        string // Package URL (purl) of the vulnerable version of the package, e.g. pkg:npm/semver@7.3.7, empty if unknown
 	PackageEcosystem  string
 	Source            string
+	Sources           []string // Paths, relative to the project root, of all the lockfiles the vulnerability was found in
 	Severity          string
 	SeverityScoreKind SeverityScoreKind
 	Summary           string
 	Details           string
 	FixAvailable      bool
-	AckReason         string // Optional reason for acknowledging the vulnerability
+	FixedVersion      string    // Lowest version of the package fixing the vulnerability, empty if no fix is known
+	AckReason         string    // Optional reason for acknowledging the vulnerability
+	Epss              float64   // Probability of exploitation in the next 30 days according to EPSS, only set if EPSS is enabled
+	Reachable         *bool     // Whether the vulnerable code is called by the project, nil if it was not analysed
+	Published         time.Time // Date the vulnerability was published in its database, zero if unknown
+}
+
+// LicenseViolation is a package whose license is not in the allowlist of licenses.
+// It is reported apart from the vulnerabilities, as it has no severity.
+type LicenseViolation struct {
+	PackageName      string
+	PackageVersion   string
+	PackageEcosystem string
+	Licenses         []string // Licenses of the package which are not allowed
+	Sources          []string // Paths, relative to the project root, of all the lockfiles the package was found in
 }
 
 // Report is the main report representation of a project vulnerability scan.
 type Report struct {
-	Project         repository.Project
-	ProjectConfig   config.ProjectConfig // Contains the project-level configuration that users of sheriff may have in their repository
-	IsVulnerable    bool
-	Vulnerabilities []Vulnerability
-	IssueUrl        string   // URL of the GitLab issue. Conditionally set if --gitlab-issue is passed
-	Error           bool     // Conditionally set if an error occurred during the scan
-	OutdatedAcks    []string // Vulnerabilities in the project configuration that are no longer present in the report
+	Project           repository.Project
+	ProjectConfig     config.ProjectConfig // Contains the project-level configuration that users of sheriff may have in their repository
+	IsVulnerable      bool
+	Vulnerabilities   []Vulnerability
+	NoManifests       bool               // Set if no lockfile or manifest was found to scan, so that the project is not known to be safe
+	LicenseViolations []LicenseViolation // Packages whose license is not allowed, only checked if an allowlist of licenses is configured
+	IssueUrl          string             // URL of the GitLab issue. Conditionally set if --gitlab-issue is passed
+	CommitSha         string             // Sha of the scanned commit, empty if it is not known
+	Error             bool               // Set if an error occurred during the scan, whenever ErrorKind is set
+	ErrorKind         ErrorKind          // Why the scan failed, empty if it succeeded
+	ErrorMessage      string             // Error which made the scan fail, empty if it succeeded
+	ConfigWarnings    []string           // Problems found in the project configuration, which did not prevent the scan
+	Duration          time.Duration      // Time spent downloading and scanning the project, whether the scan succeeded or not
+	OutdatedAcks      []string           // Vulnerabilities in the project configuration that are no longer present in the report
+	ExpiredAcks       []string           // Vulnerabilities in the report whose acknowledgement in the project configuration has expired
+	Suppressed        []Vulnerability    // Vulnerabilities only found in files listed in the ignore file of the project, left out of Vulnerabilities
+	RawOsvReport      []byte             // JSON output of osv-scanner, only kept if requested (see OsvOpts.KeepRawReport) and vulnerabilities were found
+	// Vulnerabilities not listed in the issue of the project before this run, only set once the issue is opened or updated
+	NewVulnerabilities []Vulnerability
+}
+
+// NewFailedReport creates the report of a project whose scan failed for the given reason
+func NewFailedReport(p repository.Project, kind ErrorKind, message string) Report {
+	return Report{Project: p, Error: true, ErrorKind: kind, ErrorMessage: message}
 }
 
 // VulnScanner is an interface for any vulnerability scanner
 type VulnScanner[T any] interface {
-	// Scan runs a vulnerability scan on the given directory
-	Scan(dir string) (*T, error)
+	// Scan runs a vulnerability scan on the given directory, until done or the context is cancelled
+	Scan(ctx context.Context, dir string) (*T, error)
 	// GenerateReport maps the report from the scanner to our internal representation of vulnerability reports.
 	GenerateReport(p repository.Project, r *T) Report
 }
+
+// ProjectScanner runs a vulnerability scanner on a project and maps its results to a Report,
+// whatever the format of the raw report of the scanner. It allows patrolling with several scanners at once.
+type ProjectScanner interface {
+	// Name returns the name of the scanner, one of ScannerNames
+	Name() string
+	// ScanProject scans the project downloaded in the given directory, until done or the context is cancelled
+	ScanProject(ctx context.Context, p repository.Project, dir string) (Report, error)
+}
+
+type projectScanner[T any] struct {
+	name    string
+	scanner VulnScanner[T]
+}
+
+// NewProjectScanner wraps the given VulnScanner as a ProjectScanner with the given name
+func NewProjectScanner[T any](name string, s VulnScanner[T]) ProjectScanner {
+	return &projectScanner[T]{name: name, scanner: s}
+}
+
+func (s *projectScanner[T]) Name() string {
+	return s.name
+}
+
+func (s *projectScanner[T]) ScanProject(ctx context.Context, p repository.Project, dir string) (Report, error) {
+	r, err := s.scanner.Scan(ctx, dir)
+	if err != nil {
+		return Report{}, err
+	}
+
+	return s.scanner.GenerateReport(p, r), nil
+}
+
+// MergeReports combines the reports of the same project generated by several scanners into one.
+// The vulnerabilities found by more than one scanner are only kept once, from the first report they are found in.
+func MergeReports(reports []Report) Report {
+	if len(reports) == 0 {
+		return Report{}
+	}
+	if len(reports) == 1 {
+		return reports[0]
+	}
+
+	merged := reports[0]
+	merged.Vulnerabilities = slices.Clone(merged.Vulnerabilities)
+	merged.LicenseViolations = slices.Clone(merged.LicenseViolations)
+	for _, r := range reports[1:] {
+		merged.Vulnerabilities = append(merged.Vulnerabilities, r.Vulnerabilities...)
+		merged.LicenseViolations = append(merged.LicenseViolations, r.LicenseViolations...)
+		if merged.RawOsvReport == nil {
+			merged.RawOsvReport = r.RawOsvReport
+		}
+	}
+	merged.Vulnerabilities = mergeOverlappingVulnerabilities(merged.Vulnerabilities)
+	merged.IsVulnerable = len(merged.Vulnerabilities) > 0
+	// A project is only left unscanned if none of the scanners found anything to scan
+	merged.NoManifests = pie.All(reports, func(r Report) bool { return r.NoManifests })
+
+	return merged
+}
+
+// mergeOverlappingVulnerabilities collapses the vulnerabilities of the same package version which share an identifier.
+// Unlike mergeAliasedVulnerabilities, the ecosystem is not compared, as each scanner names them differently.
+func mergeOverlappingVulnerabilities(vs []Vulnerability) (merged []Vulnerability) {
+	for _, v := range vs {
+		idx := slices.IndexFunc(merged, func(m Vulnerability) bool {
+			return m.PackageName == v.PackageName &&
+				m.PackageVersion == v.PackageVersion &&
+				slices.ContainsFunc(Identifiers(v), func(id string) bool { return slices.Contains(Identifiers(m), id) })
+		})
+		if idx == -1 {
+			merged = append(merged, v)
+			continue
+		}
+
+		log.Debug().Str("id", merged[idx].Id).Str("duplicate", v.Id).Msg("Merging vulnerability found by several scanners")
+		m := &merged[idx]
+		m.Sources = mergeSources(m.Sources, v.Sources)
+		m.Reachable = mergeReachable(m.Reachable, v.Reachable)
+		m.Published = mergePublished(m.Published, v.Published)
+		// Clip so that appending never writes into the aliases slice of another report
+		m.Aliases = slices.Clip(m.Aliases)
+		for _, id := range Identifiers(v) {
+			if !slices.Contains(Identifiers(*m), id) {
+				m.Aliases = append(m.Aliases, id)
+			}
+		}
+		if m.Severity == "" && v.Severity != "" {
+			m.Severity = v.Severity
+			m.SeverityScoreKind = v.SeverityScoreKind
+		}
+		if m.FixedVersion == "" {
+			m.FixAvailable = m.FixAvailable || v.FixAvailable
+			m.FixedVersion = v.FixedVersion
+		}
+	}
+
+	return
+}
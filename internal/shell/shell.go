@@ -11,7 +11,7 @@ const defaultTimeout = 5 * time.Second
 
 // commandRunnerInterface is an interface that defines the Run method for running shell commands.
 type commandRunnerInterface interface {
-	Run(in CommandInput) (CommandOutput, error)
+	Run(ctx context.Context, in CommandInput) (CommandOutput, error)
 }
 
 // CommandOutput is a struct that contains the output of a shell command
@@ -35,11 +35,12 @@ type shellCommandRunner struct{}
 
 // Run runs a shell command with the given input and returns the output and error.
 // If the given CommandInput timeout is 0, it will default to 5 seconds.
-func (c *shellCommandRunner) Run(in CommandInput) (CommandOutput, error) {
+// The command is killed if the given context is cancelled before it finishes.
+func (c *shellCommandRunner) Run(ctx context.Context, in CommandInput) (CommandOutput, error) {
 	if in.Timeout == 0 {
 		in.Timeout = defaultTimeout
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), in.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, in.Timeout)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, in.Name, in.Args...)
 	out, err := cmd.Output()
@@ -1,16 +1,28 @@
 package shell
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func TestCancelledContextKillsCommand(t *testing.T) {
+	runner := &shellCommandRunner{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	output, err := runner.Run(ctx, CommandInput{Name: "sleep", Args: []string{"1"}, Timeout: 1 * time.Second})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, -1, output.ExitCode)
+}
+
 func TestSuccessfulCommand(t *testing.T) {
 	runner := &shellCommandRunner{}
 
-	output, err := runner.Run(CommandInput{Name: "echo", Args: []string{"hello"}, Timeout: 1 * time.Second})
+	output, err := runner.Run(context.Background(), CommandInput{Name: "echo", Args: []string{"hello"}, Timeout: 1 * time.Second})
 
 	assert.Nil(t, err)
 	assert.Equal(t, "hello\n", string(output.Output))
@@ -20,7 +32,7 @@ func TestSuccessfulCommand(t *testing.T) {
 func TestCommandWithNoArgs(t *testing.T) {
 	runner := &shellCommandRunner{}
 
-	output, err := runner.Run(CommandInput{Name: "echo", Args: []string{}, Timeout: 1 * time.Second})
+	output, err := runner.Run(context.Background(), CommandInput{Name: "echo", Args: []string{}, Timeout: 1 * time.Second})
 
 	assert.Nil(t, err)
 	assert.Equal(t, "\n", string(output.Output))
@@ -30,7 +42,7 @@ func TestCommandWithNoArgs(t *testing.T) {
 func TestCommandTimeoutError(t *testing.T) {
 	runner := &shellCommandRunner{}
 
-	output, err := runner.Run(CommandInput{Name: "sleep", Args: []string{"1"}, Timeout: 1 * time.Millisecond})
+	output, err := runner.Run(context.Background(), CommandInput{Name: "sleep", Args: []string{"1"}, Timeout: 1 * time.Millisecond})
 
 	assert.NotNil(t, err)
 	assert.Equal(t, "", string(output.Output))
@@ -40,7 +52,7 @@ func TestCommandTimeoutError(t *testing.T) {
 func TestCommandWithNoTimeout(t *testing.T) {
 	runner := &shellCommandRunner{}
 
-	output, err := runner.Run(CommandInput{Name: "echo", Args: []string{"hello"}})
+	output, err := runner.Run(context.Background(), CommandInput{Name: "echo", Args: []string{"hello"}})
 
 	assert.Nil(t, err)
 	assert.Equal(t, "hello\n", string(output.Output))
@@ -50,7 +62,7 @@ func TestCommandWithNoTimeout(t *testing.T) {
 func TestFailedCommand(t *testing.T) {
 	runner := &shellCommandRunner{}
 
-	output, err := runner.Run(CommandInput{Name: "ls", Args: []string{"/nonexistent"}, Timeout: 1 * time.Second})
+	output, err := runner.Run(context.Background(), CommandInput{Name: "ls", Args: []string{"/nonexistent"}, Timeout: 1 * time.Second})
 
 	assert.NotNil(t, err)
 	assert.Equal(t, "", string(output.Output))
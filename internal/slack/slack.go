@@ -3,24 +3,30 @@ package slack
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/elliotchance/pie/v2"
 	"github.com/rs/zerolog/log"
 	"github.com/slack-go/slack"
 )
 
+// ChannelIdPrefix marks a channel given by its ID instead of its name, e.g. `id:C0123ABCD`.
+// Such channels are posted to directly, without looking them up in the list of conversations.
+const ChannelIdPrefix = "id:"
+
 type IService interface {
 	PostMessage(channelName string, options ...slack.MsgOption) (ts string, err error)
 }
 
 type service struct {
-	client         iclient
-	maxAttempts    int
-	initialBackoff time.Duration
-	channelCache   map[string]*slack.Channel
-	cacheMutex     sync.RWMutex
+	client          iclient
+	maxAttempts     int
+	initialBackoff  time.Duration
+	channelCache    map[string]*slack.Channel // Channels by name, as of the last listing
+	cacheGeneration int                       // Number of times the channels were listed
+	cacheMutex      sync.RWMutex
+	refreshMutex    sync.Mutex // Ensures that the channels are listed by a single lookup at a time
 }
 
 type conversationsResult struct {
@@ -45,15 +51,19 @@ func New(token string, debug bool) (IService, error) {
 	return &s, nil
 }
 
-// PostMessage posts a message to the given slack channel
+// PostMessage posts a message to the given slack channel, given by its name or by its ID prefixed with ChannelIdPrefix
 func (s *service) PostMessage(channelName string, options ...slack.MsgOption) (ts string, err error) {
-	channel, err := runWithRetries(func() (*slack.Channel, error) { return s.findSlackChannel(channelName) }, s.maxAttempts, s.initialBackoff)
-	if err != nil {
-		return
+	channelID, isID := strings.CutPrefix(channelName, ChannelIdPrefix)
+	if !isID {
+		channel, err := runWithRetries(func() (*slack.Channel, error) { return s.findSlackChannel(channelName) }, s.maxAttempts, s.initialBackoff)
+		if err != nil {
+			return "", err
+		}
+		channelID = channel.ID
 	}
 
 	ts, err = runWithRetries(func() (string, error) {
-		_, msgTs, err := s.client.PostMessage(channel.ID, options...)
+		_, msgTs, err := s.client.PostMessage(channelID, options...)
 		return msgTs, err
 	}, s.maxAttempts, s.initialBackoff)
 	if err != nil {
@@ -66,18 +76,44 @@ func (s *service) PostMessage(channelName string, options ...slack.MsgOption) (t
 }
 
 // findSlackChannel finds the slack channel by name.
+// The channels are listed once and cached for the lifetime of the service, so that looking up several channels
+// only traverses the list of conversations once. The list is fetched again on a miss, to find newly created channels.
 // If the channel is not found, it returns an error.
 func (s *service) findSlackChannel(channelName string) (channel *slack.Channel, err error) {
-	var nextCursor string
-	var channels []slack.Channel
-	var channelTypes = []string{"private_channel", "public_channel"}
-
-	cachedChannel := s.getCachedChannel(channelName)
-	if cachedChannel != nil {
+	channel, generation := s.getCachedChannel(channelName)
+	if channel != nil {
 		log.Debug().Str("channel", channelName).Msg("Found slack channel in cache")
-		return cachedChannel, nil
+		return channel, nil
+	}
+
+	if err = s.refreshChannelCache(generation); err != nil {
+		return nil, err
 	}
 
+	if channel, _ = s.getCachedChannel(channelName); channel == nil {
+		return nil, fmt.Errorf("channel %v not found", channelName)
+	}
+	log.Info().Str("channel", channelName).Msg("Found slack channel")
+
+	return
+}
+
+// refreshChannelCache lists all the channels and replaces the cache with them.
+// The listing is skipped if the cache was refreshed since the given generation, e.g. by a concurrent lookup.
+func (s *service) refreshChannelCache(generation int) error {
+	s.refreshMutex.Lock()
+	defer s.refreshMutex.Unlock()
+
+	s.cacheMutex.RLock()
+	refreshed := s.cacheGeneration != generation
+	s.cacheMutex.RUnlock()
+	if refreshed {
+		return nil
+	}
+
+	var nextCursor string
+	var channelTypes = []string{"private_channel", "public_channel"}
+	channels := make(map[string]*slack.Channel)
 	for {
 		result, opErr := runWithRetries(func() (conversationsResult, error) {
 			convChannels, convCursor, convErr := s.client.GetConversations(&slack.GetConversationsParameters{
@@ -92,42 +128,35 @@ func (s *service) findSlackChannel(channelName string) (channel *slack.Channel,
 			return conversationsResult{Channels: convChannels, NextCursor: convCursor}, nil
 		}, s.maxAttempts, s.initialBackoff)
 		if opErr != nil {
-			return nil, errors.Join(errors.New("failed to get slack channel list"), opErr)
+			return errors.Join(errors.New("failed to get slack channel list"), opErr)
 		}
 
-		channels = result.Channels
-		nextCursor = result.NextCursor
-
-		idx := pie.FindFirstUsing(channels, func(c slack.Channel) bool { return c.Name == channelName })
-		if idx > -1 {
-			log.Info().Str("channel", channelName).Msg("Found slack channel")
-			channel = &channels[idx]
-			s.saveChannelToCache(channelName, channel)
-			return
-		} else if nextCursor == "" {
-			return nil, fmt.Errorf("channel %v not found", channelName)
+		for i := range result.Channels {
+			channels[result.Channels[i].Name] = &result.Channels[i]
 		}
 
-		log.Debug().Str("channel", channelName).Str("nextPage", nextCursor).Msg("Channel not found in current page, fetching next page")
+		if nextCursor = result.NextCursor; nextCursor == "" {
+			break
+		}
+		log.Debug().Str("nextPage", nextCursor).Msg("Fetching next page of slack channels")
 	}
+
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+	s.channelCache = channels
+	s.cacheGeneration++
+	log.Debug().Int("channels", len(channels)).Msg("Listed slack channels")
+
+	return nil
 }
 
-// getCachedChannel retrieves a channel from the cache if it exists
-func (s *service) getCachedChannel(channelName string) (channel *slack.Channel) {
+// getCachedChannel retrieves a channel from the cache if it exists, along with the generation of the cache,
+// which is incremented each time the channels are listed again
+func (s *service) getCachedChannel(channelName string) (channel *slack.Channel, generation int) {
 	s.cacheMutex.RLock()
 	defer s.cacheMutex.RUnlock()
-	ch := s.channelCache[channelName]
-	return ch
-}
 
-// saveChannelToCache saves a channel to the cache
-func (s *service) saveChannelToCache(channelName string, channel *slack.Channel) {
-	s.cacheMutex.Lock()
-	defer s.cacheMutex.Unlock()
-	if s.channelCache == nil {
-		s.channelCache = make(map[string]*slack.Channel)
-	}
-	s.channelCache[channelName] = channel
+	return s.channelCache[channelName], s.cacheGeneration
 }
 
 func runWithRetries[T any](operation func() (T, error), maxAttempts int, backoff time.Duration) (result T, err error) {
@@ -2,6 +2,7 @@ package slack
 
 import (
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -49,6 +50,26 @@ func TestPostMessage(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestPostMessageToChannelId(t *testing.T) {
+	message := slack.MsgOptionText("Hello World", false)
+
+	mockClient := mockClient{}
+	mockClient.On("PostMessage", "C0123ABCD", mock.Anything).Return("", "1234.5678", nil)
+
+	svc := service{
+		client:         &mockClient,
+		maxAttempts:    3,
+		initialBackoff: 2 * time.Second,
+	}
+
+	ts, err := svc.PostMessage("id:C0123ABCD", message)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1234.5678", ts)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "GetConversations", mock.Anything)
+}
+
 func TestFindSlackChannel(t *testing.T) {
 	channelID := "1234"
 	channelName := "random channel"
@@ -86,6 +107,60 @@ func TestFindSlackChannel(t *testing.T) {
 
 }
 
+func newMockChannel(id string, name string) slack.Channel {
+	return slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: id}, Name: name}}
+}
+
+func TestFindSlackChannelListsChannelsOnce(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("GetConversations", mock.MatchedBy(func(p *slack.GetConversationsParameters) bool { return p.Cursor == "" })).
+		Return([]slack.Channel{newMockChannel("1", "security"), newMockChannel("2", "backend")}, "page-2", nil).Once()
+	mockClient.On("GetConversations", mock.MatchedBy(func(p *slack.GetConversationsParameters) bool { return p.Cursor == "page-2" })).
+		Return([]slack.Channel{newMockChannel("3", "frontend")}, "", nil).Once()
+
+	svc := service{
+		client:         &mockClient,
+		maxAttempts:    3,
+		initialBackoff: 2 * time.Second,
+	}
+
+	var wg sync.WaitGroup
+	ids := make([]string, 3)
+	for i, name := range []string{"security", "backend", "frontend"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			channel, err := svc.findSlackChannel(name)
+			assert.Nil(t, err)
+			ids[i] = channel.ID
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+	mockClient.AssertNumberOfCalls(t, "GetConversations", 2) // A single traversal of both pages
+}
+
+func TestFindSlackChannelListsChannelsAgainOnMiss(t *testing.T) {
+	mockClient := mockClient{}
+	mockClient.On("GetConversations", mock.Anything).Return([]slack.Channel{newMockChannel("1", "security")}, "", nil).Once()
+	mockClient.On("GetConversations", mock.Anything).Return([]slack.Channel{newMockChannel("1", "security"), newMockChannel("2", "new-channel")}, "", nil).Once()
+
+	svc := service{
+		client:         &mockClient,
+		maxAttempts:    3,
+		initialBackoff: 2 * time.Second,
+	}
+
+	_, err := svc.findSlackChannel("security")
+	assert.Nil(t, err)
+
+	channel, err := svc.findSlackChannel("new-channel")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", channel.ID)
+	mockClient.AssertNumberOfCalls(t, "GetConversations", 2)
+}
+
 type mockClient struct {
 	mock.Mock
 }
@@ -0,0 +1,79 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+)
+
+// webhookTimeout is the timeout of a single request to the incoming webhook
+const webhookTimeout = 30 * time.Second
+
+type webhookService struct {
+	url            string
+	httpClient     *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+}
+
+// NewWebhook creates a Slack service posting to an incoming webhook, which requires no bot token.
+// An incoming webhook is bound to a single channel: every message is posted to it, whatever the channel
+// it is meant for. Messages cannot be threaded either, as the webhook does not return their timestamp.
+func NewWebhook(url string) (IService, error) {
+	if url == "" {
+		return nil, errors.New("missing slack webhook URL")
+	}
+
+	s := webhookService{
+		url:            url,
+		httpClient:     &http.Client{Timeout: webhookTimeout},
+		maxAttempts:    5,
+		initialBackoff: 2 * time.Second,
+	}
+
+	return &s, nil
+}
+
+// PostMessage posts a message to the channel of the webhook. The given channel name is only used in the logs.
+// The returned timestamp is always empty, so replies to the message are posted as separate messages.
+func (s *webhookService) PostMessage(channelName string, options ...slack.MsgOption) (ts string, err error) {
+	msg, err := newWebhookMessage(options...)
+	if err != nil {
+		return "", errors.Join(errors.New("failed to format slack message"), err)
+	}
+
+	_, err = runWithRetries(func() (struct{}, error) {
+		return struct{}{}, slack.PostWebhookCustomHTTP(s.url, s.httpClient, msg)
+	}, s.maxAttempts, s.initialBackoff)
+	if err != nil {
+		return "", errors.Join(errors.New("failed to post slack message to webhook"), err)
+	}
+
+	log.Info().Str("channel", channelName).Msg("Posted slack message to webhook")
+
+	return "", nil
+}
+
+// newWebhookMessage converts the options of a message of the Web API into the payload of an incoming webhook.
+// Only the text and the blocks are kept, as the other options (e.g. the thread timestamp) are not supported by webhooks.
+func newWebhookMessage(options ...slack.MsgOption) (*slack.WebhookMessage, error) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", "", "", options...)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &slack.WebhookMessage{Text: values.Get("text")}
+	if raw := values.Get("blocks"); raw != "" {
+		var blocks slack.Blocks
+		if err := json.Unmarshal([]byte(raw), &blocks); err != nil {
+			return nil, err
+		}
+		msg.Blocks = &blocks
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,70 @@
+package slack
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebhook(t *testing.T) {
+	s, err := NewWebhook("https://hooks.slack.com/services/T000/B000/XXXX")
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+
+	_, err = NewWebhook("")
+	assert.Error(t, err)
+}
+
+func TestWebhookPostMessage(t *testing.T) {
+	var payloads []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var payload map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	s, err := NewWebhook(server.URL)
+	require.NoError(t, err)
+
+	ts, err := s.PostMessage("security", slack.MsgOptionBlocks(
+		slack.NewHeaderBlock(slack.NewTextBlockObject("plain_text", "Security Scan Report", false, false)),
+		slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "CRITICAL: *1*", false, false), nil, nil),
+	), slack.MsgOptionTS("1234.5678"))
+
+	assert.NoError(t, err)
+	assert.Empty(t, ts)
+	require.Len(t, payloads, 1)
+	assert.NotContains(t, payloads[0], "thread_ts")
+	blocks, ok := payloads[0]["blocks"].([]any)
+	require.True(t, ok, payloads[0])
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "header", blocks[0].(map[string]any)["type"])
+	assert.Equal(t, "Security Scan Report", blocks[0].(map[string]any)["text"].(map[string]any)["text"])
+	assert.Equal(t, "section", blocks[1].(map[string]any)["type"])
+	assert.Equal(t, "CRITICAL: *1*", blocks[1].(map[string]any)["text"].(map[string]any)["text"])
+}
+
+func TestWebhookPostMessageFailure(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "no_service", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	s := &webhookService{url: server.URL, httpClient: server.Client(), maxAttempts: 2}
+
+	_, err := s.PostMessage("security", slack.MsgOptionText("hello", false))
+
+	assert.ErrorContains(t, err, "failed to post slack message to webhook")
+	assert.Equal(t, 2, requests)
+}
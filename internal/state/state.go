@@ -0,0 +1,124 @@
+// Package state persists the outcome of a patrol so that the next run can compare against it.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sheriff/internal/scanner"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Vulnerability is the minimal representation of a vulnerability kept between runs.
+type Vulnerability struct {
+	Id                string                    `json:"id"`
+	PackageName       string                    `json:"package_name"`
+	PackageVersion    string                    `json:"package_version"`
+	SeverityScoreKind scanner.SeverityScoreKind `json:"severity_score_kind"`
+	FixedVersion      string                    `json:"fixed_version,omitempty"`
+}
+
+// ProjectState is the state of a single project as of the last run.
+type ProjectState struct {
+	CommitSha       string          `json:"commit_sha,omitempty"` // Sha of the scanned commit, empty if it is not known
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	CleanRuns       int             `json:"clean_runs,omitempty"` // Number of consecutive runs in which the project was not vulnerable
+}
+
+// State is the state of all projects scanned in the last run, keyed by project path.
+type State struct {
+	Projects map[string]ProjectState `json:"projects"`
+}
+
+// Load reads the state from the given file.
+// A missing file is not an error, it is simply an empty state (e.g. on the first run).
+func Load(path string) (s State, err error) {
+	s = State{Projects: map[string]ProjectState{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Info().Str("path", path).Msg("No state file found, starting with empty state")
+		return s, nil
+	} else if err != nil {
+		return s, errors.Join(errors.New("failed to read state file"), err)
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, errors.Join(errors.New("failed to parse state file"), err)
+	}
+
+	if s.Projects == nil {
+		s.Projects = map[string]ProjectState{}
+	}
+
+	return
+}
+
+// Save writes the state to the given file, creating its parent directory if needed.
+func Save(path string, s State) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Join(errors.New("failed to serialize state"), err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Join(errors.New("failed to create state directory"), err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errors.Join(errors.New("failed to write state file"), err)
+	}
+
+	return nil
+}
+
+// FromReports builds the new state from the given reports.
+// Projects whose scan failed keep their previous state, so that a failed scan
+// is not mistaken for all of its vulnerabilities being resolved.
+func FromReports(reports []scanner.Report, previous State) State {
+	s := State{Projects: make(map[string]ProjectState, len(reports))}
+	for _, r := range reports {
+		if r.Error {
+			if p, ok := previous.Projects[r.Project.Path]; ok {
+				s.Projects[r.Project.Path] = p
+			}
+			continue
+		}
+
+		vs := make([]Vulnerability, 0, len(r.Vulnerabilities))
+		for _, v := range r.Vulnerabilities {
+			vs = append(vs, Vulnerability{
+				Id:                v.Id,
+				PackageName:       v.PackageName,
+				PackageVersion:    v.PackageVersion,
+				SeverityScoreKind: v.SeverityScoreKind,
+				FixedVersion:      v.FixedVersion,
+			})
+		}
+		s.Projects[r.Project.Path] = ProjectState{CommitSha: r.CommitSha, Vulnerabilities: vs, CleanRuns: CountCleanRuns(r, previous)}
+	}
+
+	return s
+}
+
+// CountCleanRuns returns the number of consecutive runs in which the project of the report was not vulnerable,
+// up to and including the run of the report. It is 0 if the project is vulnerable.
+func CountCleanRuns(r scanner.Report, previous State) int {
+	if r.IsVulnerable {
+		return 0
+	}
+
+	return previous.Projects[r.Project.Path].CleanRuns + 1
+}
+
+// KeepProjects copies the previous state of the projects with the given paths, which were not scanned in this run,
+// so that they are still known in the next run.
+func (s State) KeepProjects(previous State, paths []string) {
+	for _, path := range paths {
+		if p, ok := previous.Projects[path]; ok {
+			s.Projects[path] = p
+		}
+	}
+}
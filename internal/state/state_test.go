@@ -0,0 +1,98 @@
+package state
+
+import (
+	"path/filepath"
+	"sheriff/internal/repository"
+	"sheriff/internal/scanner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadInexistentFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "inexistent.json"))
+
+	// It is allowed to run sheriff without a previous state, e.g. on the first run
+	assert.Nil(t, err)
+	assert.Empty(t, s.Projects)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	want := State{Projects: map[string]ProjectState{
+		"group/project": {Vulnerabilities: []Vulnerability{{Id: "CVE-1", PackageName: "pkg", PackageVersion: "1.0.0", SeverityScoreKind: scanner.High}}},
+	}}
+
+	err := Save(path, want)
+	assert.Nil(t, err)
+
+	got, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestFromReportsKeepsPreviousStateOfErroredReports(t *testing.T) {
+	previous := State{Projects: map[string]ProjectState{
+		"group/errored": {Vulnerabilities: []Vulnerability{{Id: "CVE-1"}}},
+		"group/scanned": {Vulnerabilities: []Vulnerability{{Id: "CVE-2"}}},
+	}}
+	reports := []scanner.Report{
+		{Project: repository.Project{Path: "group/errored"}, Error: true},
+		{Project: repository.Project{Path: "group/scanned"}, Vulnerabilities: []scanner.Vulnerability{{Id: "CVE-3", SeverityScoreKind: scanner.Low}}},
+	}
+
+	got := FromReports(reports, previous)
+
+	assert.Equal(t, previous.Projects["group/errored"], got.Projects["group/errored"])
+	assert.Equal(t, []Vulnerability{{Id: "CVE-3", SeverityScoreKind: scanner.Low}}, got.Projects["group/scanned"].Vulnerabilities)
+}
+
+func TestFromReportsKeepsCommitSha(t *testing.T) {
+	reports := []scanner.Report{{Project: repository.Project{Path: "group/project"}, CommitSha: "abc123"}}
+
+	got := FromReports(reports, State{})
+
+	assert.Equal(t, "abc123", got.Projects["group/project"].CommitSha)
+}
+
+func TestCountCleanRuns(t *testing.T) {
+	previous := State{Projects: map[string]ProjectState{
+		"group/clean":      {CleanRuns: 2},
+		"group/vulnerable": {},
+	}}
+
+	assert.Equal(t, 3, CountCleanRuns(scanner.Report{Project: repository.Project{Path: "group/clean"}}, previous))
+	assert.Equal(t, 0, CountCleanRuns(scanner.Report{Project: repository.Project{Path: "group/clean"}, IsVulnerable: true}, previous))
+	assert.Equal(t, 1, CountCleanRuns(scanner.Report{Project: repository.Project{Path: "group/vulnerable"}}, previous))
+	assert.Equal(t, 1, CountCleanRuns(scanner.Report{Project: repository.Project{Path: "group/new"}}, previous))
+}
+
+func TestFromReportsCountsCleanRuns(t *testing.T) {
+	previous := State{Projects: map[string]ProjectState{"group/clean": {CleanRuns: 1}, "group/errored": {CleanRuns: 1}}}
+	reports := []scanner.Report{
+		{Project: repository.Project{Path: "group/clean"}},
+		{Project: repository.Project{Path: "group/errored"}, Error: true},
+		{Project: repository.Project{Path: "group/vulnerable"}, IsVulnerable: true},
+	}
+
+	got := FromReports(reports, previous)
+
+	assert.Equal(t, 2, got.Projects["group/clean"].CleanRuns)
+	assert.Equal(t, 1, got.Projects["group/errored"].CleanRuns)
+	assert.Equal(t, 0, got.Projects["group/vulnerable"].CleanRuns)
+}
+
+func TestKeepProjects(t *testing.T) {
+	previous := State{Projects: map[string]ProjectState{
+		"group/skipped": {CommitSha: "abc123"},
+		"group/removed": {CommitSha: "def456"},
+	}}
+	s := State{Projects: map[string]ProjectState{"group/scanned": {CommitSha: "ghi789"}}}
+
+	s.KeepProjects(previous, []string{"group/skipped", "group/unknown"})
+
+	assert.Equal(t, map[string]ProjectState{
+		"group/scanned": {CommitSha: "ghi789"},
+		"group/skipped": {CommitSha: "abc123"},
+	}, s.Projects)
+}
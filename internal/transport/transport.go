@@ -0,0 +1,77 @@
+// Package transport configures the HTTP transport shared by all the outbound requests of sheriff,
+// so that they can be routed through a corporate proxy which intercepts TLS connections.
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+type Options struct {
+	Proxy  string // URL of the proxy of all requests, overriding the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	CACert string // Path to a PEM bundle of certificates trusted on top of those of the system
+}
+
+// New returns a clone of http.DefaultTransport configured with the given options.
+// Without a proxy, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func New(opts Options) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+
+	if opts.Proxy != "" {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %v, must be an absolute url (e.g. http://proxy.example.com:3128)", opts.Proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	if opts.CACert != "" {
+		pool, err := loadCertPool(opts.CACert)
+		if err != nil {
+			return nil, err
+		}
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+
+	return t, nil
+}
+
+// SetDefault replaces http.DefaultTransport with a transport configured with the given options.
+// All the HTTP clients of sheriff send their requests through http.DefaultTransport, including those of
+// the GitLab, GitHub and Slack libraries, so it must be called before any of them is created.
+func SetDefault(opts Options) error {
+	t, err := New(opts)
+	if err != nil {
+		return err
+	}
+	http.DefaultTransport = t
+
+	return nil
+}
+
+// loadCertPool returns the certificates of the system together with those of the given PEM bundle
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to read CA certificates %v", path), err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM encoded certificate found in %v", path)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUsesProxy(t *testing.T) {
+	tr, err := New(Options{Proxy: "http://proxy.example.com:3128"})
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://gitlab.com/api/v4/projects", nil)
+	proxy, err := tr.Proxy(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", proxy.String())
+}
+
+func TestNewInvalidProxy(t *testing.T) {
+	_, err := New(Options{Proxy: "proxy.example.com:3128"})
+
+	assert.ErrorContains(t, err, "invalid proxy url proxy.example.com:3128")
+}
+
+func TestNewTrustsCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644))
+
+	// Without the CA certificate, the certificate of the server is not trusted
+	_, err := (&http.Client{Transport: http.DefaultTransport}).Get(server.URL)
+	require.Error(t, err)
+
+	tr, err := New(Options{CACert: path})
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewInvalidCACert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, []byte("not a certificate"), 0644))
+
+	_, err := New(Options{CACert: path})
+	assert.ErrorContains(t, err, "no PEM encoded certificate found")
+
+	_, err = New(Options{CACert: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "failed to read CA certificates")
+}